@@ -1,4 +1,7 @@
-package backup
+// Package compress defines the compression types,
+// used to compress the data stored by 0-Disk,
+// such as backups and tlog aggregations.
+package compress
 
 import (
 	"io"
@@ -52,7 +55,8 @@ func (ct *CompressionType) Type() string {
 	return "CompressionType"
 }
 
-func (ct CompressionType) validate() error {
+// Validate returns an error in case the compression type is unknown.
+func (ct CompressionType) Validate() error {
 	switch ct {
 	case LZ4Compression, XZCompression:
 		return nil
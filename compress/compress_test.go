@@ -1,4 +1,4 @@
-package backup
+package compress
 
 import (
 	"bytes"
@@ -196,7 +196,10 @@ func generateCompressBenchData(b *testing.B, size int64) []byte {
 	defer b.StartTimer()
 	b.SetBytes(size)
 
-	ibm := getLedeImageBlocks()
+	ibm, err := testdata.ReadAllLedeBlocks()
+	if err != nil {
+		b.Fatal(err)
+	}
 	benchData := make([]byte, size)
 
 	offset := int64(0)
@@ -13,8 +13,21 @@ func ValidateNBDServerConfigs(source Source, serverID string) error {
 		return err
 	}
 
+	var vdiskStaticConfig *VdiskStaticConfig
+
 	errs := errors.NewErrorSlice()
 	for _, vdiskID := range cfg.Vdisks {
+		vdiskStaticConfig, err = ReadVdiskStaticConfig(source, vdiskID)
+		if err != nil {
+			errs.Add(err)
+			continue
+		}
+		if !vdiskStaticConfig.Type.Persistent() {
+			log.Debugf(
+				"not validating nbd storage for vdisk %s as it isn't persistent",
+				vdiskID)
+			continue // no storage cluster(s) required
+		}
 		_, err = ReadNBDStorageConfig(source, vdiskID)
 		if err != nil {
 			errs.Add(err)
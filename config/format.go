@@ -2,6 +2,7 @@ package config
 
 import (
 	"fmt"
+	"time"
 
 	valid "github.com/asaskevich/govalidator"
 	"github.com/zero-os/0-Disk/errors"
@@ -176,8 +177,24 @@ func NewVdiskTlogConfig(data []byte) (*VdiskTlogConfig, error) {
 }
 
 // VdiskTlogConfig represents the tlogserver-related information for a vdisk.
+// Optionally a retention policy can be defined,
+// limiting the tlog aggregations kept for a vdisk by age and/or count.
+// Optionally an authentication token can be defined,
+// which has to be given by a tlog client in order to connect for this vdisk.
+// Optionally a compression type can be defined,
+// used to compress the tlog aggregations of the vdisk prior to storing them.
 type VdiskTlogConfig struct {
 	ZeroStorClusterID string `yaml:"zeroStorClusterID" valid:"required"`
+	// maximum age of a tlog aggregation, 0 means no age limit
+	MaxAge time.Duration `yaml:"maxAge,omitempty" valid:"optional"`
+	// maximum amount of tlog aggregations, 0 means no count limit
+	MaxAggregations int64 `yaml:"maxAggregations,omitempty" valid:"optional"`
+	// token required to connect to a tlog server for this vdisk,
+	// no authentication is required when empty
+	AuthToken string `yaml:"authToken,omitempty" valid:"optional"`
+	// compression type (lz4 or xz) of the stored tlog aggregations,
+	// aggregations are stored uncompressed when empty
+	Compression string `yaml:"compression,omitempty" valid:"optional"`
 }
 
 // Validate implements FormatValidator.Validate.
@@ -192,9 +209,30 @@ func (cfg *VdiskTlogConfig) Validate() error {
 			errors.Wrap(err, "invalid VdiskTlogConfig"))
 	}
 
+	if cfg.MaxAge < 0 {
+		return errors.WrapError(ErrInvalidConfig,
+			errors.Newf("invalid VdiskTlogConfig: negative maxAge %v", cfg.MaxAge))
+	}
+	if cfg.MaxAggregations < 0 {
+		return errors.WrapError(ErrInvalidConfig,
+			errors.Newf("invalid VdiskTlogConfig: negative maxAggregations %d", cfg.MaxAggregations))
+	}
+	switch cfg.Compression {
+	case "", "lz4", "xz":
+	default:
+		return errors.WrapError(ErrInvalidConfig,
+			errors.Newf("invalid VdiskTlogConfig: unknown compression %q", cfg.Compression))
+	}
+
 	return nil
 }
 
+// HasRetention returns true in case a retention policy is defined,
+// meaning that old tlog aggregations of the vdisk can be deleted.
+func (cfg *VdiskTlogConfig) HasRetention() bool {
+	return cfg.MaxAge > 0 || cfg.MaxAggregations > 0
+}
+
 // NewStorageClusterConfig creates a new StorageClusterConfig from a given YAML slice.
 func NewStorageClusterConfig(data []byte) (*StorageClusterConfig, error) {
 	clustercfg := new(StorageClusterConfig)
@@ -137,6 +137,27 @@ zeroStorClusterID: foo
 	`
 slaveStorageClusterID: foo
 zeroStorClusterID: bar
+`, // retention policy examples
+	`
+zeroStorClusterID: foo
+maxAge: 720h
+`,
+	`
+zeroStorClusterID: foo
+maxAggregations: 1000
+`,
+	`
+zeroStorClusterID: foo
+maxAge: 24h30m
+maxAggregations: 1000
+`, // compression examples
+	`
+zeroStorClusterID: foo
+compression: lz4
+`,
+	`
+zeroStorClusterID: foo
+compression: xz
 `,
 }
 
@@ -145,6 +166,26 @@ var invalidVdiskTlogConfigYAML = []string{
 	// ZeroStorClusterID not given
 	`
 slaveStorageClusterID: bar
+`,
+	// negative maxAge
+	`
+zeroStorClusterID: foo
+maxAge: -1h
+`,
+	// negative maxAggregations
+	`
+zeroStorClusterID: foo
+maxAggregations: -1
+`,
+	// invalid maxAge
+	`
+zeroStorClusterID: foo
+maxAge: forever
+`,
+	// unknown compression
+	`
+zeroStorClusterID: foo
+compression: zip
 `,
 }
 
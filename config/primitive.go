@@ -27,19 +27,22 @@ func (vdiskType VdiskType) TemplateSupport() bool {
 	return vdiskType&propTemplateSupport != 0
 }
 
+// Persistent returns whether or not
+// the content of this vdisk is stored in external storage servers.
+// The content of a non-persistent vdisk is only available
+// during the session of creation, and is stored locally instead.
+func (vdiskType VdiskType) Persistent() bool {
+	return vdiskType&propPersistent != 0
+}
+
 // StorageType returns the type of storage this vdisk uses
 func (vdiskType VdiskType) StorageType() StorageType {
 	if vdiskType&propDeduped != 0 {
 		return StorageDeduped
 	}
-
-	// TODO: Handle propPersistent flag
-	// ignore the propPersistent flag for now,
-	// and treat non-persistent and persistent memory,
-	// both as persistent nondeduped storage.
-	// see open issue for more information:
-	// https://github.com/zero-os/0-Disk/issues/222
-
+	if vdiskType&propPersistent == 0 {
+		return StorageInMemory
+	}
 	return StorageNonDeduped
 }
 
@@ -154,6 +157,9 @@ const (
 	StorageNonDeduped
 	// StorageSemiDeduped is not used for now
 	StorageSemiDeduped
+	// StorageInMemory is used by non-persistent vdisks,
+	// and stores all content locally, for the duration of a single session.
+	StorageInMemory
 )
 
 // UInt8 returns the storage type as an uint8 value
@@ -170,6 +176,8 @@ func (st StorageType) String() string {
 		return "nondeduped"
 	case StorageSemiDeduped:
 		return "semideduped"
+	case StorageInMemory:
+		return "inmemory"
 	default:
 		return "unknown"
 	}
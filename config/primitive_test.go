@@ -79,7 +79,13 @@ func TestVdiskProperties(t *testing.T) {
 	assert.Equal(StorageDeduped, VdiskTypeBoot.StorageType())
 	assert.Equal(StorageNonDeduped, VdiskTypeDB.StorageType())
 	assert.Equal(StorageNonDeduped, VdiskTypeCache.StorageType())
-	assert.Equal(StorageNonDeduped, VdiskTypeTmp.StorageType())
+	assert.Equal(StorageInMemory, VdiskTypeTmp.StorageType())
+
+	// validate persistent property
+	assert.True(VdiskTypeBoot.Persistent())
+	assert.True(VdiskTypeDB.Persistent())
+	assert.True(VdiskTypeCache.Persistent())
+	assert.False(VdiskTypeTmp.Persistent())
 
 	// validate tlog support
 	assert.True(VdiskTypeBoot.TlogSupport())
@@ -174,6 +174,25 @@ func (s *StubSource) SetTlogZeroStorCluster(vdiskID, clusterID string, cfg *Zero
 	s.cfg.Vdisks[vdiskID] = vdiskCfg
 }
 
+// SetTlogAuthToken is a utility function to set the tlog authentication token of a vdisk, thread-safe.
+func (s *StubSource) SetTlogAuthToken(vdiskID, token string) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	defer s.triggerReload()
+
+	vdiskCfg := s.getVdiskCfg(vdiskID)
+
+	if vdiskCfg.Tlog == nil {
+		vdiskCfg.Tlog = &VdiskTlogConfig{
+			AuthToken: token,
+		}
+	} else {
+		vdiskCfg.Tlog.AuthToken = token
+	}
+
+	s.cfg.Vdisks[vdiskID] = vdiskCfg
+}
+
 // SetSlaveStorageCluster is a utility function to set a tlog storage cluster config, thread-safe.
 func (s *StubSource) SetSlaveStorageCluster(vdiskID, clusterID string, cfg *StorageClusterConfig) {
 	s.mux.Lock()
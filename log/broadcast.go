@@ -81,6 +81,8 @@ type MessageStatus uint
 
 // status codes
 const (
+	StatusServerRepaired   MessageStatus = 201
+	StatusServerRespread   MessageStatus = 202
 	StatusUnknownError     MessageStatus = 400
 	StatusClusterTimeout   MessageStatus = 401
 	StatusInvalidConfig    MessageStatus = 403
@@ -0,0 +1,37 @@
+// Package metrics exposes the 0-Disk metrics in the Prometheus exposition format.
+//
+// The metrics themselves are defined and registered
+// by the packages which collect them, using the Namespace defined here,
+// such that a server only exposes the metrics of the packages it uses.
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	// Namespace is the namespace used by all 0-Disk metrics.
+	Namespace = "zerodisk"
+
+	// Path is the HTTP path on which the metrics are served.
+	Path = "/metrics"
+)
+
+// LatencyBuckets are the (histogram) buckets used by all latency metrics,
+// ranging from 0.5ms up to about 4s.
+var LatencyBuckets = prometheus.ExponentialBuckets(0.0005, 2, 14)
+
+// Handler returns an HTTP handler which serves all registered metrics.
+func Handler() http.Handler {
+	return prometheus.UninstrumentedHandler()
+}
+
+// ListenAndServe serves all registered metrics on the Path of the given address.
+// It only returns in case the server couldn't be started or stopped unexpectedly.
+func ListenAndServe(address string) error {
+	mux := http.NewServeMux()
+	mux.Handle(Path, Handler())
+	return http.ListenAndServe(address, mux)
+}
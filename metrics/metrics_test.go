@@ -0,0 +1,37 @@
+package metrics
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandler(t *testing.T) {
+	counter := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Subsystem: "test",
+		Name:      "operations_total",
+		Help:      "Amount of test operations.",
+	}, []string{"vdisk"})
+	require.NoError(t, prometheus.Register(counter))
+	defer prometheus.Unregister(counter)
+
+	counter.WithLabelValues("a").Add(3)
+
+	server := httptest.NewServer(Handler())
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + Path)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	body, err := ioutil.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.Contains(t, string(body), `zerodisk_test_operations_total{vdisk="a"} 3`)
+}
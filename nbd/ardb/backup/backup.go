@@ -4,6 +4,7 @@ import (
 	"io"
 	"runtime"
 
+	"github.com/zero-os/0-Disk/compress"
 	"github.com/zero-os/0-Disk/config"
 	"github.com/zero-os/0-Disk/errors"
 )
@@ -33,6 +34,7 @@ type Config struct {
 	//  - When given:
 	//    - If type equals LocalStorageDriverConfig -> Create LocalStorageDriver;
 	//    - If type equals FTPStorageDriverConfig -> Create FTPStorageDriver;
+	//    - If type equals S3StorageDriverConfig -> Create S3StorageDriver;
 	//    - Else -> error
 	BackupStoragDriverConfig interface{}
 
@@ -43,7 +45,7 @@ type Config struct {
 
 	// Type of Compression to use for compressing/decompressing.
 	// Note: this should be the same value for an import/export pair
-	CompressionType CompressionType
+	CompressionType compress.CompressionType
 	// CryptoKey to use for encryption/decryption.
 	// Note: this should be the same value for an import/export pair
 	CryptoKey CryptoKey
@@ -55,6 +57,16 @@ type Config struct {
 	// or the data was encrypted/compressed using a different
 	// key/compressionType than the one given.
 	Force bool
+
+	// Optional: Only used for exporting at the moment.
+	// When true, only the blocks modified since the vdisk was last (incrementally) exported are exported,
+	// on top of the deduped map of the snapshot created by that export.
+	// A full export is done instead, in case that snapshot can't be loaded
+	// from the backup storage, was exported using a different block size,
+	// or was overwritten by another export since.
+	// Only incremental exports track the modified blocks,
+	// such that a non-incremental export can't break the next incremental export.
+	Incremental bool
 }
 
 // validate the export/import config,
@@ -80,7 +92,7 @@ func (cfg *Config) validate() error {
 		cfg.JobCount = runtime.NumCPU()
 	}
 
-	err := cfg.CompressionType.validate()
+	err := cfg.CompressionType.Validate()
 	if err != nil {
 		return err
 	}
@@ -88,8 +100,14 @@ func (cfg *Config) validate() error {
 	return nil
 }
 
-// create a storage driver based on the given backup storage driver config.
-func newStorageDriver(storagDriverConfig interface{}) (StorageDriver, error) {
+// NewStorageDriver creates a storage driver based on the given backup storage driver config.
+//  - When not given (nil), defaults to LocalStorageDriver, using the DefaultLocalRoot as the path.
+//  - When given:
+//    - If type equals LocalStorageDriverConfig -> Create LocalStorageDriver;
+//    - If type equals FTPStorageDriverConfig -> Create FTPStorageDriver;
+//    - If type equals S3StorageDriverConfig -> Create S3StorageDriver;
+//    - Else -> error
+func NewStorageDriver(storagDriverConfig interface{}) (StorageDriver, error) {
 	if storagDriverConfig == nil {
 		// default to DefaultLocalRoot, of nothing is given by the user.
 		return LocalStorageDriver(LocalStorageDriverConfig{
@@ -105,6 +123,9 @@ func newStorageDriver(storagDriverConfig interface{}) (StorageDriver, error) {
 	case LocalStorageDriverConfig:
 		return LocalStorageDriver(sdCfg)
 
+	case S3StorageDriverConfig:
+		return S3StorageDriver(sdCfg)
+
 	default:
 		return nil, errors.Newf(
 			"%[1]v (%[1]T) is not a valid BackupStoragDriverConfig",
@@ -366,5 +387,6 @@ func isNilBlock(block []byte) bool {
 
 var (
 	errNilVdiskID    = errors.New("vdisk's identifier not given")
+	errNilSnapshotID = errors.New("snapshot's identifier not given")
 	errStreamBlocked = errors.New("stream block fetcher is blocked waiting for next expected block")
 )
@@ -3,6 +3,7 @@ package backup
 import (
 	"sync"
 
+	"github.com/zero-os/0-Disk/compress"
 	"github.com/zero-os/0-Disk/testdata"
 )
 
@@ -13,7 +14,7 @@ var validConfigs = []Config{
 		SnapshotID:      "foo",
 		BlockSize:       DefaultBlockSize,
 		JobCount:        0,
-		CompressionType: LZ4Compression,
+		CompressionType: compress.LZ4Compression,
 		CryptoKey:       CryptoKey{4, 2},
 	},
 	// implicit version of first example
@@ -27,7 +28,7 @@ var validConfigs = []Config{
 		SnapshotID:      "bar",
 		BlockSize:       4096,
 		JobCount:        1,
-		CompressionType: XZCompression,
+		CompressionType: compress.XZCompression,
 		CryptoKey: CryptoKey{
 			0, 1, 2, 3, 4, 5, 6, 7, 8, 9,
 			0, 1, 2, 3, 4, 5, 6, 7, 8, 9,
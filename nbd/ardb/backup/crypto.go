@@ -8,6 +8,7 @@ import (
 	"io/ioutil"
 
 	"github.com/zero-os/0-Disk"
+	"github.com/zero-os/0-Disk/compress"
 	"github.com/zero-os/0-Disk/errors"
 )
 
@@ -204,7 +205,7 @@ func validateCryptoKey(key []byte) error {
 	return ErrInvalidCryptoKeyAllZeroes
 }
 
-func newKeyedHasher(ct CompressionType, ck CryptoKey) (zerodisk.Hasher, error) {
+func newKeyedHasher(ct compress.CompressionType, ck CryptoKey) (zerodisk.Hasher, error) {
 	var key []byte
 	if ck.Defined() {
 		key = append(ck[:], byte(ct))
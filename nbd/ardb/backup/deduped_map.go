@@ -61,6 +61,14 @@ func (dm *dedupedMap) SetHash(index int64, hash zerodisk.Hash) bool {
 	return true
 }
 
+// DeleteHash deletes the hash mapped to the given (export block) index, if any.
+func (dm *dedupedMap) DeleteHash(index int64) {
+	dm.mux.Lock()
+	defer dm.mux.Unlock()
+
+	delete(dm.hashes, index)
+}
+
 // GetHash returns the hash which is mapped to the given (export block) index.
 // `false` is returned in case no hash is mapped to the given (export block) index.
 func (dm *dedupedMap) GetHash(index int64) (zerodisk.Hash, bool) {
@@ -3,11 +3,14 @@ package backup
 import (
 	"bytes"
 	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"io"
 	"sync"
 	"time"
 
 	"github.com/zero-os/0-Disk"
+	"github.com/zero-os/0-Disk/compress"
 	"github.com/zero-os/0-Disk/config"
 	"github.com/zero-os/0-Disk/errors"
 	"github.com/zero-os/0-Disk/log"
@@ -24,14 +27,6 @@ func Export(ctx context.Context, cfg Config) error {
 		return err
 	}
 
-	log.Debugf("collecting all stored block indices for vdisk %s, this might take a while...", cfg.VdiskID)
-	indices, err := storage.ListBlockIndices(cfg.VdiskID, cfg.ConfigSource)
-	if err != nil {
-		return errors.Wrapf(err,
-			"couldn't list block (storage) indices (does vdisk '%s' exist?)",
-			cfg.VdiskID)
-	}
-
 	pool := ardb.NewPool(nil)
 	defer pool.Close()
 
@@ -44,18 +39,111 @@ func Export(ctx context.Context, cfg Config) error {
 	}
 	defer blockStorage.Close()
 
-	storageDriver, err := newStorageDriver(cfg.BackupStoragDriverConfig)
+	storageDriver, exportConfig, err := newExportSession(cfg)
 	if err != nil {
 		return err
 	}
 	defer storageDriver.Close()
 
-	staticConfig, err := config.ReadVdiskStaticConfig(cfg.ConfigSource, cfg.VdiskID)
+	var indices []int64
+	var incremental bool
+	var cluster *storage.Cluster
+	if cfg.Incremental {
+		// the primary cluster never repairs its servers,
+		// as that is the responsibility of the nbdserver serving the vdisk
+		cluster, err = storage.NewPrimaryCluster(ctx, cfg.VdiskID, cfg.ConfigSource)
+		if err != nil {
+			return err
+		}
+		defer cluster.Close()
+
+		// load the blocks modified since the last (incremental) export,
+		// prior to reading any block, such that no modification is missed
+		dirty, err := storage.LoadDirtyBlocks(cfg.VdiskID, cluster)
+		if err != nil {
+			return errors.Wrapf(err,
+				"couldn't load dirty blocks of vdisk %s", cfg.VdiskID)
+		}
+
+		exportConfig.ExportID, err = newExportID()
+		if err != nil {
+			return err
+		}
+		indices, incremental, err = prepareIncrementalExport(
+			blockStorage, dirty, storageDriver, &exportConfig)
+		if err != nil {
+			return err
+		}
+	}
+	if !incremental {
+		log.Debugf("collecting all stored block indices for vdisk %s, this might take a while...", cfg.VdiskID)
+		indices, err = storage.ListBlockIndices(cfg.VdiskID, cfg.ConfigSource)
+		if err != nil {
+			return errors.Wrapf(err,
+				"couldn't list block (storage) indices (does vdisk '%s' exist?)",
+				cfg.VdiskID)
+		}
+	}
+
+	err = exportBS(ctx, blockStorage, indices, storageDriver, exportConfig)
+	if err != nil {
+		return err
+	}
+	if cluster == nil {
+		return nil // the modified blocks are only tracked by incremental exports
+	}
+
+	// the next (incremental) export can be done incrementally,
+	// on top of the snapshot we just exported
+	return storage.ResetDirtyBlocks(
+		cfg.VdiskID, cfg.SnapshotID, exportConfig.ExportID, cluster)
+}
+
+// newExportID creates a new random export identifier,
+// stored in the header of the snapshot created by an incremental export.
+func newExportID() (string, error) {
+	id := make([]byte, 16)
+	_, err := io.ReadFull(rand.Reader, id)
+	if err != nil {
+		return "", errors.Wrap(err, "couldn't create export identifier")
+	}
+	return hex.EncodeToString(id), nil
+}
+
+// ExportBlockStorage exports the given blocks of a block storage to an FTP Server,
+// in the same way as Export exports a vdisk,
+// such that the resulting backup (snapshot) can be imported as that vdisk.
+// The block storage has to be configured
+// using the (static) config of the vdisk identified by `cfg.VdiskID`.
+func ExportBlockStorage(ctx context.Context, src storage.BlockStorage, blockIndices []int64, cfg Config) error {
+	err := cfg.validate()
 	if err != nil {
 		return err
 	}
 
-	exportConfig := exportConfig{
+	storageDriver, exportConfig, err := newExportSession(cfg)
+	if err != nil {
+		return err
+	}
+	defer storageDriver.Close()
+
+	return exportBS(ctx, src, blockIndices, storageDriver, exportConfig)
+}
+
+// newExportSession creates the storage driver and export config,
+// used to export a block storage using an already validated config.
+func newExportSession(cfg Config) (StorageDriver, exportConfig, error) {
+	staticConfig, err := config.ReadVdiskStaticConfig(cfg.ConfigSource, cfg.VdiskID)
+	if err != nil {
+		return nil, exportConfig{}, err
+	}
+
+	storageDriver, err := NewStorageDriver(cfg.BackupStoragDriverConfig)
+	if err != nil {
+		return nil, exportConfig{}, err
+	}
+
+	return storageDriver, exportConfig{
 		JobCount:        cfg.JobCount,
 		SrcBlockSize:    int64(staticConfig.BlockSize),
 		DstBlockSize:    cfg.BlockSize,
@@ -65,9 +153,91 @@ func Export(ctx context.Context, cfg Config) error {
 		VdiskID:         cfg.VdiskID,
 		SnapshotID:      cfg.SnapshotID,
 		Force:           cfg.Force,
+	}, nil
+}
+
+// prepareIncrementalExport prepares the given export config,
+// such that the blocks modified since the last export of a vdisk,
+// are exported on top of the snapshot created by that export.
+// It returns the (source) indices of all blocks which have to be exported,
+// or false in case that snapshot can't be used as the base of the export.
+func prepareIncrementalExport(src storage.BlockStorage, dirty *storage.DirtyBlocks, dst StorageDriver, cfg *exportConfig) ([]int64, bool, error) {
+	if dirty.SnapshotID == "" {
+		log.Infof(
+			"exporting vdisk %s fully, as no modifications were tracked since its last export",
+			cfg.VdiskID)
+		return nil, false, nil
+	}
+
+	header, err := LoadHeader(dirty.SnapshotID, dst, &cfg.CryptoKey, cfg.CompressionType)
+	if err != nil {
+		log.Infof(
+			"exporting vdisk %s fully, as its last snapshot %s couldn't be loaded: %v",
+			cfg.VdiskID, dirty.SnapshotID, err)
+		return nil, false, nil
+	}
+	if header.Metadata.ExportID == "" || header.Metadata.ExportID != dirty.ExportID {
+		// the snapshot was overwritten since, or was exported to another backup storage
+		log.Infof(
+			"exporting vdisk %s fully, as its last snapshot %s wasn't (last) stored by its last export",
+			cfg.VdiskID, dirty.SnapshotID)
+		return nil, false, nil
+	}
+	if header.Metadata.Source.VdiskID != cfg.VdiskID ||
+		header.Metadata.Source.BlockSize != cfg.SrcBlockSize ||
+		header.Metadata.BlockSize != cfg.DstBlockSize {
+		log.Infof(
+			"exporting vdisk %s fully, as its last snapshot %s is incompatible with this export",
+			cfg.VdiskID, dirty.SnapshotID)
+		return nil, false, nil
 	}
 
-	return exportBS(ctx, blockStorage, indices, storageDriver, exportConfig)
+	// collect the (source) blocks to export,
+	// and the (export) blocks to which they belong
+	var srcIndices, dstIndices []int64
+	if cfg.SrcBlockSize < cfg.DstBlockSize {
+		// an export block is composed out of multiple source blocks,
+		// all of which have to be exported again if one of them is modified
+		ratio := cfg.DstBlockSize / cfg.SrcBlockSize
+		for _, index := range dirty.Indices {
+			dstIndex := index / ratio
+			if n := len(dstIndices); n > 0 && dstIndices[n-1] == dstIndex {
+				continue
+			}
+			dstIndices = append(dstIndices, dstIndex)
+			for srcIndex := dstIndex * ratio; srcIndex < (dstIndex+1)*ratio; srcIndex++ {
+				srcIndices = append(srcIndices, srcIndex)
+			}
+		}
+	} else {
+		ratio := cfg.SrcBlockSize / cfg.DstBlockSize
+		for _, index := range dirty.Indices {
+			srcIndices = append(srcIndices, index)
+			for dstIndex := index * ratio; dstIndex < (index+1)*ratio; dstIndex++ {
+				dstIndices = append(dstIndices, dstIndex)
+			}
+		}
+	}
+
+	// only allocated blocks are exported,
+	// the others were deleted since the last export
+	var indices []int64
+	for _, index := range srcIndices {
+		allocated, err := storage.IsBlockAllocated(src, index)
+		if err != nil {
+			return nil, false, err
+		}
+		if allocated {
+			indices = append(indices, index)
+		}
+	}
+
+	log.Infof(
+		"exporting %d modified blocks of vdisk %s on top of its last snapshot %s",
+		len(indices), cfg.VdiskID, dirty.SnapshotID)
+	cfg.Base = header
+	cfg.DirtyIndices = dstIndices
+	return indices, true, nil
 }
 
 // existingOrNewHeader tries to first fetch an existing (snapshot) header from a given server,
@@ -76,7 +246,7 @@ func Export(ctx context.Context, cfg Config) error {
 // When `cfg.Force` is `true`, a new header will be created, even if one existed already but couldn't be loaded.
 // When `cfg.Force` is `false`, and a header exists but can't be a loaded,
 // the error of why it couldn't be loaded, is returned instead.
-func existingOrNewHeader(cfg exportConfig, src StorageDriver, key *CryptoKey, ct CompressionType) (*Header, error) {
+func existingOrNewHeader(cfg exportConfig, src StorageDriver, key *CryptoKey, ct compress.CompressionType) (*Header, error) {
 	header, err := LoadHeader(cfg.SnapshotID, src, key, ct)
 
 	if errors.Cause(err) == ErrDataDidNotExist {
@@ -116,6 +286,7 @@ func existingOrNewHeader(cfg exportConfig, src StorageDriver, key *CryptoKey, ct
 	header.Metadata.Source.BlockSize = cfg.SrcBlockSize
 	header.Metadata.Source.Size = int64(cfg.VdiskSize)
 	header.Metadata.Version = zerodisk.CurrentVersion
+	header.Metadata.ExportID = cfg.ExportID
 
 	// return existing header, which was updated
 	log.Debugf("loaded and updated existing header for snapshot %s", cfg.SnapshotID)
@@ -137,23 +308,37 @@ func newExportHeader(cfg exportConfig) *Header {
 				BlockSize: cfg.SrcBlockSize,
 				Size:      int64(cfg.VdiskSize),
 			},
-			Version: zerodisk.CurrentVersion,
+			Version:  zerodisk.CurrentVersion,
+			ExportID: cfg.ExportID,
 		},
 		DedupedMap: RawDedupedMap{},
 	}
 }
 
 func exportBS(ctx context.Context, src storage.BlockStorage, blockIndices []int64, dst StorageDriver, cfg exportConfig) error {
-	// load the header, or create a new one if it doesn't exist yet
-	header, err := existingOrNewHeader(cfg, dst, &cfg.CryptoKey, cfg.CompressionType)
-	if err != nil {
-		return err
+	var header *Header
+	if cfg.Base != nil {
+		// export on top of the deduped map of the base snapshot
+		header = newExportHeader(cfg)
+		header.DedupedMap = cfg.Base.DedupedMap
+	} else {
+		// load the header, or create a new one if it doesn't exist yet
+		var err error
+		header, err = existingOrNewHeader(cfg, dst, &cfg.CryptoKey, cfg.CompressionType)
+		if err != nil {
+			return err
+		}
 	}
 	// unpack the raw deduped map so we can use it as the model we require it to be
 	dedupedMap, err := unpackRawDedupedMap(header.DedupedMap)
 	if err != nil {
 		return err
 	}
+	// forget the modified blocks of the base snapshot,
+	// such that blocks which were deleted since, aren't part of the snapshot
+	for _, index := range cfg.DirtyIndices {
+		dedupedMap.DeleteHash(index)
+	}
 
 	errCh := make(chan error)
 	defer close(errCh)
@@ -365,7 +550,7 @@ func exportBS(ctx context.Context, src storage.BlockStorage, blockIndices []int6
 	// launch all pipeline workers
 	owg.Add(cfg.JobCount)
 	for i := 0; i < cfg.JobCount; i++ {
-		compressor, err := NewCompressor(cfg.CompressionType)
+		compressor, err := compress.NewCompressor(cfg.CompressionType)
 		if err != nil {
 			return err
 		}
@@ -470,19 +655,27 @@ type exportConfig struct {
 
 	VdiskSize uint64
 
-	CompressionType CompressionType
+	CompressionType compress.CompressionType
 	CryptoKey       CryptoKey
 
 	VdiskID    string
 	SnapshotID string
+	// random identifier of an incremental export,
+	// empty for any other export
+	ExportID string
 
 	Force bool
+
+	// header of the snapshot an incremental export is done on top of,
+	// and the (export) indices of the blocks modified since that snapshot
+	Base         *Header
+	DirtyIndices []int64
 }
 
 // compress -> encrypt -> store
 type exportPipeline struct {
 	Hasher        zerodisk.Hasher
-	Compressor    Compressor
+	Compressor    compress.Compressor
 	Encrypter     Encrypter
 	StorageDriver StorageDriver
 	DedupedMap    *dedupedMap
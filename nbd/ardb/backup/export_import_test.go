@@ -8,6 +8,7 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/zero-os/0-Disk/compress"
 	"github.com/zero-os/0-Disk/log"
 	"github.com/zero-os/0-Disk/nbd/ardb"
 	"github.com/zero-os/0-Disk/nbd/ardb/storage"
@@ -123,7 +124,7 @@ func testImportExportCommute(t *testing.T, srcBS, dstBS, blockCount int64, sgen
 		JobCount:        runtime.NumCPU(),
 		SrcBlockSize:    srcBS,
 		DstBlockSize:    dstBS,
-		CompressionType: LZ4Compression,
+		CompressionType: compress.LZ4Compression,
 		CryptoKey:       privKey,
 		SnapshotID:      vdiskID,
 	}
@@ -140,7 +141,7 @@ func testImportExportCommute(t *testing.T, srcBS, dstBS, blockCount int64, sgen
 	importCfg := importConfig{
 		JobCount:        runtime.NumCPU(),
 		DstBlockSize:    srcBS,
-		CompressionType: LZ4Compression,
+		CompressionType: compress.LZ4Compression,
 		CryptoKey:       privKey,
 		SnapshotID:      vdiskID,
 	}
@@ -250,7 +251,7 @@ func testImportExportCommuteWithOffsetAndInterval(t *testing.T, srcBS, dstBS, bl
 		JobCount:        runtime.NumCPU(),
 		SrcBlockSize:    srcBS,
 		DstBlockSize:    dstBS,
-		CompressionType: LZ4Compression,
+		CompressionType: compress.LZ4Compression,
 		CryptoKey:       privKey,
 		SnapshotID:      vdiskID,
 	}
@@ -267,7 +268,7 @@ func testImportExportCommuteWithOffsetAndInterval(t *testing.T, srcBS, dstBS, bl
 	importCfg := importConfig{
 		JobCount:        runtime.NumCPU(),
 		DstBlockSize:    srcBS,
-		CompressionType: LZ4Compression,
+		CompressionType: compress.LZ4Compression,
 		CryptoKey:       privKey,
 		SnapshotID:      vdiskID,
 	}
@@ -362,7 +363,7 @@ func testImportExportCommuteNoEncryptionWithOffsetAndInterval(t *testing.T, srcB
 		JobCount:        runtime.NumCPU(),
 		SrcBlockSize:    srcBS,
 		DstBlockSize:    dstBS,
-		CompressionType: LZ4Compression,
+		CompressionType: compress.LZ4Compression,
 		SnapshotID:      vdiskID,
 	}
 	err = exportBS(ctx, srcMS, indices, driver, exportCfg)
@@ -378,7 +379,7 @@ func testImportExportCommuteNoEncryptionWithOffsetAndInterval(t *testing.T, srcB
 	importCfg := importConfig{
 		JobCount:        runtime.NumCPU(),
 		DstBlockSize:    srcBS,
-		CompressionType: LZ4Compression,
+		CompressionType: compress.LZ4Compression,
 		SnapshotID:      vdiskID,
 	}
 	err = importBS(ctx, driver, dstMS, importCfg)
@@ -499,7 +500,7 @@ func testImportExportCommuteStatic(t *testing.T, sourceData []byte, srcBS, dstBS
 		JobCount:        runtime.NumCPU(),
 		SrcBlockSize:    srcBS,
 		DstBlockSize:    dstBS,
-		CompressionType: LZ4Compression,
+		CompressionType: compress.LZ4Compression,
 		CryptoKey:       privKey,
 		SnapshotID:      vdiskID,
 	}
@@ -516,7 +517,7 @@ func testImportExportCommuteStatic(t *testing.T, sourceData []byte, srcBS, dstBS
 	importCfg := importConfig{
 		JobCount:        runtime.NumCPU(),
 		DstBlockSize:    srcBS,
-		CompressionType: LZ4Compression,
+		CompressionType: compress.LZ4Compression,
 		CryptoKey:       privKey,
 		SnapshotID:      vdiskID,
 	}
@@ -613,7 +614,7 @@ func testImportExportCommuteUsingPremadeData(t *testing.T, ibm map[int64][]byte,
 		JobCount:        runtime.NumCPU(),
 		SrcBlockSize:    srcBS,
 		DstBlockSize:    dstBS,
-		CompressionType: LZ4Compression,
+		CompressionType: compress.LZ4Compression,
 		CryptoKey:       privKey,
 		SnapshotID:      vdiskID,
 	}
@@ -630,7 +631,7 @@ func testImportExportCommuteUsingPremadeData(t *testing.T, ibm map[int64][]byte,
 	importCfg := importConfig{
 		JobCount:        runtime.NumCPU(),
 		DstBlockSize:    srcBS,
-		CompressionType: LZ4Compression,
+		CompressionType: compress.LZ4Compression,
 		CryptoKey:       privKey,
 		SnapshotID:      vdiskID,
 	}
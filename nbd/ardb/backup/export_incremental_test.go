@@ -0,0 +1,165 @@
+package backup
+
+import (
+	"context"
+	"crypto/rand"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zero-os/0-Disk/compress"
+	"github.com/zero-os/0-Disk/nbd/ardb/storage"
+	"github.com/zero-os/0-Disk/redisstub"
+)
+
+func TestIncrementalExport_8_8(t *testing.T) {
+	testIncrementalExport(t, 8, 8)
+}
+
+func TestIncrementalExport_8_32(t *testing.T) {
+	testIncrementalExport(t, 8, 32)
+}
+
+func TestIncrementalExport_32_8(t *testing.T) {
+	testIncrementalExport(t, 32, 8)
+}
+
+func testIncrementalExport(t *testing.T, srcBS, dstBS int64) {
+	require := require.New(t)
+	assert := assert.New(t)
+
+	const (
+		vdiskID    = "foo"
+		blockCount = 32
+	)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	cluster := redisstub.NewUniCluster(true)
+	defer cluster.Close()
+
+	nondeduped, err := storage.NonDeduped(vdiskID, "", srcBS, cluster, nil)
+	require.NoError(err)
+	src := storage.DirtyTracking(vdiskID, nondeduped, cluster)
+	defer src.Close()
+
+	setBlock := func(index int64) {
+		block := make([]byte, srcBS)
+		_, err := rand.Read(block)
+		require.NoError(err)
+		require.NoError(src.SetBlock(index, block))
+	}
+
+	var indices []int64
+	for index := int64(0); index < blockCount; index++ {
+		setBlock(index)
+		indices = append(indices, index)
+	}
+	require.NoError(src.Flush())
+
+	driver := newStubDriver()
+	newExportConfig := func(snapshotID string) exportConfig {
+		return exportConfig{
+			JobCount:        runtime.NumCPU(),
+			SrcBlockSize:    srcBS,
+			DstBlockSize:    dstBS,
+			CompressionType: compress.LZ4Compression,
+			CryptoKey:       privKey,
+			VdiskID:         vdiskID,
+			SnapshotID:      snapshotID,
+			ExportID:        "export-" + snapshotID,
+		}
+	}
+
+	// an incremental export isn't possible,
+	// as the vdisk wasn't exported yet
+	dirty, err := storage.LoadDirtyBlocks(vdiskID, cluster)
+	require.NoError(err)
+	cfg := newExportConfig("a")
+	_, ok, err := prepareIncrementalExport(src, dirty, driver, &cfg)
+	require.NoError(err)
+	require.False(ok)
+
+	// export the vdisk fully
+	require.NoError(exportBS(ctx, src, indices, driver, cfg))
+	require.NoError(storage.ResetDirtyBlocks(vdiskID, "a", cfg.ExportID, cluster))
+
+	// modify, delete and add some blocks
+	setBlock(3)
+	setBlock(17)
+	require.NoError(src.DeleteBlock(5))
+	require.NoError(src.DeleteBlock(6))
+	require.NoError(src.DeleteBlock(7))
+	setBlock(blockCount + 8)
+	require.NoError(src.Flush())
+
+	// export only the modified blocks
+	dirty, err = storage.LoadDirtyBlocks(vdiskID, cluster)
+	require.NoError(err)
+	cfg = newExportConfig("b")
+	incrementalIndices, ok, err := prepareIncrementalExport(src, dirty, driver, &cfg)
+	require.NoError(err)
+	require.True(ok)
+	assert.True(len(incrementalIndices) < blockCount/2)
+	require.NoError(exportBS(ctx, src, incrementalIndices, driver, cfg))
+	require.NoError(storage.ResetDirtyBlocks(vdiskID, "b", cfg.ExportID, cluster))
+
+	// the incremental snapshot should equal the current vdisk
+	dst := storage.NewInMemoryStorage(vdiskID, srcBS)
+	defer dst.Close()
+	require.NoError(importBS(ctx, driver, dst, importConfig{
+		JobCount:        runtime.NumCPU(),
+		DstBlockSize:    srcBS,
+		CompressionType: compress.LZ4Compression,
+		CryptoKey:       privKey,
+		SnapshotID:      "b",
+	}))
+	for index := int64(0); index < blockCount*2; index++ {
+		expected, err := src.GetBlock(index)
+		require.NoError(err)
+		actual, err := dst.GetBlock(index)
+		require.NoError(err)
+		if isNilBlock(expected) {
+			assert.True(isNilBlock(actual), "block %d", index)
+		} else {
+			assert.Equal(expected, actual, "block %d", index)
+		}
+	}
+
+	// the base snapshot should be untouched
+	header, err := LoadHeader("a", driver, &privKey, compress.LZ4Compression)
+	require.NoError(err)
+	assert.Equal(blockCount*srcBS/dstBS, header.DedupedMap.Count)
+
+	// exporting the same snapshot to another backup storage,
+	// means that the snapshot on the original storage can no longer be used as a base,
+	// as the blocks modified since it was exported are no longer tracked
+	otherDriver := newStubDriver()
+	setBlock(9)
+	dirty, err = storage.LoadDirtyBlocks(vdiskID, cluster)
+	require.NoError(err)
+	cfg = newExportConfig("b")
+	cfg.ExportID = "other-export-b"
+	_, ok, err = prepareIncrementalExport(src, dirty, otherDriver, &cfg)
+	require.NoError(err)
+	require.False(ok)
+	var allocated []int64
+	for index := int64(0); index < blockCount*2; index++ {
+		ok, err := storage.IsBlockAllocated(src, index)
+		require.NoError(err)
+		if ok {
+			allocated = append(allocated, index)
+		}
+	}
+	require.NoError(exportBS(ctx, src, allocated, otherDriver, cfg))
+	require.NoError(storage.ResetDirtyBlocks(vdiskID, "b", cfg.ExportID, cluster))
+
+	dirty, err = storage.LoadDirtyBlocks(vdiskID, cluster)
+	require.NoError(err)
+	cfg = newExportConfig("c")
+	_, ok, err = prepareIncrementalExport(src, dirty, driver, &cfg)
+	require.NoError(err)
+	require.False(ok)
+}
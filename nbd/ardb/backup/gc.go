@@ -0,0 +1,113 @@
+package backup
+
+import (
+	"github.com/zero-os/0-Disk"
+	"github.com/zero-os/0-Disk/compress"
+	"github.com/zero-os/0-Disk/errors"
+	"github.com/zero-os/0-Disk/log"
+)
+
+// DedupedGCResult is the result of a garbage collection of deduped blocks,
+// see `CollectDedupedGarbage` for more information.
+type DedupedGCResult struct {
+	// amount of headers which were marked
+	Headers int64
+	// amount of unique deduped blocks,
+	// referenced by at least one header
+	ReferencedBlocks int64
+	// amount of deduped blocks,
+	// no longer referenced by any header
+	UnreferencedBlocks int64
+	// true in case the unreferenced deduped blocks have been deleted
+	Deleted bool
+}
+
+// HeaderReferences can be used to mark the deduped blocks referenced
+// by a header which isn't a snapshot header (e.g. the index of a tlog archive).
+// It returns the hashes of all deduped blocks referenced by the header with the given ID,
+// or false in case it doesn't recognize that header.
+type HeaderReferences func(id string, driver StorageDriver, key *CryptoKey, ct compress.CompressionType) ([]zerodisk.Hash, bool, error)
+
+// CollectDedupedGarbage deletes all deduped blocks stored in the given (backup) storage,
+// which are no longer referenced by any header, using a mark-and-sweep algorithm.
+// When dryRun is true, the unreferenced deduped blocks are counted, but not deleted.
+//
+// All headers are loaded as snapshot headers first, and as a last resort
+// using the given (optional) HeaderReferences functions.
+// The collection is aborted, without deleting anything,
+// as soon as a single header can't be loaded,
+// hence all headers are required to use the given crypto key and compression type.
+// No snapshot should be exported to the given storage during the collection,
+// as its deduped blocks aren't referenced until its header is stored.
+func CollectDedupedGarbage(driver StorageDriver, key *CryptoKey, ct compress.CompressionType, dryRun bool, refs ...HeaderReferences) (*DedupedGCResult, error) {
+	ids, err := driver.GetHeaders()
+	if err != nil {
+		return nil, err
+	}
+
+	// mark all referenced deduped blocks
+	referenced := make(map[string]struct{})
+	for _, id := range ids {
+		log.Debugf("marking all deduped blocks referenced by header %s", id)
+		hashes, err := headerReferences(id, driver, key, ct, refs)
+		if err != nil {
+			return nil, errors.Wrapf(err,
+				"couldn't mark deduped blocks referenced by header %s", id)
+		}
+		for _, hash := range hashes {
+			referenced[string(hash)] = struct{}{}
+		}
+	}
+
+	// sweep all unreferenced deduped blocks
+	hashes, err := driver.GetDedupedBlocks()
+	if err != nil {
+		return nil, err
+	}
+	result := &DedupedGCResult{
+		Headers:          int64(len(ids)),
+		ReferencedBlocks: int64(len(referenced)),
+		Deleted:          !dryRun,
+	}
+	for _, hash := range hashes {
+		if _, ok := referenced[string(hash)]; ok {
+			continue
+		}
+		result.UnreferencedBlocks++
+		if dryRun {
+			continue
+		}
+		log.Debugf("deleting unreferenced deduped block %x", hash)
+		err = driver.DeleteDedupedBlock(hash)
+		if err != nil && err != ErrDataDidNotExist {
+			return nil, errors.Wrapf(err, "couldn't delete deduped block %x", hash)
+		}
+	}
+
+	return result, nil
+}
+
+// headerReferences returns the hashes of all deduped blocks referenced by a header,
+// loading it as a snapshot header, or using one of the given HeaderReferences functions.
+func headerReferences(id string, driver StorageDriver, key *CryptoKey, ct compress.CompressionType, refs []HeaderReferences) ([]zerodisk.Hash, error) {
+	header, headerErr := LoadHeader(id, driver, key, ct)
+	if headerErr == nil {
+		hashes := make([]zerodisk.Hash, len(header.DedupedMap.Hashes))
+		for index, hash := range header.DedupedMap.Hashes {
+			hashes[index] = zerodisk.Hash(hash)
+		}
+		return hashes, nil
+	}
+
+	for _, ref := range refs {
+		hashes, ok, err := ref(id, driver, key, ct)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			return hashes, nil
+		}
+	}
+
+	return nil, headerErr
+}
@@ -0,0 +1,130 @@
+package backup
+
+import (
+	"bytes"
+	"crypto/rand"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zero-os/0-Disk"
+	"github.com/zero-os/0-Disk/compress"
+)
+
+func TestCollectDedupedGarbage(t *testing.T) {
+	testCollectDedupedGarbage(t, newStubDriver())
+}
+
+func TestCollectDedupedGarbageLocal(t *testing.T) {
+	root, err := ioutil.TempDir("", "gc_test")
+	require.NoError(t, err)
+	defer os.RemoveAll(root)
+
+	driver, err := LocalStorageDriver(LocalStorageDriverConfig{Path: root})
+	require.NoError(t, err)
+	defer driver.Close()
+
+	testCollectDedupedGarbage(t, driver)
+}
+
+func testCollectDedupedGarbage(t *testing.T, driver StorageDriver) {
+	require := require.New(t)
+	assert := assert.New(t)
+
+	key := new(CryptoKey)
+	ct := compress.LZ4Compression
+
+	// create 3 hashes only referenced by the first snapshot,
+	// 2 hashes only referenced by the second snapshot,
+	// and 2 hashes referenced by both snapshots
+	newHashes := func(n int) (hashes []zerodisk.Hash) {
+		for i := 0; i < n; i++ {
+			hash := zerodisk.NewHash()
+			_, err := rand.Read(hash[:])
+			require.NoError(err)
+			err = driver.SetDedupedBlock(hash, bytes.NewReader(hash))
+			require.NoError(err)
+			hashes = append(hashes, hash)
+		}
+		return
+	}
+	hashesA, hashesB, shared := newHashes(3), newHashes(2), newHashes(2)
+
+	storeHeader := func(id string, hashes ...zerodisk.Hash) {
+		header := &Header{
+			Metadata: Metadata{SnapshotID: id, BlockSize: 4096},
+		}
+		for index, hash := range hashes {
+			header.DedupedMap.Count++
+			header.DedupedMap.Indices = append(header.DedupedMap.Indices, int64(index))
+			header.DedupedMap.Hashes = append(header.DedupedMap.Hashes, hash)
+		}
+		require.NoError(StoreHeader(header, key, ct, driver))
+	}
+	storeHeader("a", append(hashesA, shared...)...)
+	storeHeader("b", append(hashesB, shared...)...)
+
+	// nothing is unreferenced yet
+	result, err := CollectDedupedGarbage(driver, key, ct, false)
+	require.NoError(err)
+	assert.Equal(int64(2), result.Headers)
+	assert.Equal(int64(7), result.ReferencedBlocks)
+	assert.Equal(int64(0), result.UnreferencedBlocks)
+	assert.True(result.Deleted)
+
+	// deleting snapshot a makes its own blocks unreferenced
+	require.NoError(driver.DeleteHeader("a"))
+	assert.Equal(ErrDataDidNotExist, driver.DeleteHeader("a"))
+
+	// a dry run doesn't delete anything
+	result, err = CollectDedupedGarbage(driver, key, ct, true)
+	require.NoError(err)
+	assert.Equal(int64(1), result.Headers)
+	assert.Equal(int64(4), result.ReferencedBlocks)
+	assert.Equal(int64(3), result.UnreferencedBlocks)
+	assert.False(result.Deleted)
+	hashes, err := driver.GetDedupedBlocks()
+	require.NoError(err)
+	assert.Len(hashes, 7)
+
+	result, err = CollectDedupedGarbage(driver, key, ct, false)
+	require.NoError(err)
+	assert.Equal(int64(3), result.UnreferencedBlocks)
+	assert.True(result.Deleted)
+
+	hashes, err = driver.GetDedupedBlocks()
+	require.NoError(err)
+	assert.Len(hashes, 4)
+	for _, hash := range hashesA {
+		assert.Equal(ErrDataDidNotExist, driver.GetDedupedBlock(hash, ioutil.Discard))
+	}
+	for _, hash := range append(hashesB, shared...) {
+		assert.NoError(driver.GetDedupedBlock(hash, ioutil.Discard))
+	}
+
+	// a header which can't be loaded aborts the collection
+	require.NoError(driver.SetHeader("c", bytes.NewReader([]byte("foo"))))
+	require.NoError(driver.DeleteHeader("b"))
+	_, err = CollectDedupedGarbage(driver, key, ct, false)
+	assert.Error(err)
+	hashes, err = driver.GetDedupedBlocks()
+	require.NoError(err)
+	assert.Len(hashes, 4)
+
+	// unless one of the given header reference functions recognizes it
+	refs := func(id string, driver StorageDriver, key *CryptoKey, ct compress.CompressionType) ([]zerodisk.Hash, bool, error) {
+		if id != "c" {
+			return nil, false, nil
+		}
+		return shared, true, nil
+	}
+	result, err = CollectDedupedGarbage(driver, key, ct, false, refs)
+	require.NoError(err)
+	assert.Equal(int64(2), result.ReferencedBlocks)
+	assert.Equal(int64(2), result.UnreferencedBlocks)
+	hashes, err = driver.GetDedupedBlocks()
+	require.NoError(err)
+	assert.Len(hashes, 2)
+}
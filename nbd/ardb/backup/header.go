@@ -7,12 +7,13 @@ import (
 	valid "github.com/asaskevich/govalidator"
 	"github.com/zeebo/bencode"
 	"github.com/zero-os/0-Disk"
+	"github.com/zero-os/0-Disk/compress"
 	"github.com/zero-os/0-Disk/errors"
 )
 
 // LoadHeader loads (read=>[decrypt=>]decompress=>decode)
 // a (snapshot) header from a given (backup) server.
-func LoadHeader(id string, src StorageDriver, key *CryptoKey, ct CompressionType) (*Header, error) {
+func LoadHeader(id string, src StorageDriver, key *CryptoKey, ct compress.CompressionType) (*Header, error) {
 	buf := bytes.NewBuffer(nil)
 	err := src.GetHeader(id, buf)
 	if err != nil {
@@ -58,8 +59,18 @@ func LoadHeader(id string, src StorageDriver, key *CryptoKey, ct CompressionType
 	return header, nil
 }
 
-func readDedupedBlock(index int64, hash zerodisk.Hash, src StorageDriver, key *CryptoKey, ct CompressionType) ([]byte, error) {
-	decompressor, err := NewDecompressor(ct)
+func readDedupedBlock(index int64, hash zerodisk.Hash, src StorageDriver, key *CryptoKey, ct compress.CompressionType) ([]byte, error) {
+	pipeline, err := newImportPipeline(src, key, ct)
+	if err != nil {
+		return nil, err
+	}
+	return pipeline.ReadBlock(index, hash)
+}
+
+// newImportPipeline creates a pipeline,
+// which reads deduped blocks from a given (backup) storage.
+func newImportPipeline(src StorageDriver, key *CryptoKey, ct compress.CompressionType) (*importPipeline, error) {
+	decompressor, err := compress.NewDecompressor(ct)
 	if err != nil {
 		return nil, err
 	}
@@ -77,14 +88,12 @@ func readDedupedBlock(index int64, hash zerodisk.Hash, src StorageDriver, key *C
 		return nil, err
 	}
 
-	pipeline := &importPipeline{
+	return &importPipeline{
 		StorageDriver: src,
 		Decrypter:     decrypter,
 		Decompressor:  decompressor,
 		Hasher:        hasher,
-	}
-
-	return pipeline.ReadBlock(index, hash)
+	}, nil
 }
 
 // decoding-related errors.
@@ -100,8 +109,8 @@ var (
 // using (if given) the private (AES) key and compression type.
 // The given compression type and (optional) private key has to match the information,
 // used to serialize this Header in the first place.
-func deserializeHeader(key *CryptoKey, ct CompressionType, src io.Reader, decoder func(src io.Reader) (*Header, error)) (*Header, error) {
-	decompressor, err := NewDecompressor(ct)
+func deserializeHeader(key *CryptoKey, ct compress.CompressionType, src io.Reader, decoder func(src io.Reader) (*Header, error)) (*Header, error) {
+	decompressor, err := compress.NewDecompressor(ct)
 	if err != nil {
 		return nil, err
 	}
@@ -174,7 +183,7 @@ var (
 
 // StoreHeader StoreHeader (encode=>compress=>[encrypt=>]writes)
 // a (snapshot) header to a given (backup) server.
-func StoreHeader(header *Header, key *CryptoKey, ct CompressionType, dst StorageDriver) error {
+func StoreHeader(header *Header, key *CryptoKey, ct compress.CompressionType, dst StorageDriver) error {
 	buf := bytes.NewBuffer(nil)
 	err := serializeHeader(header, key, ct, buf)
 	if err != nil {
@@ -187,8 +196,8 @@ func StoreHeader(header *Header, key *CryptoKey, ct CompressionType, dst Storage
 // to the given writer. The encoded data will be compressed and optionally encrypted before being
 // writen to the given writer.
 // You can deserialize this header in memory using the `deserializeHeader` function.
-func serializeHeader(header *Header, key *CryptoKey, ct CompressionType, dst io.Writer) error {
-	compressor, err := NewCompressor(ct)
+func serializeHeader(header *Header, key *CryptoKey, ct compress.CompressionType, dst io.Writer) error {
+	compressor, err := compress.NewCompressor(ct)
 	if err != nil {
 		return err
 	}
@@ -267,6 +276,9 @@ type Metadata struct {
 	Source Source `bencode:"src" valid:"optional"`
 	// optional: version of the 0-disk toolchain
 	Version zerodisk.Version `bencode:"v" valid:"optional"`
+	// optional: random identifier of the (incremental) export
+	// which last stored this snapshot
+	ExportID string `bencode:"eid" valid:"optional"`
 }
 
 // UnmarshalBencode implements bencode.Unmarshaler.UnmarshalBencode
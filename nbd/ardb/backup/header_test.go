@@ -11,6 +11,7 @@ import (
 	"github.com/stretchr/testify/require"
 	"github.com/zeebo/bencode"
 	"github.com/zero-os/0-Disk"
+	"github.com/zero-os/0-Disk/compress"
 	"github.com/zero-os/0-Disk/errors"
 )
 
@@ -110,12 +111,12 @@ func TestStoreLoadHeader(t *testing.T) {
 	// store header
 
 	driver := newStubDriver()
-	err = StoreHeader(header, &privKey, LZ4Compression, driver)
+	err = StoreHeader(header, &privKey, compress.LZ4Compression, driver)
 	require.NoError(err)
 
 	// load header
 
-	outHeader, err := LoadHeader(snapshotID, driver, &privKey, LZ4Compression)
+	outHeader, err := LoadHeader(snapshotID, driver, &privKey, compress.LZ4Compression)
 	require.NoError(err)
 	require.Equal(header.DedupedMap.Count, outHeader.DedupedMap.Count)
 
@@ -169,7 +170,7 @@ func TestLoadHeader_DeprecatedDedupedMap(t *testing.T) {
 	// also store the deduped map
 
 	buf := bytes.NewBuffer(nil)
-	err = dm.serialize(&privKey, LZ4Compression, buf)
+	err = dm.serialize(&privKey, compress.LZ4Compression, buf)
 	require.NoError(err)
 
 	err = driver.SetHeader(snapshotID, buf)
@@ -177,7 +178,7 @@ func TestLoadHeader_DeprecatedDedupedMap(t *testing.T) {
 
 	// load header (this will in fact load the deduped map, with backwards compatibility)
 
-	outHeader, err := LoadHeader(snapshotID, driver, &privKey, LZ4Compression)
+	outHeader, err := LoadHeader(snapshotID, driver, &privKey, compress.LZ4Compression)
 	require.NoError(err)
 
 	// verify loaded header
@@ -190,7 +191,7 @@ func TestLoadHeader_DeprecatedDedupedMap(t *testing.T) {
 func testExportPipeline(t *testing.T, driver StorageDriver, dm *dedupedMap) *exportPipeline {
 	require := require.New(t)
 
-	compressor, err := NewCompressor(LZ4Compression)
+	compressor, err := compress.NewCompressor(compress.LZ4Compression)
 	require.NoError(err)
 
 	var encrypter Encrypter
@@ -199,7 +200,7 @@ func testExportPipeline(t *testing.T, driver StorageDriver, dm *dedupedMap) *exp
 		require.NoError(err)
 	}
 
-	hasher, err := newKeyedHasher(LZ4Compression, privKey)
+	hasher, err := newKeyedHasher(compress.LZ4Compression, privKey)
 	require.NoError(err)
 
 	return &exportPipeline{
@@ -220,11 +221,11 @@ func testExportPipeline(t *testing.T, driver StorageDriver, dm *dedupedMap) *exp
 // to the given writer. The encoded data will be compressed and encrypted before being
 // writen to the given writer.
 // You can re-load this map in memory using the `DeserializeDedupedMap` function.
-func (dm *dedupedMap) serialize(key *CryptoKey, ct CompressionType, dst io.Writer) error {
+func (dm *dedupedMap) serialize(key *CryptoKey, ct compress.CompressionType, dst io.Writer) error {
 	dm.mux.Lock()
 	defer dm.mux.Unlock()
 
-	compressor, err := NewCompressor(ct)
+	compressor, err := compress.NewCompressor(ct)
 	if err != nil {
 		return err
 	}
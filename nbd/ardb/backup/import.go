@@ -9,6 +9,7 @@ import (
 	"sync"
 
 	"github.com/zero-os/0-Disk"
+	"github.com/zero-os/0-Disk/compress"
 	"github.com/zero-os/0-Disk/config"
 	"github.com/zero-os/0-Disk/errors"
 	"github.com/zero-os/0-Disk/log"
@@ -36,7 +37,7 @@ func Import(ctx context.Context, cfg Config) error {
 	}
 	defer blockStorage.Close()
 
-	storageDriver, err := newStorageDriver(cfg.BackupStoragDriverConfig)
+	storageDriver, err := NewStorageDriver(cfg.BackupStoragDriverConfig)
 	if err != nil {
 		return err
 	}
@@ -111,7 +112,7 @@ func importBS(ctx context.Context, src StorageDriver, dst storage.BlockStorage,
 	// launch all workers
 	wg.Add(cfg.JobCount)
 	for i := 0; i < cfg.JobCount; i++ {
-		decompressor, err := NewDecompressor(cfg.CompressionType)
+		decompressor, err := compress.NewDecompressor(cfg.CompressionType)
 		if err != nil {
 			return err
 		}
@@ -422,17 +423,22 @@ func importBS(ctx context.Context, src StorageDriver, dst storage.BlockStorage,
 type importPipeline struct {
 	StorageDriver StorageDriver
 	Decrypter     Decrypter
-	Decompressor  Decompressor
+	Decompressor  compress.Decompressor
 	Hasher        zerodisk.Hasher
 }
 
 func (p *importPipeline) ReadBlock(index int64, hash zerodisk.Hash) ([]byte, error) {
-	bufA := bytes.NewBuffer(nil)
-	err := p.StorageDriver.GetDedupedBlock(hash, bufA)
+	buf := bytes.NewBuffer(nil)
+	err := p.StorageDriver.GetDedupedBlock(hash, buf)
 	if err != nil {
 		return nil, err
 	}
+	return p.DecodeBlock(index, hash, buf)
+}
 
+// decrypt -> decompress -> validate hash
+func (p *importPipeline) DecodeBlock(index int64, hash zerodisk.Hash, bufA *bytes.Buffer) ([]byte, error) {
+	var err error
 	bufB := bytes.NewBuffer(nil)
 
 	if p.Decrypter != nil {
@@ -522,7 +528,7 @@ type importConfig struct {
 	// max destination vdisk size
 	DstVdiskSize uint64
 
-	CompressionType CompressionType
+	CompressionType compress.CompressionType
 	CryptoKey       CryptoKey
 
 	SnapshotID string
@@ -8,6 +8,7 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"github.com/zero-os/0-Disk"
+	"github.com/zero-os/0-Disk/compress"
 )
 
 func TestHashFetcher(t *testing.T) {
@@ -100,7 +101,7 @@ func TestComputeSnapshotImportSize_SmallerTargetBlocks(t *testing.T) {
 	require := require.New(t)
 	driver := newStubDriver()
 
-	compressor, err := NewCompressor(LZ4Compression)
+	compressor, err := compress.NewCompressor(compress.LZ4Compression)
 	require.NoError(err)
 
 	var encrypter Encrypter
@@ -109,7 +110,7 @@ func TestComputeSnapshotImportSize_SmallerTargetBlocks(t *testing.T) {
 		require.NoError(err)
 	}
 
-	hasher, err := newKeyedHasher(LZ4Compression, privKey)
+	hasher, err := newKeyedHasher(compress.LZ4Compression, privKey)
 	require.NoError(err)
 
 	dm := newDedupedMap()
@@ -129,7 +130,7 @@ func TestComputeSnapshotImportSize_SmallerTargetBlocks(t *testing.T) {
 				importConfig{
 					DstBlockSize:    dstBlockSize,
 					CryptoKey:       privKey,
-					CompressionType: LZ4Compression,
+					CompressionType: compress.LZ4Compression,
 				})
 			if assert.NoError(err) {
 				assert.Equal(expectedSnapshotSize, snapshotSize)
@@ -0,0 +1,182 @@
+package backup
+
+import (
+	"sort"
+	"time"
+
+	"github.com/zero-os/0-Disk/compress"
+	"github.com/zero-os/0-Disk/errors"
+	"github.com/zero-os/0-Disk/log"
+)
+
+// RetentionPolicy defines which snapshots of a vdisk are to be kept,
+// when applying it using the `ApplyRetentionPolicy` function.
+// A snapshot is kept when it is selected by at least one of the rules,
+// all other snapshots of that vdisk are deleted.
+type RetentionPolicy struct {
+	// amount of most recent snapshots to keep
+	KeepLast int
+	// amount of days for which to keep the most recent snapshot,
+	// only days which have at least one snapshot are counted
+	KeepDaily int
+	// amount of (ISO 8601) weeks for which to keep the most recent snapshot,
+	// only weeks which have at least one snapshot are counted
+	KeepWeekly int
+}
+
+// Validate this retention policy,
+// returning an error if it isn't valid.
+func (policy *RetentionPolicy) Validate() error {
+	if policy.KeepLast < 0 || policy.KeepDaily < 0 || policy.KeepWeekly < 0 {
+		return errors.New("retention policy can't keep a negative amount of snapshots")
+	}
+	if policy.KeepLast == 0 && policy.KeepDaily == 0 && policy.KeepWeekly == 0 {
+		return errors.New("retention policy has to keep at least one snapshot")
+	}
+	return nil
+}
+
+// RetentionResult is the result of applying a retention policy,
+// see `ApplyRetentionPolicy` for more information.
+type RetentionResult struct {
+	// identifiers of the snapshots which were kept,
+	// snapshots without a creation time first,
+	// followed by all others sorted from most recent to oldest
+	Kept []string
+	// identifiers of the snapshots which were deleted
+	// (or would have been in case of a dry run),
+	// sorted from most recent to oldest
+	Deleted []string
+}
+
+// ApplyRetentionPolicy applies a retention policy to all snapshots of a vdisk,
+// stored in the given (backup) storage, deleting all snapshots which the policy doesn't keep.
+// When dryRun is true, the snapshots which would be deleted are listed, but not deleted.
+//
+// The snapshots of the vdisk are identified using the source vdisk ID
+// stored in the metadata of their headers, and are ordered using their creation time.
+// Headers which can't be loaded using the given crypto key and compression type,
+// are ignored, while snapshots without a (valid) creation time are always kept.
+//
+// Only the headers of the deleted snapshots are deleted,
+// use `CollectDedupedGarbage` to delete the deduped blocks which are no longer referenced.
+func ApplyRetentionPolicy(vdiskID string, policy RetentionPolicy, driver StorageDriver, key *CryptoKey, ct compress.CompressionType, dryRun bool) (*RetentionResult, error) {
+	err := policy.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	ids, err := driver.GetHeaders()
+	if err != nil {
+		return nil, err
+	}
+
+	// collect all snapshots of the given vdisk
+	var snapshots []retentionSnapshot
+	result := new(RetentionResult)
+	for _, id := range ids {
+		header, err := LoadHeader(id, driver, key, ct)
+		if err != nil {
+			log.Debugf("ignoring header %s as it couldn't be loaded: %v", id, err)
+			continue
+		}
+		if header.Metadata.Source.VdiskID != vdiskID {
+			continue
+		}
+		created, err := time.Parse(time.RFC3339, header.Metadata.Created)
+		if err != nil {
+			log.Infof("keeping snapshot %s as it has no valid creation time: %v", id, err)
+			result.Kept = append(result.Kept, id)
+			continue
+		}
+		snapshots = append(snapshots, retentionSnapshot{ID: id, Created: created})
+	}
+
+	keep, remove := policy.selectSnapshots(snapshots)
+	for _, snapshot := range keep {
+		result.Kept = append(result.Kept, snapshot.ID)
+	}
+	for _, snapshot := range remove {
+		result.Deleted = append(result.Deleted, snapshot.ID)
+		if dryRun {
+			continue
+		}
+		log.Debugf("deleting snapshot %s, created at %s", snapshot.ID, snapshot.Created)
+		err = driver.DeleteHeader(snapshot.ID)
+		if err != nil && err != ErrDataDidNotExist {
+			return nil, errors.Wrapf(err, "couldn't delete snapshot %s", snapshot.ID)
+		}
+	}
+
+	return result, nil
+}
+
+// retentionSnapshot is a snapshot to which a retention policy is applied.
+type retentionSnapshot struct {
+	ID      string
+	Created time.Time
+}
+
+// selectSnapshots splits the given snapshots in those to keep and those to remove,
+// both sorted from most recent to oldest.
+func (policy *RetentionPolicy) selectSnapshots(snapshots []retentionSnapshot) (keep, remove []retentionSnapshot) {
+	sort.SliceStable(snapshots, func(i, j int) bool {
+		return snapshots[i].Created.After(snapshots[j].Created)
+	})
+
+	rules := []*retentionRule{
+		{Limit: policy.KeepLast},
+		{Limit: policy.KeepDaily, Bucket: func(t time.Time) interface{} {
+			year, month, day := t.Date()
+			return [3]int{year, int(month), day}
+		}},
+		{Limit: policy.KeepWeekly, Bucket: func(t time.Time) interface{} {
+			year, week := t.ISOWeek()
+			return [2]int{year, week}
+		}},
+	}
+
+	for _, snapshot := range snapshots {
+		var kept bool
+		for _, rule := range rules {
+			if rule.Keep(snapshot.Created) {
+				kept = true
+			}
+		}
+		if kept {
+			keep = append(keep, snapshot)
+		} else {
+			remove = append(remove, snapshot)
+		}
+	}
+	return keep, remove
+}
+
+// retentionRule keeps the most recent snapshot of a limited amount of buckets,
+// it expects to be given the snapshots from most recent to oldest.
+type retentionRule struct {
+	Limit int
+	// when nil, every snapshot is considered to be in its own bucket
+	Bucket func(t time.Time) interface{}
+
+	count int
+	last  interface{}
+}
+
+// Keep returns true in case the snapshot created at the given time should be kept.
+func (rule *retentionRule) Keep(created time.Time) bool {
+	if rule.count >= rule.Limit {
+		return false
+	}
+	if rule.Bucket == nil {
+		rule.count++
+		return true
+	}
+	bucket := rule.Bucket(created)
+	if rule.count > 0 && bucket == rule.last {
+		return false
+	}
+	rule.count++
+	rule.last = bucket
+	return true
+}
@@ -0,0 +1,157 @@
+package backup
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zero-os/0-Disk"
+	"github.com/zero-os/0-Disk/compress"
+)
+
+func TestRetentionPolicyValidate(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Error((&RetentionPolicy{}).Validate())
+	assert.Error((&RetentionPolicy{KeepLast: -1, KeepDaily: 2}).Validate())
+	assert.NoError((&RetentionPolicy{KeepLast: 1}).Validate())
+	assert.NoError((&RetentionPolicy{KeepDaily: 1}).Validate())
+	assert.NoError((&RetentionPolicy{KeepWeekly: 1}).Validate())
+}
+
+func TestRetentionPolicySelectSnapshots(t *testing.T) {
+	// 2 snapshots a day, at 00:00 and 12:00, for 28 days (4 weeks),
+	// starting on monday 2017-01-02 (the first day of ISO week 1 of 2017)
+	start := time.Date(2017, time.January, 2, 0, 0, 0, 0, time.UTC)
+	var snapshots []retentionSnapshot
+	for i := 0; i < 56; i++ {
+		created := start.Add(time.Duration(i) * 12 * time.Hour)
+		snapshots = append(snapshots, retentionSnapshot{
+			ID:      created.Format(time.RFC3339),
+			Created: created,
+		})
+	}
+
+	testCases := []struct {
+		policy   RetentionPolicy
+		expected []string
+	}{
+		{
+			RetentionPolicy{KeepLast: 3},
+			[]string{
+				"2017-01-29T12:00:00Z",
+				"2017-01-29T00:00:00Z",
+				"2017-01-28T12:00:00Z",
+			},
+		},
+		{
+			RetentionPolicy{KeepDaily: 3},
+			[]string{
+				"2017-01-29T12:00:00Z",
+				"2017-01-28T12:00:00Z",
+				"2017-01-27T12:00:00Z",
+			},
+		},
+		{
+			RetentionPolicy{KeepWeekly: 3},
+			[]string{
+				"2017-01-29T12:00:00Z",
+				"2017-01-22T12:00:00Z",
+				"2017-01-15T12:00:00Z",
+			},
+		},
+		{
+			RetentionPolicy{KeepLast: 2, KeepDaily: 2, KeepWeekly: 2},
+			[]string{
+				"2017-01-29T12:00:00Z",
+				"2017-01-29T00:00:00Z",
+				"2017-01-28T12:00:00Z",
+				"2017-01-22T12:00:00Z",
+			},
+		},
+		{
+			RetentionPolicy{KeepWeekly: 10},
+			[]string{
+				"2017-01-29T12:00:00Z",
+				"2017-01-22T12:00:00Z",
+				"2017-01-15T12:00:00Z",
+				"2017-01-08T12:00:00Z",
+			},
+		},
+	}
+
+	for _, testCase := range testCases {
+		input := make([]retentionSnapshot, len(snapshots))
+		copy(input, snapshots)
+
+		keep, remove := testCase.policy.selectSnapshots(input)
+		var ids []string
+		for _, snapshot := range keep {
+			ids = append(ids, snapshot.ID)
+		}
+		assert.Equal(t, testCase.expected, ids, "%+v", testCase.policy)
+		assert.Len(t, remove, len(snapshots)-len(keep), "%+v", testCase.policy)
+	}
+}
+
+func TestApplyRetentionPolicy(t *testing.T) {
+	require := require.New(t)
+	assert := assert.New(t)
+
+	driver := newStubDriver()
+	key := new(CryptoKey)
+	ct := compress.LZ4Compression
+
+	hash := zerodisk.HashBytes([]byte("foo"))
+	require.NoError(driver.SetDedupedBlock(hash, bytes.NewReader(hash)))
+
+	storeHeader := func(id, vdiskID, created string) {
+		header := &Header{
+			Metadata: Metadata{
+				SnapshotID: id,
+				BlockSize:  4096,
+				Created:    created,
+				Source:     Source{VdiskID: vdiskID},
+			},
+			DedupedMap: RawDedupedMap{
+				Count:   1,
+				Indices: []int64{0},
+				Hashes:  [][]byte{hash},
+			},
+		}
+		require.NoError(StoreHeader(header, key, ct, driver))
+	}
+	storeHeader("a1", "a", "2017-01-01T10:00:00Z")
+	storeHeader("a2", "a", "2017-01-02T10:00:00Z")
+	storeHeader("a3", "a", "2017-01-03T10:00:00Z")
+	storeHeader("a4", "a", "")
+	storeHeader("b1", "b", "2017-01-01T10:00:00Z")
+	require.NoError(driver.SetHeader("c", bytes.NewReader([]byte("foo"))))
+
+	policy := RetentionPolicy{KeepLast: 1}
+
+	// a dry run only lists the snapshots
+	result, err := ApplyRetentionPolicy("a", policy, driver, key, ct, true)
+	require.NoError(err)
+	assert.Equal([]string{"a4", "a3"}, result.Kept)
+	assert.Equal([]string{"a2", "a1"}, result.Deleted)
+	ids, err := driver.GetHeaders()
+	require.NoError(err)
+	assert.Len(ids, 6)
+
+	result, err = ApplyRetentionPolicy("a", policy, driver, key, ct, false)
+	require.NoError(err)
+	assert.Equal([]string{"a4", "a3"}, result.Kept)
+	assert.Equal([]string{"a2", "a1"}, result.Deleted)
+	ids, err = driver.GetHeaders()
+	require.NoError(err)
+	assert.Len(ids, 4)
+	assert.Equal(ErrDataDidNotExist, driver.GetHeader("a1", bytes.NewBuffer(nil)))
+	assert.Equal(ErrDataDidNotExist, driver.GetHeader("a2", bytes.NewBuffer(nil)))
+
+	// an invalid policy is refused
+	_, err = ApplyRetentionPolicy("a", RetentionPolicy{}, driver, key, ct, false)
+	assert.Error(err)
+}
@@ -1,21 +1,25 @@
 package backup
 
 import (
+	"encoding/hex"
 	"io"
+	"os"
+	"path"
 	"sync"
 
 	"github.com/zero-os/0-Disk"
+	"github.com/zero-os/0-Disk/compress"
 	"github.com/zero-os/0-Disk/errors"
 )
 
 var (
-	// ErrDataDidNotExist is returned from a ServerDriver's Getter
+	// ErrDataDidNotExist is returned from a ServerDriver's Getter (or Delete)
 	// method in case the requested data does not exist on the server.
 	ErrDataDidNotExist = errors.New("requested data did not exist")
 )
 
 // StorageDriver defines the API of a (storage) driver,
-// which allows us to read/write from/to a (backup) storage,
+// which allows us to read/write/delete from/to a (backup) storage,
 // the deduped blocks and map which form a backup.
 type StorageDriver interface {
 	SetDedupedBlock(hash zerodisk.Hash, r io.Reader) error
@@ -24,6 +28,10 @@ type StorageDriver interface {
 	GetDedupedBlock(hash zerodisk.Hash, w io.Writer) error
 	GetHeader(id string, w io.Writer) error
 
+	DeleteDedupedBlock(hash zerodisk.Hash) error
+	DeleteHeader(id string) error
+
+	GetDedupedBlocks() (hashes []zerodisk.Hash, err error)
 	GetHeaders() (ids []string, err error)
 
 	Close() error
@@ -37,10 +45,11 @@ type StorageDriver interface {
 // - When given:
 //  - If type equals LocalStorageDriverConfig -> Create LocalStorageDriver;
 //  - If type equals FTPStorageDriverConfig -> Create FTPStorageDriver;
+//  - If type equals S3StorageDriverConfig -> Create S3StorageDriver;
 //  - Else -> error
-func ReadSnapshotHeader(id string, storagDriverConfig interface{}, key *CryptoKey, ct CompressionType) (*Header, error) {
+func ReadSnapshotHeader(id string, storagDriverConfig interface{}, key *CryptoKey, ct compress.CompressionType) (*Header, error) {
 	// create (backup) storage driver (so we can list snapshot headers from it)
-	driver, err := newStorageDriver(storagDriverConfig)
+	driver, err := NewStorageDriver(storagDriverConfig)
 	if err != nil {
 		return nil, err
 	}
@@ -56,10 +65,11 @@ func ReadSnapshotHeader(id string, storagDriverConfig interface{}, key *CryptoKe
 // - When given:
 //  - If type equals LocalStorageDriverConfig -> Create LocalStorageDriver;
 //  - If type equals FTPStorageDriverConfig -> Create FTPStorageDriver;
+//  - If type equals S3StorageDriverConfig -> Create S3StorageDriver;
 //  - Else -> error
 func ListSnapshots(storagDriverConfig interface{}, pred func(id string) bool) (ids []string, err error) {
 	// create (backup) storage driver (so we can list snapshot headers from it)
-	driver, err := newStorageDriver(storagDriverConfig)
+	driver, err := NewStorageDriver(storagDriverConfig)
 	if err != nil {
 		return nil, err
 	}
@@ -85,6 +95,48 @@ func ListSnapshots(storagDriverConfig interface{}, pred func(id string) bool) (i
 	return ids[:filterPos], nil
 }
 
+// listDedupedBlocks lists the hashes of all deduped blocks of a (backup) storage,
+// using the given function to read the content of a directory,
+// relative to the root of that storage.
+// Files which don't match the location of a deduped block are ignored.
+func listDedupedBlocks(readDir func(dir string) ([]os.FileInfo, error)) ([]zerodisk.Hash, error) {
+	var hashes []zerodisk.Hash
+
+	dirs, err := readDir("")
+	if err != nil {
+		return nil, err
+	}
+	for _, dir := range dirs {
+		if !dir.IsDir() || !isHashDir(dir.Name()) {
+			continue
+		}
+		subDirs, err := readDir(dir.Name())
+		if err != nil {
+			return nil, err
+		}
+		for _, subDir := range subDirs {
+			if !subDir.IsDir() || !isHashDir(subDir.Name()) {
+				continue
+			}
+			files, err := readDir(path.Join(dir.Name(), subDir.Name()))
+			if err != nil {
+				return nil, err
+			}
+			for _, file := range files {
+				if file.IsDir() {
+					continue
+				}
+				hash, ok := dirAndFileAsHash(dir.Name(), subDir.Name(), file.Name())
+				if ok {
+					hashes = append(hashes, hash)
+				}
+			}
+		}
+	}
+
+	return hashes, nil
+}
+
 func hashAsDirAndFile(hash zerodisk.Hash) (string, string, bool) {
 	if len(hash) != zerodisk.HashSize {
 		return "", "", false
@@ -95,6 +147,30 @@ func hashAsDirAndFile(hash zerodisk.Hash) (string, string, bool) {
 	return dir, file, true
 }
 
+// dirAndFileAsHash is the inverse function of hashAsDirAndFile,
+// where the dir is given as its two separate levels.
+func dirAndFileAsHash(dir, subDir, file string) (zerodisk.Hash, bool) {
+	str := dir + subDir + file
+	if len(str) != zerodisk.HashSize*2 {
+		return nil, false
+	}
+	hash, err := hex.DecodeString(str)
+	if err != nil || hashBytesToString(hash) != str {
+		return nil, false
+	}
+	return zerodisk.Hash(hash), true
+}
+
+// isHashDir returns true if the given name
+// can be a (single level) directory of a deduped block.
+func isHashDir(name string) bool {
+	if len(name) != 4 {
+		return false
+	}
+	bs, err := hex.DecodeString(name)
+	return err == nil && hashBytesToString(bs) == name
+}
+
 func hashBytesToString(bs []byte) string {
 	var total []byte
 	for _, b := range bs {
@@ -5,6 +5,7 @@ import (
 	"crypto/x509"
 	"io"
 	"io/ioutil"
+	"os"
 	"path"
 	"regexp"
 	"strings"
@@ -290,6 +291,35 @@ func (ftp *ftpStorageDriver) GetHeader(id string, w io.Writer) error {
 	return ftp.retrieve(loc, w)
 }
 
+// DeleteDedupedBlock implements ServerDriver.DeleteDedupedBlock
+func (ftp *ftpStorageDriver) DeleteDedupedBlock(hash zerodisk.Hash) error {
+	dir, file, ok := hashAsDirAndFile(hash)
+	if !ok {
+		return errInvalidHash
+	}
+
+	loc := path.Join(ftp.rootDir, dir, file)
+	return ftp.delete(loc)
+}
+
+// DeleteHeader implements ServerDriver.DeleteHeader
+func (ftp *ftpStorageDriver) DeleteHeader(id string) error {
+	loc := path.Join(ftp.rootDir, backupDir, id)
+	return ftp.delete(loc)
+}
+
+// GetDedupedBlocks implements ServerDriver.GetDedupedBlocks
+func (ftp *ftpStorageDriver) GetDedupedBlocks() ([]zerodisk.Hash, error) {
+	return listDedupedBlocks(func(dir string) ([]os.FileInfo, error) {
+		dir = path.Join(ftp.rootDir, dir)
+		files, err := ftp.client.ReadDir(dir)
+		if err != nil {
+			return nil, errors.Wrapf(err, "couldn't read FTP backup dir (%s)", dir)
+		}
+		return files, nil
+	})
+}
+
 // GetHeaders implements ServerDriver.GetHeaders
 func (ftp *ftpStorageDriver) GetHeaders() (ids []string, err error) {
 	dir := path.Join(ftp.rootDir, backupDir)
@@ -394,6 +424,16 @@ func (ftp *ftpStorageDriver) retrieve(path string, dest io.Writer) error {
 	return err
 }
 
+// delete a file from an FTP server.
+// returns ErrDataDidNotExist in case there was no file on the given path.
+func (ftp *ftpStorageDriver) delete(path string) error {
+	err := ftp.client.Delete(path)
+	if isFTPErrorCode(ftpErrorNoExists, err) {
+		return ErrDataDidNotExist
+	}
+	return err
+}
+
 // list of ftp error codes we care about
 const (
 	ftpErrorInvalidCommand = 500
@@ -68,6 +68,33 @@ func (ld *localDriver) GetHeader(id string, w io.Writer) error {
 	return ld.readFile(backupDir, id, w)
 }
 
+// DeleteDedupedBlock implements StorageDriver.DeleteDedupedBlock
+func (ld *localDriver) DeleteDedupedBlock(hash zerodisk.Hash) error {
+	dir, file, ok := hashAsDirAndFile(hash)
+	if !ok {
+		return errInvalidHash
+	}
+
+	return ld.deleteFile(dir, file)
+}
+
+// DeleteHeader implements StorageDriver.DeleteHeader
+func (ld *localDriver) DeleteHeader(id string) error {
+	return ld.deleteFile(backupDir, id)
+}
+
+// GetDedupedBlocks implements StorageDriver.GetDedupedBlocks
+func (ld *localDriver) GetDedupedBlocks() ([]zerodisk.Hash, error) {
+	return listDedupedBlocks(func(dir string) ([]os.FileInfo, error) {
+		dir = path.Join(ld.root, dir)
+		files, err := ioutil.ReadDir(dir)
+		if err != nil {
+			return nil, errors.Wrapf(err, "couldn't read local backup dir (%s)", dir)
+		}
+		return files, nil
+	})
+}
+
 // GetHeaders implements ServerDriver.GetHeaders
 func (ld *localDriver) GetHeaders() (ids []string, err error) {
 	dir := path.Join(ld.root, backupDir)
@@ -106,6 +133,19 @@ func (ld *localDriver) readFile(dir, name string, w io.Writer) error {
 	return err
 }
 
+// deleteFile deletes a file,
+// its (possibly empty) directory is kept, as it is cached as an existing directory.
+func (ld *localDriver) deleteFile(dir, name string) error {
+	path := path.Join(ld.root, dir, name)
+
+	log.Debug("deleting: ", path)
+	err := os.Remove(path)
+	if err != nil && os.IsNotExist(err) {
+		return ErrDataDidNotExist
+	}
+	return err
+}
+
 func (ld *localDriver) writeFile(dir, name string, r io.Reader, overwrite bool) error {
 	dir = path.Join(ld.root, dir)
 	err := ld.mkdirs(dir)
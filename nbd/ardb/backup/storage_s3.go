@@ -0,0 +1,358 @@
+package backup
+
+import (
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/zero-os/0-Disk"
+	"github.com/zero-os/0-Disk/errors"
+)
+
+// S3StorageDriverConfig is used to configure and create an S3 (Storage) Driver.
+type S3StorageDriverConfig struct {
+	ServerConfig S3ServerConfig
+	TLSConfig    *TLSClientConfig
+}
+
+// NewS3ServerConfig creates a new S3 Server Config,
+// based on a given string, using the following format:
+// `s3://[accessKey[:secretKey]@]host[:port]/bucket[/root/dir][?region=region]`.
+// The `s3+http://` scheme can be used instead, to use plain HTTP instead of HTTPS.
+// The credentials have to be URL-encoded.
+func NewS3ServerConfig(data string) (cfg S3ServerConfig, err error) {
+	u, err := url.Parse(data)
+	if err != nil {
+		err = errors.Wrapf(err, "'%s' is not a valid S3 URL", data)
+		return
+	}
+	switch u.Scheme {
+	case s3Scheme:
+	case s3InsecureScheme:
+		cfg.Insecure = true
+	default:
+		err = errors.Newf("'%s' is not a valid S3 URL", data)
+		return
+	}
+
+	// set credentials
+	if u.User != nil {
+		cfg.AccessKey = u.User.Username()
+		cfg.SecretKey, _ = u.User.Password()
+	}
+
+	// set address, bucket and (optional) root dir
+	cfg.Address = u.Host
+	parts := strings.SplitN(strings.TrimPrefix(u.Path, "/"), "/", 2)
+	cfg.Bucket = parts[0]
+	if len(parts) == 2 && parts[1] != "" {
+		cfg.RootDir = "/" + parts[1]
+	}
+
+	cfg.Region = u.Query().Get("region")
+
+	// all info was set, ensure to validate it while we're at it
+	err = cfg.validate()
+	return
+}
+
+// S3ServerConfig is used to configure and create an S3 (Storage) Driver,
+// which can be used for any S3 compatible object storage.
+type S3ServerConfig struct {
+	// Address (host[:port]) of the S3 (compatible) server
+	Address string
+	// Bucket in which the backup(s) are stored,
+	// it is created if it doesn't exist yet
+	Bucket string
+
+	// Optional: access key of Authorized account,
+	//           requests are anonymous if not given
+	AccessKey string
+	// Optional: secret key of Authorized account
+	SecretKey string
+
+	// Optional: region of the bucket,
+	//           defaults to us-east-1
+	Region string
+
+	// Optional: use plain HTTP instead of HTTPS
+	Insecure bool
+
+	RootDir string // optional
+}
+
+// String implements Stringer.String
+func (cfg *S3ServerConfig) String() string {
+	if cfg.validate() != nil {
+		return "" // invalid config
+	}
+
+	u := url.URL{
+		Scheme: s3Scheme,
+		Host:   cfg.Address,
+		Path:   "/" + cfg.Bucket + cfg.RootDir,
+	}
+	if cfg.Insecure {
+		u.Scheme = s3InsecureScheme
+	}
+	if cfg.AccessKey != "" {
+		if cfg.SecretKey != "" {
+			u.User = url.UserPassword(cfg.AccessKey, cfg.SecretKey)
+		} else {
+			u.User = url.User(cfg.AccessKey)
+		}
+	}
+	if cfg.Region != "" {
+		u.RawQuery = url.Values{"region": {cfg.Region}}.Encode()
+	}
+
+	return u.String()
+}
+
+// Set implements flag.Value.Set
+func (cfg *S3ServerConfig) Set(str string) error {
+	var err error
+	*cfg, err = NewS3ServerConfig(str)
+	return err
+}
+
+// Type implements PFlag.Type
+func (cfg *S3ServerConfig) Type() string {
+	return "S3ServerConfig"
+}
+
+// validate the S3 Server Config.
+func (cfg *S3ServerConfig) validate() error {
+	if cfg == nil {
+		return nil
+	}
+
+	if cfg.Address == "" {
+		return errors.New("no S3 server address given")
+	}
+	if cfg.Bucket == "" {
+		return errors.New("no S3 bucket given")
+	}
+	if cfg.RootDir != "" && !strings.HasPrefix(cfg.RootDir, "/") {
+		return errors.Newf("invalid root dir given: %s", cfg.RootDir)
+	}
+
+	if cfg.SecretKey != "" && cfg.AccessKey == "" {
+		return errors.New("secret key given, while access key is missing")
+	}
+
+	return nil
+}
+
+// S3StorageDriver creates a driver which allows you
+// to read/write deduped blocks/map from/to an S3 (compatible) object storage.
+// The bucket is created, in case it doesn't exist yet.
+func S3StorageDriver(cfg S3StorageDriverConfig) (StorageDriver, error) {
+	err := cfg.ServerConfig.validate()
+	if err != nil {
+		return nil, err
+	}
+
+	transport := &http.Transport{
+		Proxy:               http.ProxyFromEnvironment,
+		MaxIdleConnsPerHost: 10,
+	}
+
+	// if a tls config is specified,
+	// create an actual TLS Config, and pass it onto the HTTP Transport
+	if cfg.TLSConfig != nil {
+		err = cfg.TLSConfig.validate()
+		if err != nil {
+			return nil, err
+		}
+		transport.TLSClientConfig, err = tlsClientAuth(*cfg.TLSConfig)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	client := &s3Client{
+		scheme:    "https",
+		host:      cfg.ServerConfig.Address,
+		bucket:    cfg.ServerConfig.Bucket,
+		region:    cfg.ServerConfig.Region,
+		accessKey: cfg.ServerConfig.AccessKey,
+		secretKey: cfg.ServerConfig.SecretKey,
+		client:    &http.Client{Transport: transport},
+	}
+	if cfg.ServerConfig.Insecure {
+		client.scheme = "http"
+	}
+	if client.region == "" {
+		client.region = s3DefaultRegion
+	}
+
+	// ensure the bucket exists
+	exists, err := client.HeadBucket()
+	if err != nil {
+		return nil, errors.Wrapf(err, "couldn't check S3 bucket %s", client.bucket)
+	}
+	if !exists {
+		err = client.PutBucket()
+		if err != nil {
+			return nil, errors.Wrapf(err, "couldn't create S3 bucket %s", client.bucket)
+		}
+	}
+
+	return &s3StorageDriver{
+		client:  client,
+		rootDir: strings.Trim(cfg.ServerConfig.RootDir, "/"),
+	}, nil
+}
+
+type s3StorageDriver struct {
+	client  *s3Client
+	rootDir string
+}
+
+// SetDedupedBlock implements ServerDriver.SetDedupedBlock
+func (s3 *s3StorageDriver) SetDedupedBlock(hash zerodisk.Hash, r io.Reader) error {
+	dir, file, ok := hashAsDirAndFile(hash)
+	if !ok {
+		return errInvalidHash
+	}
+
+	// only store a deduped block if it doesn't exist yet already
+	key := s3.key(dir, file)
+	exists, err := s3.client.HeadObject(key)
+	if err != nil || exists {
+		return err
+	}
+
+	content, err := ioutil.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	return s3.client.PutObject(key, content)
+}
+
+// SetHeader implements ServerDriver.SetHeader
+func (s3 *s3StorageDriver) SetHeader(id string, r io.Reader) error {
+	return s3.client.PutObjectMultipart(s3.key(backupDir, id), r)
+}
+
+// GetDedupedBlock implements ServerDriver.GetDedupedBlock
+func (s3 *s3StorageDriver) GetDedupedBlock(hash zerodisk.Hash, w io.Writer) error {
+	dir, file, ok := hashAsDirAndFile(hash)
+	if !ok {
+		return errInvalidHash
+	}
+
+	return s3.retrieve(s3.key(dir, file), w)
+}
+
+// GetHeader implements ServerDriver.GetHeader
+func (s3 *s3StorageDriver) GetHeader(id string, w io.Writer) error {
+	return s3.retrieve(s3.key(backupDir, id), w)
+}
+
+// DeleteDedupedBlock implements ServerDriver.DeleteDedupedBlock
+func (s3 *s3StorageDriver) DeleteDedupedBlock(hash zerodisk.Hash) error {
+	dir, file, ok := hashAsDirAndFile(hash)
+	if !ok {
+		return errInvalidHash
+	}
+
+	return s3.delete(s3.key(dir, file))
+}
+
+// DeleteHeader implements ServerDriver.DeleteHeader
+func (s3 *s3StorageDriver) DeleteHeader(id string) error {
+	return s3.delete(s3.key(backupDir, id))
+}
+
+// GetDedupedBlocks implements ServerDriver.GetDedupedBlocks
+func (s3 *s3StorageDriver) GetDedupedBlocks() ([]zerodisk.Hash, error) {
+	// list all objects at once, rather than one (pseudo) directory at a time,
+	// as an object storage has no actual directories
+	prefix := s3.key("")
+	keys, err := s3.client.ListObjects(prefix, "")
+	if err != nil {
+		return nil, errors.Wrapf(err, "couldn't list S3 backup objects (%s)", prefix)
+	}
+
+	var hashes []zerodisk.Hash
+	for _, key := range keys {
+		parts := strings.Split(strings.TrimPrefix(key, prefix), "/")
+		if len(parts) != 3 || !isHashDir(parts[0]) || !isHashDir(parts[1]) {
+			continue
+		}
+		hash, ok := dirAndFileAsHash(parts[0], parts[1], parts[2])
+		if ok {
+			hashes = append(hashes, hash)
+		}
+	}
+	return hashes, nil
+}
+
+// GetHeaders implements ServerDriver.GetHeaders
+func (s3 *s3StorageDriver) GetHeaders() (ids []string, err error) {
+	prefix := s3.key(backupDir, "")
+	keys, err := s3.client.ListObjects(prefix, "/")
+	if err != nil {
+		return nil, errors.Wrapf(err, "couldn't list S3 backup objects (%s)", prefix)
+	}
+
+	for _, key := range keys {
+		if id := strings.TrimPrefix(key, prefix); id != "" {
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
+
+// Close implements ServerDriver.Close
+func (s3 *s3StorageDriver) Close() error {
+	if transport, ok := s3.client.client.Transport.(*http.Transport); ok {
+		transport.CloseIdleConnections()
+	}
+	return nil
+}
+
+// key returns the (object) key for the given path elements,
+// relative to the root dir of this driver.
+// A trailing slash is kept, such that the key can be used as a prefix.
+func (s3 *s3StorageDriver) key(elem ...string) string {
+	key := path.Join(append([]string{s3.rootDir}, elem...)...)
+	if elem[len(elem)-1] == "" && key != "" {
+		key += "/"
+	}
+	return key
+}
+
+// retrieve an object from an S3 server.
+// returns ErrDataDidNotExist in case there was no object available for the given key.
+func (s3 *s3StorageDriver) retrieve(key string, w io.Writer) error {
+	err := s3.client.GetObject(key, w)
+	if isS3NotFound(err) && err.(*s3Error).Code != "NoSuchBucket" {
+		return ErrDataDidNotExist
+	}
+	return err
+}
+
+// delete an object from an S3 server.
+// returns ErrDataDidNotExist in case there was no object for the given key.
+func (s3 *s3StorageDriver) delete(key string) error {
+	// S3 doesn't report whether or not the deleted object existed
+	exists, err := s3.client.HeadObject(key)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return ErrDataDidNotExist
+	}
+	return s3.client.DeleteObject(key)
+}
+
+const (
+	s3Scheme         = "s3"
+	s3InsecureScheme = "s3+http"
+)
@@ -0,0 +1,434 @@
+package backup
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/zero-os/0-Disk/errors"
+	"github.com/zero-os/0-Disk/log"
+)
+
+// s3Client is a minimal client for S3 (compatible) object storages,
+// supporting only the operations required by the S3 storage driver.
+// All requests use path-style addressing,
+// and are signed using AWS Signature Version 4,
+// unless no access key is given, in which case the requests are anonymous.
+type s3Client struct {
+	scheme    string
+	host      string
+	bucket    string
+	region    string
+	accessKey string
+	secretKey string
+
+	client *http.Client
+}
+
+// HeadBucket returns false in case the bucket doesn't exist.
+func (c *s3Client) HeadBucket() (bool, error) {
+	resp, err := c.do(http.MethodHead, "", nil, nil)
+	if err != nil {
+		if isS3NotFound(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	resp.Body.Close()
+	return true, nil
+}
+
+// PutBucket creates the bucket, in the region of this client.
+func (c *s3Client) PutBucket() error {
+	var body []byte
+	if c.region != s3DefaultRegion {
+		var err error
+		body, err = xml.Marshal(s3CreateBucketConfiguration{LocationConstraint: c.region})
+		if err != nil {
+			return err
+		}
+	}
+	return c.discard(c.do(http.MethodPut, "", nil, body))
+}
+
+// HeadObject returns false in case the object doesn't exist.
+func (c *s3Client) HeadObject(key string) (bool, error) {
+	resp, err := c.do(http.MethodHead, key, nil, nil)
+	if err != nil {
+		if isS3NotFound(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	resp.Body.Close()
+	return true, nil
+}
+
+// PutObject stores an object in a single request.
+func (c *s3Client) PutObject(key string, content []byte) error {
+	return c.discard(c.do(http.MethodPut, key, nil, content))
+}
+
+// GetObject writes the content of an object to the given writer.
+func (c *s3Client) GetObject(key string, w io.Writer) error {
+	resp, err := c.do(http.MethodGet, key, nil, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, err = io.Copy(w, resp.Body)
+	return err
+}
+
+// DeleteObject deletes an object.
+func (c *s3Client) DeleteObject(key string) error {
+	return c.discard(c.do(http.MethodDelete, key, nil, nil))
+}
+
+// ListObjects lists the keys of all objects which start with the given prefix.
+// When a delimiter is given, only the keys which don't contain that delimiter
+// after the prefix are returned.
+func (c *s3Client) ListObjects(prefix, delimiter string) ([]string, error) {
+	var keys []string
+	var token string
+	for {
+		query := url.Values{"list-type": {"2"}, "prefix": {prefix}}
+		if delimiter != "" {
+			query.Set("delimiter", delimiter)
+		}
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+
+		var result s3ListBucketResult
+		err := c.decode(c.do(http.MethodGet, "", query, nil))(&result)
+		if err != nil {
+			return nil, err
+		}
+		for _, content := range result.Contents {
+			keys = append(keys, content.Key)
+		}
+
+		if !result.IsTruncated {
+			return keys, nil
+		}
+		token = result.NextContinuationToken
+		if token == "" {
+			return nil, errors.New("S3 list result is truncated, but has no continuation token")
+		}
+	}
+}
+
+// PutObjectMultipart stores an object using a multipart upload,
+// reading and uploading one part of (at most) s3PartSize bytes at a time.
+// The upload is aborted in case it couldn't be completed.
+func (c *s3Client) PutObjectMultipart(key string, r io.Reader) error {
+	var initiated s3InitiateMultipartUploadResult
+	err := c.decode(c.do(http.MethodPost, key, url.Values{"uploads": {""}}, nil))(&initiated)
+	if err != nil {
+		return err
+	}
+	uploadID := initiated.UploadID
+
+	err = c.uploadParts(key, uploadID, r)
+	if err != nil {
+		abortErr := c.discard(c.do(http.MethodDelete, key, url.Values{"uploadId": {uploadID}}, nil))
+		if abortErr != nil {
+			log.Errorf("couldn't abort multipart upload %s of S3 object %s: %v", uploadID, key, abortErr)
+		}
+		return err
+	}
+	return nil
+}
+
+func (c *s3Client) uploadParts(key, uploadID string, r io.Reader) error {
+	var complete s3CompleteMultipartUpload
+	buf := make([]byte, s3PartSize)
+	for partNumber := 1; ; partNumber++ {
+		n, err := io.ReadFull(r, buf)
+		if err == io.EOF && partNumber > 1 {
+			break // all parts have been uploaded
+		}
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			return err
+		}
+
+		query := url.Values{
+			"partNumber": {strconv.Itoa(partNumber)},
+			"uploadId":   {uploadID},
+		}
+		resp, err := c.do(http.MethodPut, key, query, buf[:n])
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		complete.Parts = append(complete.Parts, s3CompletedPart{
+			PartNumber: partNumber,
+			ETag:       resp.Header.Get("ETag"),
+		})
+
+		if n < len(buf) {
+			break // last part has been uploaded
+		}
+	}
+
+	body, err := xml.Marshal(complete)
+	if err != nil {
+		return err
+	}
+	// the completion can fail even if a 200 status code is returned,
+	// in which case the response body contains an error
+	var result s3CompleteMultipartUploadResult
+	err = c.decode(c.do(http.MethodPost, key, url.Values{"uploadId": {uploadID}}, body))(&result)
+	if err != nil {
+		return err
+	}
+	if result.XMLName.Local == "Error" {
+		return &s3Error{StatusCode: http.StatusOK, Code: result.Code, Message: result.Message}
+	}
+	return nil
+}
+
+// do sends a signed request for the given (object) key,
+// and returns the response if it has a 2xx status code.
+// An empty key means that the request is for the bucket itself.
+func (c *s3Client) do(method, key string, query url.Values, body []byte) (*http.Response, error) {
+	p := "/" + c.bucket
+	if key != "" {
+		p += "/" + key
+	}
+
+	req, err := http.NewRequest(method, "", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.URL = &url.URL{
+		Scheme:   c.scheme,
+		Host:     c.host,
+		Path:     p,
+		RawPath:  s3URIEncode(p, false),
+		RawQuery: s3CanonicalQuery(query),
+	}
+	req.Host = c.host
+	req.ContentLength = int64(len(body))
+	if len(body) == 0 {
+		// ensure no body is sent, not even an empty one
+		req.Body = nil
+	}
+	c.sign(req, body, time.Now())
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return resp, nil
+	}
+
+	defer resp.Body.Close()
+	s3Err := &s3Error{StatusCode: resp.StatusCode}
+	if method != http.MethodHead {
+		// a HEAD response never has a body, other responses might have one
+		data, _ := ioutil.ReadAll(resp.Body)
+		xml.Unmarshal(data, s3Err)
+	}
+	return nil, s3Err
+}
+
+// discard the body of a response, returning only the error.
+func (c *s3Client) discard(resp *http.Response, err error) error {
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// decode the XML body of a response into a given value.
+func (c *s3Client) decode(resp *http.Response, err error) func(v interface{}) error {
+	return func(v interface{}) error {
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		return xml.NewDecoder(resp.Body).Decode(v)
+	}
+}
+
+// sign a request using AWS Signature Version 4,
+// see: http://docs.aws.amazon.com/AmazonS3/latest/API/sig-v4-header-based-auth.html
+func (c *s3Client) sign(req *http.Request, body []byte, t time.Time) {
+	payloadHash := sha256.Sum256(body)
+	amzDate := t.UTC().Format(s3DateTimeFormat)
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", hex.EncodeToString(payloadHash[:]))
+	if c.accessKey == "" {
+		return // anonymous request
+	}
+
+	// collect all headers to sign
+	headers := map[string]string{"host": req.Host}
+	for key, values := range req.Header {
+		headers[strings.ToLower(key)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders bytes.Buffer
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		canonicalHeaders.String(),
+		signedHeaders,
+		req.Header.Get("X-Amz-Content-Sha256"),
+	}, "\n")
+	canonicalRequestHash := sha256.Sum256([]byte(canonicalRequest))
+
+	date := amzDate[:8]
+	scope := date + "/" + c.region + "/s3/aws4_request"
+	stringToSign := strings.Join([]string{
+		s3SignatureAlgorithm,
+		amzDate,
+		scope,
+		hex.EncodeToString(canonicalRequestHash[:]),
+	}, "\n")
+
+	key := []byte("AWS4" + c.secretKey)
+	for _, part := range []string{date, c.region, "s3", "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf(
+		"%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s3SignatureAlgorithm, c.accessKey, scope, signedHeaders, signature))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// s3CanonicalQuery encodes the given query in its canonical form,
+// which is sorted by key, and of which the keys and values are URI encoded.
+func s3CanonicalQuery(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var pairs []string
+	for _, key := range keys {
+		values := append([]string(nil), query[key]...)
+		sort.Strings(values)
+		for _, value := range values {
+			pairs = append(pairs, s3URIEncode(key, true)+"="+s3URIEncode(value, true))
+		}
+	}
+	return strings.Join(pairs, "&")
+}
+
+// s3URIEncode encodes all characters of a string,
+// except for the unreserved characters, as defined by RFC 3986.
+// A slash is only encoded if encodeSlash is true.
+func s3URIEncode(str string, encodeSlash bool) string {
+	var buf bytes.Buffer
+	for i := 0; i < len(str); i++ {
+		c := str[i]
+		if (c >= 'A' && c <= 'Z') || (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9') ||
+			c == '-' || c == '_' || c == '.' || c == '~' || (c == '/' && !encodeSlash) {
+			buf.WriteByte(c)
+			continue
+		}
+		fmt.Fprintf(&buf, "%%%02X", c)
+	}
+	return buf.String()
+}
+
+// s3Error is an error returned by an S3 (compatible) server.
+type s3Error struct {
+	StatusCode int
+	Code       string `xml:"Code"`
+	Message    string `xml:"Message"`
+}
+
+// Error implements error.Error
+func (err *s3Error) Error() string {
+	if err.Code == "" {
+		return fmt.Sprintf("S3 error: %s", http.StatusText(err.StatusCode))
+	}
+	return fmt.Sprintf("S3 error (%s): %s", err.Code, err.Message)
+}
+
+// isS3NotFound returns true if the given error
+// indicates that the requested bucket or object doesn't exist.
+func isS3NotFound(err error) bool {
+	s3Err, ok := err.(*s3Error)
+	return ok && s3Err.StatusCode == http.StatusNotFound
+}
+
+// XML types used as part of the S3 API.
+
+type s3CreateBucketConfiguration struct {
+	XMLName            xml.Name `xml:"CreateBucketConfiguration"`
+	LocationConstraint string   `xml:"LocationConstraint"`
+}
+
+type s3ListBucketResult struct {
+	Contents []struct {
+		Key string `xml:"Key"`
+	} `xml:"Contents"`
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+}
+
+type s3InitiateMultipartUploadResult struct {
+	UploadID string `xml:"UploadId"`
+}
+
+type s3CompleteMultipartUpload struct {
+	XMLName xml.Name          `xml:"CompleteMultipartUpload"`
+	Parts   []s3CompletedPart `xml:"Part"`
+}
+
+type s3CompletedPart struct {
+	PartNumber int    `xml:"PartNumber"`
+	ETag       string `xml:"ETag"`
+}
+
+type s3CompleteMultipartUploadResult struct {
+	XMLName xml.Name
+	Code    string `xml:"Code"`
+	Message string `xml:"Message"`
+}
+
+const (
+	s3DefaultRegion      = "us-east-1"
+	s3SignatureAlgorithm = "AWS4-HMAC-SHA256"
+	s3DateTimeFormat     = "20060102T150405Z"
+	// size of all parts of a multipart upload, except the last one,
+	// which is also the minimum size allowed by S3
+	s3PartSize = 5 * 1024 * 1024
+)
@@ -89,6 +89,41 @@ func (stub *stubDriver) GetHeader(id string, w io.Writer) error {
 	return nil
 }
 
+// DeleteDedupedBlock implements StorageDriver.DeleteDedupedBlock
+func (stub *stubDriver) DeleteDedupedBlock(hash zerodisk.Hash) error {
+	stub.bmux.Lock()
+	defer stub.bmux.Unlock()
+
+	if _, ok := stub.dedupedBlocks[string(hash)]; !ok {
+		return ErrDataDidNotExist
+	}
+	delete(stub.dedupedBlocks, string(hash))
+	return nil
+}
+
+// DeleteHeader implements StorageDriver.DeleteHeader
+func (stub *stubDriver) DeleteHeader(id string) error {
+	stub.mmux.Lock()
+	defer stub.mmux.Unlock()
+
+	if _, ok := stub.headers[id]; !ok {
+		return ErrDataDidNotExist
+	}
+	delete(stub.headers, id)
+	return nil
+}
+
+// GetDedupedBlocks implements StorageDriver.GetDedupedBlocks
+func (stub *stubDriver) GetDedupedBlocks() (hashes []zerodisk.Hash, err error) {
+	stub.bmux.RLock()
+	defer stub.bmux.RUnlock()
+
+	for hash := range stub.dedupedBlocks {
+		hashes = append(hashes, zerodisk.Hash(hash))
+	}
+	return hashes, nil
+}
+
 // GetHeaders implements StorageDriver.GetHeaders
 func (stub *stubDriver) GetHeaders() (ids []string, err error) {
 	stub.mmux.RLock()
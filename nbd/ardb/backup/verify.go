@@ -0,0 +1,262 @@
+package backup
+
+import (
+	"bytes"
+	"context"
+	"runtime"
+	"sort"
+	"sync"
+
+	"github.com/zero-os/0-Disk"
+	"github.com/zero-os/0-Disk/compress"
+	"github.com/zero-os/0-Disk/errors"
+	"github.com/zero-os/0-Disk/log"
+)
+
+// VerifyConfig is the configuration used to verify a snapshot,
+// see `Verify` for more information.
+type VerifyConfig struct {
+	// Required: SnapshotID of the snapshot to verify
+	SnapshotID string
+
+	// Optional: configuration of the (backup) storage driver,
+	// see `NewStorageDriver` for more information
+	BackupStoragDriverConfig interface{}
+
+	// Optional: Amount of jobs (goroutines) to run simultaneously
+	// (to fetch and verify the deduped blocks in parallel)
+	// defaults to the amount of CPUs available
+	JobCount int
+
+	// Compression type used to compress the snapshot
+	CompressionType compress.CompressionType
+	// Optional: the key used to encrypt the snapshot
+	CryptoKey CryptoKey
+}
+
+// validate the verify config,
+// and fill-in all the missing optional data.
+func (cfg *VerifyConfig) validate() error {
+	if cfg.SnapshotID == "" {
+		return errNilSnapshotID
+	}
+	if cfg.JobCount <= 0 {
+		cfg.JobCount = runtime.NumCPU()
+	}
+	return cfg.CompressionType.Validate()
+}
+
+// VerifyResult is the result of a snapshot verification,
+// see `Verify` for more information.
+type VerifyResult struct {
+	// size of the blocks of the snapshot
+	BlockSize int64
+	// amount of block indices mapped by the snapshot
+	Blocks int64
+	// amount of unique deduped blocks verified
+	DedupedBlocks int64
+
+	// deduped blocks which couldn't be found in the (backup) storage,
+	// sorted by the first block index which references them
+	MissingBlocks []InvalidDedupedBlock
+	// deduped blocks which couldn't be decrypted or decompressed,
+	// or whose content doesn't match their hash,
+	// sorted by the first block index which references them
+	CorruptBlocks []InvalidDedupedBlock
+}
+
+// Valid returns true if no missing or corrupt deduped blocks were found.
+func (r *VerifyResult) Valid() bool {
+	return len(r.MissingBlocks) == 0 && len(r.CorruptBlocks) == 0
+}
+
+// InvalidDedupedBlock is a deduped block,
+// which is either missing or corrupt.
+type InvalidDedupedBlock struct {
+	Hash zerodisk.Hash
+	// (sorted) block indices of the snapshot referencing this deduped block
+	Indices []int64
+	// reason why this deduped block is invalid
+	Err error
+}
+
+// Offsets returns the (sorted) vdisk offsets (in bytes) of the data
+// affected by this deduped block, given the block size of the snapshot.
+func (block *InvalidDedupedBlock) Offsets(blockSize int64) []int64 {
+	offsets := make([]int64, len(block.Indices))
+	for i, index := range block.Indices {
+		offsets[i] = index * blockSize
+	}
+	return offsets
+}
+
+// Verify the integrity of a snapshot, without importing it.
+//
+// The header of the snapshot is loaded, after which every deduped block
+// referenced by its deduped map is fetched from the (backup) storage,
+// decrypted (if needed) and decompressed, and its (keyed) hash is recomputed
+// and compared to the hash it's mapped to.
+// Missing and corrupt deduped blocks are reported as part of the result,
+// while any other error aborts the verification.
+func Verify(ctx context.Context, cfg VerifyConfig) (*VerifyResult, error) {
+	err := cfg.validate()
+	if err != nil {
+		return nil, err
+	}
+
+	storageDriver, err := NewStorageDriver(cfg.BackupStoragDriverConfig)
+	if err != nil {
+		return nil, err
+	}
+	defer storageDriver.Close()
+
+	return verifySnapshot(ctx, storageDriver, cfg)
+}
+
+func verifySnapshot(ctx context.Context, src StorageDriver, cfg VerifyConfig) (*VerifyResult, error) {
+	header, err := LoadHeader(cfg.SnapshotID, src, &cfg.CryptoKey, cfg.CompressionType)
+	if err != nil {
+		if errors.Cause(err) == ErrDataDidNotExist {
+			return nil, errors.Wrapf(err, "no deduped map could be found using the id %s", cfg.SnapshotID)
+		}
+		return nil, err
+	}
+
+	// group all block indices by the deduped block they reference,
+	// such that each deduped block only has to be verified once
+	var hashes []zerodisk.Hash
+	indices := make(map[string][]int64)
+	for i, index := range header.DedupedMap.Indices {
+		hash := header.DedupedMap.Hashes[i]
+		if _, ok := indices[string(hash)]; !ok {
+			hashes = append(hashes, zerodisk.Hash(hash))
+		}
+		indices[string(hash)] = append(indices[string(hash)], index)
+	}
+
+	result := &VerifyResult{
+		BlockSize:     header.Metadata.BlockSize,
+		Blocks:        int64(len(header.DedupedMap.Indices)),
+		DedupedBlocks: int64(len(hashes)),
+	}
+
+	// setup the context that we'll use for all worker goroutines
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var mux sync.Mutex
+	var verifyErr error
+
+	sendErr := func(err error) {
+		log.Errorf("an error occured while verifying: %v", err)
+		mux.Lock()
+		if verifyErr == nil {
+			verifyErr = err
+		}
+		mux.Unlock()
+		cancel() // stop all other goroutines
+	}
+	report := func(blocks *[]InvalidDedupedBlock, hash zerodisk.Hash, err error) {
+		blockIndices := append([]int64(nil), indices[string(hash)]...)
+		sortInt64s(blockIndices)
+		mux.Lock()
+		*blocks = append(*blocks, InvalidDedupedBlock{
+			Hash:    hash,
+			Indices: blockIndices,
+			Err:     err,
+		})
+		mux.Unlock()
+	}
+
+	hashCh := make(chan zerodisk.Hash, cfg.JobCount)
+
+	// launch all workers
+	var wg sync.WaitGroup
+	wg.Add(cfg.JobCount)
+	for i := 0; i < cfg.JobCount; i++ {
+		pipeline, err := newImportPipeline(src, &cfg.CryptoKey, cfg.CompressionType)
+		if err != nil {
+			return nil, err
+		}
+
+		go func(id int) {
+			defer wg.Done()
+
+			log.Debugf("starting verify worker #%d", id)
+			defer log.Debugf("stopping verify worker #%d", id)
+
+			var hash zerodisk.Hash
+			var open bool
+
+			for {
+				select {
+				case <-ctx.Done():
+					return
+
+				case hash, open = <-hashCh:
+					if !open {
+						return
+					}
+
+					buf := bytes.NewBuffer(nil)
+					err := src.GetDedupedBlock(hash, buf)
+					if err != nil {
+						if errors.Cause(err) != ErrDataDidNotExist {
+							sendErr(err)
+							return
+						}
+						log.Debugf("deduped block %x is missing", hash)
+						report(&result.MissingBlocks, hash, err)
+						continue
+					}
+
+					// decrypt, decompress and validate the hash of the block
+					_, err = pipeline.DecodeBlock(indices[string(hash)][0], hash, buf)
+					if err != nil {
+						log.Debugf("deduped block %x is corrupt: %v", hash, err)
+						report(&result.CorruptBlocks, hash, err)
+					}
+				}
+			}
+		}(i)
+	}
+
+	// send all hashes to the workers
+	func() {
+		defer close(hashCh)
+		for _, hash := range hashes {
+			select {
+			case <-ctx.Done():
+				return
+			case hashCh <- hash:
+			}
+		}
+	}()
+
+	// wait until all deduped blocks have been verified
+	wg.Wait()
+
+	// if an error occured, return it
+	if verifyErr != nil {
+		return nil, verifyErr
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	sortInvalidDedupedBlocks(result.MissingBlocks)
+	sortInvalidDedupedBlocks(result.CorruptBlocks)
+	return result, nil
+}
+
+func sortInt64s(s []int64) {
+	sort.Slice(s, func(i, j int) bool {
+		return s[i] < s[j]
+	})
+}
+
+func sortInvalidDedupedBlocks(blocks []InvalidDedupedBlock) {
+	sort.Slice(blocks, func(i, j int) bool {
+		return blocks[i].Indices[0] < blocks[j].Indices[0]
+	})
+}
@@ -0,0 +1,101 @@
+package backup
+
+import (
+	"context"
+	"crypto/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zero-os/0-Disk"
+	"github.com/zero-os/0-Disk/compress"
+	"github.com/zero-os/0-Disk/nbd/ardb/storage"
+)
+
+func TestVerifySnapshot(t *testing.T) {
+	require := require.New(t)
+	assert := assert.New(t)
+
+	const (
+		blockSize  = 4096
+		blockCount = 8
+	)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// create a vdisk, where the first and fifth block have the same content
+	src := storage.NewInMemoryStorage("foo", blockSize)
+	defer src.Close()
+	var indices []int64
+	for index := int64(0); index < blockCount; index++ {
+		block := make([]byte, blockSize)
+		_, err := rand.Read(block)
+		require.NoError(err)
+		require.NoError(src.SetBlock(index, block))
+		indices = append(indices, index)
+	}
+	block, err := src.GetBlock(0)
+	require.NoError(err)
+	require.NoError(src.SetBlock(4, block))
+
+	driver := newStubDriver()
+	require.NoError(exportBS(ctx, src, indices, driver, exportConfig{
+		JobCount:        2,
+		SrcBlockSize:    blockSize,
+		DstBlockSize:    blockSize,
+		CompressionType: compress.LZ4Compression,
+		CryptoKey:       privKey,
+		SnapshotID:      "a",
+	}))
+
+	cfg := VerifyConfig{
+		SnapshotID:      "a",
+		JobCount:        2,
+		CompressionType: compress.LZ4Compression,
+		CryptoKey:       privKey,
+	}
+
+	// an exported snapshot is valid
+	result, err := verifySnapshot(ctx, driver, cfg)
+	require.NoError(err)
+	assert.True(result.Valid())
+	assert.Equal(int64(blockSize), result.BlockSize)
+	assert.Equal(int64(blockCount), result.Blocks)
+	assert.Equal(int64(blockCount-1), result.DedupedBlocks)
+
+	// delete the deduped block shared by the first and fifth block,
+	// and corrupt the deduped block of the third block
+	header, err := LoadHeader("a", driver, &privKey, compress.LZ4Compression)
+	require.NoError(err)
+	hashes := make(map[int64]zerodisk.Hash)
+	for i, index := range header.DedupedMap.Indices {
+		hashes[index] = zerodisk.Hash(header.DedupedMap.Hashes[i])
+	}
+	require.NoError(driver.DeleteDedupedBlock(hashes[0]))
+	driver.dedupedBlocks[string(hashes[2])] = []byte("foo")
+
+	result, err = verifySnapshot(ctx, driver, cfg)
+	require.NoError(err)
+	assert.False(result.Valid())
+	assert.Equal(int64(blockCount-1), result.DedupedBlocks)
+	if assert.Len(result.MissingBlocks, 1) {
+		missing := result.MissingBlocks[0]
+		assert.Equal(hashes[0], missing.Hash)
+		assert.Equal([]int64{0, 4}, missing.Indices)
+		assert.Equal([]int64{0, 4 * blockSize}, missing.Offsets(result.BlockSize))
+		assert.Equal(ErrDataDidNotExist, missing.Err)
+	}
+	if assert.Len(result.CorruptBlocks, 1) {
+		corrupt := result.CorruptBlocks[0]
+		assert.Equal(hashes[2], corrupt.Hash)
+		assert.Equal([]int64{2}, corrupt.Indices)
+		assert.Equal([]int64{2 * blockSize}, corrupt.Offsets(result.BlockSize))
+		assert.Error(corrupt.Err)
+	}
+
+	// an unknown snapshot can't be verified
+	cfg.SnapshotID = "b"
+	_, err = verifySnapshot(ctx, driver, cfg)
+	assert.Error(err)
+}
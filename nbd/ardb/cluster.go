@@ -231,7 +231,7 @@ func (cluster *Cluster) DoForAll(pairs []IndexActionPair) ([]interface{}, error)
 	case 0:
 		return nil, nil // nothing to do
 	case 1:
-		reply, err := cluster.DoFor(pairs[0].Index, pairs[1].Action)
+		reply, err := cluster.DoFor(pairs[0].Index, pairs[0].Action)
 		if err != nil {
 			return nil, err
 		}
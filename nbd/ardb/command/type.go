@@ -25,6 +25,9 @@ var (
 	// Get the value of a key.
 	Get = Type{"GET", false}
 
+	// GetBit returns the bit value at offset in the string value stored at key.
+	GetBit = Type{"GETBIT", false}
+
 	// Scan iterates the set of keys in the currently selected ARDB database.
 	Scan = Type{"SCAN", false}
 
@@ -111,6 +114,9 @@ var (
 	// Set the value of a key.
 	Set = Type{"SET", true}
 
+	// SetBit sets or clears the bit at offset in the string value stored at key.
+	SetBit = Type{"SETBIT", true}
+
 	// SetIntersect intersects multiple sets
 	SetIntersect = Type{"SINTER", false}
 
@@ -144,4 +150,7 @@ var (
 
 	// SetUnionStore adds multiple sets and stores the resulting set in a key.
 	SetUnionStore = Type{"SUNIONSTORE", false}
+
+	// StringLength gets the length of the value stored in a key.
+	StringLength = Type{"STRLEN", false}
 )
@@ -100,11 +100,17 @@ type ConnectionDialer interface {
 }
 
 // Dial a standard (TCP) connection using a given ARDB server config.
+// The latency of the commands applied on the returned connection are observed,
+// as the command duration metric of the given server.
 func Dial(cfg config.StorageServerConfig) (Conn, error) {
 	if cfg.State != config.StorageServerStateOnline {
 		return nil, ErrServerUnavailable
 	}
-	return redis.Dial("tcp", cfg.Address, redis.DialDatabase(cfg.Database))
+	conn, err := redis.Dial("tcp", cfg.Address, redis.DialDatabase(cfg.Database))
+	if err != nil {
+		return nil, err
+	}
+	return newInstrumentedConn(conn, cfg.Address), nil
 }
 
 // DialAll dials standard (TCP) connections for the given ARDB server configs.
@@ -0,0 +1,48 @@
+package ardb
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/zero-os/0-Disk/metrics"
+)
+
+var (
+	commandDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metrics.Namespace,
+		Subsystem: "ardb",
+		Name:      "command_duration_seconds",
+		Help:      "Latency of the commands (or pipelined commands) applied on an ARDB server.",
+		Buckets:   metrics.LatencyBuckets,
+	}, []string{"server"})
+)
+
+func init() {
+	prometheus.MustRegister(commandDuration)
+}
+
+// newInstrumentedConn wraps a connection to an ARDB server,
+// such that the latency of each command applied on it is observed.
+func newInstrumentedConn(conn Conn, address string) Conn {
+	return &instrumentedConn{
+		Conn:     conn,
+		duration: commandDuration.WithLabelValues(address),
+	}
+}
+
+// instrumentedConn is an ARDB connection,
+// which observes the latency of each command applied on it.
+// Commands which are sent (pipelined) are observed as a whole,
+// when their replies are received using `Do("")`.
+type instrumentedConn struct {
+	Conn
+	duration prometheus.Histogram
+}
+
+// Do implements Conn.Do
+func (conn *instrumentedConn) Do(commandName string, args ...interface{}) (interface{}, error) {
+	start := time.Now()
+	reply, err := conn.Conn.Do(commandName, args...)
+	conn.duration.Observe(time.Since(start).Seconds())
+	return reply, err
+}
@@ -82,9 +82,105 @@ func (cluster *Cluster) DoFor(objectIndex int64, action ardb.StorageAction) (rep
 
 // DoForAll implements StorageCluster.DoForAll
 func (cluster *Cluster) DoForAll(pairs []ardb.IndexActionPair) ([]interface{}, error) {
-	// [TODO] either remove `DoForAll` from the StorageCluster API,
-	//        or support it in this implementation.
-	panic("DoForAll is not supported for now")
+	// a shortcut in case we have received no pairs, or just a single one
+	switch len(pairs) {
+	case 0:
+		return nil, nil // nothing to do
+	case 1:
+		reply, err := cluster.DoFor(pairs[0].Index, pairs[0].Action)
+		if err != nil {
+			return nil, err
+		}
+		return []interface{}{reply}, nil
+	}
+
+	// sort all actions in terms of the server their object index maps to,
+	// such that they can be applied as a single pipeline per server,
+	// actions mapped to a server which is being repaired or respread are applied one by one,
+	// as the server their data is written to depends on their object index
+	type serverActions struct {
+		state   ServerState
+		actions ardb.IndexActionMap
+	}
+	servers := make(map[int64]*serverActions)
+	var repairActions ardb.IndexActionMap
+	for index, pair := range pairs {
+		state, err := cluster.controller.ServerStateFor(pair.Index)
+		if err != nil {
+			return nil, err
+		}
+		if state.repair != nil {
+			repairActions.Add(int64(index), pair.Action)
+			continue
+		}
+		server, ok := servers[state.Index]
+		if !ok {
+			server = &serverActions{state: state}
+			servers[state.Index] = server
+		}
+		server.actions.Add(int64(index), pair.Action)
+	}
+
+	replies := make([]interface{}, len(pairs))
+
+	// doOneByOne applies the given actions one by one,
+	// storing their replies in order
+	doOneByOne := func(m *ardb.IndexActionMap) error {
+		for i, action := range m.Actions {
+			replyIndex := m.Indices[i]
+			reply, err := cluster.DoFor(pairs[replyIndex].Index, action)
+			if err != nil {
+				return err
+			}
+			replies[replyIndex] = reply
+		}
+		return nil
+	}
+
+	// apply all actions async, each goroutine storing the replies of its own actions
+	var wg sync.WaitGroup
+	errs := make([]error, len(servers)+1)
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		errs[0] = doOneByOne(&repairActions)
+	}()
+
+	var serverErrIndex int
+	for _, server := range servers {
+		serverErrIndex++
+		wg.Add(1)
+		go func(server *serverActions, err *error) {
+			defer wg.Done()
+			serverReplies, applyErr := ardb.Values(cluster.applyAction(
+				&server.state, ardb.Commands(server.actions.Actions...)))
+			if applyErr == errActionNotApplied {
+				// the server was marked as offline,
+				// apply the actions to the servers they now map to instead
+				*err = doOneByOne(&server.actions)
+				return
+			}
+			if applyErr != nil {
+				*err = applyErr
+				return
+			}
+			// collect all received replies in order
+			for i, reply := range serverReplies {
+				replies[server.actions.Indices[i]] = reply
+			}
+		}(server, &errs[serverErrIndex])
+	}
+
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// return all replies from all servers in ordered form
+	return replies, nil
 }
 
 // ServerIterator implements StorageCluster.ServerIterator
@@ -129,19 +225,39 @@ func (cluster *Cluster) Close() error {
 // applyAction applies the storage action to the server
 // that can be dialer for the given action.
 func (cluster *Cluster) applyAction(state *ServerState, action ardb.StorageAction) (reply interface{}, err error) {
-	if state.Config.State != config.StorageServerStateOnline {
-		return nil, ardb.ErrServerUnavailable
-	}
+	// the server which is broadcasted in case the action couldn't be applied
+	failedServer, failedServerType := state.Config, state.Type
+
+	switch state.Config.State {
+	case config.StorageServerStateOnline:
+		reply, err = applyActionOn(cluster.pool, state.Config, action)
+		if err == nil || errors.Cause(err) == ardb.ErrNil {
+			return reply, err
+		}
 
-	// try to open connection,
-	// and apply the action to that connection if it could be dialed.
-	conn, err := cluster.pool.Dial(state.Config)
-	if err == nil {
-		defer conn.Close()
-		reply, err = action.Do(conn)
+	case config.StorageServerStateRepair, config.StorageServerStateRespread:
+		if state.repair == nil {
+			return nil, ardb.ErrServerUnavailable
+		}
+		if _, ok := action.KeysModified(); ok && state.repairTarget == nil {
+			// the primary server the modified data has to be written to is unknown,
+			// or the server is being repaired by another process,
+			// applying the action to the slave server only would lose that modification
+			return nil, ardb.ErrServerUnavailable
+		}
+		var slaveFailed bool
+		reply, slaveFailed, err = cluster.applyRepairAction(state, action)
 		if err == nil || errors.Cause(err) == ardb.ErrNil {
 			return reply, err
 		}
+		if slaveFailed {
+			failedServer, failedServerType = state.repair.source, log.ARDBSlaveServer
+		} else if state.repairTarget != nil {
+			failedServer = *state.repairTarget
+		}
+
+	default:
+		return nil, ardb.ErrServerUnavailable
 	}
 
 	// mark the server as offline,
@@ -154,9 +270,9 @@ func (cluster *Cluster) applyAction(state *ServerState, action ardb.StorageActio
 			status,
 			log.SubjectStorage,
 			log.ARDBServerTimeoutBody{
-				Address:  state.Config.Address,
-				Database: state.Config.Database,
-				Type:     state.Type,
+				Address:  failedServer.Address,
+				Database: failedServer.Database,
+				Type:     failedServerType,
 				VdiskID:  cluster.vdiskID,
 			},
 		)
@@ -165,6 +281,34 @@ func (cluster *Cluster) applyAction(state *ServerState, action ardb.StorageActio
 	return nil, errActionNotApplied
 }
 
+// applyRepairAction applies the storage action to a primary server which is being repaired or respread.
+// All actions are applied to the slave server the data is being copied from,
+// while actions which modify data are applied to the target primary server as well (if known),
+// such that no data is lost once the repair or respread is finished.
+// The returned boolean is true in case the action couldn't be applied on the slave server.
+func (cluster *Cluster) applyRepairAction(state *ServerState, action ardb.StorageAction) (reply interface{}, slaveFailed bool, err error) {
+	if _, ok := action.KeysModified(); !ok || state.repairTarget == nil {
+		reply, err = applyActionOn(cluster.pool, state.repair.source, action)
+		return reply, true, err
+	}
+
+	// ensure the action isn't applied while data is being copied
+	state.repair.mux.RLock()
+	defer state.repair.mux.RUnlock()
+
+	reply, err = applyActionOn(cluster.pool, state.repair.source, action)
+	if err != nil && errors.Cause(err) != ardb.ErrNil {
+		return nil, true, err
+	}
+
+	_, targetErr := applyActionOn(cluster.pool, *state.repairTarget, action)
+	if targetErr != nil && errors.Cause(targetErr) != ardb.ErrNil {
+		return nil, false, targetErr
+	}
+
+	return reply, false, err
+}
+
 // smartServer defines an ardb.StorageServer returned
 // by the default storage.Cluster, and applies a connection to
 // whatever server that functions first for the given server index.
@@ -235,6 +379,12 @@ type ServerState struct {
 	Config config.StorageServerConfig
 	// Type of the server: {primary, slave, template}
 	Type log.ARDBServerType
+
+	// only defined for a primary server which is being repaired or respread
+	repair *serverRepair
+	// primary server data-modifying actions are applied to as well,
+	// only defined for a primary server which is being repaired or respread
+	repairTarget *config.StorageServerConfig
 }
 
 // NewPrimaryCluster creates a new PrimaryCluster.
@@ -242,14 +392,49 @@ type ServerState struct {
 // This cluster type does support hot-swapping of 2 online servers (which share the same index),
 // meaning that prior to swapping, the data for this vdisk will be copied from the old to the new server.
 // See `Cluster` for more information.
+//
+// A primary server marked as `repair` (or `respread`) is expected to be repaired (or respread) by
+// the (nbdserver) process which uses a SelfHealingPrimaryCluster for this vdisk.
+// In the meantime actions which don't modify data are applied to the slave server with the same index,
+// while actions which do modify data can't be applied to that server.
 func NewPrimaryCluster(ctx context.Context, vdiskID string, cs config.Source) (*Cluster, error) {
+	return newPrimaryCluster(ctx, vdiskID, false, cs)
+}
+
+// NewSelfHealingPrimaryCluster creates a new SelfHealingPrimaryCluster.
+// This cluster type supports config hot-reloading, as well as self-healing of servers,
+// and should only be used by the one process which serves the vdisk,
+// as the data of a primary server can't be copied by multiple processes at once.
+// This cluster type does support hot-swapping of 2 online servers (which share the same index),
+// meaning that prior to swapping, the data for this vdisk will be copied from the old to the new server.
+// See `Cluster` for more information.
+//
+// A primary server marked as `repair` will have all its data for this vdisk
+// copied from the slave server with the same index, while it keeps serving I/O using that slave server.
+// Once the repair is finished, the primary server is marked as `online` once again.
+//
+// A primary server marked as `respread` will have all its data for this vdisk
+// copied from the slave server with the same index to the other primary servers,
+// while it keeps serving I/O using that slave server.
+// Once the respread is finished, the primary server is marked as `rip`.
+//
+// A primary server marked as `offline` will have all actions applied
+// to the slave server with the same index instead, as long as that slave server is `online`.
+func NewSelfHealingPrimaryCluster(ctx context.Context, vdiskID string, cs config.Source) (*Cluster, error) {
+	return newPrimaryCluster(ctx, vdiskID, true, cs)
+}
+
+// newPrimaryCluster creates a new (self-healing) PrimaryCluster.
+func newPrimaryCluster(ctx context.Context, vdiskID string, selfHealing bool, cs config.Source) (*Cluster, error) {
 	controller := &singleClusterStateController{
-		vdiskID:       vdiskID,
-		optional:      false,
-		copyOnHotSwap: true,
-		configSource:  cs,
-		serverType:    log.ARDBPrimaryServer,
-		getClusterID:  getPrimaryClusterID,
+		vdiskID:           vdiskID,
+		optional:          false,
+		selfHealing:       selfHealing,
+		copyOnHotSwap:     true,
+		configSource:      cs,
+		serverType:        log.ARDBPrimaryServer,
+		getClusterID:      getPrimaryClusterID,
+		getSlaveClusterID: getSlaveClusterID,
 	}
 	err := controller.spawnConfigReloader(ctx, cs)
 	if err != nil {
@@ -316,6 +501,10 @@ type singleClusterStateController struct {
 	// otherwise this will be tracked as an error.
 	optional bool
 
+	// when true, primary servers are repaired by this controller,
+	// and offline primary servers are replaced by their slave servers.
+	selfHealing bool
+
 	// an optioan bool,
 	// when enabled data will be copied from the old to the new server,
 	// iff the new sever replaces the old server AND both are in state `online`
@@ -328,11 +517,18 @@ type singleClusterStateController struct {
 	servers     []config.StorageServerConfig
 	serverCount int64
 
+	// only used by a primary cluster,
+	// in which case it is used to repair primary servers
+	slaveServers []config.StorageServerConfig
+	repairs      map[int64]*serverRepair
+
 	mux sync.RWMutex
 
+	ctx    context.Context
 	cancel context.CancelFunc
 
-	getClusterID func(cfg config.VdiskNBDConfig) string
+	getClusterID      func(cfg config.VdiskNBDConfig) string
+	getSlaveClusterID func(cfg config.VdiskNBDConfig) string
 }
 
 // ServerState implements ClusterStateController.ServerState
@@ -352,6 +548,10 @@ func (ctrl *singleClusterStateController) ServerState() (state ServerState, err
 
 	state.Config = ctrl.servers[state.Index]
 	state.Type = ctrl.serverType
+	err = ctrl.setRepairState(&state, func(predicate ardb.ServerIndexPredicate) (int64, error) {
+		return ardb.FindFirstServerIndex(ctrl.serverCount, predicate)
+	})
+	ctrl.setFailoverState(&state)
 	return
 }
 
@@ -372,6 +572,10 @@ func (ctrl *singleClusterStateController) ServerStateFor(objectIndex int64) (sta
 
 	state.Config = ctrl.servers[state.Index]
 	state.Type = ctrl.serverType
+	err = ctrl.setRepairState(&state, func(predicate ardb.ServerIndexPredicate) (int64, error) {
+		return ardb.ComputeServerIndex(ctrl.serverCount, objectIndex, predicate)
+	})
+	ctrl.setFailoverState(&state)
 	return
 }
 
@@ -392,9 +596,101 @@ func (ctrl *singleClusterStateController) ServerStateAt(serverIndex int64) (stat
 	state.Index = serverIndex
 	state.Config = ctrl.servers[state.Index]
 	state.Type = ctrl.serverType
+	// the data stored on a server which is being respread can't be linked
+	// to a single target server, hence data-modifying actions
+	// can't be applied to that server until the respread is finished
+	err = ctrl.setRepairState(&state, nil)
+	ctrl.setFailoverState(&state)
 	return
 }
 
+// setFailoverState replaces the state of an offline primary server,
+// with the state of the slave server at the same index, if that slave server is online.
+// Doing so all actions are applied to that slave server instead.
+func (ctrl *singleClusterStateController) setFailoverState(state *ServerState) {
+	slave, ok := ctrl.failoverServer(state.Index)
+	if !ok {
+		return
+	}
+	state.Config = slave
+	state.Type = log.ARDBSlaveServer
+}
+
+// failoverServer returns the slave server which can be used,
+// in place of the (offline) primary server at the given index.
+func (ctrl *singleClusterStateController) failoverServer(index int64) (config.StorageServerConfig, bool) {
+	if !ctrl.selfHealing || ctrl.servers[index].State != config.StorageServerStateOffline {
+		return config.StorageServerConfig{}, false
+	}
+	if int64(len(ctrl.slaveServers)) != ctrl.serverCount {
+		return config.StorageServerConfig{}, false
+	}
+	slave := ctrl.slaveServers[index]
+	if slave.State != config.StorageServerStateOnline {
+		return config.StorageServerConfig{}, false
+	}
+	return slave, true
+}
+
+// setRepairState defines the repair state of a server which is being repaired or respread.
+// In case of a respread the given function is used to compute the index of the primary server,
+// data-modifying actions have to be applied to as well, using a predicate which excludes the respread server.
+// When no function is given, no target server will be defined for a server which is being respread.
+func (ctrl *singleClusterStateController) setRepairState(state *ServerState, respreadTarget func(ardb.ServerIndexPredicate) (int64, error)) error {
+	state.repair = ctrl.repairs[state.Index]
+	if state.repair == nil {
+		// the server might be repaired by another process,
+		// in which case no target server is defined,
+		// as the data-modifying actions can't be synchronized with that repair
+		state.repair = ctrl.passiveRepair(state.Index)
+		return nil
+	}
+
+	if !state.repair.respread {
+		target := ctrl.servers[state.Index]
+		target.State = config.StorageServerStateOnline
+		state.repairTarget = &target
+		return nil
+	}
+
+	if respreadTarget == nil {
+		return nil
+	}
+	index, err := respreadTarget(serverRespreadTarget(ctrl.servers, state.Index))
+	if err != nil {
+		return err
+	}
+	target := ctrl.servers[index]
+	state.repairTarget = &target
+	return nil
+}
+
+// passiveRepair returns the state of a primary server which is being repaired (or respread) by another process,
+// such that actions which don't modify data can be applied to its slave server in the meantime.
+// Nil is returned in case this controller repairs its servers itself,
+// or in case the slave server isn't available.
+func (ctrl *singleClusterStateController) passiveRepair(index int64) *serverRepair {
+	if ctrl.selfHealing || ctrl.getSlaveClusterID == nil {
+		return nil
+	}
+	var respread bool
+	switch ctrl.servers[index].State {
+	case config.StorageServerStateRepair:
+	case config.StorageServerStateRespread:
+		respread = true
+	default:
+		return nil
+	}
+	if int64(len(ctrl.slaveServers)) != ctrl.serverCount {
+		return nil
+	}
+	source := ctrl.slaveServers[index]
+	if source.State != config.StorageServerStateOnline {
+		return nil
+	}
+	return &serverRepair{source: source, respread: respread}
+}
+
 // UpdateServerState implements ClusterStateController.UpdateServerState
 func (ctrl *singleClusterStateController) UpdateServerState(state ServerState) bool {
 	ctrl.mux.Lock()
@@ -406,6 +702,11 @@ func (ctrl *singleClusterStateController) UpdateServerState(state ServerState) b
 		return false // OOB
 	}
 
+	// a slave server which is used in place of an offline primary server
+	if state.Type != ctrl.serverType {
+		return ctrl.setFailoverServerState(state)
+	}
+
 	// ensure given config isn't out of date,
 	// as we'll assume that when the given (dial) config differs from the used config,
 	// the used config is correct and the given config is out of date.
@@ -422,6 +723,27 @@ func (ctrl *singleClusterStateController) UpdateServerState(state ServerState) b
 	return ctrl.setServerState(state.Index, state.Config.State)
 }
 
+// setFailoverServerState updates the (internal) state of a slave server,
+// used in place of the offline primary server which shares the same index.
+// NOTE: the write lock of the controller has to be held while calling this method.
+func (ctrl *singleClusterStateController) setFailoverServerState(state ServerState) bool {
+	if state.Index >= int64(len(ctrl.slaveServers)) {
+		return false // OOB
+	}
+	cur := ctrl.slaveServers[state.Index]
+	if !storageServersEqual(cur, state.Config) || cur.State == state.Config.State {
+		return false // given config is out of date, or state remains unchanged
+	}
+
+	// [TODO] Notify AYS about this error
+	log.Errorf(
+		"marking slave server #%d %s (state: %s) as %s, "+
+			"no longer using it in place of offline %s server %s",
+		state.Index, &cur, cur.State, state.Config.State, ctrl.serverType, &ctrl.servers[state.Index])
+	ctrl.slaveServers[state.Index].State = state.Config.State
+	return true
+}
+
 // ServerCount implements ClusterStateController.ServerCount
 func (ctrl *singleClusterStateController) ServerCount() int64 {
 	ctrl.mux.RLock()
@@ -441,6 +763,20 @@ func (ctrl *singleClusterStateController) serverOperational(index int64) (bool,
 	switch ctrl.servers[index].State {
 	case config.StorageServerStateOnline:
 		return true, nil
+	case config.StorageServerStateRepair, config.StorageServerStateRespread:
+		// a server which is being repaired or respread is operational,
+		// as it can be used via its slave server in the meantime
+		if ctrl.repairs[index] != nil || ctrl.passiveRepair(index) != nil {
+			return true, nil
+		}
+		return false, ardb.ErrServerUnavailable
+	case config.StorageServerStateOffline:
+		// an offline server is operational,
+		// in case its slave server can be used in its place
+		if _, ok := ctrl.failoverServer(index); ok {
+			return true, nil
+		}
+		return false, ardb.ErrServerUnavailable
 	case config.StorageServerStateRIP:
 		return false, nil
 	default:
@@ -455,6 +791,7 @@ func (ctrl *singleClusterStateController) serverOperational(index int64) (bool,
 func (ctrl *singleClusterStateController) spawnConfigReloader(ctx context.Context, cs config.Source) error {
 	// create the context and cancelFunc used for the master watcher.
 	ctx, ctrl.cancel = context.WithCancel(ctx)
+	ctrl.ctx = ctx
 
 	// create the master watcher if possible
 	vdiskNBDRefCh, err := config.WatchVdiskNBDConfig(ctx, cs, ctrl.vdiskID)
@@ -463,7 +800,23 @@ func (ctrl *singleClusterStateController) spawnConfigReloader(ctx context.Contex
 	}
 	vdiskNBDConfig := <-vdiskNBDRefCh
 
-	var clusterCfg config.StorageClusterConfig
+	var clusterCfg, slaveClusterCfg config.StorageClusterConfig
+
+	// create the slave storage cluster watcher,
+	// only used in order to be able to repair (primary) servers,
+	// hence it is fine if no slave cluster is defined.
+	var slaveWatcher ClusterConfigWatcher
+	if ctrl.getSlaveClusterID != nil {
+		slaveClusterExists, err := slaveWatcher.SetClusterID(
+			ctx, cs, ctrl.vdiskID, ctrl.getSlaveClusterID(vdiskNBDConfig))
+		if err != nil {
+			return err
+		}
+		if slaveClusterExists {
+			slaveClusterCfg = <-slaveWatcher.Receive()
+			ctrl.slaveServers = slaveClusterCfg.Servers
+		}
+	}
 
 	// create the storage cluster watcher,
 	// and execute the initial config update iff
@@ -483,6 +836,21 @@ func (ctrl *singleClusterStateController) spawnConfigReloader(ctx context.Contex
 			"%s cluster %s does not exist", ctrl.serverType, ctrl.clusterID)
 	}
 
+	// start repairing and respreading all servers which are already marked as such,
+	// in case this controller is the one (self-healing) controller which is allowed to do so
+	if ctrl.selfHealing {
+		ctrl.mux.Lock()
+		for index := range ctrl.servers {
+			switch ctrl.servers[index].State {
+			case config.StorageServerStateRepair:
+				ctrl.startServerRepair(int64(index), false)
+			case config.StorageServerStateRespread:
+				ctrl.startServerRepair(int64(index), true)
+			}
+		}
+		ctrl.mux.Unlock()
+	}
+
 	// spawn the config update goroutine
 	go func() {
 		var ok bool
@@ -512,9 +880,26 @@ func (ctrl *singleClusterStateController) spawnConfigReloader(ctx context.Contex
 					ctrl.setServers(nil)
 				}
 
+				if ctrl.getSlaveClusterID == nil {
+					continue
+				}
+				slaveClusterID := ctrl.getSlaveClusterID(vdiskNBDConfig)
+				slaveClusterExists, err := slaveWatcher.SetClusterID(ctx, cs, ctrl.vdiskID, slaveClusterID)
+				if err != nil {
+					log.Errorf("failed to watch new slave cluster %s: %v", slaveClusterID, err)
+					continue
+				}
+				if !slaveClusterExists {
+					ctrl.setSlaveServers(nil)
+				}
+
 			// handle cluster storage updates
 			case clusterCfg = <-clusterWatcher.Receive():
 				ctrl.setServers(clusterCfg.Servers)
+
+			// handle slave cluster storage updates
+			case slaveClusterCfg = <-slaveWatcher.Receive():
+				ctrl.setSlaveServers(slaveClusterCfg.Servers)
 			}
 		}
 	}()
@@ -579,6 +964,28 @@ func (ctrl *singleClusterStateController) setServers(servers []config.StorageSer
 	}
 }
 
+// setSlaveServers updates the slave servers used to repair and respread (primary) servers.
+// Any repair or respread for which the slave server is no longer available will be stopped.
+func (ctrl *singleClusterStateController) setSlaveServers(servers []config.StorageServerConfig) {
+	ctrl.mux.Lock()
+	defer ctrl.mux.Unlock()
+	ctrl.slaveServers = servers
+
+	for index, repair := range ctrl.repairs {
+		if index < int64(len(servers)) &&
+			servers[index].State == config.StorageServerStateOnline &&
+			storageServersEqual(servers[index], repair.source) {
+			continue // slave server is still usable for the repair
+		}
+
+		// [TODO] Notify AYS about this error
+		log.Errorf(
+			"stopping %s of %s server #%d %s, as its slave server %s is no longer available",
+			repair.action(), ctrl.serverType, index, &ctrl.servers[index], &repair.source)
+		ctrl.setServerState(index, config.StorageServerStateOffline)
+	}
+}
+
 // setServerState allows you to update the state of a pre-configured server,
 // the resulted boolean indicates whether or not an update took place.
 func (ctrl *singleClusterStateController) setServerState(index int64, state config.StorageServerState) bool {
@@ -597,6 +1004,12 @@ func (ctrl *singleClusterStateController) setServerState(index int64, state conf
 		state = config.StorageServerStateRIP
 	}
 
+	if repair := ctrl.repairs[index]; repair != nil && ctrl.stopServerRepair(index) {
+		log.Errorf(
+			"stopped %s of %s server #%d %s, as it is marked as %s before the %s was finished",
+			repair.action(), ctrl.serverType, index, &old, state, repair.action())
+	}
+
 	switch state {
 	case config.StorageServerStateOnline:
 		// [TODO] Notify AYS about this (unexpected?) event
@@ -606,15 +1019,64 @@ func (ctrl *singleClusterStateController) setServerState(index int64, state conf
 
 	case config.StorageServerStateOffline:
 		// [TODO] Notify AYS about this error
-		log.Errorf(
-			"marking %s server #%d %s (state: %s) as offline (no self-healing is possible)",
+		if ctrl.selfHealing {
+			log.Errorf(
+				"marking %s server #%d %s (state: %s) as offline (using slave server in its place, if available)",
+				ctrl.serverType, index, &old, old.State)
+		} else {
+			log.Errorf(
+				"marking %s server #%d %s (state: %s) as offline (no self-healing is possible)",
+				ctrl.serverType, index, &old, old.State)
+		}
+
+	case config.StorageServerStateRepair:
+		log.Infof(
+			"marking %s server #%d %s (state: %s) as repair",
 			ctrl.serverType, index, &old, old.State)
+		ctrl.servers[index].State = state
+		if !ctrl.selfHealing {
+			log.Infof(
+				"%s server #%d %s is expected to be repaired by the process serving vdisk %s",
+				ctrl.serverType, index, &old, ctrl.vdiskID)
+			return true
+		}
+		if !ctrl.startServerRepair(index, false) {
+			// [TODO] Notify AYS about this error
+			log.Errorf(
+				"%s server #%d %s cannot be repaired and will remain unavailable",
+				ctrl.serverType, index, &old)
+		}
+		return true
+
+	case config.StorageServerStateRespread:
+		log.Infof(
+			"marking %s server #%d %s (state: %s) as respread",
+			ctrl.serverType, index, &old, old.State)
+		ctrl.servers[index].State = state
+		if !ctrl.selfHealing {
+			log.Infof(
+				"%s server #%d %s is expected to be respread by the process serving vdisk %s",
+				ctrl.serverType, index, &old, ctrl.vdiskID)
+			return true
+		}
+		if !ctrl.startServerRepair(index, true) {
+			// [TODO] Notify AYS about this error
+			log.Errorf(
+				"%s server #%d %s cannot be respread and will remain unavailable",
+				ctrl.serverType, index, &old)
+		}
+		return true
 
 	case config.StorageServerStateRIP:
+		if old.State == config.StorageServerStateRespread {
+			log.Infof(
+				"marking %s server #%d %s (state: %s) as RIP, as all its data has been respread",
+				ctrl.serverType, index, &old, old.State)
+			break
+		}
 		// [TODO] Notify AYS about this error
-		// [TODO] Support respreading of data (blocked by issue #601)
 		log.Errorf(
-			"marking %s server #%d %s (state: %s) as RIP (no respreading supported)",
+			"marking %s server #%d %s (state: %s) as RIP (without respreading its data)",
 			ctrl.serverType, index, &old, old.State)
 
 	default:
@@ -629,6 +1091,151 @@ func (ctrl *singleClusterStateController) setServerState(index int64, state conf
 	return true
 }
 
+// startServerRepair starts repairing (or respreading) the server at the given index,
+// using the slave server at the same index as the source of the repair (or respread).
+// False is returned in case the repair (or respread) couldn't be started.
+// NOTE: the write lock of the controller has to be held while calling this method.
+func (ctrl *singleClusterStateController) startServerRepair(index int64, respread bool) bool {
+	action := (&serverRepair{respread: respread}).action()
+	if ctrl.getSlaveClusterID == nil {
+		log.Errorf("cannot %s %s server #%d for vdisk %s: not supported for this cluster type",
+			action, ctrl.serverType, index, ctrl.vdiskID)
+		return false
+	}
+	if int64(len(ctrl.slaveServers)) != ctrl.serverCount {
+		log.Errorf(
+			"cannot %s %s server #%d for vdisk %s: "+
+				"slave cluster has %d servers, while %d servers are required",
+			action, ctrl.serverType, index, ctrl.vdiskID, len(ctrl.slaveServers), ctrl.serverCount)
+		return false
+	}
+	source := ctrl.slaveServers[index]
+	if source.State != config.StorageServerStateOnline {
+		log.Errorf(
+			"cannot %s %s server #%d for vdisk %s: slave server %s is not online (state: %s)",
+			action, ctrl.serverType, index, ctrl.vdiskID, &source, source.State)
+		return false
+	}
+
+	// snapshot the current state of both clusters
+	primaryServers := make([]config.StorageServerConfig, len(ctrl.servers))
+	copy(primaryServers, ctrl.servers)
+	slaveServers := make([]config.StorageServerConfig, len(ctrl.slaveServers))
+	copy(slaveServers, ctrl.slaveServers)
+
+	ctx, cancel := context.WithCancel(ctrl.ctx)
+	repair := &serverRepair{source: source, respread: respread, cancel: cancel}
+	if ctrl.repairs == nil {
+		ctrl.repairs = make(map[int64]*serverRepair)
+	}
+	ctrl.repairs[index] = repair
+
+	go ctrl.repairServer(ctx, index, primaryServers, slaveServers, repair)
+	return true
+}
+
+// stopServerRepair stops the repair (or respread) of the server at the given index,
+// returning true in case a repair (or respread) was still in progress.
+// NOTE: the write lock of the controller has to be held while calling this method.
+func (ctrl *singleClusterStateController) stopServerRepair(index int64) bool {
+	repair, ok := ctrl.repairs[index]
+	if !ok {
+		return false
+	}
+	repair.cancel()
+	delete(ctrl.repairs, index)
+	return true
+}
+
+// repairServer repairs (or respreads) the server at the given index,
+// marking it as online once the repair is finished (or RIP once the respread is finished),
+// or as offline in case the repair (or respread) failed.
+func (ctrl *singleClusterStateController) repairServer(ctx context.Context, index int64, primaryServers, slaveServers []config.StorageServerConfig, repair *serverRepair) {
+	target := primaryServers[index]
+	log.Infof(
+		"%sing %s server #%d %s for vdisk %s, using slave server %s",
+		repair.action(), ctrl.serverType, index, &target, ctrl.vdiskID, &repair.source)
+
+	var err error
+	if repair.respread {
+		err = respreadServerData(ctx, ctrl.vdiskID, index, primaryServers, slaveServers, repair)
+	} else {
+		err = repairServerData(ctx, ctrl.vdiskID, index, primaryServers, slaveServers, repair)
+	}
+
+	ctrl.mux.Lock()
+	defer ctrl.mux.Unlock()
+
+	if ctrl.repairs[index] != repair {
+		return // repair was stopped in the meantime
+	}
+	ctrl.stopServerRepair(index)
+
+	if err != nil {
+		log.Errorf(
+			"failed to %s %s server #%d %s for vdisk %s: %v",
+			repair.action(), ctrl.serverType, index, &target, ctrl.vdiskID, err)
+		ctrl.setServerState(index, config.StorageServerStateOffline)
+		log.Broadcast(
+			MapErrorToBroadcastStatus(err),
+			log.SubjectStorage,
+			log.ARDBServerTimeoutBody{
+				Address:  target.Address,
+				Database: target.Database,
+				Type:     ctrl.serverType,
+				VdiskID:  ctrl.vdiskID,
+			},
+		)
+		return
+	}
+
+	if !repair.respread {
+		log.Infof(
+			"repaired %s server #%d %s for vdisk %s",
+			ctrl.serverType, index, &target, ctrl.vdiskID)
+		ctrl.setServerState(index, config.StorageServerStateOnline)
+		log.Broadcast(
+			log.StatusServerRepaired,
+			log.SubjectStorage,
+			log.ARDBServerTimeoutBody{
+				Address:  target.Address,
+				Database: target.Database,
+				Type:     ctrl.serverType,
+				VdiskID:  ctrl.vdiskID,
+			},
+		)
+		return
+	}
+
+	// both the primary and slave server are no longer used for this vdisk,
+	// the slave cluster is expected to be updated by AYS
+	log.Infof(
+		"respread %s server #%d %s for vdisk %s",
+		ctrl.serverType, index, &target, ctrl.vdiskID)
+	ctrl.setServerState(index, config.StorageServerStateRIP)
+	if index < int64(len(ctrl.slaveServers)) {
+		ctrl.slaveServers[index].State = config.StorageServerStateRIP
+	}
+	for _, server := range []struct {
+		cfg        config.StorageServerConfig
+		serverType log.ARDBServerType
+	}{
+		{target, ctrl.serverType},
+		{repair.source, log.ARDBSlaveServer},
+	} {
+		log.Broadcast(
+			log.StatusServerRespread,
+			log.SubjectStorage,
+			log.ARDBServerTimeoutBody{
+				Address:  server.cfg.Address,
+				Database: server.cfg.Database,
+				Type:     server.serverType,
+				VdiskID:  ctrl.vdiskID,
+			},
+		)
+	}
+}
+
 // getters to get a specific clusterID,
 // used to  create the different kind of singleCluster controllers.
 func getPrimaryClusterID(cfg config.VdiskNBDConfig) string  { return cfg.StorageClusterID }
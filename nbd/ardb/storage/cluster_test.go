@@ -474,10 +474,6 @@ func TestTemplateServerRIP(t *testing.T) {
 	}
 }
 
-/*
-// [TODO]
-//    + Either activate these tests again, once this method is supported
-//    + or delete these tests and the underlying private test if this method is no longer required and defined
 func TestPrimaryClusterDoForAll(t *testing.T) {
 	slice := redisstub.NewMemoryRedisSlice(4)
 	defer slice.Close()
@@ -530,7 +526,6 @@ func TestTemplateClusterDoForAll(t *testing.T) {
 
 	testClusterDoForAll(t, cluster)
 }
-*/
 
 func testClusterDoForAll(t *testing.T, cluster ardb.StorageCluster) {
 	require := require.New(t)
@@ -623,6 +618,409 @@ func testClusterDoForAll(t *testing.T, cluster ardb.StorageCluster) {
 	}
 }
 
+func TestPrimaryServerRepairNonDeduped(t *testing.T) {
+	testPrimaryServerRepair(t, func(vdiskID string, blockSize int64, cluster ardb.StorageCluster) (BlockStorage, error) {
+		return NonDeduped(vdiskID, "", blockSize, cluster, nil)
+	})
+}
+
+func TestPrimaryServerRepairDeduped(t *testing.T) {
+	testPrimaryServerRepair(t, func(vdiskID string, blockSize int64, cluster ardb.StorageCluster) (BlockStorage, error) {
+		return Deduped(vdiskID, blockSize, ardb.DefaultLBACacheLimit, cluster, nil)
+	})
+}
+
+func TestPrimaryServerRepairSemiDeduped(t *testing.T) {
+	testPrimaryServerRepair(t, func(vdiskID string, blockSize int64, cluster ardb.StorageCluster) (BlockStorage, error) {
+		return SemiDeduped(vdiskID, blockSize, ardb.DefaultLBACacheLimit, cluster, nil)
+	})
+}
+
+type storageCreator func(vdiskID string, blockSize int64, cluster ardb.StorageCluster) (BlockStorage, error)
+
+func testPrimaryServerRepair(t *testing.T, createStorage storageCreator) {
+	primarySlice := redisstub.NewMemoryRedisSlice(2)
+	defer primarySlice.Close()
+	slaveSlice := redisstub.NewMemoryRedisSlice(2)
+	defer slaveSlice.Close()
+	mr := redisstub.NewMemoryRedis()
+	defer mr.Close()
+
+	const (
+		vdiskID          = "foo"
+		primaryClusterID = "primary"
+		slaveClusterID   = "slave"
+		blockSize        = 8
+		blockCount       = 512
+	)
+
+	source := config.NewStubSource()
+	primaryClusterConfig := primarySlice.StorageClusterConfig()
+	source.SetPrimaryStorageCluster(vdiskID, primaryClusterID, &primaryClusterConfig)
+	slaveClusterConfig := slaveSlice.StorageClusterConfig()
+	source.SetSlaveStorageCluster(vdiskID, slaveClusterID, &slaveClusterConfig)
+
+	require := require.New(t)
+
+	primaryCluster, err := ardb.NewCluster(primaryClusterConfig, nil)
+	require.NoError(err)
+	slaveCluster, err := ardb.NewCluster(slaveClusterConfig, nil)
+	require.NoError(err)
+
+	primaryStorage, err := createStorage(vdiskID, blockSize, primaryCluster)
+	require.NoError(err)
+	slaveStorage, err := createStorage(vdiskID, blockSize, slaveCluster)
+	require.NoError(err)
+
+	// store the content in both clusters
+	var contentSlice [][]byte
+	for index := int64(0); index < blockCount; index++ {
+		content := make([]byte, blockSize)
+		rand.Read(content)
+		contentSlice = append(contentSlice, content)
+		require.NoError(primaryStorage.SetBlock(index, content))
+		require.NoError(slaveStorage.SetBlock(index, content))
+	}
+	require.NoError(primaryStorage.Flush())
+	require.NoError(slaveStorage.Flush())
+
+	// replace the 2nd primary server with an empty server which requires a repair,
+	// as if the original server lost all its data
+	primarySlice.CloseServer(1)
+	primaryClusterConfig.Servers[1] = mr.StorageServerConfig()
+	primaryClusterConfig.Servers[1].State = config.StorageServerStateRepair
+	source.SetStorageCluster(primaryClusterID, &primaryClusterConfig)
+
+	ctx := context.Background()
+
+	// a cluster which isn't self-healing never repairs the server,
+	// but can read its data from the slave server in the meantime
+	passiveCluster, err := NewPrimaryCluster(ctx, vdiskID, source)
+	require.NoError(err)
+	passiveStorage, err := createStorage(vdiskID, blockSize, passiveCluster)
+	require.NoError(err)
+	for index := int64(0); index < blockCount; index++ {
+		content, err := passiveStorage.GetBlock(index)
+		require.NoError(err)
+		require.Equal(contentSlice[index], content)
+	}
+	require.Empty(passiveCluster.controller.(*singleClusterStateController).repairs)
+	_, err = passiveCluster.Do(ardb.Command(command.Set, "foo", "bar"))
+	if state, _ := passiveCluster.controller.ServerState(); state.Index == 1 {
+		require.Equal(ardb.ErrServerUnavailable, errors.Cause(err))
+	}
+	state, err := passiveCluster.controller.ServerStateAt(1)
+	require.NoError(err)
+	require.Equal(config.StorageServerStateRepair, state.Config.State)
+	passiveStorage.Close()
+	passiveCluster.Close()
+
+	cluster, err := NewSelfHealingPrimaryCluster(ctx, vdiskID, source)
+	require.NoError(err)
+	defer cluster.Close()
+
+	// wait until the repair is finished
+	waitForAsyncClusterUpdate(t, func() bool {
+		state, err := cluster.controller.ServerStateAt(1)
+		require.NoError(err)
+		return state.Config.State == config.StorageServerStateOnline
+	})
+
+	// all content should now be available using the primary cluster only
+	slaveSlice.Close()
+	storage, err := createStorage(vdiskID, blockSize, cluster)
+	require.NoError(err)
+	defer storage.Close()
+	for index := int64(0); index < blockCount; index++ {
+		content, err := storage.GetBlock(index)
+		require.NoError(err)
+		require.Equal(contentSlice[index], content)
+	}
+}
+
+func TestPrimaryServerRespreadNonDeduped(t *testing.T) {
+	testPrimaryServerRespread(t, func(vdiskID string, blockSize int64, cluster ardb.StorageCluster) (BlockStorage, error) {
+		return NonDeduped(vdiskID, "", blockSize, cluster, nil)
+	})
+}
+
+func TestPrimaryServerRespreadDeduped(t *testing.T) {
+	testPrimaryServerRespread(t, func(vdiskID string, blockSize int64, cluster ardb.StorageCluster) (BlockStorage, error) {
+		return Deduped(vdiskID, blockSize, ardb.DefaultLBACacheLimit, cluster, nil)
+	})
+}
+
+func TestPrimaryServerRespreadSemiDeduped(t *testing.T) {
+	testPrimaryServerRespread(t, func(vdiskID string, blockSize int64, cluster ardb.StorageCluster) (BlockStorage, error) {
+		return SemiDeduped(vdiskID, blockSize, ardb.DefaultLBACacheLimit, cluster, nil)
+	})
+}
+
+func testPrimaryServerRespread(t *testing.T, createStorage storageCreator) {
+	primarySlice := redisstub.NewMemoryRedisSlice(3)
+	defer primarySlice.Close()
+	slaveSlice := redisstub.NewMemoryRedisSlice(3)
+	defer slaveSlice.Close()
+
+	const (
+		vdiskID          = "foo"
+		primaryClusterID = "primary"
+		slaveClusterID   = "slave"
+		blockSize        = 8
+		blockCount       = 512
+	)
+
+	source := config.NewStubSource()
+	primaryClusterConfig := primarySlice.StorageClusterConfig()
+	source.SetPrimaryStorageCluster(vdiskID, primaryClusterID, &primaryClusterConfig)
+	slaveClusterConfig := slaveSlice.StorageClusterConfig()
+	source.SetSlaveStorageCluster(vdiskID, slaveClusterID, &slaveClusterConfig)
+
+	require := require.New(t)
+
+	primaryCluster, err := ardb.NewCluster(primaryClusterConfig, nil)
+	require.NoError(err)
+	slaveCluster, err := ardb.NewCluster(slaveClusterConfig, nil)
+	require.NoError(err)
+
+	primaryStorage, err := createStorage(vdiskID, blockSize, primaryCluster)
+	require.NoError(err)
+	primaryStorage = DirtyTracking(vdiskID, primaryStorage, primaryCluster)
+	slaveStorage, err := createStorage(vdiskID, blockSize, slaveCluster)
+	require.NoError(err)
+	slaveStorage = DirtyTracking(vdiskID, slaveStorage, slaveCluster)
+
+	// store the content in both clusters
+	var contentSlice [][]byte
+	var indices []int64
+	for index := int64(0); index < blockCount; index++ {
+		content := make([]byte, blockSize)
+		rand.Read(content)
+		contentSlice = append(contentSlice, content)
+		indices = append(indices, index)
+		require.NoError(primaryStorage.SetBlock(index, content))
+		require.NoError(slaveStorage.SetBlock(index, content))
+	}
+	require.NoError(primaryStorage.Flush())
+	require.NoError(slaveStorage.Flush())
+
+	// the 1st primary server is lost forever, and its data has to be respread,
+	// including the metadata which is only stored on the first available server
+	primarySlice.CloseServer(0)
+	primaryClusterConfig.Servers[0].State = config.StorageServerStateRespread
+	source.SetStorageCluster(primaryClusterID, &primaryClusterConfig)
+
+	ctx := context.Background()
+
+	// a cluster which isn't self-healing never respreads the server,
+	// nor can it apply data-modifying actions to it in the meantime
+	passiveCluster, err := NewPrimaryCluster(ctx, vdiskID, source)
+	require.NoError(err)
+	require.Empty(passiveCluster.controller.(*singleClusterStateController).repairs)
+	server := smartServer{Index: 0, Cluster: passiveCluster}
+	_, err = server.Do(ardb.Command(command.Set, "foo", "bar"))
+	require.Equal(ardb.ErrServerUnavailable, errors.Cause(err))
+	state, err := passiveCluster.controller.ServerStateAt(0)
+	require.NoError(err)
+	require.Equal(config.StorageServerStateRespread, state.Config.State)
+	passiveCluster.Close()
+
+	cluster, err := NewSelfHealingPrimaryCluster(ctx, vdiskID, source)
+	require.NoError(err)
+	defer cluster.Close()
+
+	// wait until the respread is finished
+	waitForAsyncClusterUpdate(t, func() bool {
+		state, err := cluster.controller.ServerStateAt(0)
+		require.NoError(err)
+		return state.Config.State == config.StorageServerStateRIP
+	})
+
+	// all content should now be available using the remaining primary servers only
+	slaveSlice.Close()
+	storage, err := createStorage(vdiskID, blockSize, cluster)
+	require.NoError(err)
+	defer storage.Close()
+	for index := int64(0); index < blockCount; index++ {
+		content, err := storage.GetBlock(index)
+		require.NoError(err)
+		require.Equal(contentSlice[index], content)
+	}
+	dirty, err := LoadDirtyBlocks(vdiskID, cluster)
+	require.NoError(err)
+	require.Equal(indices, dirty.Indices)
+}
+
+func TestPrimaryServerRespreadServerActions(t *testing.T) {
+	primarySlice := redisstub.NewMemoryRedisSlice(2)
+	defer primarySlice.Close()
+	slaveSlice := redisstub.NewMemoryRedisSlice(2)
+	defer slaveSlice.Close()
+
+	require := require.New(t)
+
+	// the 1st primary server is being respread to the 2nd primary server
+	primaryServers := primarySlice.StorageClusterConfig().Servers
+	primaryServers[0].State = config.StorageServerStateRespread
+	slaveServers := slaveSlice.StorageClusterConfig().Servers
+	controller := &singleClusterStateController{
+		vdiskID:      "foo",
+		serverType:   log.ARDBPrimaryServer,
+		servers:      primaryServers,
+		serverCount:  int64(len(primaryServers)),
+		slaveServers: slaveServers,
+		selfHealing:  true,
+		repairs: map[int64]*serverRepair{
+			0: {source: slaveServers[0], respread: true},
+		},
+		cancel: func() {},
+	}
+	cluster, err := NewCluster("foo", controller)
+	require.NoError(err)
+	defer cluster.Close()
+
+	slave, err := ardb.NewUniCluster(slaveServers[0], nil)
+	require.NoError(err)
+	target, err := ardb.NewUniCluster(primaryServers[1], nil)
+	require.NoError(err)
+
+	// a data-modifying action applied to the server itself can't be respread,
+	// and thus isn't applied at all
+	server := smartServer{Index: 0, Cluster: cluster}
+	_, err = server.Do(ardb.Command(command.Set, "a", "1"))
+	require.Equal(ardb.ErrServerUnavailable, errors.Cause(err))
+	exists, err := ardb.Bool(slave.Do(ardb.Command(command.Exists, "a")))
+	require.NoError(err)
+	require.False(exists)
+
+	// other actions are still applied to the slave server
+	require.NoError(ardb.Error(slave.Do(ardb.Command(command.Set, "a", "2"))))
+	value, err := ardb.String(server.Do(ardb.Command(command.Get, "a")))
+	require.NoError(err)
+	require.Equal("2", value)
+
+	// a data-modifying action applied to the cluster
+	// is applied to both the slave server and the respread target
+	require.NoError(ardb.Error(cluster.Do(ardb.Command(command.Set, "b", "3"))))
+	for _, c := range []ardb.StorageCluster{slave, target} {
+		value, err = ardb.String(c.Do(ardb.Command(command.Get, "b")))
+		require.NoError(err)
+		require.Equal("3", value)
+	}
+}
+
+func TestPrimaryServerFailoverNonDeduped(t *testing.T) {
+	testPrimaryServerFailover(t, func(vdiskID string, blockSize int64, cluster ardb.StorageCluster) (BlockStorage, error) {
+		return NonDeduped(vdiskID, "", blockSize, cluster, nil)
+	})
+}
+
+func TestPrimaryServerFailoverDeduped(t *testing.T) {
+	testPrimaryServerFailover(t, func(vdiskID string, blockSize int64, cluster ardb.StorageCluster) (BlockStorage, error) {
+		return Deduped(vdiskID, blockSize, ardb.DefaultLBACacheLimit, cluster, nil)
+	})
+}
+
+func TestPrimaryServerFailoverSemiDeduped(t *testing.T) {
+	testPrimaryServerFailover(t, func(vdiskID string, blockSize int64, cluster ardb.StorageCluster) (BlockStorage, error) {
+		return SemiDeduped(vdiskID, blockSize, ardb.DefaultLBACacheLimit, cluster, nil)
+	})
+}
+
+func testPrimaryServerFailover(t *testing.T, createStorage storageCreator) {
+	primarySlice := redisstub.NewMemoryRedisSlice(2)
+	defer primarySlice.Close()
+	slaveSlice := redisstub.NewMemoryRedisSlice(2)
+	defer slaveSlice.Close()
+
+	const (
+		vdiskID          = "foo"
+		primaryClusterID = "primary"
+		slaveClusterID   = "slave"
+		blockSize        = 8
+		blockCount       = 512
+	)
+
+	source := config.NewStubSource()
+	primaryClusterConfig := primarySlice.StorageClusterConfig()
+	source.SetPrimaryStorageCluster(vdiskID, primaryClusterID, &primaryClusterConfig)
+	slaveClusterConfig := slaveSlice.StorageClusterConfig()
+	source.SetSlaveStorageCluster(vdiskID, slaveClusterID, &slaveClusterConfig)
+
+	require := require.New(t)
+
+	slaveCluster, err := ardb.NewCluster(slaveClusterConfig, nil)
+	require.NoError(err)
+	slaveStorage, err := createStorage(vdiskID, blockSize, slaveCluster)
+	require.NoError(err)
+
+	ctx := context.Background()
+	cluster, err := NewSelfHealingPrimaryCluster(ctx, vdiskID, source)
+	require.NoError(err)
+	defer cluster.Close()
+	storage, err := createStorage(vdiskID, blockSize, cluster)
+	require.NoError(err)
+	defer storage.Close()
+
+	// store the content in both clusters,
+	// as the tlog server would sync the slave cluster
+	var contentSlice [][]byte
+	for index := int64(0); index < blockCount; index++ {
+		content := make([]byte, blockSize)
+		rand.Read(content)
+		contentSlice = append(contentSlice, content)
+		require.NoError(storage.SetBlock(index, content))
+		require.NoError(slaveStorage.SetBlock(index, content))
+	}
+	require.NoError(storage.Flush())
+	require.NoError(slaveStorage.Flush())
+
+	// the 2nd primary server goes down,
+	// which should make the cluster use the 2nd slave server in its place
+	primarySlice.CloseServer(1)
+
+	storage, err = createStorage(vdiskID, blockSize, cluster)
+	require.NoError(err)
+	defer storage.Close()
+	for index := int64(0); index < blockCount; index++ {
+		content, err := storage.GetBlock(index)
+		require.NoError(err)
+		require.Equal(contentSlice[index], content)
+	}
+
+	state, err := cluster.controller.ServerStateAt(1)
+	require.NoError(err)
+	require.Equal(log.ARDBSlaveServer, state.Type)
+	require.Equal(slaveClusterConfig.Servers[1], state.Config)
+
+	// writing should work as well
+	for index := int64(0); index < blockCount; index++ {
+		rand.Read(contentSlice[index])
+		require.NoError(storage.SetBlock(index, contentSlice[index]))
+	}
+	require.NoError(storage.Flush())
+	for index := int64(0); index < blockCount; index++ {
+		content, err := storage.GetBlock(index)
+		require.NoError(err)
+		require.Equal(contentSlice[index], content)
+	}
+
+	// once the slave server goes down as well, the storage is no longer usable
+	slaveSlice.CloseServer(1)
+	storage, err = createStorage(vdiskID, blockSize, cluster)
+	require.NoError(err)
+	defer storage.Close()
+	var failed bool
+	for index := int64(0); index < blockCount; index++ {
+		_, err = storage.GetBlock(index)
+		if err != nil {
+			failed = true
+			break
+		}
+	}
+	require.True(failed)
+}
+
 func waitForAsyncClusterUpdate(t *testing.T, predicate func() bool) {
 	timeoutTicker := time.NewTicker(30 * time.Second)
 	pollTicker := time.NewTicker(5 * time.Millisecond)
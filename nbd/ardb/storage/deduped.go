@@ -25,7 +25,7 @@ func Deduped(vdiskID string, blockSize, lbaCacheLimit int64, cluster, templateCl
 
 	// create the LBA (used to store deduped metadata)
 	lbaStorage := newLBASectorStorage(vdiskID, cluster)
-	vlba, err := lba.NewLBA(cacheLimit, lbaStorage)
+	vlba, err := lba.NewLBA(vdiskID, cacheLimit, lbaStorage)
 	if err != nil {
 		log.Errorf("couldn't create the LBA: %s", err.Error())
 		return nil, err
@@ -98,6 +98,22 @@ func (ds *dedupedStorage) GetBlock(blockIndex int64) (content []byte, err error)
 	return
 }
 
+// IsBlockAllocated implements BlockAllocationChecker.IsBlockAllocated,
+// only checking the LBA, as a block is referenced in the LBA, when it is stored.
+func (ds *dedupedStorage) IsBlockAllocated(blockIndex int64) (bool, error) {
+	hash, err := ds.lba.Get(blockIndex)
+	if err != nil {
+		return false, err
+	}
+	return hash != nil && !hash.Equals(zerodisk.NilHash), nil
+}
+
+// AllocatedBlocks implements BlockRangeAllocationChecker.AllocatedBlocks,
+// only checking the LBA, fetching each of its sectors only once.
+func (ds *dedupedStorage) AllocatedBlocks(startIndex, count int64) ([]bool, error) {
+	return ds.lba.Has(startIndex, count)
+}
+
 // DeleteBlock implements BlockStorage.DeleteBlock
 func (ds *dedupedStorage) DeleteBlock(blockIndex int64) (err error) {
 	// first get hash
@@ -230,8 +246,8 @@ func deleteDedupedData(vdiskID string, cluster ardb.StorageCluster) (bool, error
 	resultCh := make(chan serverResult)
 
 	var serverCount int
-	// TODO: dereference deduped blocks as well
-	// https://github.com/zero-os/0-Disk/issues/88
+	// deduped blocks aren't deleted here, as they can be shared between vdisks,
+	// use `CollectDedupedGarbage` to delete deduped blocks which are no longer referenced
 	action := ardb.Command(command.Delete, lbaStorageKey(vdiskID))
 	for server := range serverCh {
 		server := server
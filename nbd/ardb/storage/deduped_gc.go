@@ -0,0 +1,280 @@
+package storage
+
+import (
+	"context"
+	"strings"
+
+	"github.com/zero-os/0-Disk"
+	"github.com/zero-os/0-Disk/errors"
+	"github.com/zero-os/0-Disk/log"
+	"github.com/zero-os/0-Disk/nbd/ardb"
+	"github.com/zero-os/0-Disk/nbd/ardb/command"
+	"github.com/zero-os/0-Disk/nbd/ardb/storage/lba"
+)
+
+// DedupedGCResult is the result of a garbage collection of deduped blocks,
+// see `CollectDedupedGarbage` for more information.
+type DedupedGCResult struct {
+	// amount of unique deduped blocks,
+	// referenced by at least one LBA sector
+	ReferencedBlocks int64
+	// amount of deduped blocks,
+	// no longer referenced by any LBA sector
+	UnreferencedBlocks int64
+	// total size in bytes of all unreferenced deduped blocks
+	ReclaimableBytes int64
+	// true in case the unreferenced deduped blocks have been deleted
+	Deleted bool
+}
+
+// CollectDedupedGarbage deletes all deduped blocks stored in the given cluster,
+// which are no longer referenced by any LBA sector, using a mark-and-sweep algorithm.
+// When dryRun is true, the unreferenced deduped blocks are counted, but not deleted.
+//
+// All LBA sectors stored in the given cluster and reference clusters are marked first,
+// reference clusters are required in case deduped blocks of the given cluster
+// can be referenced by LBA sectors stored in other clusters
+// (e.g. when the given cluster is used as a template cluster).
+// Only keys which store content that hashes to the key itself are identified as deduped blocks,
+// such that no other data stored in the given cluster can be deleted.
+// NOTE: this function is very slow,
+//       and puts a lot of pressure on the ARDB cluster.
+// NOTE: all deduped vdisks which use the given cluster should be stopped,
+//       as deduped blocks written during the collection might not be referenced yet,
+//       this can't be verified by this function, and is the responsibility of the caller.
+func CollectDedupedGarbage(ctx context.Context, cluster ardb.StorageCluster, referenceClusters []ardb.StorageCluster, dryRun bool) (*DedupedGCResult, error) {
+	// mark all referenced deduped blocks
+	referenced := make(dedupedReferenceSet)
+	clusters := append([]ardb.StorageCluster{cluster}, referenceClusters...)
+	for _, c := range clusters {
+		err := forEachServer(ctx, c, func(server ardb.StorageServer) error {
+			log.Infof("marking all deduped blocks referenced by LBA sectors stored on %v", server.Config())
+			return markDedupedReferences(ctx, server, referenced)
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// sweep all unreferenced deduped blocks
+	result := &DedupedGCResult{
+		ReferencedBlocks: int64(len(referenced)),
+		Deleted:          !dryRun,
+	}
+	err := forEachServer(ctx, cluster, func(server ardb.StorageServer) error {
+		log.Infof("sweeping all unreferenced deduped blocks stored on %v", server.Config())
+		return scanKeys(ctx, server, "", func(keys []string) error {
+			count, size, err := sweepDedupedBlockKeys(server, keys, referenced, dryRun)
+			if err != nil {
+				return err
+			}
+			result.UnreferencedBlocks += count
+			result.ReclaimableBytes += size
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// dedupedReferenceSet is the set of all (marked) referenced deduped blocks,
+// using the hash of a deduped block as its key.
+type dedupedReferenceSet map[string]struct{}
+
+// markDedupedReferences marks all deduped blocks,
+// referenced by the LBA sectors stored on the given server.
+func markDedupedReferences(ctx context.Context, server ardb.StorageServer, referenced dedupedReferenceSet) error {
+	return scanKeys(ctx, server, lbaStorageKeyPrefix+"*", func(keys []string) error {
+		for _, key := range keys {
+			err := markLBAReferences(ctx, server, key, referenced)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// markLBAReferences marks all deduped blocks,
+// referenced by the LBA sectors stored under the given key on the given server.
+func markLBAReferences(ctx context.Context, server ardb.StorageServer, key string, referenced dedupedReferenceSet) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var hash zerodisk.Hash
+	for result := range dedupMetadataFetcher(ctx, key, server) {
+		if result.Error != nil {
+			return result.Error
+		}
+		for index, bytes := range result.Data {
+			sector, err := lba.SectorFromBytes(bytes)
+			if err != nil {
+				return errors.Wrapf(err, "invalid raw sector bytes at sector index %d of %s", index, key)
+			}
+			for hashIndex := int64(0); hashIndex < lba.NumberOfRecordsPerLBASector; hashIndex++ {
+				hash = sector.Get(hashIndex)
+				if hash != nil {
+					referenced[string(hash)] = struct{}{}
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// sweepDedupedBlockKeys deletes all unreferenced deduped blocks found in the given keys,
+// returning the amount and total size in bytes of those unreferenced deduped blocks.
+// Keys which do not belong to a deduped block are ignored,
+// and no deduped blocks are deleted in case dryRun is true.
+func sweepDedupedBlockKeys(server ardb.StorageServer, keys []string, referenced dedupedReferenceSet, dryRun bool) (int64, int64, error) {
+	var candidates []string
+	var getActions []ardb.StorageAction
+	for _, key := range keys {
+		if !isDedupedBlockKey(key) {
+			continue
+		}
+		if _, ok := referenced[key]; ok {
+			continue
+		}
+		candidates = append(candidates, key)
+		getActions = append(getActions, ardb.Command(command.Get, key))
+	}
+	if len(candidates) == 0 {
+		return 0, 0, nil
+	}
+
+	// only keys which store content that hashes to the key itself are deduped blocks,
+	// any other key (of the same length) isn't ours to delete
+	contents, err := ardb.Values(server.Do(ardb.Commands(getActions...)))
+	if err != nil {
+		return 0, 0, err
+	}
+	var unreferenced []interface{}
+	var size int64
+	for index, reply := range contents {
+		content, err := ardb.OptBytes(reply, nil)
+		if err != nil {
+			return 0, 0, err
+		}
+		if !isDedupedBlock(candidates[index], content) {
+			log.Debugf("ignoring key %x, as it doesn't store a deduped block", candidates[index])
+			continue
+		}
+		unreferenced = append(unreferenced, candidates[index])
+		size += int64(len(content))
+	}
+	if len(unreferenced) == 0 {
+		return 0, 0, nil
+	}
+
+	if !dryRun {
+		err = ardb.Error(server.Do(ardb.Command(command.Delete, unreferenced...)))
+		if err != nil {
+			return 0, 0, err
+		}
+	}
+
+	return int64(len(unreferenced)), size, nil
+}
+
+// isDedupedBlockKey returns true in case the given key can be the key of a deduped block,
+// which is the case for any key of `zerodisk.HashSize` bytes without a known prefix.
+// Use `isDedupedBlock` to verify that such a key actually stores a deduped block.
+func isDedupedBlockKey(key string) bool {
+	if len(key) != zerodisk.HashSize {
+		return false
+	}
+	for _, prefix := range knownStorageKeyPrefixes {
+		if strings.HasPrefix(key, prefix) {
+			return false
+		}
+	}
+	return true
+}
+
+// isDedupedBlock returns true in case the given key and content belong to a deduped block,
+// which is the case when the key is the hash of the (non-empty) content.
+func isDedupedBlock(key string, content []byte) bool {
+	if len(content) == 0 {
+		return false
+	}
+	return zerodisk.HashBytes(content).Equals(zerodisk.Hash(key))
+}
+
+var knownStorageKeyPrefixes = []string{
+	lbaStorageKeyPrefix,
+	nonDedupedStorageKeyPrefix,
+	semiDedupBitMapKeyPrefix,
+	tlogMetadataKeyPrefix,
+	dirtyBlocksKeyPrefix,
+}
+
+// scanKeys scans all keys stored on the given server which match the given pattern,
+// calling the given callback for each batch of keys found.
+// All keys are scanned in case no pattern is given.
+func scanKeys(ctx context.Context, server ardb.StorageServer, pattern string, cb func(keys []string) error) error {
+	const (
+		startCursor = "0"
+		itemCount   = "5000"
+	)
+
+	var err error
+	var slice interface{}
+	var keys []string
+
+	cursor := startCursor
+	for {
+		args := []interface{}{cursor}
+		if pattern != "" {
+			args = append(args, "MATCH", pattern)
+		}
+		args = append(args, "COUNT", itemCount)
+
+		// get new cursor and raw keys
+		cursor, slice, err = ardb.CursorAndValues(server.Do(ardb.Command(command.Scan, args...)))
+		keys, err = ardb.OptStrings(slice, err)
+		if err != nil {
+			return err
+		}
+		if len(keys) > 0 {
+			err = cb(keys)
+			if err != nil {
+				return err
+			}
+		}
+
+		// stop in case we iterated through all possible values
+		if cursor == startCursor || cursor == "" {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+		}
+	}
+}
+
+// forEachServer calls the given callback for each server of the given cluster,
+// one server at a time.
+func forEachServer(ctx context.Context, cluster ardb.StorageCluster, cb func(server ardb.StorageServer) error) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	serverCh, err := cluster.ServerIterator(ctx)
+	if err != nil {
+		return err
+	}
+	for server := range serverCh {
+		err = cb(server)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,182 @@
+package storage
+
+import (
+	"context"
+	crand "crypto/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zero-os/0-Disk"
+	"github.com/zero-os/0-Disk/nbd/ardb"
+	"github.com/zero-os/0-Disk/nbd/ardb/command"
+	"github.com/zero-os/0-Disk/redisstub"
+)
+
+func TestIsDedupedBlockKey(t *testing.T) {
+	hash := zerodisk.HashBytes([]byte("foo"))
+	assert.True(t, isDedupedBlockKey(string(hash.Bytes())))
+
+	assert.False(t, isDedupedBlockKey(""))
+	assert.False(t, isDedupedBlockKey(string(hash.Bytes()[:zerodisk.HashSize-1])))
+	assert.False(t, isDedupedBlockKey(lbaStorageKey("0123456789012345678901234567")))
+	assert.False(t, isDedupedBlockKey(nonDedupedStorageKey("01234567890123456789012")))
+	assert.False(t, isDedupedBlockKey(semiDedupBitMapKey("012345678901234")))
+	assert.False(t, isDedupedBlockKey(tlogMetadataKey("012345678901234567890123456")))
+	assert.False(t, isDedupedBlockKey(dirtyBlocksBitMapKey("0123456789012345678")))
+	assert.False(t, isDedupedBlockKey(dirtyBlocksExportKey("0123456789012345678")))
+	assert.False(t, isDedupedBlockKey(dirtyBlocksSnapshotKey("01234567890123456")))
+}
+
+func TestIsDedupedBlock(t *testing.T) {
+	content := []byte("foo")
+	hash := zerodisk.HashBytes(content)
+	assert.True(t, isDedupedBlock(string(hash.Bytes()), content))
+
+	assert.False(t, isDedupedBlock(string(hash.Bytes()), nil))
+	assert.False(t, isDedupedBlock(string(hash.Bytes()), []byte("bar")))
+	assert.False(t, isDedupedBlock(string(zerodisk.HashBytes([]byte("bar")).Bytes()), content))
+}
+
+func TestDedupedGarbageMarkAndSweep(t *testing.T) {
+	const (
+		vdiskID    = "a"
+		blockSize  = 8
+		blockCount = 64
+	)
+
+	mr := redisstub.NewMemoryRedis()
+	defer mr.Close()
+	cluster, err := ardb.NewUniCluster(mr.StorageServerConfig(), nil)
+	require.NoError(t, err)
+
+	var server ardb.StorageServer
+	err = forEachServer(context.Background(), cluster, func(s ardb.StorageServer) error {
+		server = s
+		return nil
+	})
+	require.NoError(t, err)
+	require.NotNil(t, server)
+
+	storage, err := Deduped(vdiskID, blockSize, ardb.DefaultLBACacheLimit, cluster, nil)
+	require.NoError(t, err)
+	defer storage.Close()
+
+	// store some blocks, and overwrite half of them,
+	// such that the original content of those blocks is no longer referenced
+	var keys []string
+	contents := make([][]byte, blockCount)
+	for index := int64(0); index < blockCount; index++ {
+		contents[index] = make([]byte, blockSize)
+		crand.Read(contents[index])
+		require.NoError(t, storage.SetBlock(index, contents[index]))
+		keys = append(keys, string(zerodisk.HashBytes(contents[index]).Bytes()))
+	}
+	for index := int64(0); index < blockCount; index += 2 {
+		contents[index] = make([]byte, blockSize)
+		crand.Read(contents[index])
+		require.NoError(t, storage.SetBlock(index, contents[index]))
+		keys = append(keys, string(zerodisk.HashBytes(contents[index]).Bytes()))
+	}
+	require.NoError(t, storage.Flush())
+	keys = append(keys, lbaStorageKey(vdiskID))
+
+	// a key which looks like the key of a deduped block,
+	// but whose content doesn't hash to that key, isn't ours to delete
+	foreignKey := string(zerodisk.HashBytes([]byte("foo")).Bytes())
+	require.NoError(t, ardb.Error(server.Do(ardb.Command(command.Set, foreignKey, "bar"))))
+	keys = append(keys, foreignKey)
+
+	// mark all referenced blocks
+	referenced := make(dedupedReferenceSet)
+	require.NoError(t, markLBAReferences(context.Background(), server, lbaStorageKey(vdiskID), referenced))
+	require.Len(t, referenced, blockCount)
+
+	// a dry run shouldn't delete anything
+	count, size, err := sweepDedupedBlockKeys(server, keys, referenced, true)
+	require.NoError(t, err)
+	assert.Equal(t, int64(blockCount/2), count)
+	assert.Equal(t, int64(blockCount/2*blockSize), size)
+	for _, key := range keys[:blockCount] {
+		exists, err := ardb.Bool(server.Do(ardb.Command(command.Exists, key)))
+		require.NoError(t, err)
+		assert.True(t, exists)
+	}
+
+	// sweep all unreferenced blocks for real this time
+	count, size, err = sweepDedupedBlockKeys(server, keys, referenced, false)
+	require.NoError(t, err)
+	assert.Equal(t, int64(blockCount/2), count)
+	assert.Equal(t, int64(blockCount/2*blockSize), size)
+	for index, key := range keys[:blockCount] {
+		exists, err := ardb.Bool(server.Do(ardb.Command(command.Exists, key)))
+		require.NoError(t, err)
+		assert.Equal(t, index%2 == 1, exists)
+	}
+	exists, err := ardb.Bool(server.Do(ardb.Command(command.Exists, foreignKey)))
+	require.NoError(t, err)
+	assert.True(t, exists)
+
+	// all (referenced) content should still be available
+	for index := int64(0); index < blockCount; index++ {
+		content, err := storage.GetBlock(index)
+		require.NoError(t, err)
+		assert.Equal(t, contents[index], content)
+	}
+}
+
+func TestDedupedGarbageSweepKeepsDirtyBlocks(t *testing.T) {
+	const blockSize = 8
+
+	mr := redisstub.NewMemoryRedis()
+	defer mr.Close()
+	cluster, err := ardb.NewUniCluster(mr.StorageServerConfig(), nil)
+	require.NoError(t, err)
+
+	var server ardb.StorageServer
+	err = forEachServer(context.Background(), cluster, func(s ardb.StorageServer) error {
+		server = s
+		return nil
+	})
+	require.NoError(t, err)
+	require.NotNil(t, server)
+
+	// vdisk IDs of various lengths, such that some of the dirty block keys
+	// have the same length as the key of a deduped block
+	vdiskIDs := []string{
+		"01234567890123456",
+		"0123456789012345678",
+		"01234567890123456789012345",
+	}
+	var keys []string
+	for _, vdiskID := range vdiskIDs {
+		nondeduped, err := NonDeduped(vdiskID, "", blockSize, cluster, nil)
+		require.NoError(t, err)
+		storage := DirtyTracking(vdiskID, nondeduped, cluster)
+
+		// ensure the bitmap, export and snapshot keys all exist
+		require.NoError(t, storage.SetBlock(0, []byte{1, 2, 3, 4, 5, 6, 7, 8}))
+		_, err = LoadDirtyBlocks(vdiskID, cluster)
+		require.NoError(t, err)
+		require.NoError(t, ResetDirtyBlocks(vdiskID, "snapshot", "export", cluster))
+		require.NoError(t, storage.SetBlock(1, []byte{1, 2, 3, 4, 5, 6, 7, 8}))
+		_, err = LoadDirtyBlocks(vdiskID, cluster)
+		require.NoError(t, err)
+		require.NoError(t, storage.SetBlock(2, []byte{1, 2, 3, 4, 5, 6, 7, 8}))
+		require.NoError(t, storage.Close())
+
+		for _, key := range dirtyBlocksKeys(vdiskID) {
+			keys = append(keys, key.(string))
+		}
+	}
+
+	// none of the dirty block keys should be swept
+	count, _, err := sweepDedupedBlockKeys(server, keys, make(dedupedReferenceSet), false)
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), count)
+	for _, key := range keys {
+		exists, err := ardb.Bool(server.Do(ardb.Command(command.Exists, key)))
+		require.NoError(t, err)
+		assert.True(t, exists, key)
+	}
+}
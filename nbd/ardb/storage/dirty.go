@@ -0,0 +1,320 @@
+package storage
+
+import (
+	"context"
+
+	"github.com/zero-os/0-Disk/config"
+	"github.com/zero-os/0-Disk/errors"
+	"github.com/zero-os/0-Disk/nbd/ardb"
+	"github.com/zero-os/0-Disk/nbd/ardb/command"
+)
+
+// DirtyTracking wraps the given BlockStorage,
+// marking all blocks which are set or deleted as dirty
+// in a bitmap stored on the given cluster.
+// A block is marked prior to being modified,
+// such that no modified block can be missed, even when the storage crashes.
+// The bitmap is spread over all servers of the cluster,
+// each block being marked on the server its index maps to.
+// The dirty blocks can be loaded using `LoadDirtyBlocks`,
+// and allow a backup to export only the blocks
+// which changed since the previous export.
+func DirtyTracking(vdiskID string, storage BlockStorage, cluster ardb.StorageCluster) BlockStorage {
+	return &dirtyTrackingStorage{
+		storage:   storage,
+		vdiskID:   vdiskID,
+		bitmapKey: dirtyBlocksBitMapKey(vdiskID),
+		cluster:   cluster,
+	}
+}
+
+// dirtyTrackingStorage is a BlockStorage implementation,
+// wrapping another BlockStorage, and tracking all blocks modified through it.
+type dirtyTrackingStorage struct {
+	storage BlockStorage
+
+	vdiskID   string
+	bitmapKey string
+	cluster   ardb.StorageCluster
+}
+
+// SetBlock implements BlockStorage.SetBlock
+func (dts *dirtyTrackingStorage) SetBlock(blockIndex int64, content []byte) error {
+	err := dts.markDirty(blockIndex)
+	if err != nil {
+		return err
+	}
+	return dts.storage.SetBlock(blockIndex, content)
+}
+
+// GetBlock implements BlockStorage.GetBlock
+func (dts *dirtyTrackingStorage) GetBlock(blockIndex int64) ([]byte, error) {
+	return dts.storage.GetBlock(blockIndex)
+}
+
+// DeleteBlock implements BlockStorage.DeleteBlock
+func (dts *dirtyTrackingStorage) DeleteBlock(blockIndex int64) error {
+	err := dts.markDirty(blockIndex)
+	if err != nil {
+		return err
+	}
+	return dts.storage.DeleteBlock(blockIndex)
+}
+
+// IsBlockAllocated implements BlockAllocationChecker.IsBlockAllocated
+func (dts *dirtyTrackingStorage) IsBlockAllocated(blockIndex int64) (bool, error) {
+	return IsBlockAllocated(dts.storage, blockIndex)
+}
+
+// AllocatedBlocks implements BlockRangeAllocationChecker.AllocatedBlocks
+func (dts *dirtyTrackingStorage) AllocatedBlocks(startIndex, count int64) ([]bool, error) {
+	return AllocatedBlocks(dts.storage, startIndex, count)
+}
+
+// Flush implements BlockStorage.Flush
+func (dts *dirtyTrackingStorage) Flush() error {
+	return dts.storage.Flush()
+}
+
+// Close implements BlockStorage.Close
+func (dts *dirtyTrackingStorage) Close() error {
+	return dts.storage.Close()
+}
+
+// markDirty sets the bit of the given block in the dirty bitmap,
+// stored on the server the given block index maps to.
+func (dts *dirtyTrackingStorage) markDirty(blockIndex int64) error {
+	err := ardb.Error(dts.cluster.DoFor(blockIndex,
+		ardb.Command(command.SetBit, dts.bitmapKey, blockIndex, 1)))
+	if err != nil {
+		return errors.Wrapf(err,
+			"couldn't mark block %d of vdisk %s as dirty", blockIndex, dts.vdiskID)
+	}
+	return nil
+}
+
+// DirtyBlocks contains the blocks of a vdisk
+// which were modified since its last export.
+type DirtyBlocks struct {
+	// identifier of the snapshot created by the last export,
+	// empty in case the dirty blocks weren't tracked since an export
+	SnapshotID string
+	// identifier of the last export, which is stored in the header of that snapshot,
+	// such that it can be verified that the snapshot was (last) created by that export
+	ExportID string
+	// (sorted) indices of all blocks modified since that export
+	Indices []int64
+}
+
+// LoadDirtyBlocks loads the blocks of a vdisk from the given ARDB storage cluster,
+// which were modified since the vdisk was last exported.
+// The loaded blocks are set aside, such that all blocks modified from now on
+// are tracked separately. They are only cleared by `ResetDirtyBlocks`,
+// and are loaded again (along with the blocks modified since),
+// in case the export fails.
+func LoadDirtyBlocks(vdiskID string, cluster ardb.StorageCluster) (*DirtyBlocks, error) {
+	if cluster == nil {
+		return nil, ErrClusterNotDefined
+	}
+
+	bitmapKey := dirtyBlocksBitMapKey(vdiskID)
+	exportKey := dirtyBlocksExportKey(vdiskID)
+	script := ardb.Script(0, loadDirtyBlocksScriptSource,
+		[]string{bitmapKey, exportKey}, bitmapKey, exportKey)
+
+	// the dirty bitmap is spread over all servers,
+	// and thus has to be loaded from each server
+	var bitmap []byte
+	err := forEachDirtyBlocksServer(cluster, func(server ardb.StorageServer) error {
+		err := ardb.Error(server.Do(script))
+		if err != nil {
+			return err
+		}
+		serverBitmap, err := ardb.OptBytes(server.Do(ardb.Command(command.Get, exportKey)))
+		if err != nil {
+			return err
+		}
+		bitmap = mergeDirtyBitMaps(bitmap, serverBitmap)
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "couldn't load dirty blocks of vdisk %s", vdiskID)
+	}
+
+	dirty := DirtyBlocks{Indices: dirtyBlockIndices(bitmap)}
+	dirty.SnapshotID, err = ardb.OptString(cluster.Do(
+		ardb.Command(command.Get, dirtyBlocksSnapshotKey(vdiskID))))
+	if err != nil {
+		return nil, err
+	}
+	dirty.ExportID, err = ardb.OptString(cluster.Do(
+		ardb.Command(command.Get, dirtyBlocksExportIDKey(vdiskID))))
+	if err != nil {
+		return nil, err
+	}
+	return &dirty, nil
+}
+
+// ResetDirtyBlocks marks the dirty blocks of a vdisk,
+// last loaded using `LoadDirtyBlocks`, as exported,
+// as part of the snapshot with the given identifier,
+// created by the export with the given identifier.
+// Blocks modified since those dirty blocks were loaded are kept,
+// such that the next export will (also) export them.
+func ResetDirtyBlocks(vdiskID, snapshotID, exportID string, cluster ardb.StorageCluster) error {
+	if cluster == nil {
+		return ErrClusterNotDefined
+	}
+
+	// the snapshot is stored first, such that a failure can only cause
+	// blocks to be exported twice, rather than not at all
+	err := ardb.Error(cluster.Do(ardb.Commands(
+		ardb.Command(command.Set, dirtyBlocksSnapshotKey(vdiskID), snapshotID),
+		ardb.Command(command.Set, dirtyBlocksExportIDKey(vdiskID), exportID),
+	)))
+	if err != nil {
+		return err
+	}
+
+	action := ardb.Command(command.Delete, dirtyBlocksExportKey(vdiskID))
+	return forEachDirtyBlocksServer(cluster, func(server ardb.StorageServer) error {
+		return ardb.Error(server.Do(action))
+	})
+}
+
+// deleteDirtyBlocks deletes the dirty blocks of a given vdisk from a given cluster.
+func deleteDirtyBlocks(vdiskID string, cluster ardb.StorageCluster) (bool, error) {
+	var deleted bool
+	action := ardb.Command(command.Delete, dirtyBlocksKeys(vdiskID)...)
+	err := forEachDirtyBlocksServer(cluster, func(server ardb.StorageServer) error {
+		serverDeleted, err := ardb.Bool(server.Do(action))
+		deleted = deleted || serverDeleted
+		return err
+	})
+	return deleted, err
+}
+
+// forEachDirtyBlocksServer applies the given function
+// to each server of the given cluster, one server at a time,
+// stopping at the first error. RIP servers are skipped,
+// as their dirty blocks were respread to the other servers.
+func forEachDirtyBlocksServer(cluster ardb.StorageCluster, fn func(server ardb.StorageServer) error) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	serverCh, err := cluster.ServerIterator(ctx)
+	if err != nil {
+		return err
+	}
+	for server := range serverCh {
+		if server.Config().State == config.StorageServerStateRIP {
+			continue
+		}
+		err = fn(server)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// mergeDirtyBitMaps merges the second bitmap into the first one,
+// returning the merged bitmap.
+func mergeDirtyBitMaps(a, b []byte) []byte {
+	if len(b) > len(a) {
+		a, b = b, a
+	}
+	for i := range b {
+		a[i] |= b[i]
+	}
+	return a
+}
+
+// dirtyBlockIndices returns the (sorted) indices of all bits set in a bitmap,
+// as stored by ARDB, where the first bit is the most significant bit of the first byte.
+func dirtyBlockIndices(bitmap []byte) []int64 {
+	var indices []int64
+	for i, b := range bitmap {
+		if b == 0 {
+			continue // skip empty bytes, common for sparse bitmaps
+		}
+		for j := uint(0); j < 8; j++ {
+			if b&(0x80>>j) != 0 {
+				indices = append(indices, int64(i)*8+int64(j))
+			}
+		}
+	}
+	return indices
+}
+
+// dirtyBitMapCommands returns the commands which set all bits set in the given bitmap,
+// merging it into the bitmap already stored at the given key (if any).
+func dirtyBitMapCommands(key string, bitmap []byte) []ardb.StorageAction {
+	var cmds []ardb.StorageAction
+	for _, index := range dirtyBlockIndices(bitmap) {
+		cmds = append(cmds, ardb.Command(command.SetBit, key, index, 1))
+	}
+	return cmds
+}
+
+// dirtyBlocksKeys returns all keys used to track the dirty blocks of a vdisk.
+func dirtyBlocksKeys(vdiskID string) []interface{} {
+	return []interface{}{
+		dirtyBlocksBitMapKey(vdiskID),
+		dirtyBlocksExportKey(vdiskID),
+		dirtyBlocksSnapshotKey(vdiskID),
+		dirtyBlocksExportIDKey(vdiskID),
+	}
+}
+
+// dirtyBlocksBitMapKey returns the key of the ARDB bitmap,
+// which marks the blocks of a vdisk modified since they were last loaded.
+func dirtyBlocksBitMapKey(vdiskID string) string {
+	return dirtyBlocksBitMapKeyPrefix + vdiskID
+}
+
+// dirtyBlocksExportKey returns the key of the ARDB bitmap,
+// which marks the blocks of a vdisk loaded by an export in progress.
+func dirtyBlocksExportKey(vdiskID string) string {
+	return dirtyBlocksExportKeyPrefix + vdiskID
+}
+
+// dirtyBlocksSnapshotKey returns the key of the ARDB string,
+// which stores the identifier of the snapshot created by the last export of a vdisk.
+func dirtyBlocksSnapshotKey(vdiskID string) string {
+	return dirtyBlocksSnapshotKeyPrefix + vdiskID
+}
+
+// dirtyBlocksExportIDKey returns the key of the ARDB string,
+// which stores the identifier of the last export of a vdisk.
+func dirtyBlocksExportIDKey(vdiskID string) string {
+	return dirtyBlocksExportIDKeyPrefix + vdiskID
+}
+
+const (
+	dirtyBlocksKeyPrefix         = "dirty:"
+	dirtyBlocksBitMapKeyPrefix   = dirtyBlocksKeyPrefix + "bitmap:"
+	dirtyBlocksExportKeyPrefix   = dirtyBlocksKeyPrefix + "export:"
+	dirtyBlocksSnapshotKeyPrefix = dirtyBlocksKeyPrefix + "snapshot:"
+	dirtyBlocksExportIDKeyPrefix = dirtyBlocksKeyPrefix + "exportid:"
+)
+
+// loadDirtyBlocksScriptSource moves the dirty bitmap into the export bitmap,
+// merging it with the blocks of a previous export which didn't complete (if any).
+const loadDirtyBlocksScriptSource = `
+local bitmapKey = ARGV[1]
+local exportKey = ARGV[2]
+
+local bitmap = redis.call("GET", bitmapKey)
+if not bitmap then
+    return 0
+end
+
+if redis.call("EXISTS", exportKey) == 1 then
+    redis.call("BITOP", "OR", exportKey, exportKey, bitmapKey)
+else
+    redis.call("SET", exportKey, bitmap)
+end
+redis.call("DEL", bitmapKey)
+return 1
+`
@@ -0,0 +1,111 @@
+package storage
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zero-os/0-Disk/nbd/ardb"
+	"github.com/zero-os/0-Disk/nbd/ardb/command"
+	"github.com/zero-os/0-Disk/redisstub"
+)
+
+func TestDirtyTracking(t *testing.T) {
+	require := require.New(t)
+	assert := assert.New(t)
+
+	const (
+		vdiskID   = "a"
+		blockSize = 8
+	)
+
+	// the dirty blocks are spread over all servers
+	cluster := redisstub.NewCluster(4, true)
+	defer cluster.Close()
+
+	nondeduped, err := NonDeduped(vdiskID, "", blockSize, cluster, nil)
+	require.NoError(err)
+	storage := DirtyTracking(vdiskID, nondeduped, cluster)
+
+	// no blocks are tracked, nor exported yet
+	dirty, err := LoadDirtyBlocks(vdiskID, cluster)
+	require.NoError(err)
+	assert.Empty(dirty.SnapshotID)
+	assert.Empty(dirty.Indices)
+
+	// blocks are marked as soon as they are modified
+	content := []byte{1, 2, 3, 4, 5, 6, 7, 8}
+	require.NoError(storage.SetBlock(3, content))
+	require.NoError(storage.SetBlock(130, content))
+	require.NoError(storage.DeleteBlock(1))
+	testBlockAllocated(t, storage, 3, true)
+	testBlockAllocated(t, storage, 1, false)
+	dirty, err = LoadDirtyBlocks(vdiskID, cluster)
+	require.NoError(err)
+	assert.Equal([]int64{1, 3, 130}, dirty.Indices)
+	for _, index := range dirty.Indices {
+		marked, err := ardb.Bool(cluster.DoFor(index,
+			ardb.Command(command.GetBit, dirtyBlocksExportKey(vdiskID), index)))
+		require.NoError(err)
+		assert.True(marked, "block %d", index)
+	}
+
+	// resetting clears the dirty blocks, and stores the snapshot ID
+	require.NoError(ResetDirtyBlocks(vdiskID, "snapshot1", "export1", cluster))
+	dirty, err = LoadDirtyBlocks(vdiskID, cluster)
+	require.NoError(err)
+	assert.Equal("snapshot1", dirty.SnapshotID)
+	assert.Equal("export1", dirty.ExportID)
+	assert.Empty(dirty.Indices)
+
+	// blocks modified while exporting are kept,
+	// even when they were already loaded by that export
+	require.NoError(storage.SetBlock(5, content))
+	dirty, err = LoadDirtyBlocks(vdiskID, cluster)
+	require.NoError(err)
+	assert.Equal([]int64{5}, dirty.Indices)
+	require.NoError(storage.SetBlock(5, content))
+	require.NoError(storage.SetBlock(7, content))
+	require.NoError(ResetDirtyBlocks(vdiskID, "snapshot2", "export2", cluster))
+	dirty, err = LoadDirtyBlocks(vdiskID, cluster)
+	require.NoError(err)
+	assert.Equal("snapshot2", dirty.SnapshotID)
+	assert.Equal("export2", dirty.ExportID)
+	assert.Equal([]int64{5, 7}, dirty.Indices)
+
+	// the blocks of an export which didn't complete are loaded again,
+	// along with the blocks modified since
+	require.NoError(storage.SetBlock(2, content))
+	dirty, err = LoadDirtyBlocks(vdiskID, cluster)
+	require.NoError(err)
+	assert.Equal("snapshot2", dirty.SnapshotID)
+	assert.Equal([]int64{2, 5, 7}, dirty.Indices)
+
+	require.NoError(storage.Close())
+
+	// deleting the vdisk deletes its dirty blocks
+	deleted, err := deleteDirtyBlocks(vdiskID, cluster)
+	require.NoError(err)
+	assert.True(deleted)
+	dirty, err = LoadDirtyBlocks(vdiskID, cluster)
+	require.NoError(err)
+	assert.Empty(dirty.SnapshotID)
+	assert.Empty(dirty.ExportID)
+	assert.Empty(dirty.Indices)
+}
+
+func TestMergeDirtyBitMaps(t *testing.T) {
+	assert.Empty(t, mergeDirtyBitMaps(nil, nil))
+	assert.Equal(t, []byte{0x81}, mergeDirtyBitMaps(nil, []byte{0x81}))
+	assert.Equal(t,
+		[]byte{0x83, 0x40, 0x03},
+		mergeDirtyBitMaps([]byte{0x81}, []byte{0x02, 0x40, 0x03}))
+}
+
+func TestDirtyBlockIndices(t *testing.T) {
+	assert.Empty(t, dirtyBlockIndices(nil))
+	assert.Empty(t, dirtyBlockIndices([]byte{0, 0}))
+	assert.Equal(t,
+		[]int64{0, 7, 9, 22, 23},
+		dirtyBlockIndices([]byte{0x81, 0x40, 0x03}))
+}
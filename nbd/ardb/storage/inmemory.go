@@ -1,10 +1,16 @@
 package storage
 
 import (
+	"io/ioutil"
+	"os"
 	"sync"
+
+	"github.com/zero-os/0-Disk/errors"
+	"github.com/zero-os/0-Disk/log"
 )
 
-// NewInMemoryStorage returns an in-memory BlockStorage implementation
+// NewInMemoryStorage returns an in-memory BlockStorage implementation,
+// which stores all blocks in memory, without any memory limit.
 func NewInMemoryStorage(vdiskID string, blockSize int64) BlockStorage {
 	return &inMemoryStorage{
 		blockSize: blockSize,
@@ -13,17 +19,42 @@ func NewInMemoryStorage(vdiskID string, blockSize int64) BlockStorage {
 	}
 }
 
+// InMemory returns an in-memory BlockStorage implementation,
+// used by non-persistent (temporary) vdisks.
+// Once the memory limit (in bytes) has been reached,
+// all new blocks are stored in a local spill file instead,
+// created in the given spill directory.
+// If no spill directory is given, the default directory for temporary files is used.
+// A memory limit of 0 (or less) means that all blocks are stored in memory.
+// All content (including the spill file) is released when the storage is closed.
+func InMemory(vdiskID string, blockSize, memoryLimit int64, spillDir string) (BlockStorage, error) {
+	if blockSize <= 0 {
+		return nil, errors.Newf("invalid block size %d for in-memory storage", blockSize)
+	}
+	return &inMemoryStorage{
+		blockSize:   blockSize,
+		vdiskID:     vdiskID,
+		vdisk:       make(map[int64][]byte),
+		memoryLimit: memoryLimit,
+		spillDir:    spillDir,
+	}, nil
+}
+
 // inMemoryStorage is a BlockStorage implementation,
-// that simply stores each block in-memory,
-// only meant for dev and test purposes.
-// Altought we might want to turn this into a proper supported storage,
-// see the following open issue for more info:
+// that stores each block in-memory, and optionally spills
+// the blocks to a local file once a given memory limit has been reached.
+// See the following issue for more info:
 // https://github.com/zero-os/0-Disk/issues/222
 type inMemoryStorage struct {
 	blockSize int64
 	vdiskID   string
 	vdisk     map[int64][]byte
 	mux       sync.RWMutex
+
+	memoryLimit int64
+	memoryUsage int64
+	spillDir    string
+	spill       *spillFile
 }
 
 // SetBlock implements BlockStorage.SetBlock
@@ -34,13 +65,38 @@ func (ms *inMemoryStorage) SetBlock(blockIndex int64, content []byte) (err error
 	// don't store zero blocks,
 	// and delete existing ones if they already existed
 	if ms.isZeroContent(content) {
-		delete(ms.vdisk, blockIndex)
-		return
+		return ms.deleteBlock(blockIndex)
 	}
 
-	// content is not zero, so let's (over)write it
-	ms.vdisk[blockIndex] = content
-	return
+	// content is not zero, so let's (over)write it, in memory if possible,
+	// copying the content as the given buffer might be reused by the caller
+	length := int64(len(content))
+	if old, ok := ms.vdisk[blockIndex]; ok {
+		ms.memoryUsage += length - int64(len(old))
+		ms.vdisk[blockIndex] = copyBytes(content)
+		return nil
+	}
+	if ms.memoryLimit <= 0 || ms.memoryUsage+length <= ms.memoryLimit {
+		if ms.spill != nil {
+			ms.spill.Delete(blockIndex)
+		}
+		ms.memoryUsage += length
+		ms.vdisk[blockIndex] = copyBytes(content)
+		return nil
+	}
+
+	// memory limit has been reached,
+	// so store it in the spill file instead
+	if ms.spill == nil {
+		ms.spill, err = newSpillFile(ms.spillDir, ms.vdiskID, ms.blockSize)
+		if err != nil {
+			return err
+		}
+		log.Infof(
+			"memory limit of %d bytes reached for vdisk %s, spilling blocks to %s",
+			ms.memoryLimit, ms.vdiskID, ms.spill.file.Name())
+	}
+	return ms.spill.Set(blockIndex, content)
 }
 
 // GetBlock implements BlockStorage.GetBlock
@@ -48,8 +104,43 @@ func (ms *inMemoryStorage) GetBlock(blockIndex int64) (content []byte, err error
 	ms.mux.RLock()
 	defer ms.mux.RUnlock()
 
-	content, _ = ms.vdisk[blockIndex]
-	return
+	content, ok := ms.vdisk[blockIndex]
+	if ok || ms.spill == nil {
+		return
+	}
+	return ms.spill.Get(blockIndex)
+}
+
+// IsBlockAllocated implements BlockAllocationChecker.IsBlockAllocated
+func (ms *inMemoryStorage) IsBlockAllocated(blockIndex int64) (bool, error) {
+	ms.mux.RLock()
+	defer ms.mux.RUnlock()
+
+	if _, ok := ms.vdisk[blockIndex]; ok {
+		return true, nil
+	}
+	if ms.spill == nil {
+		return false, nil
+	}
+	_, ok := ms.spill.offsets[blockIndex]
+	return ok, nil
+}
+
+// AllocatedBlocks implements BlockRangeAllocationChecker.AllocatedBlocks
+func (ms *inMemoryStorage) AllocatedBlocks(startIndex, count int64) ([]bool, error) {
+	ms.mux.RLock()
+	defer ms.mux.RUnlock()
+
+	allocated := make([]bool, count)
+	for i := range allocated {
+		blockIndex := startIndex + int64(i)
+		if _, ok := ms.vdisk[blockIndex]; ok {
+			allocated[i] = true
+		} else if ms.spill != nil {
+			_, allocated[i] = ms.spill.offsets[blockIndex]
+		}
+	}
+	return allocated, nil
 }
 
 // DeleteBlock implements BlockStorage.DeleteBlock
@@ -57,8 +148,20 @@ func (ms *inMemoryStorage) DeleteBlock(blockIndex int64) (err error) {
 	ms.mux.Lock()
 	defer ms.mux.Unlock()
 
-	delete(ms.vdisk, blockIndex)
-	return
+	return ms.deleteBlock(blockIndex)
+}
+
+// deleteBlock deletes a block, whether it is stored in memory or in the spill file
+func (ms *inMemoryStorage) deleteBlock(blockIndex int64) error {
+	if content, ok := ms.vdisk[blockIndex]; ok {
+		ms.memoryUsage -= int64(len(content))
+		delete(ms.vdisk, blockIndex)
+		return nil
+	}
+	if ms.spill != nil {
+		ms.spill.Delete(blockIndex)
+	}
+	return nil
 }
 
 // Flush implements BlockStorage.Flush
@@ -78,5 +181,105 @@ func (ms *inMemoryStorage) isZeroContent(content []byte) bool {
 	return true
 }
 
-// Close implements BlockStorage.Close
-func (ms *inMemoryStorage) Close() error { return nil }
+// Close implements BlockStorage.Close,
+// releasing all content stored in memory and in the spill file.
+func (ms *inMemoryStorage) Close() error {
+	ms.mux.Lock()
+	defer ms.mux.Unlock()
+
+	ms.vdisk = make(map[int64][]byte)
+	ms.memoryUsage = 0
+	if ms.spill == nil {
+		return nil
+	}
+	err := ms.spill.Close()
+	ms.spill = nil
+	return err
+}
+
+// newSpillFile creates a new (temporary) spill file in the given directory.
+func newSpillFile(dir, vdiskID string, blockSize int64) (*spillFile, error) {
+	file, err := ioutil.TempFile(dir, "zerodisk_"+vdiskID+"_")
+	if err != nil {
+		return nil, errors.Wrapf(err, "couldn't create spill file for vdisk %s", vdiskID)
+	}
+	return &spillFile{
+		file:      file,
+		blockSize: blockSize,
+		offsets:   make(map[int64]int64),
+	}, nil
+}
+
+// spillFile is a local file which stores fixed-size blocks,
+// reusing the space of deleted blocks for new blocks.
+type spillFile struct {
+	file      *os.File
+	blockSize int64
+	offsets   map[int64]int64
+	free      []int64
+	size      int64
+}
+
+// Set a block in the spill file.
+func (sf *spillFile) Set(blockIndex int64, content []byte) error {
+	offset, ok := sf.offsets[blockIndex]
+	if !ok {
+		if n := len(sf.free); n > 0 {
+			offset = sf.free[n-1]
+			sf.free = sf.free[:n-1]
+		} else {
+			offset = sf.size
+			sf.size += sf.blockSize
+		}
+		sf.offsets[blockIndex] = offset
+	}
+
+	block := content
+	if int64(len(block)) != sf.blockSize {
+		block = make([]byte, sf.blockSize)
+		copy(block, content)
+	}
+	_, err := sf.file.WriteAt(block, offset)
+	return err
+}
+
+// Get a block from the spill file,
+// returning nil in case the block isn't stored in the spill file.
+func (sf *spillFile) Get(blockIndex int64) ([]byte, error) {
+	offset, ok := sf.offsets[blockIndex]
+	if !ok {
+		return nil, nil
+	}
+	content := make([]byte, sf.blockSize)
+	_, err := sf.file.ReadAt(content, offset)
+	if err != nil {
+		return nil, err
+	}
+	return content, nil
+}
+
+// Delete a block from the spill file, if it exists.
+func (sf *spillFile) Delete(blockIndex int64) {
+	offset, ok := sf.offsets[blockIndex]
+	if !ok {
+		return
+	}
+	delete(sf.offsets, blockIndex)
+	sf.free = append(sf.free, offset)
+}
+
+// Close the spill file, and remove it from the local file system.
+func (sf *spillFile) Close() error {
+	err := sf.file.Close()
+	if removeErr := os.Remove(sf.file.Name()); err == nil {
+		err = removeErr
+	}
+	return err
+}
+
+// copyBytes returns a copy of the given byte slice.
+func copyBytes(content []byte) []byte {
+	output := make([]byte, len(content))
+	copy(output, content)
+	return output
+}
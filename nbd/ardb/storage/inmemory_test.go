@@ -1,9 +1,13 @@
 package storage
 
 import (
+	crand "crypto/rand"
+	"io/ioutil"
+	"os"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestInMemoryStorage(t *testing.T) {
@@ -33,3 +37,85 @@ func TestInMemoryStorageForceFlush(t *testing.T) {
 
 	testBlockStorageForceFlush(t, blockStorage)
 }
+
+func TestInMemoryStorageWithSpillFile(t *testing.T) {
+	const (
+		vdiskID   = "a"
+		blockSize = 8
+	)
+
+	// a memory limit of a single block,
+	// ensures that the spill file is used as well
+	blockStorage, err := InMemory(vdiskID, blockSize, blockSize, "")
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	testBlockStorage(t, blockStorage)
+}
+
+func TestInMemoryStorageWithSpillFileForceFlush(t *testing.T) {
+	const (
+		vdiskID   = "a"
+		blockSize = 8
+	)
+
+	blockStorage, err := InMemory(vdiskID, blockSize, blockSize, "")
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	testBlockStorageForceFlush(t, blockStorage)
+}
+
+func TestInMemoryStorageSpillFile(t *testing.T) {
+	const (
+		vdiskID    = "a"
+		blockSize  = 8
+		blockCount = 16
+	)
+
+	dir, err := ioutil.TempDir("", "inmemory")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	blockStorage, err := InMemory(vdiskID, blockSize, blockSize*(blockCount/2), dir)
+	require.NoError(t, err)
+
+	contents := make([][]byte, blockCount)
+	for index := range contents {
+		contents[index] = make([]byte, blockSize)
+		crand.Read(contents[index])
+		require.NoError(t, blockStorage.SetBlock(int64(index), contents[index]))
+	}
+
+	// half of the blocks should be stored in the spill file
+	files, err := ioutil.ReadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+	assert.Equal(t, int64(blockSize*(blockCount/2)), files[0].Size())
+
+	for index, content := range contents {
+		output, err := blockStorage.GetBlock(int64(index))
+		require.NoError(t, err)
+		assert.Equal(t, content, output)
+	}
+
+	// deleting a block stored in memory, frees space for a new block
+	require.NoError(t, blockStorage.DeleteBlock(0))
+	content := make([]byte, blockSize)
+	crand.Read(content)
+	require.NoError(t, blockStorage.SetBlock(blockCount, content))
+	output, err := blockStorage.GetBlock(blockCount)
+	require.NoError(t, err)
+	assert.Equal(t, content, output)
+
+	// closing the storage releases all content, including the spill file
+	require.NoError(t, blockStorage.Close())
+	files, err = ioutil.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Empty(t, files)
+	output, err = blockStorage.GetBlock(1)
+	require.NoError(t, err)
+	assert.Nil(t, output)
+}
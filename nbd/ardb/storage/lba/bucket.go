@@ -11,7 +11,7 @@ import (
 
 // create a new sector bucket
 // NOTE that this constructor doesn't validate its input
-func newSectorBucket(sizeLimitInBytes int64, storage SectorStorage) *sectorBucket {
+func newSectorBucket(sizeLimitInBytes int64, storage SectorStorage, stats bucketStats) *sectorBucket {
 	// the amount of most recent sectors we keep in memory
 	size := sizeLimitInBytes / BytesPerSector
 
@@ -20,6 +20,7 @@ func newSectorBucket(sizeLimitInBytes int64, storage SectorStorage) *sectorBucke
 		evictList: list.New(),
 		size:      int(size),
 		storage:   storage,
+		stats:     stats,
 	}
 }
 
@@ -30,6 +31,7 @@ type sectorBucket struct {
 	evictList *list.List
 	size      int
 	storage   SectorStorage
+	stats     bucketStats
 	mux       sync.Mutex
 }
 
@@ -74,6 +76,28 @@ func (bucket *sectorBucket) GetHash(index int64) (zerodisk.Hash, error) {
 	return hash, nil
 }
 
+// HasHashes checks for consecutive hashes, starting at the given index,
+// whether or not they are set, storing the result of each hash in the given slice.
+// All hashes have to be stored in the same sector,
+// such that the sector has to be fetched only once.
+func (bucket *sectorBucket) HasHashes(startIndex int64, has []bool) error {
+	bucket.mux.Lock()
+	defer bucket.mux.Unlock()
+
+	// get the sector of the hashes
+	sectorIndex := startIndex / NumberOfRecordsPerLBASector
+	sector, err := bucket.getSector(sectorIndex)
+	if err != nil {
+		return err
+	}
+
+	localIndex := startIndex % NumberOfRecordsPerLBASector
+	for i := range has {
+		has[i] = sector.Has(localIndex + int64(i))
+	}
+	return nil
+}
+
 // Flush all sectors from this bucket to persistent storage,
 // after which its bucket will be empty.
 func (bucket *sectorBucket) Flush() error {
@@ -114,9 +138,11 @@ func (bucket *sectorBucket) Flush() error {
 func (bucket *sectorBucket) getSector(index int64) (*Sector, error) {
 	// return the sector from bucket
 	if elem, ok := bucket.sectors[index]; ok {
+		bucket.stats.hits.Inc()
 		bucket.evictList.MoveToFront(elem)
 		return elem.Value.(*cacheEntry).sector, nil
 	}
+	bucket.stats.misses.Inc()
 
 	// try to fetch the sector from persistent storage
 	sector, err := bucket.storage.GetSector(index)
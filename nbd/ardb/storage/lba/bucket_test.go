@@ -5,6 +5,8 @@ import (
 	"crypto/rand"
 	"testing"
 
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
 	"github.com/zero-os/0-Disk"
 )
 
@@ -125,5 +127,45 @@ func createTestSectorBucket(bucketSize int64, storage SectorStorage) *sectorBuck
 		evictList: list.New(),
 		size:      int(size),
 		storage:   storage,
+		stats: bucketStats{
+			hits:   cacheHits.WithLabelValues("test"),
+			misses: cacheMisses.WithLabelValues("test"),
+		},
 	}
 }
+
+func TestBucketCacheStats(t *testing.T) {
+	const bucketSize = 2 * BytesPerSector
+
+	bucket := createTestSectorBucket(bucketSize, nil)
+	bucket.stats = bucketStats{
+		hits:   cacheHits.WithLabelValues("stats"),
+		misses: cacheMisses.WithLabelValues("stats"),
+	}
+
+	// first lookup of a sector misses, all other lookups hit
+	for i := int64(0); i < 3; i++ {
+		if _, err := bucket.GetHash(i); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// a lookup in another sector misses once more
+	if _, err := bucket.GetHash(NumberOfRecordsPerLBASector); err != nil {
+		t.Fatal(err)
+	}
+
+	if hits := counterValue(t, bucket.stats.hits); hits != 2 {
+		t.Errorf("expected 2 cache hits, got %v", hits)
+	}
+	if misses := counterValue(t, bucket.stats.misses); misses != 2 {
+		t.Errorf("expected 2 cache misses, got %v", misses)
+	}
+}
+
+func counterValue(t *testing.T, counter prometheus.Counter) float64 {
+	var metric dto.Metric
+	if err := counter.Write(&metric); err != nil {
+		t.Fatal(err)
+	}
+	return metric.GetCounter().GetValue()
+}
@@ -13,8 +13,9 @@ const (
 	MinimumBucketSizeLimit = BytesPerSector * 8
 )
 
-// NewLBA creates a new LBA
-func NewLBA(cacheLimitInBytes int64, storage SectorStorage) (*LBA, error) {
+// NewLBA creates a new LBA for the given vdisk,
+// the vdiskID is only used to label the cache metrics of the LBA.
+func NewLBA(vdiskID string, cacheLimitInBytes int64, storage SectorStorage) (*LBA, error) {
 	if cacheLimitInBytes < MinimumBucketSizeLimit {
 		return nil, errors.Newf(
 			"sectorCache requires at least %d bytes",
@@ -32,10 +33,14 @@ func NewLBA(cacheLimitInBytes int64, storage SectorStorage) (*LBA, error) {
 	}
 	bucketLimitInBytes := cacheLimitInBytes / bucketCount
 
-	// create all buckets
+	// create all buckets, sharing the cache metrics of this vdisk
+	stats := bucketStats{
+		hits:   cacheHits.WithLabelValues(vdiskID),
+		misses: cacheMisses.WithLabelValues(vdiskID),
+	}
 	buckets := make([]*sectorBucket, bucketCount)
 	for index := range buckets {
-		buckets[index] = newSectorBucket(bucketLimitInBytes, storage)
+		buckets[index] = newSectorBucket(bucketLimitInBytes, storage, stats)
 	}
 
 	// create the LBA itself
@@ -78,6 +83,26 @@ func (lba *LBA) Get(blockIndex int64) (zerodisk.Hash, error) {
 	return bucket.GetHash(blockIndex)
 }
 
+// Has returns for each block of the given range whether or not a hash is registered for it.
+// Each sector containing (some of) these block indices is fetched only once.
+func (lba *LBA) Has(startIndex, count int64) ([]bool, error) {
+	has := make([]bool, count)
+	endIndex := startIndex + count
+	for blockIndex := startIndex; blockIndex < endIndex; {
+		sectorEndIndex := (blockIndex/NumberOfRecordsPerLBASector + 1) * NumberOfRecordsPerLBASector
+		if sectorEndIndex > endIndex {
+			sectorEndIndex = endIndex
+		}
+		bucket := lba.getBucket(blockIndex)
+		err := bucket.HasHashes(blockIndex, has[blockIndex-startIndex:sectorEndIndex-startIndex])
+		if err != nil {
+			return nil, err
+		}
+		blockIndex = sectorEndIndex
+	}
+	return has, nil
+}
+
 // Flush stores all dirty sectors to the external storage
 func (lba *LBA) Flush() error {
 	var wg sync.WaitGroup
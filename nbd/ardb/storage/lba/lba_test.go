@@ -21,7 +21,7 @@ func TestLBAWithEmptyStubStorage(t *testing.T) {
 	storage := newStubSectorStorage()
 	require.NotNil(storage)
 
-	lba, err := NewLBA(lbaCacheLimit, storage)
+	lba, err := NewLBA("foo", lbaCacheLimit, storage)
 	require.NoError(err)
 	require.NotNil(lba)
 
@@ -157,6 +157,43 @@ func testLBAWithEmptyStorage(t *testing.T, sectors, buckets int64, lba *LBA) {
 	}
 }
 
+func TestLBAHas(t *testing.T) {
+	const (
+		bucketCount   = 4
+		lbaCacheLimit = MinimumBucketSizeLimit * bucketCount
+	)
+
+	require := require.New(t)
+
+	lba, err := NewLBA("foo", lbaCacheLimit, newStubSectorStorage())
+	require.NoError(err)
+
+	// set every third hash, spread over multiple sectors
+	const count = NumberOfRecordsPerLBASector*3 + 5
+	for index := int64(0); index < count; index += 3 {
+		hash := zerodisk.NewHash()
+		rand.Read(hash[:])
+		require.NoError(lba.Set(index, hash))
+	}
+	require.NoError(lba.Flush())
+
+	// check ranges which start and end in the middle of a sector
+	for _, startIndex := range []int64{0, 7, NumberOfRecordsPerLBASector - 1} {
+		has, err := lba.Has(startIndex, count-startIndex)
+		require.NoError(err)
+		require.Len(has, int(count-startIndex))
+		for i, ok := range has {
+			index := startIndex + int64(i)
+			require.Equal(index%3 == 0, ok, "block %d", index)
+		}
+	}
+
+	// a range which isn't registered at all
+	has, err := lba.Has(count*2, 3)
+	require.NoError(err)
+	require.Equal([]bool{false, false, false}, has)
+}
+
 func TestBucketIndex_1_Bucket(t *testing.T) {
 	testBucketIndex(t, 1)
 }
@@ -0,0 +1,30 @@
+package lba
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/zero-os/0-Disk/metrics"
+)
+
+var (
+	cacheHits = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metrics.Namespace,
+		Subsystem: "lba",
+		Name:      "cache_hits_total",
+		Help:      "Amount of LBA sector lookups served from the cache of a vdisk.",
+	}, []string{"vdisk"})
+	cacheMisses = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metrics.Namespace,
+		Subsystem: "lba",
+		Name:      "cache_misses_total",
+		Help:      "Amount of LBA sector lookups which had to be fetched from the storage of a vdisk.",
+	}, []string{"vdisk"})
+)
+
+func init() {
+	prometheus.MustRegister(cacheHits, cacheMisses)
+}
+
+// bucketStats are the cache metrics shared by all buckets of an LBA.
+type bucketStats struct {
+	hits, misses prometheus.Counter
+}
@@ -83,6 +83,12 @@ func (s *Sector) Get(hashIndex int64) zerodisk.Hash {
 	return hash
 }
 
+// Has returns true if a (non-nil) hash is set at the given index.
+func (s *Sector) Has(hashIndex int64) bool {
+	offset := hashIndex * zerodisk.HashSize
+	return !zerodisk.Hash(s.hashes[offset : offset+zerodisk.HashSize]).Equals(zerodisk.NilHash)
+}
+
 // IsNil returns false if this sector contains no non-nil hash.
 func (s *Sector) IsNil() bool {
 	for _, b := range s.hashes {
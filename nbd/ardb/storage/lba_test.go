@@ -26,7 +26,7 @@ func TestLBAWithEmptyLBASectorStorage(t *testing.T) {
 	storage := newLBASectorStorage("foo", cluster)
 	require.NotNil(storage)
 
-	lba, err := lba.NewLBA(lbaCacheLimit, storage)
+	lba, err := lba.NewLBA("foo", lbaCacheLimit, storage)
 	require.NoError(err)
 	require.NotNil(lba)
 
@@ -75,6 +75,63 @@ func (ss *nonDedupedStorage) GetBlock(blockIndex int64) (content []byte, err err
 	return
 }
 
+// IsBlockAllocated implements BlockAllocationChecker.IsBlockAllocated,
+// checking whether the block exists in the primary (or template) storage.
+func (ss *nonDedupedStorage) IsBlockAllocated(blockIndex int64) (bool, error) {
+	cmd := ardb.Command(command.HashExists, ss.storageKey, blockIndex)
+	exists, err := ardb.Bool(ss.cluster.DoFor(blockIndex, cmd))
+	if err != nil || exists || ss.templateCluster == nil {
+		return exists, err
+	}
+
+	cmd = ardb.Command(command.HashExists, ss.templateStorageKey, blockIndex)
+	exists, err = ardb.Bool(ss.templateCluster.DoFor(blockIndex, cmd))
+	if err != nil {
+		// the template cluster might simply not be defined,
+		// in which case no block can be stored there
+		if errors.Cause(err) == ErrClusterNotDefined {
+			return false, nil
+		}
+		return false, err
+	}
+	return exists, nil
+}
+
+// AllocatedBlocks implements BlockRangeAllocationChecker.AllocatedBlocks,
+// checking whether the blocks exist in the primary (or template) storage,
+// using a single pipeline per server.
+func (ss *nonDedupedStorage) AllocatedBlocks(startIndex, count int64) ([]bool, error) {
+	indices := make([]int64, count)
+	for i := range indices {
+		indices[i] = startIndex + int64(i)
+	}
+	allocated, err := hashFieldsExist(ss.cluster, ss.storageKey, indices)
+	if err != nil || ss.templateCluster == nil {
+		return allocated, err
+	}
+
+	// check the template storage for all blocks not stored in the primary storage
+	var templateIndices []int64
+	for i, ok := range allocated {
+		if !ok {
+			templateIndices = append(templateIndices, indices[i])
+		}
+	}
+	templateAllocated, err := hashFieldsExist(ss.templateCluster, ss.templateStorageKey, templateIndices)
+	if err != nil {
+		// the template cluster might simply not be defined,
+		// in which case no block can be stored there
+		if errors.Cause(err) == ErrClusterNotDefined {
+			return allocated, nil
+		}
+		return nil, err
+	}
+	for i, index := range templateIndices {
+		allocated[index-startIndex] = templateAllocated[i]
+	}
+	return allocated, nil
+}
+
 // Delete implements BlockStorage.Delete
 func (ss *nonDedupedStorage) DeleteBlock(blockIndex int64) error {
 	cmd := ardb.Command(command.HashDelete, ss.storageKey, blockIndex)
@@ -91,6 +148,34 @@ func (ss *nonDedupedStorage) Flush() (err error) {
 // Close implements BlockStorage.Close
 func (ss *nonDedupedStorage) Close() error { return nil }
 
+// hashFieldsExist checks for each of the given block indices,
+// whether or not it exists as a field of the hash stored at the given key,
+// applying all checks as a single pipeline per server.
+func hashFieldsExist(cluster ardb.StorageCluster, key string, indices []int64) ([]bool, error) {
+	if len(indices) == 0 {
+		return nil, nil
+	}
+	pairs := make([]ardb.IndexActionPair, len(indices))
+	for i, index := range indices {
+		pairs[i] = ardb.IndexActionPair{
+			Index:  index,
+			Action: ardb.Command(command.HashExists, key, index),
+		}
+	}
+	replies, err := cluster.DoForAll(pairs)
+	if err != nil {
+		return nil, err
+	}
+	exist := make([]bool, len(indices))
+	for i, reply := range replies {
+		exist[i], err = ardb.Bool(reply, nil)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return exist, nil
+}
+
 // (*nonDedupedStorage).getContent in case storage has no template support
 func (ss *nonDedupedStorage) getPrimaryContent(blockIndex int64) (content []byte, err error) {
 	cmd := ardb.Command(command.HashGet, ss.storageKey, blockIndex)
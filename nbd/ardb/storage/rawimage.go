@@ -0,0 +1,192 @@
+package storage
+
+import (
+	"os"
+	"sort"
+	"sync"
+
+	"github.com/zero-os/0-Disk/errors"
+)
+
+// RawImage returns a BlockStorage implementation,
+// which stores all blocks in a local (sparse) raw image file,
+// such that the file can be used as a plain disk image once the storage is closed.
+// The file is created (or truncated if it already existed),
+// and has the given size (in bytes), without allocating any space for it.
+// Only the blocks that are set are allocated in the raw image.
+func RawImage(path string, blockSize, size int64) (BlockStorage, error) {
+	if blockSize <= 0 {
+		return nil, errors.Newf("invalid block size %d for raw image storage", blockSize)
+	}
+	if size <= 0 || size%blockSize != 0 {
+		return nil, errors.Newf(
+			"invalid size %d for raw image storage with block size %d", size, blockSize)
+	}
+
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return nil, errors.Wrapf(err, "couldn't create raw image %s", path)
+	}
+	// truncating the file to its size doesn't allocate any space,
+	// and makes sure that the blocks which are never set, read as zeroes
+	err = file.Truncate(size)
+	if err != nil {
+		file.Close()
+		return nil, errors.Wrapf(err, "couldn't resize raw image %s", path)
+	}
+
+	return &rawImageStorage{
+		blockSize: blockSize,
+		size:      size,
+		file:      file,
+		allocated: make(map[int64]struct{}),
+	}, nil
+}
+
+// rawImageStorage is a BlockStorage implementation,
+// that stores each block in a local raw image file,
+// at the offset defined by its index and the block size.
+type rawImageStorage struct {
+	blockSize int64
+	size      int64
+	file      *os.File
+	allocated map[int64]struct{}
+	mux       sync.RWMutex
+}
+
+// SetBlock implements BlockStorage.SetBlock
+func (rs *rawImageStorage) SetBlock(blockIndex int64, content []byte) error {
+	if int64(len(content)) > rs.blockSize {
+		return errors.Newf(
+			"content of block %d is bigger than the block size %d", blockIndex, rs.blockSize)
+	}
+
+	rs.mux.Lock()
+	defer rs.mux.Unlock()
+
+	// don't store zero blocks,
+	// and delete existing ones if they already existed
+	if rs.isZeroContent(content) {
+		return rs.deleteBlock(blockIndex)
+	}
+
+	// pad the content, such that no stale data remains in the block
+	if int64(len(content)) < rs.blockSize {
+		padded := make([]byte, rs.blockSize)
+		copy(padded, content)
+		content = padded
+	}
+	if err := rs.writeBlock(blockIndex, content); err != nil {
+		return err
+	}
+	rs.allocated[blockIndex] = struct{}{}
+	return nil
+}
+
+// GetBlock implements BlockStorage.GetBlock
+func (rs *rawImageStorage) GetBlock(blockIndex int64) ([]byte, error) {
+	rs.mux.RLock()
+	defer rs.mux.RUnlock()
+
+	if _, ok := rs.allocated[blockIndex]; !ok {
+		return nil, nil
+	}
+
+	content := make([]byte, rs.blockSize)
+	_, err := rs.file.ReadAt(content, blockIndex*rs.blockSize)
+	if err != nil {
+		return nil, errors.Wrapf(err, "couldn't read block %d from raw image", blockIndex)
+	}
+	return content, nil
+}
+
+// IsBlockAllocated implements BlockAllocationChecker.IsBlockAllocated
+func (rs *rawImageStorage) IsBlockAllocated(blockIndex int64) (bool, error) {
+	rs.mux.RLock()
+	_, ok := rs.allocated[blockIndex]
+	rs.mux.RUnlock()
+	return ok, nil
+}
+
+// AllocatedBlocks implements BlockRangeAllocationChecker.AllocatedBlocks
+func (rs *rawImageStorage) AllocatedBlocks(startIndex, count int64) ([]bool, error) {
+	allocated := make([]bool, count)
+	rs.mux.RLock()
+	for i := range allocated {
+		_, allocated[i] = rs.allocated[startIndex+int64(i)]
+	}
+	rs.mux.RUnlock()
+	return allocated, nil
+}
+
+// BlockIndices implements BlockIndexLister.BlockIndices
+func (rs *rawImageStorage) BlockIndices() ([]int64, error) {
+	rs.mux.RLock()
+	indices := make([]int64, 0, len(rs.allocated))
+	for blockIndex := range rs.allocated {
+		indices = append(indices, blockIndex)
+	}
+	rs.mux.RUnlock()
+
+	sort.Sort(int64Slice(indices))
+	return indices, nil
+}
+
+// DeleteBlock implements BlockStorage.DeleteBlock
+func (rs *rawImageStorage) DeleteBlock(blockIndex int64) error {
+	rs.mux.Lock()
+	defer rs.mux.Unlock()
+	return rs.deleteBlock(blockIndex)
+}
+
+func (rs *rawImageStorage) deleteBlock(blockIndex int64) error {
+	if _, ok := rs.allocated[blockIndex]; !ok {
+		return nil // nothing to delete
+	}
+	// overwrite the block with zeroes,
+	// as a deleted block has to read as zeroes from the raw image
+	if err := rs.writeBlock(blockIndex, make([]byte, rs.blockSize)); err != nil {
+		return err
+	}
+	delete(rs.allocated, blockIndex)
+	return nil
+}
+
+// writeBlock writes the content of a block at its offset in the raw image
+func (rs *rawImageStorage) writeBlock(blockIndex int64, content []byte) error {
+	offset := blockIndex * rs.blockSize
+	if blockIndex < 0 || offset+rs.blockSize > rs.size {
+		return errors.Newf(
+			"block %d is out of range for raw image of %d bytes", blockIndex, rs.size)
+	}
+	_, err := rs.file.WriteAt(content, offset)
+	if err != nil {
+		return errors.Wrapf(err, "couldn't write block %d to raw image", blockIndex)
+	}
+	return nil
+}
+
+// Flush implements BlockStorage.Flush
+func (rs *rawImageStorage) Flush() error {
+	return rs.file.Sync()
+}
+
+// Close implements BlockStorage.Close
+func (rs *rawImageStorage) Close() error {
+	err := rs.file.Sync()
+	if closeErr := rs.file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// isZeroContent detects if a given content buffer is completely filled with 0s
+func (rs *rawImageStorage) isZeroContent(content []byte) bool {
+	for _, c := range content {
+		if c != 0 {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,84 @@
+package storage
+
+import (
+	crand "crypto/rand"
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRawImageStorage(t *testing.T) {
+	const (
+		blockSize = 8
+		size      = blockSize * 4
+	)
+
+	dir, err := ioutil.TempDir("", "rawimage")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	blockStorage, err := RawImage(path.Join(dir, "a.img"), blockSize, size)
+	require.NoError(t, err)
+
+	testBlockStorage(t, blockStorage)
+}
+
+func TestRawImageStorageForceFlush(t *testing.T) {
+	const (
+		blockSize = 8
+		size      = blockSize * 4
+	)
+
+	dir, err := ioutil.TempDir("", "rawimage")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	blockStorage, err := RawImage(path.Join(dir, "a.img"), blockSize, size)
+	require.NoError(t, err)
+
+	testBlockStorageForceFlush(t, blockStorage)
+}
+
+func TestRawImageStorageContent(t *testing.T) {
+	const (
+		blockSize  = 8
+		blockCount = 16
+	)
+
+	dir, err := ioutil.TempDir("", "rawimage")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	imagePath := path.Join(dir, "a.img")
+
+	blockStorage, err := RawImage(imagePath, blockSize, blockSize*blockCount)
+	require.NoError(t, err)
+
+	// set every other block, and delete one of them again
+	expected := make([]byte, blockSize*blockCount)
+	for index := 0; index < blockCount; index += 2 {
+		content := make([]byte, blockSize)
+		crand.Read(content)
+		require.NoError(t, blockStorage.SetBlock(int64(index), content))
+		copy(expected[index*blockSize:], content)
+	}
+	require.NoError(t, blockStorage.DeleteBlock(4))
+	copy(expected[4*blockSize:], make([]byte, blockSize))
+
+	// blocks out of range can't be stored
+	assert.Error(t, blockStorage.SetBlock(blockCount, []byte{4, 2}))
+
+	// only the indices of the stored blocks are listed, in order
+	indices, err := blockStorage.(BlockIndexLister).BlockIndices()
+	require.NoError(t, err)
+	assert.Equal(t, []int64{0, 2, 6, 8, 10, 12, 14}, indices)
+
+	// the closed storage leaves the raw image behind
+	require.NoError(t, blockStorage.Close())
+	image, err := ioutil.ReadFile(imagePath)
+	require.NoError(t, err)
+	assert.Equal(t, expected, image)
+}
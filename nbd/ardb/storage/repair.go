@@ -0,0 +1,446 @@
+package storage
+
+import (
+	"context"
+	"sync"
+
+	"github.com/zero-os/0-Disk"
+	"github.com/zero-os/0-Disk/config"
+	"github.com/zero-os/0-Disk/errors"
+	"github.com/zero-os/0-Disk/log"
+	"github.com/zero-os/0-Disk/nbd/ardb"
+	"github.com/zero-os/0-Disk/nbd/ardb/command"
+	"github.com/zero-os/0-Disk/nbd/ardb/storage/lba"
+)
+
+// serverRepair defines the state of a primary server which is being repaired or respread,
+// using the slave server which shares the same index.
+type serverRepair struct {
+	// slave server the data is read from, while the primary server is being repaired or respread
+	source config.StorageServerConfig
+	// true in case the data is respread to the other primary servers,
+	// rather than copied back to the primary server itself
+	respread bool
+	// read-locked while a data-modifying action is applied,
+	// write-locked while a chunk of data is being copied,
+	// such that a copied chunk can never overwrite more recent data.
+	mux sync.RWMutex
+	// cancels the repair while it is still in progress
+	cancel context.CancelFunc
+}
+
+// action returns the name of the action applied to the primary server.
+func (repair *serverRepair) action() string {
+	if repair.respread {
+		return "respread"
+	}
+	return "repair"
+}
+
+// repairServerData repairs the data of a vdisk
+// for the primary server at the given index,
+// by copying all that data from the slave server at the same index.
+// All data which was still stored for that vdisk on the primary server is deleted first.
+func repairServerData(ctx context.Context, vdiskID string, serverIndex int64, primaryServers, slaveServers []config.StorageServerConfig, repair *serverRepair) error {
+	pool := ardb.NewPool(nil)
+	defer pool.Close()
+
+	target := primaryServers[serverIndex]
+	target.State = config.StorageServerStateOnline
+
+	// delete all (possibly outdated) data still stored on the primary server,
+	// which is safe, as all data-modifying actions are applied to both servers from now on
+	repair.mux.Lock()
+	keys := append([]interface{}{
+		nonDedupedStorageKey(vdiskID), lbaStorageKey(vdiskID),
+		semiDedupBitMapKey(vdiskID), tlogMetadataKey(vdiskID),
+	}, dirtyBlocksKeys(vdiskID)...)
+	err := ardb.Error(applyActionOn(pool, target, ardb.Command(command.Delete, keys...)))
+	repair.mux.Unlock()
+	if err != nil {
+		return errors.Wrapf(err,
+			"couldn't delete outdated data of vdisk %s from primary server %s", vdiskID, &target)
+	}
+
+	copier := serverDataCopier{
+		vdiskID:        vdiskID,
+		serverIndex:    serverIndex,
+		primaryServers: primaryServers,
+		slaveServers:   slaveServers,
+		serverFor: func(int64) (config.StorageServerConfig, error) {
+			return target, nil
+		},
+		firstServer: func() (config.StorageServerConfig, error) {
+			return target, nil
+		},
+		mux:  &repair.mux,
+		pool: pool,
+	}
+	return copier.Copy(ctx)
+}
+
+// respreadServerData respreads the data of a vdisk
+// for the primary server at the given index,
+// by copying all that data from the slave server at the same index
+// to the primary servers it maps to, once the primary server at the given index is no longer used.
+func respreadServerData(ctx context.Context, vdiskID string, serverIndex int64, primaryServers, slaveServers []config.StorageServerConfig, repair *serverRepair) error {
+	pool := ardb.NewPool(nil)
+	defer pool.Close()
+
+	serverCount := int64(len(primaryServers))
+	predicate := serverRespreadTarget(primaryServers, serverIndex)
+
+	copier := serverDataCopier{
+		vdiskID:        vdiskID,
+		serverIndex:    serverIndex,
+		primaryServers: primaryServers,
+		slaveServers:   slaveServers,
+		serverFor: func(objectIndex int64) (config.StorageServerConfig, error) {
+			index, err := ardb.ComputeServerIndex(serverCount, objectIndex, predicate)
+			if err != nil {
+				return config.StorageServerConfig{}, err
+			}
+			return primaryServers[index], nil
+		},
+		firstServer: func() (config.StorageServerConfig, error) {
+			index, err := ardb.FindFirstServerIndex(serverCount, predicate)
+			if err != nil {
+				return config.StorageServerConfig{}, err
+			}
+			return primaryServers[index], nil
+		},
+		mux:  &repair.mux,
+		pool: pool,
+	}
+	return copier.Copy(ctx)
+}
+
+// serverDataCopier copies all data of a vdisk,
+// stored on a single slave server, to one or multiple primary servers.
+type serverDataCopier struct {
+	vdiskID string
+
+	// index of the slave server to copy the data from
+	serverIndex int64
+
+	// primary servers, as they were prior to the copy,
+	// used to know which deduped content is stored on the server to copy
+	primaryServers []config.StorageServerConfig
+	// slave servers, used to read the data from
+	slaveServers []config.StorageServerConfig
+
+	// serverFor returns the (primary) server to copy
+	// the data of a given object index to.
+	serverFor func(objectIndex int64) (config.StorageServerConfig, error)
+	// firstServer returns the first available (primary) server,
+	// used to copy the data which isn't linked to any object index to.
+	firstServer func() (config.StorageServerConfig, error)
+
+	// (write) locked while copying a chunk of data
+	mux  *sync.RWMutex
+	pool *ardb.Pool
+}
+
+// Copy all data stored for the vdisk on the slave server.
+func (copier *serverDataCopier) Copy(ctx context.Context) error {
+	source := copier.slaveServers[copier.serverIndex]
+
+	// copy all data which is linked to an object index
+	for _, key := range []string{nonDedupedStorageKey(copier.vdiskID), lbaStorageKey(copier.vdiskID)} {
+		log.Debugf("copying %s from slave server %s...", key, &source)
+		err := copier.copyIndexedHash(ctx, source, key)
+		if err != nil {
+			return err
+		}
+	}
+
+	// copy all vdisk metadata
+	log.Debugf("copying metadata of vdisk %s from slave server %s...", copier.vdiskID, &source)
+	err := copier.copyMetadata(source)
+	if err != nil {
+		return err
+	}
+
+	// copy all deduped content referenced by the vdisk and stored on the slave server
+	return copier.copyDedupedContent(ctx)
+}
+
+// copyIndexedHash copies a hash (map) which uses object indices as its fields,
+// one chunk at a time.
+func (copier *serverDataCopier) copyIndexedHash(ctx context.Context, source config.StorageServerConfig, key string) error {
+	const (
+		startCursor = "0"
+		itemCount   = "1000"
+	)
+
+	cursor := startCursor
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+		}
+
+		var err error
+		cursor, err = copier.copyIndexedHashChunk(source, key, cursor, itemCount)
+		if err != nil {
+			return err
+		}
+		if cursor == startCursor || cursor == "" {
+			return nil
+		}
+	}
+}
+
+// copyIndexedHashChunk copies a single chunk of a hash (map),
+// while no data-modifying actions can be applied.
+func (copier *serverDataCopier) copyIndexedHashChunk(source config.StorageServerConfig, key, cursor, itemCount string) (string, error) {
+	copier.mux.Lock()
+	defer copier.mux.Unlock()
+
+	cursor, slice, err := ardb.CursorAndValues(applyActionOn(copier.pool, source,
+		ardb.Command(command.HashScan, key, cursor, "COUNT", itemCount)))
+	if err == nil {
+		var data map[int64][]byte
+		data, err = ardb.Int64ToBytesMapping(slice, nil)
+		if err == nil {
+			err = copier.setIndexedHashFields(key, data)
+		}
+	}
+	if err != nil {
+		return "", errors.Wrapf(err, "couldn't copy %s from slave server %s", key, &source)
+	}
+	return cursor, nil
+}
+
+// setIndexedHashFields sets the given fields,
+// grouping all commands per target server.
+func (copier *serverDataCopier) setIndexedHashFields(key string, data map[int64][]byte) error {
+	servers := make(map[config.StorageServerConfig][]ardb.StorageAction)
+	for index, value := range data {
+		server, err := copier.serverFor(index)
+		if err != nil {
+			return err
+		}
+		servers[server] = append(servers[server], ardb.Command(command.HashSet, key, index, value))
+	}
+
+	for server, cmds := range servers {
+		err := ardb.Error(applyActionOn(copier.pool, server, ardb.Commands(cmds...)))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// copyMetadata copies the semi-deduped bitmap, tlog metadata and dirty blocks,
+// all only stored on the first available server, except for the dirty blocks,
+// of which each server stores the blocks its indices map to.
+// The dirty blocks of all servers are loaded together,
+// and can thus be copied to the first available server as well.
+func (copier *serverDataCopier) copyMetadata(source config.StorageServerConfig) error {
+	copier.mux.Lock()
+	defer copier.mux.Unlock()
+
+	bitmapKey := semiDedupBitMapKey(copier.vdiskID)
+	bitmap, err := ardb.OptBytes(applyActionOn(copier.pool, source, ardb.Command(command.Get, bitmapKey)))
+	if err != nil {
+		return errors.Wrapf(err, "couldn't get %s from slave server %s", bitmapKey, &source)
+	}
+	tlogKey := tlogMetadataKey(copier.vdiskID)
+	lastFlushedSequence, err := ardb.OptUint64(applyActionOn(copier.pool, source,
+		ardb.Command(command.HashGet, tlogKey, tlogMetadataLastFlushedSequenceField)))
+	if err != nil {
+		return errors.Wrapf(err, "couldn't get %s from slave server %s", tlogKey, &source)
+	}
+
+	var cmds []ardb.StorageAction
+	if bitmap != nil {
+		cmds = append(cmds, ardb.Command(command.Set, bitmapKey, bitmap))
+	}
+	if lastFlushedSequence != 0 {
+		cmds = append(cmds, ardb.Command(command.HashSet,
+			tlogKey, tlogMetadataLastFlushedSequenceField, lastFlushedSequence))
+	}
+
+	snapshotKey := dirtyBlocksSnapshotKey(copier.vdiskID)
+	exportIDKey := dirtyBlocksExportIDKey(copier.vdiskID)
+	for _, key := range []string{dirtyBlocksBitMapKey(copier.vdiskID), dirtyBlocksExportKey(copier.vdiskID), snapshotKey, exportIDKey} {
+		value, err := ardb.OptBytes(applyActionOn(copier.pool, source, ardb.Command(command.Get, key)))
+		if err != nil {
+			return errors.Wrapf(err, "couldn't get %s from slave server %s", key, &source)
+		}
+		if value == nil {
+			continue
+		}
+		if key == snapshotKey || key == exportIDKey {
+			cmds = append(cmds, ardb.Command(command.Set, key, value))
+			continue
+		}
+		// dirty blocks are merged rather than overwritten,
+		// as blocks might have been marked on the target server since the repair started
+		cmds = append(cmds, dirtyBitMapCommands(key, value)...)
+	}
+	if len(cmds) == 0 {
+		return nil // no metadata stored on this server
+	}
+
+	target, err := copier.firstServer()
+	if err != nil {
+		return err
+	}
+	return ardb.Error(applyActionOn(copier.pool, target, ardb.Commands(cmds...)))
+}
+
+// copyDedupedContent copies all deduped content,
+// referenced by the vdisk and stored on the slave server.
+// As deduped content is immutable, no locking is required.
+func (copier *serverDataCopier) copyDedupedContent(ctx context.Context) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	primaryServerCount := int64(len(copier.primaryServers))
+	slaveServerCount := int64(len(copier.slaveServers))
+	key := lbaStorageKey(copier.vdiskID)
+	copied := make(map[string]struct{})
+
+	for index, slave := range copier.slaveServers {
+		if slave.State == config.StorageServerStateRIP {
+			continue
+		}
+
+		server := pooledServer{cfg: slave, pool: copier.pool}
+		log.Debugf("collecting deduped content referenced by LBA sectors of slave server #%d %s...", index, &slave)
+		for result := range dedupMetadataFetcher(ctx, key, server) {
+			if result.Error != nil {
+				if errors.Cause(result.Error) == ardb.ErrNil {
+					break // no LBA sectors stored on this server
+				}
+				return result.Error
+			}
+
+			for sectorIndex, bytes := range result.Data {
+				sector, err := lba.SectorFromBytes(bytes)
+				if err != nil {
+					return errors.Wrapf(err, "invalid raw sector bytes at sector index %d", sectorIndex)
+				}
+
+				for hashIndex := int64(0); hashIndex < lba.NumberOfRecordsPerLBASector; hashIndex++ {
+					hash := sector.Get(hashIndex)
+					if hash == nil {
+						continue
+					}
+					if _, ok := copied[string(hash)]; ok {
+						continue
+					}
+
+					primaryIndex, err := ardb.ComputeServerIndex(
+						primaryServerCount, int64(hash[0]), serverNotRIP(copier.primaryServers))
+					if err != nil {
+						return err
+					}
+					if primaryIndex != copier.serverIndex {
+						continue // content isn't stored on the server we're copying
+					}
+
+					err = copier.copyContent(hash, slaveServerCount)
+					if err != nil {
+						return err
+					}
+					copied[string(hash)] = struct{}{}
+				}
+			}
+		}
+	}
+
+	log.Debugf("copied %d deduped blocks of vdisk %s from slave server #%d",
+		len(copied), copier.vdiskID, copier.serverIndex)
+	return nil
+}
+
+// copyContent copies a single deduped block from the slave cluster,
+// in case it is available in the slave cluster.
+func (copier *serverDataCopier) copyContent(hash zerodisk.Hash, slaveServerCount int64) error {
+	slaveIndex, err := ardb.ComputeServerIndex(
+		slaveServerCount, int64(hash[0]), serverNotRIP(copier.slaveServers))
+	if err != nil {
+		return err
+	}
+	source := copier.slaveServers[slaveIndex]
+	content, err := ardb.OptBytes(applyActionOn(copier.pool, source, ardb.Command(command.Get, hash.Bytes())))
+	if err != nil {
+		return errors.Wrapf(err, "couldn't get deduped block %v from slave server %s", hash, &source)
+	}
+	if content == nil {
+		// content might only be available in the template cluster
+		return nil
+	}
+
+	target, err := copier.serverFor(int64(hash[0]))
+	if err != nil {
+		return err
+	}
+	return ardb.Error(applyActionOn(copier.pool, target, ardb.Command(command.Set, hash.Bytes(), content)))
+}
+
+// serverRespreadTarget returns a predicate which marks all online servers as operational,
+// except for the server (at the given index) which is being respread.
+// RIP servers are skipped, while any other server is considered unavailable.
+func serverRespreadTarget(servers []config.StorageServerConfig, respreadIndex int64) ardb.ServerIndexPredicate {
+	return func(index int64) (bool, error) {
+		if index == respreadIndex {
+			return false, nil
+		}
+		switch servers[index].State {
+		case config.StorageServerStateOnline:
+			return true, nil
+		case config.StorageServerStateRIP:
+			return false, nil
+		default:
+			return false, ardb.ErrServerUnavailable
+		}
+	}
+}
+
+// serverNotRIP returns a predicate which marks all servers as operational,
+// which are not marked as RIP.
+func serverNotRIP(servers []config.StorageServerConfig) ardb.ServerIndexPredicate {
+	return func(index int64) (bool, error) {
+		return servers[index].State != config.StorageServerStateRIP, nil
+	}
+}
+
+// pooledServer is a StorageServer,
+// which dials its connections using a given pool.
+type pooledServer struct {
+	cfg  config.StorageServerConfig
+	pool *ardb.Pool
+}
+
+// Do implements StorageServer.Do
+func (server pooledServer) Do(action ardb.StorageAction) (reply interface{}, err error) {
+	return applyActionOn(server.pool, server.cfg, action)
+}
+
+// Config implements StorageServer.Config
+func (server pooledServer) Config() config.StorageServerConfig {
+	return server.cfg
+}
+
+// applyActionOn dials a connection for the given server using the given pool,
+// and applies the storage action on that connection.
+func applyActionOn(pool *ardb.Pool, cfg config.StorageServerConfig, action ardb.StorageAction) (interface{}, error) {
+	conn, err := pool.Dial(cfg)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	return action.Do(conn)
+}
+
+// enforces that our pooledServer
+// is actually a StorageServer
+var (
+	_ ardb.StorageServer = pooledServer{}
+)
@@ -106,6 +106,50 @@ func (sds *semiDedupedStorage) GetBlock(blockIndex int64) ([]byte, error) {
 	return sds.templateStorage.GetBlock(blockIndex)
 }
 
+// IsBlockAllocated implements BlockAllocationChecker.IsBlockAllocated
+func (sds *semiDedupedStorage) IsBlockAllocated(blockIndex int64) (bool, error) {
+	// if a bit is enabled in the bitmap,
+	// it means the data is stored in the user storage
+	if sds.userStorageBitMap.Test(int(blockIndex)) {
+		return IsBlockAllocated(sds.userStorage, blockIndex)
+	}
+
+	return IsBlockAllocated(sds.templateStorage, blockIndex)
+}
+
+// AllocatedBlocks implements BlockRangeAllocationChecker.AllocatedBlocks,
+// checking the blocks enabled in the bitmap in the user storage,
+// and all other blocks in the template storage.
+func (sds *semiDedupedStorage) AllocatedBlocks(startIndex, count int64) ([]bool, error) {
+	var userAllocated, templateAllocated []bool
+	var err error
+
+	allocated := make([]bool, count)
+	for i := range allocated {
+		// if a bit is enabled in the bitmap,
+		// it means the data is stored in the user storage
+		if sds.userStorageBitMap.Test(int(startIndex) + i) {
+			if userAllocated == nil {
+				userAllocated, err = AllocatedBlocks(sds.userStorage, startIndex, count)
+				if err != nil {
+					return nil, err
+				}
+			}
+			allocated[i] = userAllocated[i]
+			continue
+		}
+
+		if templateAllocated == nil {
+			templateAllocated, err = AllocatedBlocks(sds.templateStorage, startIndex, count)
+			if err != nil {
+				return nil, err
+			}
+		}
+		allocated[i] = templateAllocated[i]
+	}
+	return allocated, nil
+}
+
 // DeleteBlock implements BlockStorage.DeleteBlock
 func (sds *semiDedupedStorage) DeleteBlock(blockIndex int64) error {
 	errs := errors.NewErrorSlice()
@@ -100,6 +100,12 @@ func TestSemiDedupedContentBasic(t *testing.T) {
 		t.Fatalf("unexpected content found: %v", content)
 	}
 
+	// both template and user content is allocated
+	testBlockAllocated(t, storage, templateIndexA, true)
+	testBlockAllocated(t, storage, templateIndexB, true)
+	testBlockAllocated(t, storage, userIndexA, true)
+	testBlockAllocated(t, storage, blockCount-1, false)
+
 	// overwrite template content B
 	err = storage.SetBlock(templateIndexB, userContentA)
 	if err != nil {
@@ -127,6 +133,7 @@ func TestSemiDedupedContentBasic(t *testing.T) {
 	if content != nil {
 		t.Fatalf("unexpected content found: %v", content)
 	}
+	testBlockAllocated(t, storage, templateIndexB, false)
 }
 
 func init() {
@@ -28,6 +28,61 @@ type BlockStorage interface {
 	Close() (err error)
 }
 
+// BlockAllocationChecker is an optional interface which can be implemented by a BlockStorage,
+// allowing it to check whether or not a block is stored,
+// without having to fetch the content of that block.
+type BlockAllocationChecker interface {
+	IsBlockAllocated(blockIndex int64) (bool, error)
+}
+
+// IsBlockAllocated returns true in case a block is stored in the given BlockStorage,
+// using its BlockAllocationChecker implementation if possible,
+// and fetching the content of that block otherwise.
+func IsBlockAllocated(storage BlockStorage, blockIndex int64) (bool, error) {
+	if checker, ok := storage.(BlockAllocationChecker); ok {
+		return checker.IsBlockAllocated(blockIndex)
+	}
+	content, err := storage.GetBlock(blockIndex)
+	if err != nil {
+		return false, err
+	}
+	return len(content) > 0, nil
+}
+
+// BlockIndexLister is an optional interface which can be implemented by a BlockStorage,
+// allowing it to list the indices of all blocks it stores,
+// without having to check each possible block individually.
+type BlockIndexLister interface {
+	// BlockIndices returns the (sorted) indices of all blocks stored.
+	BlockIndices() ([]int64, error)
+}
+
+// BlockRangeAllocationChecker is an optional interface which can be implemented by a BlockStorage,
+// allowing it to check for a range of blocks at once whether or not they are stored,
+// using far less roundtrips than checking each block individually.
+type BlockRangeAllocationChecker interface {
+	AllocatedBlocks(startIndex, count int64) ([]bool, error)
+}
+
+// AllocatedBlocks returns for each block of the given range,
+// whether or not it is stored in the given BlockStorage,
+// using its BlockRangeAllocationChecker implementation if possible,
+// and checking each block individually otherwise.
+func AllocatedBlocks(storage BlockStorage, startIndex, count int64) ([]bool, error) {
+	if checker, ok := storage.(BlockRangeAllocationChecker); ok {
+		return checker.AllocatedBlocks(startIndex, count)
+	}
+	allocated := make([]bool, count)
+	for i := range allocated {
+		var err error
+		allocated[i], err = IsBlockAllocated(storage, startIndex+int64(i))
+		if err != nil {
+			return nil, err
+		}
+	}
+	return allocated, nil
+}
+
 // BlockStorageConfig is used when creating a block storage using the
 // NewBlockStorage helper constructor.
 type BlockStorageConfig struct {
@@ -44,6 +99,15 @@ type BlockStorageConfig struct {
 
 	// optional: used by (semi)deduped storage
 	LBACacheLimit int64
+
+	// optional: used by in-memory storage,
+	// max amount of bytes stored in memory (0 means no limit),
+	// blocks are stored in a local spill file once this limit is reached
+	MemoryLimit int64
+	// optional: used by in-memory storage,
+	// directory in which the spill file is created,
+	// the default directory for temporary files is used if not defined
+	SpillDirectory string
 }
 
 // Validate this BlockStorageConfig.
@@ -63,17 +127,26 @@ func (cfg *BlockStorageConfig) Validate() error {
 	return nil
 }
 
+// ErrVdiskNotPersistent is an error returned
+// in case a vdisk is used for an operation which requires persistent storage,
+// while the vdisk in question is not persistent (e.g. a tmp vdisk).
+var ErrVdiskNotPersistent = errors.New("vdisk is not persistent")
+
 // BlockStorageFromConfig creates a block storage
 // from the config retrieved from the given config source.
 // It is the simplest way to create a BlockStorage,
 // but it also has the disadvantage that
 // it does not support SelfHealing or HotReloading of the used configuration.
+// Only block storages for persistent vdisks can be created using this function.
 func BlockStorageFromConfig(vdiskID string, cs config.Source, dialer ardb.ConnectionDialer) (BlockStorage, error) {
 	// get configs from source
 	vdiskConfig, err := config.ReadVdiskStaticConfig(cs, vdiskID)
 	if err != nil {
 		return nil, err
 	}
+	if !vdiskConfig.Type.Persistent() {
+		return nil, errors.Wrapf(ErrVdiskNotPersistent, "vdisk %s", vdiskID)
+	}
 	nbdStorageConfig, err := config.ReadNBDStorageConfig(cs, vdiskID)
 	if err != nil {
 		return nil, err
@@ -147,6 +220,13 @@ func NewBlockStorage(cfg BlockStorageConfig, cluster, templateCluster ardb.Stora
 			cluster,
 			templateCluster)
 
+	case config.StorageInMemory:
+		return InMemory(
+			cfg.VdiskID,
+			cfg.BlockSize,
+			cfg.MemoryLimit,
+			cfg.SpillDirectory)
+
 	default:
 		return nil, errors.Newf(
 			"no block storage available for %s's storage type %s",
@@ -164,6 +244,9 @@ func VdiskExists(vdiskID string, source config.Source) (bool, error) {
 		return false, errors.Wrapf(err,
 			"cannot read static vdisk config for vdisk %s", vdiskID)
 	}
+	if !staticConfig.Type.Persistent() {
+		return false, errors.Wrapf(ErrVdiskNotPersistent, "vdisk %s", vdiskID)
+	}
 	nbdConfig, err := config.ReadVdiskNBDConfig(source, vdiskID)
 	if err != nil {
 		return false, errors.Wrapf(err,
@@ -264,6 +347,9 @@ func DeleteVdisk(vdiskID string, configSource config.Source) (bool, error) {
 	if err != nil {
 		return false, err
 	}
+	if !staticConfig.Type.Persistent() {
+		return false, errors.Wrapf(ErrVdiskNotPersistent, "vdisk %s", vdiskID)
+	}
 	nbdConfig, err := config.ReadVdiskNBDConfig(configSource, vdiskID)
 	if err != nil {
 		return false, err
@@ -311,6 +397,11 @@ func DeleteVdiskInCluster(vdiskID string, t config.VdiskType, cluster ardb.Stora
 		}
 	}
 
+	deletedDirtyBlocks, err := deleteDirtyBlocks(vdiskID, cluster)
+	if err != nil {
+		return false, err
+	}
+
 	var deletedStorage bool
 	switch st := t.StorageType(); st {
 	case config.StorageDeduped:
@@ -323,7 +414,7 @@ func DeleteVdiskInCluster(vdiskID string, t config.VdiskType, cluster ardb.Stora
 		err = errors.Newf("%v is not a supported storage type", st)
 	}
 
-	return deletedTlogMetadata || deletedStorage, err
+	return deletedTlogMetadata || deletedDirtyBlocks || deletedStorage, err
 }
 
 // ListVdisks scans a given storage cluster
@@ -477,6 +568,9 @@ func ListBlockIndices(vdiskID string, source config.Source) ([]int64, error) {
 	if err != nil {
 		return nil, err
 	}
+	if !staticConfig.Type.Persistent() {
+		return nil, errors.Wrapf(ErrVdiskNotPersistent, "vdisk %s", vdiskID)
+	}
 
 	nbdConfig, err := config.ReadNBDStorageConfig(source, vdiskID)
 	if err != nil {
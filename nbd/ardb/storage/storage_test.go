@@ -3,6 +3,7 @@ package storage
 import (
 	"bytes"
 	"crypto/rand"
+	"reflect"
 	"sync"
 	"testing"
 
@@ -40,6 +41,7 @@ func testBlockStorage(t *testing.T, storage BlockStorage) {
 	if content != nil {
 		t.Fatalf("found block %v, while expected nil-block", content)
 	}
+	testBlockAllocated(t, storage, testBlockIndexA, false)
 
 	// setting blocks should be always fine
 	for i := 0; i < 3; i++ {
@@ -57,6 +59,8 @@ func testBlockStorage(t *testing.T, storage BlockStorage) {
 	if len(content) < 2 || bytes.Compare(testContentA, content[:2]) != 0 {
 		t.Fatalf("unexpected content found: %v", content)
 	}
+	testBlockAllocated(t, storage, testBlockIndexA, true)
+	testBlockAllocated(t, storage, testBlockIndexB, false)
 
 	// deleting and getting non-existent block is still fine
 	err = storage.DeleteBlock(testBlockIndexB)
@@ -86,6 +90,7 @@ func testBlockStorage(t *testing.T, storage BlockStorage) {
 	if content != nil {
 		t.Fatalf("found content %v, while expected nil-content", content)
 	}
+	testBlockAllocated(t, storage, testBlockIndexA, false)
 
 	// Deleting content, should really delete it
 	err = storage.DeleteBlock(testBlockIndexB)
@@ -103,6 +108,56 @@ func testBlockStorage(t *testing.T, storage BlockStorage) {
 	}
 }
 
+// testBlockAllocated ensures the allocation status of a block is as expected,
+// both using the storage's own BlockAllocationChecker implementation,
+// and the content fallback used for storages which don't implement it
+func testBlockAllocated(t *testing.T, storage BlockStorage, blockIndex int64, expected bool) {
+	if _, ok := storage.(BlockAllocationChecker); !ok {
+		t.Fatalf("storage %T doesn't implement BlockAllocationChecker", storage)
+	}
+	allocated, err := IsBlockAllocated(storage, blockIndex)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if allocated != expected {
+		t.Fatalf("block %d allocated: %v, while expected %v", blockIndex, allocated, expected)
+	}
+	allocated, err = IsBlockAllocated(struct{ BlockStorage }{storage}, blockIndex)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if allocated != expected {
+		t.Fatalf("block %d allocated (fallback): %v, while expected %v", blockIndex, allocated, expected)
+	}
+
+	// the allocation status of a range containing the block,
+	// should match the allocation status of each individual block
+	if _, ok := storage.(BlockRangeAllocationChecker); !ok {
+		t.Fatalf("storage %T doesn't implement BlockRangeAllocationChecker", storage)
+	}
+	startIndex := blockIndex - 2
+	if startIndex < 0 {
+		startIndex = 0
+	}
+	const count = 5
+	allocatedBlocks, err := AllocatedBlocks(storage, startIndex, count)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fallbackAllocatedBlocks, err := AllocatedBlocks(struct{ BlockStorage }{storage}, startIndex, count)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(allocatedBlocks, fallbackAllocatedBlocks) {
+		t.Fatalf("blocks [%d, %d) allocated: %v, while expected %v",
+			startIndex, startIndex+count, allocatedBlocks, fallbackAllocatedBlocks)
+	}
+	if allocatedBlocks[blockIndex-startIndex] != expected {
+		t.Fatalf("block %d allocated (range): %v, while expected %v",
+			blockIndex, allocatedBlocks[blockIndex-startIndex], expected)
+	}
+}
+
 // shared test function to test all types of BlockStorage equally,
 // this gives us some confidence that all storages behave the same
 // from an end-user perspective
@@ -56,6 +56,8 @@ type Connection struct {
 	numInflight        int64                 // number of inflight requests
 	name               string                // the name of the connection for logging purposes
 	disconnectReceived int64                 // more then 0 if disconnect has been received
+	structuredReplies  bool                  // true if structured replies have been negotiated
+	allocationContext  bool                  // true if the base:allocation meta context has been selected
 
 	killCh    chan struct{} // closed by workers to indicate a hard close is required
 	killed    bool          // true if killCh closed already
@@ -67,15 +69,49 @@ type Backend interface {
 	WriteAt(ctx context.Context, b []byte, offset int64) (int64, error)     // write data to w at offset
 	WriteZeroesAt(ctx context.Context, offset, length int64) (int64, error) // write zeroes to w at offset
 	ReadAt(ctx context.Context, offset, length int64) ([]byte, error)       // read from o b at offset
-	TrimAt(ctx context.Context, offset, length int64) (int64, error)        // trim
+	TrimAt(ctx context.Context, offset, length int64) (int64, error)        // trim (length not limited by maximum BS)
 	Flush(ctx context.Context) error                                        // flush
 	Close(ctx context.Context) error                                        // close
 	Geometry(ctx context.Context) (Geometry, error)                         // size, minimum BS, preferred BS, maximum BS
 	HasFua(ctx context.Context) bool                                        // does the driver support FUA?
 	HasFlush(ctx context.Context) bool                                      // does the driver support flush?
+	HasTrim(ctx context.Context) bool                                       // does the driver support trim?
+	HasMultiConn(ctx context.Context) bool                                  // can the driver be used by multiple connections at once?
 	GoBackground(ctx context.Context)                                       // optional background thread
 }
 
+// SparseBackend is an optional interface which can be implemented by a Backend,
+// allowing it to report unallocated ranges (holes) while reading.
+// Holes are sent as OFFSET_HOLE chunks to clients which negotiated structured replies,
+// rather than sending the zeroes over the connection.
+type SparseBackend interface {
+	// ReadSparseAt reads from the backend at the given offset,
+	// the same way as ReadAt does, except that it returns a nil payload
+	// in case the range is unallocated, and thus should be read as zeroes.
+	ReadSparseAt(ctx context.Context, offset, length int64) ([]byte, error)
+}
+
+// Extent describes the allocation status of a range of a backend
+type Extent struct {
+	Length int64 // length of the range in bytes
+	Hole   bool  // true if the range is unallocated
+	Zero   bool  // true if the range reads as zeroes
+}
+
+// BlockStatusBackend is an optional interface which can be implemented by a Backend,
+// allowing it to report the allocation status of its content,
+// using the base:allocation meta context.
+// Backends which don't implement this interface report all their content as allocated.
+type BlockStatusBackend interface {
+	// BlockStatus returns the extents, in order, starting at the given offset.
+	// The extents should cover at least 1 byte, but can cover less than the given length.
+	BlockStatus(ctx context.Context, offset, length int64) ([]Extent, error)
+}
+
+// allocationContextID is the ID of the base:allocation meta context,
+// the only meta context supported
+const allocationContextID = 1
+
 // BackendGenerator is a generator function type that generates a backend
 type BackendGenerator func(ctx context.Context, e *ExportConfig) (Backend, error)
 
@@ -181,6 +217,156 @@ func (c *Connection) sendPayload(ctx context.Context, payload []byte) bool {
 	return true
 }
 
+// readResult is the result of reading a single part of a read request
+type readResult struct {
+	offset  uint64 // offset of the read part
+	length  uint64 // length of the read part
+	payload []byte // read payload, nil in case of a hole or error
+	hole    bool   // true if the read part is unallocated
+	err     error  // error which occurred while reading
+}
+
+// sendChunk sends a single structured reply chunk,
+// and returns true in case the sending was OK
+func (c *Connection) sendChunk(ctx context.Context, handle uint64, flags, replyType uint16, data ...interface{}) bool {
+	var body bytes.Buffer
+	for _, d := range data {
+		if err := binary.Write(&body, binary.BigEndian, d); err != nil {
+			c.logger.Infof("Client %s couldn't encode structured reply chunk: %s", c.name, err)
+			return false
+		}
+	}
+
+	var buffer bytes.Buffer
+	err := binary.Write(&buffer, binary.BigEndian, nbdStructuredReply{
+		NbdStructuredReplyMagic:  NBD_STRUCTURED_REPLY_MAGIC,
+		NbdStructuredReplyFlags:  flags,
+		NbdStructuredReplyType:   replyType,
+		NbdHandle:                handle,
+		NbdStructuredReplyLength: uint32(body.Len()),
+	})
+	if err != nil {
+		c.logger.Infof("Client %s couldn't send structured reply chunk", c.name)
+		return false
+	}
+	buffer.Write(body.Bytes())
+
+	return c.sendPayload(ctx, buffer.Bytes())
+}
+
+// sendReadChunk sends a read part as either an OFFSET_DATA or OFFSET_HOLE chunk,
+// and returns true in case the sending was OK
+func (c *Connection) sendReadChunk(ctx context.Context, handle uint64, flags uint16, part *readResult) bool {
+	if part.hole {
+		return c.sendChunk(ctx, handle, flags, NBD_REPLY_TYPE_OFFSET_HOLE, nbdStructuredReplyOffsetHole{
+			NbdOffset:   part.offset,
+			NbdHoleSize: uint32(part.length),
+		})
+	}
+	return c.sendChunk(ctx, handle, flags, NBD_REPLY_TYPE_OFFSET_DATA, part.offset, part.payload)
+}
+
+// sendStructuredRead sends the read parts of a read request,
+// in order, as a structured reply, merging consecutive holes into a single chunk.
+// A read error is sent as an ERROR_OFFSET chunk, ending the reply.
+// It returns true in case the sending was OK.
+func (c *Connection) sendStructuredRead(ctx context.Context, handle uint64, readChannels []chan readResult) bool {
+	var pending *readResult
+	for _, ch := range readChannels {
+		part := <-ch
+		if part.err != nil {
+			if pending != nil && !c.sendReadChunk(ctx, handle, 0, pending) {
+				return false
+			}
+			msg := []byte(part.err.Error())
+			if len(msg) > 4096 {
+				msg = msg[:4096]
+			}
+			return c.sendChunk(
+				ctx, handle, NBD_REPLY_FLAG_DONE, NBD_REPLY_TYPE_ERROR_OFFSET,
+				errorCodeFromGolangError(part.err), uint16(len(msg)), msg, part.offset)
+		}
+
+		if pending != nil {
+			if pending.hole && part.hole {
+				pending.length += part.length
+				continue
+			}
+			if !c.sendReadChunk(ctx, handle, 0, pending) {
+				return false
+			}
+		}
+		pending = &part
+	}
+
+	if pending == nil {
+		return c.sendChunk(ctx, handle, NBD_REPLY_FLAG_DONE, NBD_REPLY_TYPE_NONE)
+	}
+	return c.sendReadChunk(ctx, handle, NBD_REPLY_FLAG_DONE, pending)
+}
+
+// sendBlockStatus sends the block status of the given range,
+// for the base:allocation meta context, as a structured reply.
+// The range is limited to the maximum block size, and only the first extent is sent,
+// in case reqOne is true.
+// It returns true in case the sending was OK.
+func (c *Connection) sendBlockStatus(ctx context.Context, handle, offset, length uint64, reqOne bool) bool {
+	if length > c.export.maximumBlockSize {
+		length = c.export.maximumBlockSize
+	}
+
+	var extents []Extent
+	if backend, ok := c.backend.(BlockStatusBackend); ok {
+		var err error
+		extents, err = backend.BlockStatus(ctx, int64(offset), int64(length))
+		if err != nil {
+			c.logger.Infof("Client %s got block status I/O error: %s", c.name, err)
+			msg := []byte(err.Error())
+			if len(msg) > 4096 {
+				msg = msg[:4096]
+			}
+			return c.sendChunk(
+				ctx, handle, NBD_REPLY_FLAG_DONE, NBD_REPLY_TYPE_ERROR_OFFSET,
+				errorCodeFromGolangError(err), uint16(len(msg)), msg, offset)
+		}
+	}
+	if len(extents) == 0 {
+		// report the entire range as allocated
+		extents = []Extent{{Length: int64(length)}}
+	}
+
+	descriptors := make([]nbdBlockDescriptor, 0, len(extents))
+	remaining := int64(length)
+	for _, extent := range extents {
+		if extent.Length <= 0 || remaining <= 0 {
+			continue
+		}
+		if extent.Length > remaining {
+			extent.Length = remaining
+		}
+		remaining -= extent.Length
+
+		var flags uint32
+		if extent.Hole {
+			flags |= NBD_STATE_HOLE
+		}
+		if extent.Zero {
+			flags |= NBD_STATE_ZERO
+		}
+		descriptors = append(descriptors, nbdBlockDescriptor{
+			NbdLength:      uint32(extent.Length),
+			NbdStatusFlags: flags,
+		})
+		if reqOne {
+			break
+		}
+	}
+
+	return c.sendChunk(
+		ctx, handle, NBD_REPLY_FLAG_DONE, NBD_REPLY_TYPE_BLOCK_STATUS,
+		uint32(allocationContextID), descriptors)
+}
+
 // reply handles the sending of replies over the connection
 // done async over a goroutine
 func (c *Connection) reply(ctx context.Context) {
@@ -276,7 +462,12 @@ func (c *Connection) receive(ctx context.Context) {
 				return
 			}
 
-			if length&(c.export.minimumBlockSize-1) != 0 || req.NbdOffset&(c.export.minimumBlockSize-1) != 0 || length > c.export.maximumBlockSize {
+			maximumLength := c.export.maximumBlockSize
+			if flags&CMDT_UNLIMITED_LENGTH != 0 {
+				maximumLength = c.export.size
+			}
+
+			if length&(c.export.minimumBlockSize-1) != 0 || req.NbdOffset&(c.export.minimumBlockSize-1) != 0 || length > maximumLength {
 				c.logger.Infof("Client %s gave offset or length outside blocksize paramaters cmd=%d (len=%08x,off=%08x,minbs=%08x,maxbs=%08x)", c.name, req.NbdCommandType, req.NbdLength, req.NbdOffset, c.export.minimumBlockSize, c.export.maximumBlockSize)
 				return
 			}
@@ -330,7 +521,8 @@ func (c *Connection) receive(ctx context.Context) {
 		switch req.NbdCommandType {
 		case NBD_CMD_READ:
 			// be positive, and send header already!
-			if !c.sendHeader(ctx, nbdRep) {
+			// (structured replies send their header as part of each chunk instead)
+			if !c.structuredReplies && !c.sendHeader(ctx, nbdRep) {
 				return // ouch
 			}
 
@@ -341,24 +533,36 @@ func (c *Connection) receive(ctx context.Context) {
 				readParts++ // 1 extra because of block alignment
 			}
 
+			// holes are only reported when structured replies have been negotiated
+			sparseBackend, sparse := c.backend.(SparseBackend)
+			sparse = sparse && c.structuredReplies
+
 			// create channels for reading concurrently,
 			// while still replying in order
-			readChannels := make([]chan []byte, readParts)
+			readChannels := make([]chan readResult, readParts)
 			for i = 0; i < readParts; i++ {
-				readChannels[i] = make(chan []byte, 1)
-				go func(out chan []byte, offset int64, blocklen int64) {
-					payload, err := c.backend.ReadAt(ctx, offset, blocklen)
+				readChannels[i] = make(chan readResult, 1)
+				go func(out chan readResult, offset int64, blocklen int64) {
+					result := readResult{offset: uint64(offset), length: uint64(blocklen)}
+
+					var err error
+					if sparse {
+						result.payload, err = sparseBackend.ReadSparseAt(ctx, offset, blocklen)
+						result.hole = err == nil && result.payload == nil
+					} else {
+						result.payload, err = c.backend.ReadAt(ctx, offset, blocklen)
+					}
+
 					if err != nil {
 						c.logger.Infof("Client %s got read I/O error: %s", c.name, err)
-						out <- nil
-						return
-					} else if actualLength := int64(len(payload)); actualLength != blocklen {
+						result.payload, result.err = nil, err
+					} else if actualLength := int64(len(result.payload)); !result.hole && actualLength != blocklen {
 						c.logger.Infof("Client %s got incomplete read (%d != %d) at offset %d", c.name, actualLength, blocklen, offset)
-						out <- nil
-						return
+						result.payload = nil
+						result.err = errors.Newf("incomplete read (%d != %d)", actualLength, blocklen)
 					}
 
-					out <- payload
+					out <- result
 				}(readChannels[i], int64(offset), int64(blocklen))
 
 				length -= blocklen
@@ -370,14 +574,21 @@ func (c *Connection) receive(ctx context.Context) {
 				}
 			}
 
-			var payload []byte
+			if c.structuredReplies {
+				if !c.sendStructuredRead(ctx, req.NbdHandle, readChannels) {
+					return // an error occured
+				}
+				break
+			}
+
+			var result readResult
 			for i = 0; i < readParts; i++ {
-				payload = <-readChannels[i]
-				if payload == nil {
+				result = <-readChannels[i]
+				if result.err != nil {
 					return // an error occured
 				}
 
-				if !c.sendPayload(ctx, payload) {
+				if !c.sendPayload(ctx, result.payload) {
 					return // an error occured
 				}
 			}
@@ -439,10 +650,22 @@ func (c *Connection) receive(ctx context.Context) {
 		case NBD_CMD_WRITE_ZEROES:
 			wg := sync.WaitGroup{}
 
+			// the length of a write zeroes request isn't limited by the maximum block size,
+			// hence the amount of blocks written concurrently is limited instead
+			maxConcurrentWrites := c.export.maximumBlockSize / memoryBlockSize
+			if maxConcurrentWrites == 0 {
+				maxConcurrentWrites = 1
+			}
+			writeCh := make(chan struct{}, maxConcurrentWrites)
+
 			for blocklen > 0 {
 				wg.Add(1)
+				writeCh <- struct{}{}
 				go func(offset int64, blocklen int64) {
-					defer wg.Done()
+					defer func() {
+						<-writeCh
+						wg.Done()
+					}()
 					n, err := c.backend.WriteZeroesAt(ctx, offset, blocklen)
 					if err != nil {
 						c.logger.Infof("Client %s got write I/O error: %s", c.name, err)
@@ -479,32 +702,32 @@ func (c *Connection) receive(ctx context.Context) {
 			}
 
 		case NBD_CMD_TRIM:
-			wg := sync.WaitGroup{}
-
-			for blocklen > 0 {
-				wg.Add(1)
-				go func(offset int64, blocklen int64) {
-					defer wg.Done()
-					n, err := c.backend.TrimAt(ctx, offset, blocklen)
-					if err != nil {
-						c.logger.Infof("Client %s got trim I/O error: %s", c.name, err)
-						nbdRep.NbdError = errorCodeFromGolangError(err)
-					} else if int64(n) != blocklen {
-						c.logger.Infof("Client %s got incomplete trim (%d != %d) at offset %d", c.name, n, blocklen, offset)
-						nbdRep.NbdError = NBD_EIO
-					}
-				}(int64(offset), int64(blocklen))
-
-				length -= blocklen
-				offset += blocklen
+			// the entire range is trimmed at once,
+			// as a trim request isn't limited by the maximum block size,
+			// and only blocks fully covered by that range can be trimmed
+			n, err := c.backend.TrimAt(ctx, int64(offset), int64(length))
+			if err != nil {
+				c.logger.Infof("Client %s got trim I/O error: %s", c.name, err)
+				nbdRep.NbdError = errorCodeFromGolangError(err)
+			} else if uint64(n) != length {
+				c.logger.Infof("Client %s got incomplete trim (%d != %d) at offset %d", c.name, n, length, offset)
+				nbdRep.NbdError = NBD_EIO
+			}
 
-				blocklen = memoryBlockSize
-				if blocklen > length {
-					blocklen = length
+		case NBD_CMD_BLOCK_STATUS:
+			if !c.structuredReplies || !c.allocationContext {
+				c.logger.Infof("Client %s requested block status without selecting a meta context", c.name)
+				nbdRep.NbdError = NBD_EINVAL
+				if !c.sendHeader(ctx, nbdRep) {
+					return
 				}
+				break
 			}
 
-			wg.Wait()
+			reqOne := req.NbdCommandFlags&NBD_CMD_FLAG_REQ_ONE != 0
+			if !c.sendBlockStatus(ctx, req.NbdHandle, offset, length, reqOne) {
+				return // an error occured
+			}
 
 		case NBD_CMD_DISC:
 			c.waitForInflight(ctx, 1) // this request is itself in flight, so 1 is permissible
@@ -520,7 +743,7 @@ func (c *Connection) receive(ctx context.Context) {
 			return
 		}
 
-		if req.NbdCommandType != NBD_CMD_READ {
+		if req.NbdCommandType != NBD_CMD_READ && req.NbdCommandType != NBD_CMD_BLOCK_STATUS {
 			if !c.sendHeader(ctx, nbdRep) {
 				return
 			}
@@ -992,6 +1215,31 @@ func (c *Connection) negotiate(ctx context.Context) error {
 					return errors.Wrap(err, "TLS handshake failed")
 				}
 			}
+		case NBD_OPT_STRUCTURED_REPLY:
+			or := nbdOptReply{
+				NbdOptReplyMagic:  NBD_REP_MAGIC,
+				NbdOptID:          opt.NbdOptID,
+				NbdOptReplyType:   NBD_REP_ACK,
+				NbdOptReplyLength: 0,
+			}
+			if opt.NbdOptLen != 0 {
+				// this option doesn't carry any data
+				if err := skip(c.conn, opt.NbdOptLen); err != nil {
+					return err
+				}
+				or.NbdOptReplyType = NBD_REP_ERR_INVALID
+			}
+			if err := binary.Write(c.conn, binary.BigEndian, or); err != nil {
+				return errors.Wrap(err, "Cannot reply to structured reply option")
+			}
+			if or.NbdOptReplyType == NBD_REP_ACK {
+				c.logger.Debugf("Using structured replies for %s", c.name)
+				c.structuredReplies = true
+			}
+		case NBD_OPT_LIST_META_CONTEXT, NBD_OPT_SET_META_CONTEXT:
+			if err := c.negotiateMetaContext(opt); err != nil {
+				return err
+			}
 		case NBD_OPT_ABORT:
 			or := nbdOptReply{
 				NbdOptReplyMagic:  NBD_REP_MAGIC,
@@ -1025,6 +1273,114 @@ func (c *Connection) negotiate(ctx context.Context) error {
 	return nil
 }
 
+// negotiateMetaContext handles the list and set meta context options,
+// where base:allocation is the only meta context supported.
+func (c *Connection) negotiateMetaContext(opt nbdClientOpt) error {
+	data := make([]byte, opt.NbdOptLen)
+	if _, err := io.ReadFull(c.conn, data); err != nil {
+		return errors.Wrap(err, "Cannot read meta context option")
+	}
+
+	or := nbdOptReply{
+		NbdOptReplyMagic:  NBD_REP_MAGIC,
+		NbdOptID:          opt.NbdOptID,
+		NbdOptReplyType:   NBD_REP_ACK,
+		NbdOptReplyLength: 0,
+	}
+
+	exportName, queries, ok := parseMetaContextOption(data)
+	if !ok || (opt.NbdOptID == NBD_OPT_SET_META_CONTEXT && !c.structuredReplies) {
+		or.NbdOptReplyType = NBD_REP_ERR_INVALID
+	} else {
+		if exportName == "" {
+			exportName = c.listener.defaultExport
+		}
+		if _, err := c.listener.GetExportConfig(exportName); err != nil {
+			or.NbdOptReplyType = NBD_REP_ERR_UNKNOWN
+		}
+	}
+	if or.NbdOptReplyType != NBD_REP_ACK {
+		if err := binary.Write(c.conn, binary.BigEndian, or); err != nil {
+			return errors.Wrap(err, "Cannot reply to meta context option")
+		}
+		return nil
+	}
+
+	// an empty list query lists all meta contexts,
+	// while an empty set query deselects all meta contexts
+	selected := opt.NbdOptID == NBD_OPT_LIST_META_CONTEXT && len(queries) == 0
+	for _, query := range queries {
+		if query == NBD_META_CONTEXT_BASE_ALLOCATION ||
+			(opt.NbdOptID == NBD_OPT_LIST_META_CONTEXT && query == "base:") {
+			selected = true
+		}
+	}
+	if opt.NbdOptID == NBD_OPT_SET_META_CONTEXT {
+		c.allocationContext = selected
+	}
+
+	if selected {
+		name := []byte(NBD_META_CONTEXT_BASE_ALLOCATION)
+		mor := nbdOptReply{
+			NbdOptReplyMagic:  NBD_REP_MAGIC,
+			NbdOptID:          opt.NbdOptID,
+			NbdOptReplyType:   NBD_REP_META_CONTEXT,
+			NbdOptReplyLength: uint32(4 + len(name)),
+		}
+		if err := binary.Write(c.conn, binary.BigEndian, mor); err != nil {
+			return errors.Wrap(err, "Cannot send meta context")
+		}
+		if err := binary.Write(c.conn, binary.BigEndian, uint32(allocationContextID)); err != nil {
+			return errors.Wrap(err, "Cannot send meta context id")
+		}
+		if err := binary.Write(c.conn, binary.BigEndian, name); err != nil {
+			return errors.Wrap(err, "Cannot send meta context name")
+		}
+	}
+
+	if err := binary.Write(c.conn, binary.BigEndian, or); err != nil {
+		return errors.Wrap(err, "Cannot send meta context ack")
+	}
+	return nil
+}
+
+// parseMetaContextOption parses the export name and queries
+// of a list or set meta context option,
+// returning false in case the option data is invalid.
+func parseMetaContextOption(data []byte) (exportName string, queries []string, ok bool) {
+	readString := func() (string, bool) {
+		if len(data) < 4 {
+			return "", false
+		}
+		length := binary.BigEndian.Uint32(data)
+		data = data[4:]
+		if uint32(len(data)) < length {
+			return "", false
+		}
+		str := string(data[:length])
+		data = data[length:]
+		return str, true
+	}
+
+	if exportName, ok = readString(); !ok {
+		return
+	}
+	if len(data) < 4 {
+		return "", nil, false
+	}
+	count := binary.BigEndian.Uint32(data)
+	data = data[4:]
+	for i := uint32(0); i < count; i++ {
+		query, ok := readString()
+		if !ok {
+			return "", nil, false
+		}
+		queries = append(queries, query)
+	}
+
+	return exportName, queries, len(data) == 0
+}
+
 // skip bytes
 func skip(r io.Reader, n uint32) error {
 	for n > 0 {
@@ -1111,13 +1467,19 @@ func (c *Connection) connectExport(ctx context.Context, ec *ExportConfig) (*Expo
 		gem.MaximumBlockSize = gem.PreferredBlockSize
 	}
 
-	flags := uint16(NBD_FLAG_HAS_FLAGS | NBD_FLAG_SEND_WRITE_ZEROES | NBD_FLAG_SEND_CLOSE)
+	flags := uint16(NBD_FLAG_HAS_FLAGS | NBD_FLAG_SEND_WRITE_ZEROES)
 	if backend.HasFua(ctx) || forceFua {
 		flags |= NBD_FLAG_SEND_FUA
 	}
 	if backend.HasFlush(ctx) || forceFlush {
 		flags |= NBD_FLAG_SEND_FLUSH
 	}
+	if backend.HasTrim(ctx) {
+		flags |= NBD_FLAG_SEND_TRIM
+	}
+	if backend.HasMultiConn(ctx) {
+		flags |= NBD_FLAG_CAN_MULTI_CONN
+	}
 
 	c.logger.Debugf("generating backend %s, using %d flags, for %s", driver, flags, c.name)
 
@@ -69,6 +69,18 @@ func (fb *FileBackend) HasFlush(ctx context.Context) bool {
 	return true
 }
 
+// HasTrim implements Backend.HasTrim
+func (fb *FileBackend) HasTrim(ctx context.Context) bool {
+	return false
+}
+
+// HasMultiConn implements Backend.HasMultiConn,
+// all connections write to the same file,
+// and a flush syncs the writes of all connections.
+func (fb *FileBackend) HasMultiConn(ctx context.Context) bool {
+	return true
+}
+
 // GoBackground implements Backend.GoBackground
 func (fb *FileBackend) GoBackground(ctx context.Context) {
 	// No background thread needed
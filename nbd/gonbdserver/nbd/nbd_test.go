@@ -1,11 +1,14 @@
 package nbd
 
 import (
+	"bytes"
+	"context"
 	"crypto/tls"
 	"crypto/x509"
 	"encoding/binary"
 	"flag"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net"
 	"os"
@@ -543,3 +546,481 @@ func TestConnectionIntegrityHugeTls(t *testing.T) {
 		doTestConnectionIntegrity(t, []byte(testHugeTransactionLog), true, "file")
 	}
 }
+
+func (ni *NbdInstance) StructuredReply(t *testing.T) error {
+	opt := nbdClientOpt{
+		NbdOptMagic: NBD_OPTS_MAGIC,
+		NbdOptID:    NBD_OPT_STRUCTURED_REPLY,
+		NbdOptLen:   0,
+	}
+	if err := binary.Write(ni.conn, binary.BigEndian, opt); err != nil {
+		return errors.Wrap(err, "Could not send structured reply option")
+	}
+	var optReply nbdOptReply
+	if err := binary.Read(ni.conn, binary.BigEndian, &optReply); err != nil {
+		return errors.Wrap(err, "Could not receive structured reply option reply")
+	}
+	if optReply.NbdOptReplyMagic != NBD_REP_MAGIC {
+		return errors.Newf("structured reply option reply had wrong magic (%x)", optReply.NbdOptReplyMagic)
+	}
+	if optReply.NbdOptID != NBD_OPT_STRUCTURED_REPLY {
+		return errors.New("structured reply option reply had wrong id")
+	}
+	if optReply.NbdOptReplyType != NBD_REP_ACK {
+		return errors.New("structured reply option reply had wrong reply type")
+	}
+	if optReply.NbdOptReplyLength != 0 {
+		return errors.New("structured reply option reply had bogus length")
+	}
+	return nil
+}
+
+// sparseFileBackend is a FileBackend,
+// which reports all ranges filled with zeroes as holes
+type sparseFileBackend struct {
+	Backend
+}
+
+func (sb *sparseFileBackend) ReadSparseAt(ctx context.Context, offset, length int64) ([]byte, error) {
+	payload, err := sb.ReadAt(ctx, offset, length)
+	if err != nil {
+		return nil, err
+	}
+	for _, b := range payload {
+		if b != 0 {
+			return payload, nil
+		}
+	}
+	return nil, nil
+}
+
+func (sb *sparseFileBackend) BlockStatus(ctx context.Context, offset, length int64) ([]Extent, error) {
+	payload, err := sb.ReadAt(ctx, offset, length)
+	if err != nil {
+		return nil, err
+	}
+	var extents []Extent
+	for _, b := range payload {
+		hole := b == 0
+		if n := len(extents); n > 0 && extents[n-1].Hole == hole {
+			extents[n-1].Length++
+		} else {
+			extents = append(extents, Extent{Length: 1, Hole: hole, Zero: hole})
+		}
+	}
+	return extents, nil
+}
+
+func init() {
+	RegisterBackend("sparsefile", func(ctx context.Context, ec *ExportConfig) (Backend, error) {
+		backend, err := NewFileBackend(ctx, ec)
+		if err != nil {
+			return nil, err
+		}
+		return &sparseFileBackend{Backend: backend}, nil
+	})
+}
+
+type structuredReadChunk struct {
+	nbdStructuredReply
+	offset uint64
+	data   []byte
+	size   uint32
+}
+
+func (ni *NbdInstance) readStructuredChunks(t *testing.T, handle uint64) ([]structuredReadChunk, error) {
+	var chunks []structuredReadChunk
+	for {
+		var chunk structuredReadChunk
+		if err := binary.Read(ni.conn, binary.BigEndian, &chunk.nbdStructuredReply); err != nil {
+			return nil, errors.Wrap(err, "Could not receive structured reply chunk")
+		}
+		if chunk.NbdStructuredReplyMagic != NBD_STRUCTURED_REPLY_MAGIC {
+			return nil, errors.Newf("structured reply chunk had wrong magic (%x)", chunk.NbdStructuredReplyMagic)
+		}
+		if chunk.NbdHandle != handle {
+			return nil, errors.New("structured reply chunk had wrong handle")
+		}
+		// match the raw type values defined by the NBD spec,
+		// rather than our own constants, so a wrong constant can't go unnoticed
+		switch chunk.NbdStructuredReplyType {
+		case 0x0001: // NBD_REPLY_TYPE_OFFSET_DATA
+			if err := binary.Read(ni.conn, binary.BigEndian, &chunk.offset); err != nil {
+				return nil, errors.Wrap(err, "Could not receive data chunk offset")
+			}
+			chunk.data = make([]byte, chunk.NbdStructuredReplyLength-8)
+			if _, err := io.ReadFull(ni.conn, chunk.data); err != nil {
+				return nil, errors.Wrap(err, "Could not receive data chunk data")
+			}
+		case 0x0002: // NBD_REPLY_TYPE_OFFSET_HOLE
+			if err := binary.Read(ni.conn, binary.BigEndian, &chunk.offset); err != nil {
+				return nil, errors.Wrap(err, "Could not receive hole chunk offset")
+			}
+			if err := binary.Read(ni.conn, binary.BigEndian, &chunk.size); err != nil {
+				return nil, errors.Wrap(err, "Could not receive hole chunk size")
+			}
+		default:
+			return nil, errors.Newf("unexpected structured reply chunk type %d", chunk.NbdStructuredReplyType)
+		}
+		chunks = append(chunks, chunk)
+		if chunk.NbdStructuredReplyFlags&NBD_REPLY_FLAG_DONE != 0 {
+			return chunks, nil
+		}
+	}
+}
+
+func doTestConnectionStructuredRead(t *testing.T, driver string, expectHoles bool) {
+	const chunkSize = 32 * 1024 // preferred block size of the file backend
+
+	ni := StartNbd(t, TestConfig{Driver: driver, NoFlush: *noFlush})
+	defer ni.Close()
+
+	if err := ni.CreateFile(t, 1024*1024); err != nil {
+		t.Fatalf("Error on create file: %v", err)
+	}
+	if err := ni.Connect(t); err != nil {
+		t.Fatalf("Error on connect: %v", err)
+	}
+	if err := ni.StructuredReply(t); err != nil {
+		t.Fatalf("Error on structured reply: %v", err)
+	}
+	if err := ni.Go(t); err != nil {
+		t.Fatalf("Error on go: %v", err)
+	}
+	ni.conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	// write data in the 3rd chunk only
+	data := make([]byte, chunkSize)
+	for i := range data {
+		data[i] = byte(i%255) + 1
+	}
+	writeHandle := getHandle()
+	err := binary.Write(ni.conn, binary.BigEndian, nbdRequest{
+		NbdRequestMagic: NBD_REQUEST_MAGIC,
+		NbdCommandType:  NBD_CMD_WRITE,
+		NbdHandle:       writeHandle,
+		NbdOffset:       2 * chunkSize,
+		NbdLength:       chunkSize,
+	})
+	if err != nil {
+		t.Fatalf("Could not send write command: %v", err)
+	}
+	if _, err := ni.conn.Write(data); err != nil {
+		t.Fatalf("Could not send write payload: %v", err)
+	}
+	var rep nbdReply
+	if err := binary.Read(ni.conn, binary.BigEndian, &rep); err != nil {
+		t.Fatalf("Could not receive write reply: %v", err)
+	}
+	if rep.NbdReplyMagic != NBD_REPLY_MAGIC || rep.NbdHandle != writeHandle || rep.NbdError != 0 {
+		t.Fatalf("Unexpected write reply: %+v", rep)
+	}
+
+	// read the first 8 chunks
+	readHandle := getHandle()
+	err = binary.Write(ni.conn, binary.BigEndian, nbdRequest{
+		NbdRequestMagic: NBD_REQUEST_MAGIC,
+		NbdCommandType:  NBD_CMD_READ,
+		NbdHandle:       readHandle,
+		NbdOffset:       0,
+		NbdLength:       8 * chunkSize,
+	})
+	if err != nil {
+		t.Fatalf("Could not send read command: %v", err)
+	}
+	chunks, err := ni.readStructuredChunks(t, readHandle)
+	if err != nil {
+		t.Fatalf("Could not receive structured read reply: %v", err)
+	}
+
+	// ensure the chunks cover the entire read range, in order
+	expected := make([]byte, 8*chunkSize)
+	copy(expected[2*chunkSize:], data)
+	payload := make([]byte, 0, len(expected))
+	holes := 0
+	for _, chunk := range chunks {
+		if chunk.offset != uint64(len(payload)) {
+			t.Fatalf("Unexpected chunk offset %d (expected %d)", chunk.offset, len(payload))
+		}
+		if chunk.NbdStructuredReplyType == 0x0002 { // NBD_REPLY_TYPE_OFFSET_HOLE
+			holes++
+			payload = append(payload, make([]byte, chunk.size)...)
+		} else {
+			payload = append(payload, chunk.data...)
+		}
+	}
+	if !bytes.Equal(expected, payload) {
+		t.Fatalf("Unexpected structured read payload")
+	}
+
+	if expectHoles {
+		// hole, data, hole
+		if len(chunks) != 3 || holes != 2 {
+			t.Fatalf("Expected 2 merged holes and 1 data chunk, received %d chunks (%d holes)", len(chunks), holes)
+		}
+	} else if holes != 0 {
+		t.Fatalf("Expected no holes, received %d", holes)
+	}
+
+	if err := ni.Disconnect(t); err != nil {
+		t.Fatalf("Error on disconnect: %v", err)
+	}
+}
+
+func TestConnectionStructuredRead(t *testing.T) {
+	doTestConnectionStructuredRead(t, "file", false)
+}
+
+func TestConnectionStructuredSparseRead(t *testing.T) {
+	doTestConnectionStructuredRead(t, "sparsefile", true)
+}
+
+func (ni *NbdInstance) SetMetaContext(t *testing.T, queries ...string) ([]string, error) {
+	export := "foo"
+
+	var data bytes.Buffer
+	binary.Write(&data, binary.BigEndian, uint32(len(export)))
+	data.WriteString(export)
+	binary.Write(&data, binary.BigEndian, uint32(len(queries)))
+	for _, query := range queries {
+		binary.Write(&data, binary.BigEndian, uint32(len(query)))
+		data.WriteString(query)
+	}
+
+	opt := nbdClientOpt{
+		NbdOptMagic: NBD_OPTS_MAGIC,
+		NbdOptID:    NBD_OPT_SET_META_CONTEXT,
+		NbdOptLen:   uint32(data.Len()),
+	}
+	if err := binary.Write(ni.conn, binary.BigEndian, opt); err != nil {
+		return nil, errors.Wrap(err, "Could not send set meta context option")
+	}
+	if _, err := ni.conn.Write(data.Bytes()); err != nil {
+		return nil, errors.Wrap(err, "Could not send set meta context option data")
+	}
+
+	var contexts []string
+	for {
+		var optReply nbdOptReply
+		if err := binary.Read(ni.conn, binary.BigEndian, &optReply); err != nil {
+			return nil, errors.Wrap(err, "Could not receive set meta context option reply")
+		}
+		if optReply.NbdOptReplyMagic != NBD_REP_MAGIC {
+			return nil, errors.Newf("set meta context option reply had wrong magic (%x)", optReply.NbdOptReplyMagic)
+		}
+		if optReply.NbdOptID != NBD_OPT_SET_META_CONTEXT {
+			return nil, errors.New("set meta context option reply had wrong id")
+		}
+		switch optReply.NbdOptReplyType {
+		case NBD_REP_ACK:
+			return contexts, nil
+		case NBD_REP_META_CONTEXT:
+			var contextID uint32
+			if err := binary.Read(ni.conn, binary.BigEndian, &contextID); err != nil {
+				return nil, errors.Wrap(err, "Could not receive meta context id")
+			}
+			name := make([]byte, optReply.NbdOptReplyLength-4)
+			if _, err := io.ReadFull(ni.conn, name); err != nil {
+				return nil, errors.Wrap(err, "Could not receive meta context name")
+			}
+			contexts = append(contexts, string(name))
+		default:
+			return nil, errors.Newf("set meta context option reply type %x was unexpected", optReply.NbdOptReplyType)
+		}
+	}
+}
+
+func TestConnectionBlockStatus(t *testing.T) {
+	const chunkSize = 32 * 1024
+
+	ni := StartNbd(t, TestConfig{Driver: "sparsefile", NoFlush: *noFlush})
+	defer ni.Close()
+
+	if err := ni.CreateFile(t, 1024*1024); err != nil {
+		t.Fatalf("Error on create file: %v", err)
+	}
+	if err := ni.Connect(t); err != nil {
+		t.Fatalf("Error on connect: %v", err)
+	}
+	if err := ni.StructuredReply(t); err != nil {
+		t.Fatalf("Error on structured reply: %v", err)
+	}
+	contexts, err := ni.SetMetaContext(t, "foo:bar", NBD_META_CONTEXT_BASE_ALLOCATION)
+	if err != nil {
+		t.Fatalf("Error on set meta context: %v", err)
+	}
+	if len(contexts) != 1 || contexts[0] != NBD_META_CONTEXT_BASE_ALLOCATION {
+		t.Fatalf("Unexpected meta contexts: %v", contexts)
+	}
+	if err := ni.Go(t); err != nil {
+		t.Fatalf("Error on go: %v", err)
+	}
+	ni.conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	// write data in the 2nd chunk only
+	data := make([]byte, chunkSize)
+	for i := range data {
+		data[i] = byte(i%255) + 1
+	}
+	writeHandle := getHandle()
+	err = binary.Write(ni.conn, binary.BigEndian, nbdRequest{
+		NbdRequestMagic: NBD_REQUEST_MAGIC,
+		NbdCommandType:  NBD_CMD_WRITE,
+		NbdHandle:       writeHandle,
+		NbdOffset:       chunkSize,
+		NbdLength:       chunkSize,
+	})
+	if err != nil {
+		t.Fatalf("Could not send write command: %v", err)
+	}
+	if _, err := ni.conn.Write(data); err != nil {
+		t.Fatalf("Could not send write payload: %v", err)
+	}
+	var rep nbdReply
+	if err := binary.Read(ni.conn, binary.BigEndian, &rep); err != nil {
+		t.Fatalf("Could not receive write reply: %v", err)
+	}
+	if rep.NbdReplyMagic != NBD_REPLY_MAGIC || rep.NbdHandle != writeHandle || rep.NbdError != 0 {
+		t.Fatalf("Unexpected write reply: %+v", rep)
+	}
+
+	// request the block status of the first 4 chunks
+	handle := getHandle()
+	err = binary.Write(ni.conn, binary.BigEndian, nbdRequest{
+		NbdRequestMagic: NBD_REQUEST_MAGIC,
+		NbdCommandType:  NBD_CMD_BLOCK_STATUS,
+		NbdHandle:       handle,
+		NbdOffset:       0,
+		NbdLength:       4 * chunkSize,
+	})
+	if err != nil {
+		t.Fatalf("Could not send block status command: %v", err)
+	}
+	var chunk nbdStructuredReply
+	if err := binary.Read(ni.conn, binary.BigEndian, &chunk); err != nil {
+		t.Fatalf("Could not receive block status reply: %v", err)
+	}
+	if chunk.NbdStructuredReplyMagic != NBD_STRUCTURED_REPLY_MAGIC || chunk.NbdHandle != handle ||
+		chunk.NbdStructuredReplyType != 0x0005 || // NBD_REPLY_TYPE_BLOCK_STATUS
+		chunk.NbdStructuredReplyFlags&NBD_REPLY_FLAG_DONE == 0 {
+		t.Fatalf("Unexpected block status reply: %+v", chunk)
+	}
+	var contextID uint32
+	if err := binary.Read(ni.conn, binary.BigEndian, &contextID); err != nil {
+		t.Fatalf("Could not receive block status context id: %v", err)
+	}
+	descriptors := make([]nbdBlockDescriptor, (chunk.NbdStructuredReplyLength-4)/8)
+	if err := binary.Read(ni.conn, binary.BigEndian, &descriptors); err != nil {
+		t.Fatalf("Could not receive block status descriptors: %v", err)
+	}
+
+	expected := []nbdBlockDescriptor{
+		{NbdLength: chunkSize, NbdStatusFlags: NBD_STATE_HOLE | NBD_STATE_ZERO},
+		{NbdLength: chunkSize, NbdStatusFlags: 0},
+		{NbdLength: 2 * chunkSize, NbdStatusFlags: NBD_STATE_HOLE | NBD_STATE_ZERO},
+	}
+	if len(descriptors) != len(expected) {
+		t.Fatalf("Unexpected block status descriptors: %+v", descriptors)
+	}
+	for i := range expected {
+		if descriptors[i] != expected[i] {
+			t.Fatalf("Unexpected block status descriptors: %+v", descriptors)
+		}
+	}
+
+	if err := ni.Disconnect(t); err != nil {
+		t.Fatalf("Error on disconnect: %v", err)
+	}
+}
+
+// trimFileBackend is a FileBackend,
+// which limits its maximum block size to 32 MiB,
+// and records all ranges it is requested to trim
+type trimFileBackend struct {
+	Backend
+}
+
+// trimmedRanges contains all ranges trimmed by a trimFileBackend
+var trimmedRanges struct {
+	mux    sync.Mutex
+	ranges [][2]int64
+}
+
+func (tb *trimFileBackend) TrimAt(ctx context.Context, offset, length int64) (int64, error) {
+	trimmedRanges.mux.Lock()
+	trimmedRanges.ranges = append(trimmedRanges.ranges, [2]int64{offset, length})
+	trimmedRanges.mux.Unlock()
+	return tb.Backend.TrimAt(ctx, offset, length)
+}
+
+func (tb *trimFileBackend) Geometry(ctx context.Context) (Geometry, error) {
+	geometry, err := tb.Backend.Geometry(ctx)
+	geometry.MaximumBlockSize = 32 * 1024 * 1024
+	return geometry, err
+}
+
+func init() {
+	RegisterBackend("trimfile", func(ctx context.Context, ec *ExportConfig) (Backend, error) {
+		backend, err := NewFileBackend(ctx, ec)
+		if err != nil {
+			return nil, err
+		}
+		return &trimFileBackend{Backend: backend}, nil
+	})
+}
+
+func TestConnectionUnlimitedLength(t *testing.T) {
+	const (
+		maximumBlockSize = 32 * 1024 * 1024
+		length           = 2 * maximumBlockSize
+	)
+
+	ni := StartNbd(t, TestConfig{Driver: "trimfile", NoFlush: *noFlush})
+	defer ni.Close()
+
+	if err := ni.CreateFile(t, 2*length); err != nil {
+		t.Fatalf("Error on create file: %v", err)
+	}
+	if err := ni.Connect(t); err != nil {
+		t.Fatalf("Error on connect: %v", err)
+	}
+	if err := ni.Go(t); err != nil {
+		t.Fatalf("Error on go: %v", err)
+	}
+	ni.conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	// both trim and write zeroes requests can be bigger than the maximum block size,
+	// without the connection being dropped
+	for _, cmd := range []uint16{NBD_CMD_TRIM, NBD_CMD_WRITE_ZEROES} {
+		handle := getHandle()
+		err := binary.Write(ni.conn, binary.BigEndian, nbdRequest{
+			NbdRequestMagic: NBD_REQUEST_MAGIC,
+			NbdCommandType:  cmd,
+			NbdHandle:       handle,
+			NbdOffset:       maximumBlockSize,
+			NbdLength:       length,
+		})
+		if err != nil {
+			t.Fatalf("Could not send command %d: %v", cmd, err)
+		}
+		var rep nbdReply
+		if err := binary.Read(ni.conn, binary.BigEndian, &rep); err != nil {
+			t.Fatalf("Could not receive reply of command %d: %v", cmd, err)
+		}
+		if rep.NbdReplyMagic != NBD_REPLY_MAGIC || rep.NbdHandle != handle || rep.NbdError != 0 {
+			t.Fatalf("Unexpected reply of command %d: %+v", cmd, rep)
+		}
+	}
+
+	// the entire range is trimmed at once
+	trimmedRanges.mux.Lock()
+	ranges := trimmedRanges.ranges
+	trimmedRanges.mux.Unlock()
+	if len(ranges) != 1 || ranges[0] != [2]int64{maximumBlockSize, length} {
+		t.Fatalf("Unexpected trimmed ranges: %v", ranges)
+	}
+
+	if err := ni.Disconnect(t); err != nil {
+		t.Fatalf("Error on disconnect: %v", err)
+	}
+}
@@ -15,13 +15,15 @@ const (
 	NBD_CMD_FLUSH        = 3
 	NBD_CMD_TRIM         = 4
 	NBD_CMD_WRITE_ZEROES = 6
+	NBD_CMD_BLOCK_STATUS = 7
 )
 
 // NBD command flags
 const (
-	NBD_CMD_FLAG_FUA = uint16(1 << 0)
-	NBD_CMD_MAY_TRIM = uint16(1 << 1)
-	NBD_CMD_FLAG_DF  = uint16(1 << 2)
+	NBD_CMD_FLAG_FUA     = uint16(1 << 0)
+	NBD_CMD_MAY_TRIM     = uint16(1 << 1)
+	NBD_CMD_FLAG_DF      = uint16(1 << 2)
+	NBD_CMD_FLAG_REQ_ONE = uint16(1 << 3)
 )
 
 // NBD negotiation flags
@@ -34,7 +36,7 @@ const (
 	NBD_FLAG_SEND_TRIM         = uint16(1 << 5)
 	NBD_FLAG_SEND_WRITE_ZEROES = uint16(1 << 6)
 	NBD_FLAG_SEND_DF           = uint16(1 << 7)
-	NBD_FLAG_SEND_CLOSE        = uint16(1 << 8)
+	NBD_FLAG_CAN_MULTI_CONN    = uint16(1 << 8)
 )
 
 // NBD magic numbers
@@ -55,14 +57,16 @@ const (
 
 // NBD options
 const (
-	NBD_OPT_EXPORT_NAME      = 1
-	NBD_OPT_ABORT            = 2
-	NBD_OPT_LIST             = 3
-	NBD_OPT_PEEK_EXPORT      = 4
-	NBD_OPT_STARTTLS         = 5
-	NBD_OPT_INFO             = 6
-	NBD_OPT_GO               = 7
-	NBD_OPT_STRUCTURED_REPLY = 8
+	NBD_OPT_EXPORT_NAME       = 1
+	NBD_OPT_ABORT             = 2
+	NBD_OPT_LIST              = 3
+	NBD_OPT_PEEK_EXPORT       = 4
+	NBD_OPT_STARTTLS          = 5
+	NBD_OPT_INFO              = 6
+	NBD_OPT_GO                = 7
+	NBD_OPT_STRUCTURED_REPLY  = 8
+	NBD_OPT_LIST_META_CONTEXT = 9
+	NBD_OPT_SET_META_CONTEXT  = 10
 )
 
 // NBD option reply types
@@ -70,6 +74,7 @@ const (
 	NBD_REP_ACK                 = uint32(1)
 	NBD_REP_SERVER              = uint32(2)
 	NBD_REP_INFO                = uint32(3)
+	NBD_REP_META_CONTEXT        = uint32(4)
 	NBD_REP_FLAG_ERROR          = uint32(1 << 31)
 	NBD_REP_ERR_UNSUP           = uint32(1 | NBD_REP_FLAG_ERROR)
 	NBD_REP_ERR_POLICY          = uint32(2 | NBD_REP_FLAG_ERROR)
@@ -89,10 +94,22 @@ const (
 // NBD reply types
 const (
 	NBD_REPLY_TYPE_NONE         = 0
-	NBD_REPLY_TYPE_ERROR        = 1
-	NBD_REPLY_TYPE_ERROR_OFFSET = 2
-	NBD_REPLY_TYPE_OFFSET_DATA  = 3
-	NBD_REPLY_TYPE_OFFSET_HOLE  = 4
+	NBD_REPLY_TYPE_OFFSET_DATA  = 1
+	NBD_REPLY_TYPE_OFFSET_HOLE  = 2
+	NBD_REPLY_TYPE_BLOCK_STATUS = 5
+	NBD_REPLY_TYPE_ERROR        = 1<<15 + 1
+	NBD_REPLY_TYPE_ERROR_OFFSET = 1<<15 + 2
+)
+
+// NBD meta contexts
+const (
+	NBD_META_CONTEXT_BASE_ALLOCATION = "base:allocation"
+)
+
+// NBD base:allocation block status flags
+const (
+	NBD_STATE_HOLE = uint32(1 << 0)
+	NBD_STATE_ZERO = uint32(1 << 1)
 )
 
 // NBD hanshake flags
@@ -175,6 +192,27 @@ type nbdReply struct {
 	NbdHandle     uint64
 }
 
+// NBD structured reply chunk header
+type nbdStructuredReply struct {
+	NbdStructuredReplyMagic  uint32
+	NbdStructuredReplyFlags  uint16
+	NbdStructuredReplyType   uint16
+	NbdHandle                uint64
+	NbdStructuredReplyLength uint32
+}
+
+// NBD structured reply offset hole chunk payload
+type nbdStructuredReplyOffsetHole struct {
+	NbdOffset   uint64
+	NbdHoleSize uint32
+}
+
+// NBD structured reply block status descriptor
+type nbdBlockDescriptor struct {
+	NbdLength      uint32
+	NbdStatusFlags uint32
+}
+
 // NBD info export
 type nbdInfoExport struct {
 	NbdInfoType          uint16
@@ -200,6 +238,7 @@ const (
 	CMDT_REP_PAYLOAD                         // reply carries a payload
 	CMDT_CHECK_NOT_READ_ONLY                 // not valid on read-only media
 	CMDT_SET_DISCONNECT_RECEIVED             // a disconnect - don't process any further commands
+	CMDT_UNLIMITED_LENGTH                    // length is not limited by the maximum block size
 )
 
 // CmdTypeMap is a map specifying each command
@@ -208,6 +247,7 @@ var CmdTypeMap = map[int]uint64{
 	NBD_CMD_WRITE:        CMDT_CHECK_LENGTH_OFFSET | CMDT_CHECK_NOT_READ_ONLY | CMDT_REQ_PAYLOAD,
 	NBD_CMD_DISC:         CMDT_SET_DISCONNECT_RECEIVED,
 	NBD_CMD_FLUSH:        CMDT_CHECK_NOT_READ_ONLY,
-	NBD_CMD_TRIM:         CMDT_CHECK_LENGTH_OFFSET | CMDT_CHECK_NOT_READ_ONLY,
-	NBD_CMD_WRITE_ZEROES: CMDT_CHECK_LENGTH_OFFSET | CMDT_CHECK_NOT_READ_ONLY | CMDT_REQ_FAKE_PAYLOAD,
+	NBD_CMD_TRIM:         CMDT_CHECK_LENGTH_OFFSET | CMDT_CHECK_NOT_READ_ONLY | CMDT_UNLIMITED_LENGTH,
+	NBD_CMD_WRITE_ZEROES: CMDT_CHECK_LENGTH_OFFSET | CMDT_CHECK_NOT_READ_ONLY | CMDT_REQ_FAKE_PAYLOAD | CMDT_UNLIMITED_LENGTH,
+	NBD_CMD_BLOCK_STATUS: CMDT_CHECK_LENGTH_OFFSET | CMDT_UNLIMITED_LENGTH,
 }
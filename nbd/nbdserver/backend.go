@@ -148,6 +148,18 @@ func (ab *backend) merge(blockIndex, offset int64, content []byte) error {
 
 // ReadAt implements nbd.Backend.ReadAt
 func (ab *backend) ReadAt(ctx context.Context, offset, length int64) (payload []byte, err error) {
+	return ab.readAt(offset, length, false)
+}
+
+// ReadSparseAt implements nbd.SparseBackend.ReadSparseAt,
+// returning a nil payload in case the block isn't stored.
+func (ab *backend) ReadSparseAt(ctx context.Context, offset, length int64) (payload []byte, err error) {
+	return ab.readAt(offset, length, true)
+}
+
+// readAt reads a payload from a single block,
+// returning a nil payload for a block which isn't stored, in case sparse is true.
+func (ab *backend) readAt(offset, length int64, sparse bool) (payload []byte, err error) {
 	blockIndex := offset / ab.blockSize
 
 	// try to read the payload
@@ -155,6 +167,10 @@ func (ab *backend) ReadAt(ctx context.Context, offset, length int64) (payload []
 	if err != nil {
 		return
 	}
+	if sparse && len(payload) == 0 {
+		ab.vdiskStatsLogger.LogReadOperation(length)
+		return nil, nil
+	}
 
 	// calculate the local offset and the length of the read payload
 	offsetInsideBlock := offset % ab.blockSize
@@ -181,9 +197,72 @@ func (ab *backend) ReadAt(ctx context.Context, offset, length int64) (payload []
 	return
 }
 
-// TrimAt implements nbd.Backend.TrimAt
+// TrimAt implements nbd.Backend.TrimAt,
+// deleting all blocks which are fully covered by the given range.
+// Partially covered blocks are left untouched,
+// which is allowed as a trim is only a hint to the backend.
 func (ab *backend) TrimAt(ctx context.Context, offset, length int64) (int64, error) {
-	return 0, nil
+	// first block fully covered, and the block right after the last block fully covered
+	startBlockIndex := (offset + ab.blockSize - 1) / ab.blockSize
+	endBlockIndex := (offset + length) / ab.blockSize
+
+	for blockIndex := startBlockIndex; blockIndex < endBlockIndex; blockIndex++ {
+		err := ab.storage.DeleteBlock(blockIndex)
+		if err != nil {
+			log.Debugf(
+				"backend failed to TrimAt %d (offset=%d, length=%d): %s",
+				blockIndex, offset, length, err.Error())
+			return 0, err
+		}
+	}
+
+	return length, nil
+}
+
+// BlockStatus implements nbd.BlockStatusBackend.BlockStatus,
+// reporting blocks which aren't stored as holes which read as zeroes.
+func (ab *backend) BlockStatus(ctx context.Context, offset, length int64) ([]nbd.Extent, error) {
+	if length <= 0 {
+		return nil, nil
+	}
+
+	// check all blocks of the range at once
+	end := offset + length
+	startBlockIndex := offset / ab.blockSize
+	blockCount := (end-1)/ab.blockSize - startBlockIndex + 1
+	allocatedBlocks, err := storage.AllocatedBlocks(ab.storage, startBlockIndex, blockCount)
+	if err != nil {
+		log.Debugf(
+			"backend failed to get block status of %d blocks starting at %d (offset=%d, length=%d): %s",
+			blockCount, startBlockIndex, offset, length, err.Error())
+		return nil, err
+	}
+
+	var extents []nbd.Extent
+	for offset < end {
+		blockIndex := offset / ab.blockSize
+		extentLength := (blockIndex+1)*ab.blockSize - offset
+		if offset+extentLength > end {
+			extentLength = end - offset
+		}
+
+		allocated := allocatedBlocks[blockIndex-startBlockIndex]
+
+		// merge consecutive blocks with the same status into a single extent
+		if n := len(extents); n > 0 && extents[n-1].Hole == !allocated {
+			extents[n-1].Length += extentLength
+		} else {
+			extents = append(extents, nbd.Extent{
+				Length: extentLength,
+				Hole:   !allocated,
+				Zero:   !allocated,
+			})
+		}
+
+		offset += extentLength
+	}
+
+	return extents, nil
 }
 
 // Flush implements nbd.Backend.Flush
@@ -229,6 +308,18 @@ func (ab *backend) HasFlush(ctx context.Context) bool {
 	return true
 }
 
+// HasTrim implements nbd.Backend.HasTrim
+// Yes, we support trim
+func (ab *backend) HasTrim(ctx context.Context) bool {
+	return true
+}
+
+// HasMultiConn implements nbd.Backend.HasMultiConn
+// Yes, as all connections of a vdisk share the same backend
+func (ab *backend) HasMultiConn(ctx context.Context) bool {
+	return true
+}
+
 // GoBackground implements Backend.GoBackground
 // ensuring that a backend gracefully exists when a SIGTERM signal is received.
 func (ab *backend) GoBackground(ctx context.Context) {
@@ -2,6 +2,8 @@ package main
 
 import (
 	"context"
+	"crypto/tls"
+	"sync"
 
 	"github.com/zero-os/0-Disk/config"
 	"github.com/zero-os/0-Disk/errors"
@@ -15,9 +17,12 @@ import (
 
 // backendFactoryConfig is used to create a new BackendFactory
 type backendFactoryConfig struct {
-	LBACacheLimit int64         // min-capped to LBA.BytesPerSector
-	ConfigSource  config.Source // config source
-	TlogPrivKey   string        // tlog private key
+	LBACacheLimit  int64         // min-capped to LBA.BytesPerSector
+	ConfigSource   config.Source // config source
+	TlogPrivKey    string        // tlog private key
+	TlogTLSConfig  *tls.Config   // optional TLS config, used to connect to the tlog servers
+	TmpMemoryLimit int64         // memory limit (in bytes) of a tmp vdisk, 0 means no limit
+	TmpSpillDir    string        // directory used by tmp vdisks once their memory limit is reached
 }
 
 // Validate all the parameters of this BackendFactoryConfig,
@@ -40,10 +45,14 @@ func newBackendFactory(cfg backendFactoryConfig) (*backendFactory, error) {
 	}
 
 	return &backendFactory{
-		lbaCacheLimit: cfg.LBACacheLimit,
-		configSource:  cfg.ConfigSource,
-		vdiskComp:     newVdiskCompletion(),
-		tlogPrivKey:   cfg.TlogPrivKey,
+		lbaCacheLimit:  cfg.LBACacheLimit,
+		configSource:   cfg.ConfigSource,
+		vdiskComp:      newVdiskCompletion(),
+		tlogPrivKey:    cfg.TlogPrivKey,
+		tlogTLSConfig:  cfg.TlogTLSConfig,
+		tmpMemoryLimit: cfg.TmpMemoryLimit,
+		tmpSpillDir:    cfg.TmpSpillDir,
+		backends:       make(map[string]*sharedBackend),
 	}, nil
 }
 
@@ -51,10 +60,17 @@ func newBackendFactory(cfg backendFactoryConfig) (*backendFactory, error) {
 // that can not be passed in the exportconfig like the config source.
 // Its NewBackend method is used as the ardb backend generator.
 type backendFactory struct {
-	lbaCacheLimit int64
-	configSource  config.Source
-	vdiskComp     *vdiskCompletion
-	tlogPrivKey   string
+	lbaCacheLimit  int64
+	configSource   config.Source
+	vdiskComp      *vdiskCompletion
+	tlogPrivKey    string
+	tlogTLSConfig  *tls.Config
+	tmpMemoryLimit int64
+	tmpSpillDir    string
+
+	// backends shared by all connections of the same vdisk
+	backends   map[string]*sharedBackend
+	backendMux sync.Mutex
 }
 
 type closers []Closer
@@ -71,28 +87,140 @@ func (cs closers) Close() error {
 	return nil
 }
 
-// NewBackend generates a new ardb backend
-func (f *backendFactory) NewBackend(ctx context.Context, ec *nbd.ExportConfig) (backend nbd.Backend, err error) {
+// NewBackend returns an ardb backend for the vdisk of the given export,
+// shared by all connections of that vdisk, such that they can be used concurrently.
+// The shared backend is only created for the first connection,
+// and closed when the last connection using it is closed.
+func (f *backendFactory) NewBackend(ctx context.Context, ec *nbd.ExportConfig) (nbd.Backend, error) {
 	vdiskID := ec.Name
 
+	f.backendMux.Lock()
+	defer f.backendMux.Unlock()
+
+	shared, ok := f.backends[vdiskID]
+	if !ok {
+		// the shared backend outlives the connection it is created for,
+		// and thus can't use the context of that connection
+		backendCtx, cancel := context.WithCancel(context.Background())
+		backend, err := f.newBackend(backendCtx, vdiskID)
+		if err != nil {
+			cancel()
+			return nil, err
+		}
+		shared = &sharedBackend{
+			backend: backend,
+			cancel:  cancel,
+		}
+		f.backends[vdiskID] = shared
+
+		// start the background thread once per shared backend,
+		// it exits when the shared backend is closed
+		go backend.GoBackground(backendCtx)
+	} else {
+		log.Infof("sharing existing backend for vdisk `%v`", vdiskID)
+	}
+
+	shared.refCount++
+	return &sharedBackendRef{
+		backend: shared.backend,
+		factory: f,
+		vdiskID: vdiskID,
+	}, nil
+}
+
+// releaseBackend releases a reference to the shared backend of a given vdisk,
+// closing it in case it was the last reference to that backend.
+func (f *backendFactory) releaseBackend(ctx context.Context, vdiskID string) error {
+	f.backendMux.Lock()
+	defer f.backendMux.Unlock()
+
+	shared, ok := f.backends[vdiskID]
+	if !ok {
+		return nil
+	}
+	shared.refCount--
+	if shared.refCount > 0 {
+		return nil
+	}
+
+	log.Infof("closing shared backend for vdisk `%v`", vdiskID)
+	delete(f.backends, vdiskID)
+	err := shared.backend.Close(ctx)
+	shared.cancel()
+	return err
+}
+
+// newBackend creates a new ardb backend for a given vdisk
+func (f *backendFactory) newBackend(ctx context.Context, vdiskID string) (*backend, error) {
 	log.Infof("creating new backend for vdisk `%v`", vdiskID)
 
 	// fetch static config
 	staticConfig, err := config.ReadVdiskStaticConfig(f.configSource, vdiskID)
 	if err != nil {
 		log.Error(err)
-		return
+		return nil, err
 	}
 
 	blockSize := int64(staticConfig.BlockSize)
 
 	var resourceCloser closers
+	var blockStorage storage.BlockStorage
 
-	// create primary cluster
-	primaryCluster, err := storage.NewPrimaryCluster(ctx, vdiskID, f.configSource)
+	if staticConfig.Type.Persistent() {
+		blockStorage, resourceCloser, err = f.newPersistentBlockStorage(ctx, vdiskID, staticConfig)
+	} else {
+		// non-persistent vdisks are served from memory (or a local spill file),
+		// and their content is released as soon as the backend is closed
+		log.Infof("creating in-memory storage for backend %v (%v)", vdiskID, staticConfig.Type)
+		blockStorage, err = storage.NewBlockStorage(
+			storage.BlockStorageConfig{
+				VdiskID:        vdiskID,
+				VdiskType:      staticConfig.Type,
+				BlockSize:      blockSize,
+				MemoryLimit:    f.tmpMemoryLimit,
+				SpillDirectory: f.tmpSpillDir,
+			}, nil, nil)
+	}
 	if err != nil {
 		log.Error(err)
-		return
+		return nil, err
+	}
+
+	// create statistics loggers
+	vdiskLogger, err := statistics.NewVdiskLogger(ctx, f.configSource, vdiskID)
+	if err != nil {
+		blockStorage.Close()
+		resourceCloser.Close()
+		log.Infof("couldn't create vdisk logger: %s", err.Error())
+		return nil, err
+	}
+
+	// Create the actual ARDB backend
+	return newBackend(
+		vdiskID,
+		staticConfig.Size*uint64(ardb.GibibyteAsBytes),
+		blockSize,
+		blockStorage,
+		f.vdiskComp,
+		resourceCloser,
+		vdiskLogger,
+	), nil
+}
+
+// newPersistentBlockStorage creates the block storage of a persistent vdisk,
+// storing its content in the primary (and optionally template) storage cluster,
+// tracking the blocks it modifies, and wrapping it with a tlog storage if the vdisk has tlog support.
+func (f *backendFactory) newPersistentBlockStorage(ctx context.Context, vdiskID string, staticConfig *config.VdiskStaticConfig) (storage.BlockStorage, closers, error) {
+	blockSize := int64(staticConfig.BlockSize)
+
+	var resourceCloser closers
+
+	// create primary cluster,
+	// which uses the servers of the slave cluster (if defined) in place of offline primary servers,
+	// and repairs (or respreads) primary servers marked as such, as the nbdserver serves this vdisk
+	primaryCluster, err := storage.NewSelfHealingPrimaryCluster(ctx, vdiskID, f.configSource)
+	if err != nil {
+		return nil, nil, err
 	}
 	resourceCloser = append(resourceCloser, primaryCluster)
 
@@ -103,8 +231,7 @@ func (f *backendFactory) NewBackend(ctx context.Context, ec *nbd.ExportConfig) (
 		templateCluster, err = storage.NewTemplateCluster(ctx, vdiskID, true, f.configSource)
 		if err != nil {
 			resourceCloser.Close()
-			log.Error(err)
-			return
+			return nil, nil, err
 		}
 		resourceCloser = append(resourceCloser, templateCluster)
 	}
@@ -119,10 +246,13 @@ func (f *backendFactory) NewBackend(ctx context.Context, ec *nbd.ExportConfig) (
 		}, primaryCluster, templateCluster)
 	if err != nil {
 		resourceCloser.Close()
-		log.Error(err)
-		return
+		return nil, nil, err
 	}
 
+	// track all blocks modified by the nbdserver,
+	// such that the vdisk can be exported incrementally
+	blockStorage = storage.DirtyTracking(vdiskID, blockStorage, primaryCluster)
+
 	// If the vdisk has tlog support,
 	// the storage is wrapped with a tlog storage,
 	// which sends all write transactions to the tlog server via an embbed tlog client.
@@ -134,52 +264,65 @@ func (f *backendFactory) NewBackend(ctx context.Context, ec *nbd.ExportConfig) (
 			blockStorage.Close()
 			resourceCloser.Close()
 			log.Infof("couldn't vdisk %s's NBD config: %s", vdiskID, err.Error())
-			return nil, err
+			return nil, nil, err
 		}
 		if vdiskNBDConfig.TlogServerClusterID != "" {
 			log.Infof("creating tlogStorage for backend %v (%v)", vdiskID, staticConfig.Type)
 			tlogBlockStorage, err := tlog.Storage(ctx,
-				vdiskID, f.tlogPrivKey,
+				vdiskID, f.tlogPrivKey, f.tlogTLSConfig,
 				f.configSource, blockSize, blockStorage, primaryCluster, nil)
 			if err != nil {
 				blockStorage.Close()
 				resourceCloser.Close()
 				log.Infof("couldn't create tlog storage: %s", err.Error())
-				return nil, err
+				return nil, nil, err
 			}
 			blockStorage = tlogBlockStorage
 		}
 	}
 
-	// create statistics loggers
-	vdiskLogger, err := statistics.NewVdiskLogger(ctx, f.configSource, vdiskID)
-	if err != nil {
-		blockStorage.Close()
-		resourceCloser.Close()
-		log.Infof("couldn't create vdisk logger: %s", err.Error())
-		return nil, err
-	}
+	return blockStorage, resourceCloser, nil
+}
 
-	// Create the actual ARDB backend
-	backend = newBackend(
-		vdiskID,
-		staticConfig.Size*uint64(ardb.GibibyteAsBytes),
-		blockSize,
-		blockStorage,
-		f.vdiskComp,
-		resourceCloser,
-		vdiskLogger,
-	)
+// sharedBackend is an ardb backend shared by all connections of a single vdisk
+type sharedBackend struct {
+	backend  *backend
+	refCount int
+	cancel   context.CancelFunc // cancels the context of the shared backend
+}
 
+// sharedBackendRef is the nbd.Backend used by a single connection,
+// referencing the backend shared by all connections of its vdisk.
+// Closing it only closes the shared backend,
+// in case it is the last reference to that backend.
+type sharedBackendRef struct {
+	*backend
+	factory   *backendFactory
+	vdiskID   string
+	closeOnce sync.Once
+}
+
+// Close implements nbd.Backend.Close
+func (ref *sharedBackendRef) Close(ctx context.Context) (err error) {
+	ref.closeOnce.Do(func() {
+		err = ref.factory.releaseBackend(ctx, ref.vdiskID)
+	})
 	return
 }
 
+// GoBackground implements nbd.Backend.GoBackground,
+// the background thread of the shared backend is already started by the backendFactory,
+// so this only waits until the connection is done.
+func (ref *sharedBackendRef) GoBackground(ctx context.Context) {
+	<-ctx.Done()
+}
+
 // StopAndWait stops all vdisk and waits for vdisks completion.
 // It only stop and wait for vdisk which has vdiskCompletion
 // attached.
 // It returns errors from vdisk that exited
 // because of context cancellation.
-func (f backendFactory) StopAndWait() []error {
+func (f *backendFactory) StopAndWait() []error {
 	f.vdiskComp.StopAll()
 	return f.vdiskComp.Wait()
 }
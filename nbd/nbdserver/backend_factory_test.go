@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zero-os/0-Disk/config"
+	"github.com/zero-os/0-Disk/nbd/gonbdserver/nbd"
+)
+
+func TestBackendFactorySharedBackend(t *testing.T) {
+	const (
+		vdiskID   = "a"
+		blockSize = 512
+	)
+
+	source := config.NewStubSource()
+	source.SetVdiskConfig(vdiskID, &config.VdiskStaticConfig{
+		BlockSize: blockSize,
+		Size:      1,
+		Type:      config.VdiskTypeTmp,
+	})
+
+	factory, err := newBackendFactory(backendFactoryConfig{ConfigSource: source})
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	ec := &nbd.ExportConfig{Name: vdiskID}
+
+	// both connections share the same backend
+	backendA, err := factory.NewBackend(ctx, ec)
+	require.NoError(t, err)
+	backendB, err := factory.NewBackend(ctx, ec)
+	require.NoError(t, err)
+	assert.True(t, backendA.HasMultiConn(ctx))
+
+	content := make([]byte, blockSize)
+	for i := range content {
+		content[i] = byte(i%255) + 1
+	}
+	_, err = backendA.WriteAt(ctx, content, blockSize)
+	require.NoError(t, err)
+	payload, err := backendB.ReadAt(ctx, blockSize, blockSize)
+	require.NoError(t, err)
+	assert.Equal(t, content, payload)
+
+	// closing one connection keeps the shared backend alive,
+	// even when closed multiple times
+	require.NoError(t, backendA.Close(ctx))
+	require.NoError(t, backendA.Close(ctx))
+	payload, err = backendB.ReadAt(ctx, blockSize, blockSize)
+	require.NoError(t, err)
+	assert.Equal(t, content, payload)
+
+	// closing the last connection closes the shared backend,
+	// releasing the content of this tmp vdisk
+	require.NoError(t, backendB.Close(ctx))
+	assert.Empty(t, factory.backends)
+
+	backendC, err := factory.NewBackend(ctx, ec)
+	require.NoError(t, err)
+	defer backendC.Close(ctx)
+	payload, err = backendC.ReadAt(ctx, blockSize, blockSize)
+	require.NoError(t, err)
+	assert.Equal(t, make([]byte, blockSize), payload)
+}
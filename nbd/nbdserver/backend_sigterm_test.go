@@ -64,7 +64,7 @@ func TestBackendSigtermHandler(t *testing.T) {
 			Servers: []string{tlogrpc},
 		})
 
-		tls, err := tlog.Storage(ctx, vdiskID, tlogPrivKey, source, blockSize, storage, cluster, nil)
+		tls, err := tlog.Storage(ctx, vdiskID, tlogPrivKey, nil, source, blockSize, storage, cluster, nil)
 		require.NoError(t, err)
 		require.NotNil(t, tls)
 
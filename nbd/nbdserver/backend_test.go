@@ -7,6 +7,7 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/zero-os/0-Disk/nbd/ardb"
 	"github.com/zero-os/0-Disk/nbd/ardb/storage"
+	"github.com/zero-os/0-Disk/nbd/gonbdserver/nbd"
 	"github.com/zero-os/0-Disk/redisstub"
 )
 
@@ -183,6 +184,333 @@ func testBackendReadWrite(ctx context.Context, t *testing.T, vdiskID string, blo
 	}
 }
 
+func TestDedupedBackendTrim(t *testing.T) {
+	const (
+		vdiskID   = "a"
+		size      = 64
+		blockSize = 8
+	)
+
+	cluster := redisstub.NewUniCluster(true)
+	defer cluster.Close()
+
+	storage, err := storage.Deduped(
+		vdiskID, blockSize,
+		ardb.DefaultLBACacheLimit, cluster, nil)
+	if err != nil || storage == nil {
+		t.Fatalf("storage could not be created: %v", err)
+	}
+
+	ctx := context.Background()
+	testBackendTrim(ctx, t, vdiskID, blockSize, size, storage)
+}
+
+func TestNonDedupedBackendTrim(t *testing.T) {
+	const (
+		vdiskID   = "a"
+		size      = 64
+		blockSize = 8
+	)
+
+	cluster := redisstub.NewUniCluster(true)
+	defer cluster.Close()
+
+	storage, err := storage.NonDeduped(vdiskID, "", blockSize, cluster, nil)
+	if err != nil || storage == nil {
+		t.Fatalf("storage could not be created: %v", err)
+	}
+
+	ctx := context.Background()
+	testBackendTrim(ctx, t, vdiskID, blockSize, size, storage)
+}
+
+func testBackendTrim(ctx context.Context, t *testing.T, vdiskID string, blockSize int64, size uint64, storage storage.BlockStorage) {
+	if !assert.NotNil(t, storage) {
+		return
+	}
+
+	vComp := newVdiskCompletion()
+	backend := newBackend(vdiskID, size, blockSize, storage, vComp, nil, dummyVdiskLogger{})
+	if !assert.NotNil(t, backend) {
+		return
+	}
+	go backend.GoBackground(ctx)
+	defer backend.Close(ctx)
+
+	assert.True(t, backend.HasTrim(ctx))
+
+	blockCount := int64(size) / blockSize
+	someContent := make([]byte, blockSize)
+	for i := range someContent {
+		someContent[i] = byte(i%255) + 1
+	}
+	nilContent := make([]byte, blockSize)
+
+	// write all blocks
+	for index := int64(0); index < blockCount; index++ {
+		bw, err := backend.WriteAt(ctx, someContent, index*blockSize)
+		if !assert.NoError(t, err) || !assert.Equal(t, blockSize, bw) {
+			return
+		}
+	}
+
+	// trim the 2nd half of the 1st block up to the 1st half of the 4th block,
+	// which should only delete the 2nd and 3rd block
+	offset, length := blockSize/2, blockSize*3
+	bt, err := backend.TrimAt(ctx, offset, length)
+	if !assert.NoError(t, err) || !assert.Equal(t, length, bt) {
+		return
+	}
+	if !assert.NoError(t, backend.Flush(ctx)) {
+		return
+	}
+
+	for index := int64(0); index < blockCount; index++ {
+		payload, err := backend.ReadAt(ctx, index*blockSize, blockSize)
+		if !assert.NoError(t, err) {
+			return
+		}
+		if index == 1 || index == 2 {
+			assert.Equal(t, nilContent, payload, "block %d", index)
+		} else {
+			assert.Equal(t, someContent, payload, "block %d", index)
+		}
+	}
+
+	// a range which covers no block fully, shouldn't delete anything
+	bt, err = backend.TrimAt(ctx, blockSize*4+1, blockSize-2)
+	if !assert.NoError(t, err) || !assert.Equal(t, blockSize-2, bt) {
+		return
+	}
+	payload, err := backend.ReadAt(ctx, blockSize*4, blockSize)
+	if assert.NoError(t, err) {
+		assert.Equal(t, someContent, payload)
+	}
+
+	// trim the entire vdisk
+	bt, err = backend.TrimAt(ctx, 0, int64(size))
+	if !assert.NoError(t, err) || !assert.Equal(t, int64(size), bt) {
+		return
+	}
+	for index := int64(0); index < blockCount; index++ {
+		payload, err := backend.ReadAt(ctx, index*blockSize, blockSize)
+		if assert.NoError(t, err) {
+			assert.Equal(t, nilContent, payload, "block %d", index)
+		}
+	}
+}
+
+func TestDedupedBackendReadSparse(t *testing.T) {
+	const (
+		vdiskID   = "a"
+		size      = 64
+		blockSize = 8
+	)
+
+	cluster := redisstub.NewUniCluster(true)
+	defer cluster.Close()
+
+	storage, err := storage.Deduped(
+		vdiskID, blockSize,
+		ardb.DefaultLBACacheLimit, cluster, nil)
+	if err != nil || storage == nil {
+		t.Fatalf("storage could not be created: %v", err)
+	}
+
+	ctx := context.Background()
+	testBackendReadSparse(ctx, t, vdiskID, blockSize, size, storage)
+}
+
+func TestNonDedupedBackendReadSparse(t *testing.T) {
+	const (
+		vdiskID   = "a"
+		size      = 64
+		blockSize = 8
+	)
+
+	cluster := redisstub.NewUniCluster(true)
+	defer cluster.Close()
+
+	storage, err := storage.NonDeduped(vdiskID, "", blockSize, cluster, nil)
+	if err != nil || storage == nil {
+		t.Fatalf("storage could not be created: %v", err)
+	}
+
+	ctx := context.Background()
+	testBackendReadSparse(ctx, t, vdiskID, blockSize, size, storage)
+}
+
+func testBackendReadSparse(ctx context.Context, t *testing.T, vdiskID string, blockSize int64, size uint64, storage storage.BlockStorage) {
+	if !assert.NotNil(t, storage) {
+		return
+	}
+
+	vComp := newVdiskCompletion()
+	backend := newBackend(vdiskID, size, blockSize, storage, vComp, nil, dummyVdiskLogger{})
+	if !assert.NotNil(t, backend) {
+		return
+	}
+	go backend.GoBackground(ctx)
+	defer backend.Close(ctx)
+
+	someContent := make([]byte, blockSize)
+	for i := range someContent {
+		someContent[i] = byte(i%255) + 1
+	}
+
+	// write only the 2nd block
+	bw, err := backend.WriteAt(ctx, someContent, blockSize)
+	if !assert.NoError(t, err) || !assert.Equal(t, blockSize, bw) {
+		return
+	}
+	if !assert.NoError(t, backend.Flush(ctx)) {
+		return
+	}
+
+	// unwritten blocks are holes
+	payload, err := backend.ReadSparseAt(ctx, 0, blockSize)
+	if assert.NoError(t, err) {
+		assert.Nil(t, payload)
+	}
+	payload, err = backend.ReadSparseAt(ctx, blockSize*2+2, blockSize/2)
+	if assert.NoError(t, err) {
+		assert.Nil(t, payload)
+	}
+
+	// written blocks are read as usual
+	payload, err = backend.ReadSparseAt(ctx, blockSize, blockSize)
+	if assert.NoError(t, err) {
+		assert.Equal(t, someContent, payload)
+	}
+	payload, err = backend.ReadSparseAt(ctx, blockSize+2, blockSize/2)
+	if assert.NoError(t, err) {
+		assert.Equal(t, someContent[2:2+blockSize/2], payload)
+	}
+
+	// a regular read never returns a hole
+	payload, err = backend.ReadAt(ctx, 0, blockSize)
+	if assert.NoError(t, err) {
+		assert.Equal(t, make([]byte, blockSize), payload)
+	}
+}
+
+func TestDedupedBackendBlockStatus(t *testing.T) {
+	const (
+		vdiskID   = "a"
+		size      = 64
+		blockSize = 8
+	)
+
+	cluster := redisstub.NewUniCluster(true)
+	defer cluster.Close()
+
+	storage, err := storage.Deduped(
+		vdiskID, blockSize,
+		ardb.DefaultLBACacheLimit, cluster, nil)
+	if err != nil || storage == nil {
+		t.Fatalf("storage could not be created: %v", err)
+	}
+
+	ctx := context.Background()
+	testBackendBlockStatus(ctx, t, vdiskID, blockSize, size, storage)
+}
+
+func TestNonDedupedBackendBlockStatus(t *testing.T) {
+	const (
+		vdiskID   = "a"
+		size      = 64
+		blockSize = 8
+	)
+
+	cluster := redisstub.NewUniCluster(true)
+	defer cluster.Close()
+
+	storage, err := storage.NonDeduped(vdiskID, "", blockSize, cluster, nil)
+	if err != nil || storage == nil {
+		t.Fatalf("storage could not be created: %v", err)
+	}
+
+	ctx := context.Background()
+	testBackendBlockStatus(ctx, t, vdiskID, blockSize, size, storage)
+}
+
+func TestSemiDedupedBackendBlockStatus(t *testing.T) {
+	const (
+		vdiskID   = "a"
+		size      = 64
+		blockSize = 8
+	)
+
+	cluster := redisstub.NewUniCluster(true)
+	defer cluster.Close()
+
+	storage, err := storage.SemiDeduped(
+		vdiskID, blockSize,
+		ardb.DefaultLBACacheLimit, cluster, nil)
+	if err != nil || storage == nil {
+		t.Fatalf("storage could not be created: %v", err)
+	}
+
+	ctx := context.Background()
+	testBackendBlockStatus(ctx, t, vdiskID, blockSize, size, storage)
+}
+
+func testBackendBlockStatus(ctx context.Context, t *testing.T, vdiskID string, blockSize int64, size uint64, storage storage.BlockStorage) {
+	if !assert.NotNil(t, storage) {
+		return
+	}
+
+	vComp := newVdiskCompletion()
+	backend := newBackend(vdiskID, size, blockSize, storage, vComp, nil, dummyVdiskLogger{})
+	if !assert.NotNil(t, backend) {
+		return
+	}
+	go backend.GoBackground(ctx)
+	defer backend.Close(ctx)
+
+	someContent := make([]byte, blockSize)
+	for i := range someContent {
+		someContent[i] = byte(i%255) + 1
+	}
+
+	// an empty vdisk is a single hole
+	extents, err := backend.BlockStatus(ctx, 0, int64(size))
+	if assert.NoError(t, err) {
+		assert.Equal(t, []nbd.Extent{
+			{Length: int64(size), Hole: true, Zero: true},
+		}, extents)
+	}
+
+	// write the 3rd and 4th block
+	for index := int64(2); index < 4; index++ {
+		bw, err := backend.WriteAt(ctx, someContent, index*blockSize)
+		if !assert.NoError(t, err) || !assert.Equal(t, blockSize, bw) {
+			return
+		}
+	}
+	if !assert.NoError(t, backend.Flush(ctx)) {
+		return
+	}
+
+	extents, err = backend.BlockStatus(ctx, 0, int64(size))
+	if assert.NoError(t, err) {
+		assert.Equal(t, []nbd.Extent{
+			{Length: 2 * blockSize, Hole: true, Zero: true},
+			{Length: 2 * blockSize},
+			{Length: int64(size) - 4*blockSize, Hole: true, Zero: true},
+		}, extents)
+	}
+
+	// unaligned ranges are supported as well
+	extents, err = backend.BlockStatus(ctx, blockSize+2, blockSize*2)
+	if assert.NoError(t, err) {
+		assert.Equal(t, []nbd.Extent{
+			{Length: blockSize - 2, Hole: true, Zero: true},
+			{Length: blockSize + 2},
+		}, extents)
+	}
+}
+
 type dummyVdiskLogger struct{}
 
 func (vl dummyVdiskLogger) LogReadOperation(bytes int64)  {}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"crypto/tls"
 	"flag"
 	"fmt"
 	"net/http"
@@ -15,9 +16,11 @@ import (
 	"github.com/zero-os/0-Disk"
 	"github.com/zero-os/0-Disk/config"
 	"github.com/zero-os/0-Disk/log"
+	"github.com/zero-os/0-Disk/metrics"
 	"github.com/zero-os/0-Disk/nbd/ardb"
 	"github.com/zero-os/0-Disk/nbd/ardb/storage/lba"
 	"github.com/zero-os/0-Disk/nbd/gonbdserver/nbd"
+	"github.com/zero-os/0-Disk/tlog"
 )
 
 func main() {
@@ -26,17 +29,22 @@ func main() {
 	var verbose bool
 	var lbacachelimit int64
 	var profileAddress string
+	var metricsAddress string
 	var protocol string
 	var address string
 	var sourceConfig config.SourceConfig
 	var logPath string
 	var serverID string
 	var tlogPrivKey string
+	var tmpMemoryLimit int64
+	var tmpSpillDir string
+	var tlogTLSConfig tlog.TLSConfig
 
 	flag.BoolVar(&verbose, "v", false, "when false, only log warnings and errors")
 	flag.StringVar(&logPath, "logfile", "", "optionally log to the specified file, instead of the stderr")
 	flag.BoolVar(&tlsonly, "tlsonly", false, "Forces all nbd connections to be tls-enabled")
 	flag.StringVar(&profileAddress, "profile-address", "", "Enables profiling of this server as an http service")
+	flag.StringVar(&metricsAddress, "metrics-address", "", "Enables Prometheus metrics of this server as an http service, served on the /metrics path")
 	flag.StringVar(&protocol, "protocol", "unix", "Protocol to listen on, 'tcp' or 'unix'")
 	flag.StringVar(&address, "address", "/tmp/nbd-socket", "Address to listen on, unix socket or tcp address, ':6666' for example")
 	flag.Var(&sourceConfig, "config", "config resource: dialstrings (etcd cluster) or path (yaml file)")
@@ -45,6 +53,18 @@ func main() {
 	flag.StringVar(&serverID, "id", "default", "The server ID (default: default)")
 	flag.BoolVar(&version, "version", false, "prints build version and exits")
 	flag.StringVar(&tlogPrivKey, "tlog-priv-key", "", "32 bytes tlog private key")
+	flag.Int64Var(&tmpMemoryLimit, "tmp-memory-limit", 0,
+		"Memory limit in bytes of a single tmp vdisk, blocks are spilled to a local file once reached (0 = no limit)")
+	flag.StringVar(&tmpSpillDir, "tmp-spill-dir", "",
+		"Directory used for the spill files of tmp vdisks (default: the default directory for temporary files)")
+	flag.StringVar(&tlogTLSConfig.CertFile, "tlog-tls-cert", "",
+		"PEM-encoded file containing the TLS client cert, used to connect to tlog servers (mutual TLS is enabled when given)")
+	flag.StringVar(&tlogTLSConfig.KeyFile, "tlog-tls-key", "",
+		"PEM-encoded file containing the private TLS client key, used to connect to tlog servers")
+	flag.StringVar(&tlogTLSConfig.CAFile, "tlog-tls-ca", "",
+		"PEM-encoded file containing the TLS CA Pool, used to verify the certs of tlog servers")
+	flag.StringVar(&tlogTLSConfig.ServerName, "tlog-tls-server", "",
+		"name used to verify the certs of tlog servers (defaults to the host of each tlog server)")
 
 	flag.Parse()
 
@@ -72,14 +92,21 @@ func main() {
 
 	zerodisk.LogVersion()
 
-	log.Debugf("flags parsed: tlsonly=%t profileaddress=%q protocol=%q address=%q config=%q lbacachelimit=%d logfile=%q id=%q",
+	log.Debugf("flags parsed: tlsonly=%t profileaddress=%q metricsaddress=%q protocol=%q address=%q config=%q lbacachelimit=%d logfile=%q id=%q tmpmemorylimit=%d tmpspilldir=%q tlogtlscert=%q tlogtlskey=%q tlogtlsca=%q tlogtlsserver=%q",
 		tlsonly,
 		profileAddress,
+		metricsAddress,
 		protocol, address,
 		sourceConfig.String(),
 		lbacachelimit,
 		logPath,
 		serverID,
+		tmpMemoryLimit,
+		tmpSpillDir,
+		tlogTLSConfig.CertFile,
+		tlogTLSConfig.KeyFile,
+		tlogTLSConfig.CAFile,
+		tlogTLSConfig.ServerName,
 	)
 
 	// let's create the source and defer close it
@@ -94,6 +121,15 @@ func main() {
 		log.Fatal(err)
 	}
 
+	// mutual TLS for the tlog connections is optional
+	var tlogTLS *tls.Config
+	if tlogTLSConfig.CertFile != "" || tlogTLSConfig.KeyFile != "" || tlogTLSConfig.CAFile != "" {
+		tlogTLS, err = tlogTLSConfig.ClientConfig()
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
 	if len(profileAddress) > 0 {
 		go func() {
 			log.Info("profiling enabled, available on", profileAddress)
@@ -104,6 +140,16 @@ func main() {
 		}()
 	}
 
+	if len(metricsAddress) > 0 {
+		go func() {
+			log.Info("metrics enabled, available on", metricsAddress)
+			err := metrics.ListenAndServe(metricsAddress)
+			if err != nil {
+				log.Info("metrics server couldn't be started:", err)
+			}
+		}()
+	}
+
 	ctx, cancelFunc := context.WithCancel(context.Background())
 
 	var sessionWaitGroup sync.WaitGroup
@@ -122,9 +168,12 @@ func main() {
 	}
 
 	backendFactory, err := newBackendFactory(backendFactoryConfig{
-		ConfigSource:  configSource,
-		LBACacheLimit: lbacachelimit,
-		TlogPrivKey:   tlogPrivKey,
+		ConfigSource:   configSource,
+		LBACacheLimit:  lbacachelimit,
+		TlogPrivKey:    tlogPrivKey,
+		TlogTLSConfig:  tlogTLS,
+		TmpMemoryLimit: tmpMemoryLimit,
+		TmpSpillDir:    tmpSpillDir,
 	})
 	handleSigterm(backendFactory, cancelFunc)
 
@@ -5,17 +5,21 @@ import (
 	"math/big"
 	"time"
 
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/zero-os/0-Disk/config"
 	"github.com/zero-os/0-Disk/log"
+	"github.com/zero-os/0-Disk/metrics"
 )
 
 // VdiskLogger defines an nbd  statistics logger interface
 type VdiskLogger interface {
 	// LogReadOperation logs a read operation,
-	// using it to keep track of the read IOPS and read throughput (in KiB/s).
+	// using it to keep track of the read IOPS and read throughput (in KiB/s),
+	// as well as the read operation and byte metrics.
 	LogReadOperation(bytes int64)
 	// LogWriteOperation logs a write operation,
-	// using it to keep track of the write IOPS and write throughput (in KiB/s).
+	// using it to keep track of the write IOPS and write throughput (in KiB/s),
+	// as well as the write operation and byte metrics.
 	LogWriteOperation(bytes int64)
 
 	// Close all open resources and
@@ -26,14 +30,26 @@ type VdiskLogger interface {
 // NewVdiskLogger creates a new VdiskLogger which
 // tracks the read and write operations of a vdisk for statistics purposes.
 func NewVdiskLogger(ctx context.Context, configSource config.Source, vdiskID string) (VdiskLogger, error) {
-	ctx, cancel := context.WithCancel(ctx)
-
-	configCh, err := config.WatchVdiskNBDConfig(ctx, configSource, vdiskID)
+	staticConfig, err := config.ReadVdiskStaticConfig(configSource, vdiskID)
 	if err != nil {
-		cancel()
 		return nil, err
 	}
-	cfg := <-configCh
+
+	ctx, cancel := context.WithCancel(ctx)
+
+	// non-persistent vdisks have no NBD config,
+	// and thus no cluster tags either
+	var configCh <-chan config.VdiskNBDConfig
+	tags := log.MetricTags{}
+	if staticConfig.Type.Persistent() {
+		configCh, err = config.WatchVdiskNBDConfig(ctx, configSource, vdiskID)
+		if err != nil {
+			cancel()
+			return nil, err
+		}
+		cfg := <-configCh
+		tags[clusterKey] = cfg.StorageClusterID
+	}
 
 	logger := &vdiskLogger{
 		// context-related values
@@ -46,9 +62,16 @@ func NewVdiskLogger(ctx context.Context, configSource config.Source, vdiskID str
 		writeThroughputKey: "vdisk.throughput.write@virt." + vdiskID,
 		writeIOPSKey:       "vdisk.iops.write@virt." + vdiskID,
 
-		tags: log.MetricTags{clusterKey: cfg.StorageClusterID},
+		// pre-bound metrics
+		readOperations:  vdiskOperations.WithLabelValues(vdiskID, operationRead),
+		readBytes:       vdiskBytes.WithLabelValues(vdiskID, operationRead),
+		writeOperations: vdiskOperations.WithLabelValues(vdiskID, operationWrite),
+		writeBytes:      vdiskBytes.WithLabelValues(vdiskID, operationWrite),
+
+		tags: tags,
 		// configCh to keep track of incoming config changes,
-		// and used as the input for the metric tags of this logger
+		// and used as the input for the metric tags of this logger,
+		// nil in case the vdisk is not persistent
 		configCh: configCh,
 
 		// incoming bytes (data) channel
@@ -73,6 +96,11 @@ type vdiskLogger struct {
 	readThroughputKey, readIOPSKey   string
 	writeThroughputKey, writeIOPSKey string
 
+	// metrics of this vdisk, from which the IOPS and throughput
+	// can be computed by the metric consumer (e.g. using the Prometheus rate function)
+	readOperations, readBytes   prometheus.Counter
+	writeOperations, writeBytes prometheus.Counter
+
 	// the tags contain the clusterID information
 	tags log.MetricTags
 	// configCh used to ensure this logger is using
@@ -99,11 +127,15 @@ func broadcastStatistic(key string, value float64, tags log.MetricTags) {
 
 // LogReadOperation implements VdiskLogger.LogReadOperation
 func (vl *vdiskLogger) LogReadOperation(bytes int64) {
+	vl.readOperations.Inc()
+	vl.readBytes.Add(float64(bytes))
 	vl.readDataCh <- bytes
 }
 
 // LogWriteOperation implements VdiskLogger.LogWriteOperation
 func (vl *vdiskLogger) LogWriteOperation(bytes int64) {
+	vl.writeOperations.Inc()
+	vl.writeBytes.Add(float64(bytes))
 	vl.writeDataCh <- bytes
 }
 
@@ -248,6 +280,30 @@ const (
 	templateClusterKey = "templateCluster"
 )
 
+const (
+	operationRead  = "read"
+	operationWrite = "write"
+)
+
+var (
+	vdiskOperations = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metrics.Namespace,
+		Subsystem: "vdisk",
+		Name:      "operations_total",
+		Help:      "Amount of read or write operations applied on a vdisk.",
+	}, []string{"vdisk", "operation"})
+	vdiskBytes = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metrics.Namespace,
+		Subsystem: "vdisk",
+		Name:      "bytes_total",
+		Help:      "Amount of bytes read from or written to a vdisk.",
+	}, []string{"vdisk", "operation"})
+)
+
+func init() {
+	prometheus.MustRegister(vdiskOperations, vdiskBytes)
+}
+
 var (
 	vdiskThroughputScalar = big.NewFloat(1024)
 )
@@ -1,11 +1,16 @@
 package statistics
 
 import (
+	"context"
 	"math"
 	"testing"
 	"time"
 
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zero-os/0-Disk/config"
 )
 
 func TestVdiskAggregator(t *testing.T) {
@@ -91,3 +96,35 @@ func TestVdiskAggregator(t *testing.T) {
 	assert.Equal(2.0, iops)
 	assert.Equal(4.0, throughput)
 }
+
+func TestVdiskLoggerMetrics(t *testing.T) {
+	const vdiskID = "metrics"
+
+	source := config.NewStubSource()
+	defer source.Close()
+	source.SetVdiskConfig(vdiskID, &config.VdiskStaticConfig{
+		BlockSize: 4096,
+		Size:      1,
+		Type:      config.VdiskTypeTmp,
+	})
+
+	logger, err := NewVdiskLogger(context.Background(), source, vdiskID)
+	require.NoError(t, err)
+	defer logger.Close()
+
+	logger.LogReadOperation(4096)
+	logger.LogReadOperation(2048)
+	logger.LogWriteOperation(512)
+
+	assert := assert.New(t)
+	assert.Equal(2.0, counterValue(t, vdiskOperations.WithLabelValues(vdiskID, operationRead)))
+	assert.Equal(6144.0, counterValue(t, vdiskBytes.WithLabelValues(vdiskID, operationRead)))
+	assert.Equal(1.0, counterValue(t, vdiskOperations.WithLabelValues(vdiskID, operationWrite)))
+	assert.Equal(512.0, counterValue(t, vdiskBytes.WithLabelValues(vdiskID, operationWrite)))
+}
+
+func counterValue(t *testing.T, counter prometheus.Counter) float64 {
+	var metric dto.Metric
+	require.NoError(t, counter.Write(&metric))
+	return metric.GetCounter().GetValue()
+}
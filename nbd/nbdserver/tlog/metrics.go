@@ -0,0 +1,54 @@
+package tlog
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/zero-os/0-Disk/metrics"
+)
+
+var (
+	flushQueueLength = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metrics.Namespace,
+		Subsystem: "tlogstorage",
+		Name:      "flush_queue_length",
+		Help:      "Amount of flushed tlog aggregations waiting to be written from the cache into the storage of a vdisk.",
+	}, []string{"vdisk"})
+	flushQueueCapacity = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metrics.Namespace,
+		Subsystem: "tlogstorage",
+		Name:      "flush_queue_capacity",
+		Help:      "Amount of flushed tlog aggregations which can be queued, before the tlog client of a vdisk is blocked.",
+	}, []string{"vdisk"})
+	flushDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metrics.Namespace,
+		Subsystem: "tlogstorage",
+		Name:      "flush_duration_seconds",
+		Help:      "Latency of writing a flushed tlog aggregation from the cache into the storage of a vdisk.",
+		Buckets:   metrics.LatencyBuckets,
+	}, []string{"vdisk"})
+)
+
+func init() {
+	prometheus.MustRegister(flushQueueLength, flushQueueCapacity, flushDuration)
+}
+
+// tlogStorageMetrics are the metrics of a single tlog storage.
+type tlogStorageMetrics struct {
+	flushQueueLength prometheus.Gauge
+	flushDuration    prometheus.Histogram
+}
+
+// newTlogStorageMetrics creates the metrics for the tlog storage of a given vdisk.
+func newTlogStorageMetrics(vdiskID string, flushQueueCap int) tlogStorageMetrics {
+	flushQueueCapacity.WithLabelValues(vdiskID).Set(float64(flushQueueCap))
+	return tlogStorageMetrics{
+		flushQueueLength: flushQueueLength.WithLabelValues(vdiskID),
+		flushDuration:    flushDuration.WithLabelValues(vdiskID),
+	}
+}
+
+// deleteTlogStorageMetrics deletes the gauges of the tlog storage of a given vdisk,
+// such that no stale queue values are reported once the tlog storage is closed.
+func deleteTlogStorageMetrics(vdiskID string) {
+	flushQueueLength.DeleteLabelValues(vdiskID)
+	flushQueueCapacity.DeleteLabelValues(vdiskID)
+}
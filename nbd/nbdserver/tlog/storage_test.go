@@ -54,6 +54,7 @@ func testBlockStorage(t *testing.T, storage storage.BlockStorage) {
 	if len(content) < 2 || bytes.Compare(testContentA, content[:2]) != 0 {
 		t.Fatalf("unexpected content found: %v", content)
 	}
+	testBlockAllocated(t, storage, testBlockIndexA, true)
 
 	// deleting and getting non-existent block is still fine
 	err = storage.DeleteBlock(testBlockIndexB)
@@ -83,6 +84,7 @@ func testBlockStorage(t *testing.T, storage storage.BlockStorage) {
 	if content != nil {
 		t.Fatalf("found content %v, while expected nil-content", content)
 	}
+	testBlockAllocated(t, storage, testBlockIndexA, false)
 
 	// Deleting content, should really delete it
 	err = storage.DeleteBlock(testBlockIndexB)
@@ -100,6 +102,17 @@ func testBlockStorage(t *testing.T, storage storage.BlockStorage) {
 	}
 }
 
+// testBlockAllocated ensures the allocation status of a block is as expected
+func testBlockAllocated(t *testing.T, blockStorage storage.BlockStorage, blockIndex int64, expected bool) {
+	allocated, err := storage.IsBlockAllocated(blockStorage, blockIndex)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if allocated != expected {
+		t.Fatalf("block %d allocated: %v, while expected %v", blockIndex, allocated, expected)
+	}
+}
+
 // shared test function to test all types of BlockStorage equally,
 // this gives us some confidence that all storages behave the same
 // from an end-user perspective
@@ -2,6 +2,7 @@ package tlog
 
 import (
 	"context"
+	"crypto/tls"
 	"fmt"
 	"math"
 	"sync"
@@ -22,7 +23,9 @@ import (
 // Storage creates a tlog storage BlockStorage,
 // wrapping around a given backend storage,
 // using the given tlog client to send its write transactions to the tlog server.
-func Storage(ctx context.Context, vdiskID, tlogPrivKey string, configSource config.Source, blockSize int64, bstorage storage.BlockStorage, cluster ardb.StorageCluster, client tlogClient) (storage.BlockStorage, error) {
+// When no tlog client is given, one is created, using the given (optional) TLS config
+// and the authentication token defined in the (optional) tlog config of the vdisk.
+func Storage(ctx context.Context, vdiskID, tlogPrivKey string, tlsConfig *tls.Config, configSource config.Source, blockSize int64, bstorage storage.BlockStorage, cluster ardb.StorageCluster, client tlogClient) (storage.BlockStorage, error) {
 	if bstorage == nil {
 		return nil, errors.New("tlogStorage requires a non-nil BlockStorage")
 	}
@@ -47,6 +50,7 @@ func Storage(ctx context.Context, vdiskID, tlogPrivKey string, configSource conf
 		toFlushCh:      make(chan []uint64, toFlushChCapacity),
 		cacheEmptyCond: sync.NewCond(&sync.Mutex{}),
 		cancel:         cancel,
+		metrics:        newTlogStorageMetrics(vdiskID, toFlushChCapacity),
 	}
 
 	tlogClusterConfig, err := tlogStorage.tlogRPCReloader(ctx, vdiskID, configSource)
@@ -56,8 +60,17 @@ func Storage(ctx context.Context, vdiskID, tlogPrivKey string, configSource conf
 	}
 
 	if client == nil {
+		// the tlog config is optional for the NBD server,
+		// in which case no authentication token is sent
+		authToken, err := tlog.ReadAuthToken(configSource, vdiskID)
+		if err != nil {
+			log.Debugf("not using a tlog authentication token for vdisk %s: %v", vdiskID, err)
+		}
 		log.Infof("creating tlogclient for vdisk `%v`", vdiskID)
-		client, err = tlogclient.New(tlogClusterConfig.Servers, vdiskID)
+		client, err = tlogclient.New(tlogClusterConfig.Servers, vdiskID, &tlogclient.Config{
+			AuthToken: authToken,
+			TLSConfig: tlsConfig,
+		})
 		if err != nil {
 			cancel()
 			return nil, errors.Wrap(err, "tlogStorage requires valid tlogclient")
@@ -146,6 +159,8 @@ type tlogStorage struct {
 	tlogReady        bool
 	tlogNotReadyBuff []writeOp
 	cancel           context.CancelFunc
+
+	metrics tlogStorageMetrics
 }
 
 type transaction struct {
@@ -255,6 +270,44 @@ func (tls *tlogStorage) GetBlock(blockIndex int64) (content []byte, err error) {
 	*/
 }
 
+// IsBlockAllocated implements storage.BlockAllocationChecker.IsBlockAllocated
+func (tls *tlogStorage) IsBlockAllocated(blockIndex int64) (bool, error) {
+	tls.mux.Lock()
+	defer tls.mux.Unlock()
+
+	content, found := tls.cache.Get(blockIndex)
+	if found {
+		return len(content) > 0, nil
+	}
+
+	tls.storageMux.Lock()
+	defer tls.storageMux.Unlock()
+
+	return storage.IsBlockAllocated(tls.storage, blockIndex)
+}
+
+// AllocatedBlocks implements storage.BlockRangeAllocationChecker.AllocatedBlocks
+func (tls *tlogStorage) AllocatedBlocks(startIndex, count int64) ([]bool, error) {
+	tls.mux.Lock()
+	defer tls.mux.Unlock()
+
+	tls.storageMux.Lock()
+	allocated, err := storage.AllocatedBlocks(tls.storage, startIndex, count)
+	tls.storageMux.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	// cached blocks aren't stored yet, and overrule the stored blocks
+	for i := range allocated {
+		content, found := tls.cache.Get(startIndex + int64(i))
+		if found {
+			allocated[i] = len(content) > 0
+		}
+	}
+	return allocated, nil
+}
+
 // DeleteBlock implements BlockStorage.DeleteBlock
 func (tls *tlogStorage) DeleteBlock(blockIndex int64) (err error) {
 	tls.mux.Lock()
@@ -358,6 +411,7 @@ func (tls *tlogStorage) Close() (err error) {
 
 	log.Infof("tlog storage closed with cache empty = %v", tls.cache.Empty())
 	tls.cancel()
+	deleteTlogStorageMetrics(tls.vdiskID)
 
 	err = tls.storage.Close()
 	if err != nil {
@@ -455,6 +509,7 @@ func (tls *tlogStorage) spawnBackgroundGoroutine(parentCtx context.Context) erro
 
 				case tlog.BlockStatusFlushOK:
 					tls.toFlushCh <- res.Resp.Sequences
+					tls.metrics.flushQueueLength.Set(float64(len(tls.toFlushCh)))
 				case tlog.BlockStatusWaitNbdSlaveSyncReceived:
 
 				case tlog.BlockStatusFlushFailed:
@@ -520,7 +575,10 @@ func (tls *tlogStorage) flusher(ctx context.Context) {
 	for {
 		select {
 		case seqs := <-tls.toFlushCh:
+			tls.metrics.flushQueueLength.Set(float64(len(tls.toFlushCh)))
+			start := time.Now()
 			err := tls.flushCachedContent(seqs)
+			tls.metrics.flushDuration.Observe(time.Since(start).Seconds())
 			if err != nil {
 				panic(errors.Wrapf(err,
 					"failed to write cached content into storage for vdisk %s: %s",
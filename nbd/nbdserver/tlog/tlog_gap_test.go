@@ -51,7 +51,7 @@ func TestTlogStorageSlow(t *testing.T) {
 	defer source.Close()
 
 	storage, err := Storage(
-		ctx, vdiskID, tlogPrivKey, source, blockSize, slowStorage, ardb.NopCluster{}, nil)
+		ctx, vdiskID, tlogPrivKey, nil, source, blockSize, slowStorage, ardb.NopCluster{}, nil)
 	if !assert.NoError(t, err) || !assert.NotNil(t, storage) {
 		return
 	}
@@ -165,7 +165,7 @@ func testTlogStorage(ctx context.Context, t *testing.T, vdiskID string, blockSiz
 	defer source.Close()
 
 	storage, err := Storage(
-		ctx, vdiskID, "", source, blockSize, storage, ardb.NopCluster{}, nil)
+		ctx, vdiskID, "", nil, source, blockSize, storage, ardb.NopCluster{}, nil)
 	if !assert.NoError(t, err) || !assert.NotNil(t, storage) {
 		return
 	}
@@ -188,7 +188,7 @@ func testTlogStorageForceFlush(ctx context.Context, t *testing.T, vdiskID string
 	defer source.Close()
 
 	storage, err := Storage(
-		ctx, vdiskID, "", source, blockSize, storage, ardb.NopCluster{}, nil)
+		ctx, vdiskID, "", nil, source, blockSize, storage, ardb.NopCluster{}, nil)
 	if !assert.NoError(t, err) || !assert.NotNil(t, storage) {
 		return
 	}
@@ -301,7 +301,7 @@ func testTlogStorageReplay(t *testing.T, storageCreator storageCreator) {
 	defer source.Close()
 
 	storage, err := Storage(
-		ctx, vdiskID, "", source, blockSize, internalStorage, ardb.NopCluster{}, nil)
+		ctx, vdiskID, "", nil, source, blockSize, internalStorage, ardb.NopCluster{}, nil)
 	if !assert.NoError(t, err) {
 		return
 	}
@@ -999,7 +999,7 @@ func TestTlogSwitchClusterID(t *testing.T) {
 
 	tlogClient := &stubTlogClient{servers: lastValidCluster.Servers}
 
-	storage, err := Storage(ctx, vdiskID, tlogPrivKey, source, blockSize, storage, ardb.NopCluster{}, tlogClient)
+	storage, err := Storage(ctx, vdiskID, tlogPrivKey, nil, source, blockSize, storage, ardb.NopCluster{}, tlogClient)
 	require.NoError(err)
 
 	defer storage.Close()
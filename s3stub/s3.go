@@ -0,0 +1,399 @@
+package s3stub
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// NewServer creates a new in-memory S3 compatible server,
+// supporting the bucket and object operations used by the S3 backup storage driver.
+// When an access key is given, all requests have to be signed using that key,
+// otherwise anonymous requests are accepted as well.
+// Signatures aren't verified, only the presence of the access key is checked.
+func NewServer(accessKey string) *Server {
+	server := &Server{
+		accessKey: accessKey,
+		buckets:   make(map[string]map[string][]byte),
+		uploads:   make(map[string]*multipartUpload),
+		PageSize:  1000,
+	}
+	server.server = httptest.NewServer(server)
+	return server
+}
+
+// Server is an in-memory S3 compatible server.
+type Server struct {
+	server    *httptest.Server
+	accessKey string
+
+	buckets map[string]map[string][]byte
+	uploads map[string]*multipartUpload
+	// amount of multipart uploads completed
+	completedUploads int
+	// amount of uploads started, used to generate upload IDs
+	uploadCount int
+	mux         sync.Mutex
+
+	// PageSize is the maximum amount of keys listed in a single response,
+	// defaults to 1000, just like S3
+	PageSize int
+}
+
+type multipartUpload struct {
+	bucket, key string
+	parts       map[int][]byte
+}
+
+// Address returns the address (host:port) of this server.
+func (s *Server) Address() string {
+	return strings.TrimPrefix(s.server.URL, "http://")
+}
+
+// Object returns the content of an object,
+// and false in case that object doesn't exist.
+func (s *Server) Object(bucket, key string) ([]byte, bool) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	content, ok := s.buckets[bucket][key]
+	return content, ok
+}
+
+// SetObject sets the content of an object,
+// creating its bucket if it doesn't exist yet.
+func (s *Server) SetObject(bucket, key string, content []byte) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	if s.buckets[bucket] == nil {
+		s.buckets[bucket] = make(map[string][]byte)
+	}
+	s.buckets[bucket][key] = content
+}
+
+// CompletedMultipartUploads returns the amount of multipart uploads,
+// which were completed on this server.
+func (s *Server) CompletedMultipartUploads() int {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	return s.completedUploads
+}
+
+// Close this server.
+func (s *Server) Close() {
+	s.server.Close()
+}
+
+// ServeHTTP implements http.Handler.ServeHTTP
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if s.accessKey != "" &&
+		!strings.Contains(r.Header.Get("Authorization"), "Credential="+s.accessKey+"/") {
+		writeError(w, http.StatusForbidden, "AccessDenied", "Access Denied.")
+		return
+	}
+
+	parts := strings.SplitN(strings.TrimPrefix(r.URL.Path, "/"), "/", 2)
+	bucket := parts[0]
+	if bucket == "" {
+		writeError(w, http.StatusNotImplemented, "NotImplemented", "Listing buckets is not supported.")
+		return
+	}
+
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	if len(parts) == 1 || parts[1] == "" {
+		s.serveBucket(w, r, bucket)
+		return
+	}
+	objects, ok := s.buckets[bucket]
+	if !ok {
+		writeError(w, http.StatusNotFound, "NoSuchBucket", "The specified bucket does not exist.")
+		return
+	}
+	s.serveObject(w, r, bucket, parts[1], objects)
+}
+
+func (s *Server) serveBucket(w http.ResponseWriter, r *http.Request, bucket string) {
+	objects, exists := s.buckets[bucket]
+
+	switch r.Method {
+	case http.MethodPut:
+		if exists {
+			writeError(w, http.StatusConflict, "BucketAlreadyOwnedByYou", "Your previous request to create the named bucket succeeded and you already own it.")
+			return
+		}
+		s.buckets[bucket] = make(map[string][]byte)
+		return
+	}
+
+	if !exists {
+		writeError(w, http.StatusNotFound, "NoSuchBucket", "The specified bucket does not exist.")
+		return
+	}
+
+	switch r.Method {
+	case http.MethodHead:
+		return
+
+	case http.MethodGet:
+		query := r.URL.Query()
+		if query.Get("list-type") != "2" {
+			writeError(w, http.StatusNotImplemented, "NotImplemented", "Only ListObjectsV2 is supported.")
+			return
+		}
+		writeXML(w, listObjects(objects,
+			query.Get("prefix"), query.Get("delimiter"),
+			query.Get("continuation-token"), s.PageSize))
+
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "MethodNotAllowed", "The specified method is not allowed against this resource.")
+	}
+}
+
+func (s *Server) serveObject(w http.ResponseWriter, r *http.Request, bucket, key string, objects map[string][]byte) {
+	query := r.URL.Query()
+	_, initiate := query["uploads"]
+	uploadID := query.Get("uploadId")
+
+	switch {
+	case r.Method == http.MethodPost && initiate:
+		s.uploadCount++
+		uploadID = strconv.Itoa(s.uploadCount)
+		s.uploads[uploadID] = &multipartUpload{
+			bucket: bucket,
+			key:    key,
+			parts:  make(map[int][]byte),
+		}
+		writeXML(w, initiateMultipartUploadResult{
+			Bucket:   bucket,
+			Key:      key,
+			UploadID: uploadID,
+		})
+
+	case uploadID != "":
+		upload, ok := s.uploads[uploadID]
+		if !ok || upload.bucket != bucket || upload.key != key {
+			writeError(w, http.StatusNotFound, "NoSuchUpload", "The specified multipart upload does not exist.")
+			return
+		}
+		s.serveMultipartUpload(w, r, uploadID, upload, objects)
+
+	case r.Method == http.MethodHead || r.Method == http.MethodGet:
+		content, ok := objects[key]
+		if !ok {
+			writeError(w, http.StatusNotFound, "NoSuchKey", "The specified key does not exist.")
+			return
+		}
+		w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+		if r.Method == http.MethodGet {
+			w.Write(content)
+		}
+
+	case r.Method == http.MethodPut:
+		content, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "IncompleteBody", err.Error())
+			return
+		}
+		objects[key] = content
+
+	case r.Method == http.MethodDelete:
+		delete(objects, key)
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "MethodNotAllowed", "The specified method is not allowed against this resource.")
+	}
+}
+
+func (s *Server) serveMultipartUpload(w http.ResponseWriter, r *http.Request, uploadID string, upload *multipartUpload, objects map[string][]byte) {
+	switch r.Method {
+	case http.MethodPut:
+		partNumber, err := strconv.Atoi(r.URL.Query().Get("partNumber"))
+		if err != nil || partNumber < 1 || partNumber > 10000 {
+			writeError(w, http.StatusBadRequest, "InvalidArgument", "Part number must be an integer between 1 and 10000, inclusive.")
+			return
+		}
+		content, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "IncompleteBody", err.Error())
+			return
+		}
+		upload.parts[partNumber] = content
+		w.Header().Set("ETag", partETag(partNumber))
+
+	case http.MethodPost:
+		var complete completeMultipartUpload
+		err := xml.NewDecoder(r.Body).Decode(&complete)
+		if err != nil || len(complete.Parts) == 0 {
+			writeError(w, http.StatusBadRequest, "MalformedXML", "The XML you provided was not well-formed.")
+			return
+		}
+		var content []byte
+		for index, part := range complete.Parts {
+			data, ok := upload.parts[part.PartNumber]
+			if !ok || part.ETag != partETag(part.PartNumber) {
+				writeError(w, http.StatusBadRequest, "InvalidPart", "One or more of the specified parts could not be found.")
+				return
+			}
+			if index > 0 && part.PartNumber <= complete.Parts[index-1].PartNumber {
+				writeError(w, http.StatusBadRequest, "InvalidPartOrder", "The list of parts was not in ascending order.")
+				return
+			}
+			if index < len(complete.Parts)-1 && len(data) < minPartSize {
+				writeError(w, http.StatusBadRequest, "EntityTooSmall", "Your proposed upload is smaller than the minimum allowed object size.")
+				return
+			}
+			content = append(content, data...)
+		}
+		objects[upload.key] = content
+		delete(s.uploads, uploadID)
+		s.completedUploads++
+		writeXML(w, completeMultipartUploadResult{
+			Bucket: upload.bucket,
+			Key:    upload.key,
+		})
+
+	case http.MethodDelete:
+		delete(s.uploads, uploadID)
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "MethodNotAllowed", "The specified method is not allowed against this resource.")
+	}
+}
+
+// listObjects lists the objects of a bucket, using the ListObjectsV2 semantics.
+func listObjects(objects map[string][]byte, prefix, delimiter, token string, pageSize int) listBucketResult {
+	var keys []string
+	prefixes := make(map[string]struct{})
+	for key := range objects {
+		if !strings.HasPrefix(key, prefix) {
+			continue
+		}
+		if delimiter != "" {
+			if index := strings.Index(key[len(prefix):], delimiter); index >= 0 {
+				prefixes[key[:len(prefix)+index+len(delimiter)]] = struct{}{}
+				continue
+			}
+		}
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	result := listBucketResult{
+		Prefix:            prefix,
+		Delimiter:         delimiter,
+		MaxKeys:           pageSize,
+		ContinuationToken: token,
+	}
+	for prefix := range prefixes {
+		result.CommonPrefixes = append(result.CommonPrefixes, commonPrefix{Prefix: prefix})
+	}
+	sort.Slice(result.CommonPrefixes, func(i, j int) bool {
+		return result.CommonPrefixes[i].Prefix < result.CommonPrefixes[j].Prefix
+	})
+
+	// the continuation token is the last key of the previous page
+	start := sort.SearchStrings(keys, token)
+	if token != "" && start < len(keys) && keys[start] == token {
+		start++
+	}
+	keys = keys[start:]
+	if len(keys) > pageSize {
+		keys = keys[:pageSize]
+		result.IsTruncated = true
+		result.NextContinuationToken = keys[len(keys)-1]
+	}
+
+	for _, key := range keys {
+		result.Contents = append(result.Contents, object{
+			Key:  key,
+			Size: len(objects[key]),
+		})
+	}
+	result.KeyCount = len(result.Contents)
+	return result
+}
+
+func partETag(partNumber int) string {
+	return fmt.Sprintf("\"part-%d\"", partNumber)
+}
+
+func writeXML(w http.ResponseWriter, v interface{}) {
+	data, err := xml.Marshal(v)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "InternalError", err.Error())
+		return
+	}
+	w.Header().Set("Content-Type", "application/xml")
+	w.Write([]byte(xml.Header))
+	w.Write(data)
+}
+
+func writeError(w http.ResponseWriter, status int, code, message string) {
+	data, _ := xml.Marshal(errorResponse{Code: code, Message: message})
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	w.Write([]byte(xml.Header))
+	w.Write(data)
+}
+
+// XML types used as part of the S3 API.
+
+type errorResponse struct {
+	XMLName xml.Name `xml:"Error"`
+	Code    string   `xml:"Code"`
+	Message string   `xml:"Message"`
+}
+
+type listBucketResult struct {
+	XMLName               xml.Name       `xml:"ListBucketResult"`
+	Prefix                string         `xml:"Prefix"`
+	Delimiter             string         `xml:"Delimiter,omitempty"`
+	MaxKeys               int            `xml:"MaxKeys"`
+	KeyCount              int            `xml:"KeyCount"`
+	IsTruncated           bool           `xml:"IsTruncated"`
+	ContinuationToken     string         `xml:"ContinuationToken,omitempty"`
+	NextContinuationToken string         `xml:"NextContinuationToken,omitempty"`
+	Contents              []object       `xml:"Contents"`
+	CommonPrefixes        []commonPrefix `xml:"CommonPrefixes"`
+}
+
+type object struct {
+	Key  string `xml:"Key"`
+	Size int    `xml:"Size"`
+}
+
+type commonPrefix struct {
+	Prefix string `xml:"Prefix"`
+}
+
+type initiateMultipartUploadResult struct {
+	XMLName  xml.Name `xml:"InitiateMultipartUploadResult"`
+	Bucket   string   `xml:"Bucket"`
+	Key      string   `xml:"Key"`
+	UploadID string   `xml:"UploadId"`
+}
+
+type completeMultipartUpload struct {
+	Parts []struct {
+		PartNumber int    `xml:"PartNumber"`
+		ETag       string `xml:"ETag"`
+	} `xml:"Part"`
+}
+
+type completeMultipartUploadResult struct {
+	XMLName xml.Name `xml:"CompleteMultipartUploadResult"`
+	Bucket  string   `xml:"Bucket"`
+	Key     string   `xml:"Key"`
+}
+
+// minimum size of all parts of a multipart upload, except the last one
+const minPartSize = 5 * 1024 * 1024
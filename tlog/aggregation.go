@@ -18,6 +18,18 @@ var (
 	ErrEmptyAggregation = errors.New("empty aggregation")
 )
 
+// CheckpointAggregationName is the name of an aggregation which is (part of)
+// a checkpoint, created by compacting the history of a vdisk.
+// A checkpoint only contains the latest block of each block index,
+// out of all aggregations it replaces.
+const CheckpointAggregationName = "checkpoint"
+
+// IsCheckpoint returns true if the given aggregation is (part of) a checkpoint.
+func IsCheckpoint(agg *schema.TlogAggregation) bool {
+	name, err := agg.Name()
+	return err == nil && name == CheckpointAggregationName
+}
+
 // Aggregation defines a tlog aggregation
 type Aggregation struct {
 	agg          schema.TlogAggregation
@@ -147,6 +159,11 @@ func (a *Aggregation) IgnoreSeqBefore(seq uint64) error {
 	return nil
 }
 
+// SetName sets the name of this aggregation
+func (a *Aggregation) SetName(name string) error {
+	return a.agg.SetName(name)
+}
+
 // SetTimestamp sets timestamp of this aggregation
 func (a *Aggregation) SetTimestamp(timestamp int64) {
 	a.agg.SetTimestamp(timestamp)
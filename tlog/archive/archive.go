@@ -0,0 +1,479 @@
+package archive
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"time"
+
+	"github.com/zeebo/bencode"
+	"github.com/zero-os/0-stor/client/meta"
+	"gopkg.in/validator.v2"
+
+	"github.com/zero-os/0-Disk"
+	"github.com/zero-os/0-Disk/compress"
+	"github.com/zero-os/0-Disk/config"
+	"github.com/zero-os/0-Disk/errors"
+	"github.com/zero-os/0-Disk/log"
+	"github.com/zero-os/0-Disk/nbd/ardb/backup"
+	"github.com/zero-os/0-Disk/tlog"
+	"github.com/zero-os/0-Disk/tlog/stor"
+)
+
+const (
+	// DefaultChunkSize is the default amount of (aggregation) bytes
+	// stored in a single chunk of an archive.
+	DefaultChunkSize = 1024 * 1024 * 4 // 4 MiB
+)
+
+// Config represents the config used to export the tlog of a vdisk into an archive,
+// or to import an archive into the tlog of a vdisk.
+type Config struct {
+	// Required: ID of the vdisk to export the tlog from or import the tlog into
+	VdiskID string `validate:"nonzero"`
+	// Required: ID of the archive
+	ArchiveID string `validate:"nonzero"`
+	// Required: private key used to encrypt the tlog data in 0-stor
+	PrivKey string `validate:"nonzero"`
+
+	// Optional: epoch range of the exported aggregations,
+	// by default all aggregations are exported.
+	// NOTE: only used by Export (ignored by Import)
+	StartEpoch int64
+	EndEpoch   int64
+
+	// Optional: amount of (aggregation) bytes stored per chunk,
+	// DefaultChunkSize by default.
+	// NOTE: only used by Export (ignored by Import)
+	ChunkSize int64
+
+	// Optional: BackupStoragDriverConfig used to configure the backup storage driver,
+	// see backup.NewStorageDriver for more information.
+	BackupStoragDriverConfig interface{}
+
+	// Type of Compression to use for compressing/decompressing.
+	// Note: this should be the same value for an import/export pair
+	CompressionType compress.CompressionType
+	// CryptoKey to use for encryption/decryption.
+	// Note: this should be the same value for an import/export pair
+	CryptoKey backup.CryptoKey
+
+	// Optional: when true, an existing archive with the same ID is overwritten.
+	// NOTE: only used by Export (ignored by Import)
+	Force bool
+}
+
+// validate the config, and fill-in all the missing optional data.
+func (cfg *Config) validate() error {
+	if err := validator.Validate(*cfg); err != nil {
+		return err
+	}
+	if cfg.ChunkSize <= 0 {
+		cfg.ChunkSize = DefaultChunkSize
+	}
+	if cfg.EndEpoch <= 0 {
+		cfg.EndEpoch = tlog.TimeNowTimestamp()
+	}
+	if cfg.StartEpoch > cfg.EndEpoch {
+		return errors.Newf("start epoch %d is after end epoch %d", cfg.StartEpoch, cfg.EndEpoch)
+	}
+	return nil
+}
+
+// Index is stored as the header of an archive,
+// and describes the tlog history stored in its chunks.
+type Index struct {
+	// ID of the archive
+	ArchiveID string `bencode:"id"`
+	// ID of the vdisk the tlog was exported from
+	VdiskID string `bencode:"vdisk"`
+	// creation (RFC3339) date, time and timezone
+	Created string `bencode:"at"`
+	// version of the 0-disk toolchain
+	Version zerodisk.Version `bencode:"v"`
+
+	// total amount of aggregations stored
+	Aggregations int64 `bencode:"n"`
+	// sequence range of the stored blocks
+	FirstSequence uint64 `bencode:"fs"`
+	LastSequence  uint64 `bencode:"ls"`
+	// epoch range of the stored aggregations
+	FirstEpoch int64 `bencode:"fe"`
+	LastEpoch  int64 `bencode:"le"`
+
+	// chunks in the order they were exported
+	Chunks []Chunk `bencode:"c"`
+}
+
+// Chunk describes a single chunk of an archive.
+type Chunk struct {
+	// hash of the chunk, as stored in the backup storage
+	Hash []byte `bencode:"h"`
+	// amount of aggregations stored in the chunk
+	Aggregations int64 `bencode:"n"`
+}
+
+// aggregation as stored in a chunk,
+// in the same (raw) format as it was stored in 0-stor.
+type aggregation struct {
+	Epoch int64  `bencode:"e"`
+	Data  []byte `bencode:"d"`
+}
+
+// Export streams the tlog aggregations of a vdisk into a backup storage,
+// as a series of (compressed and optionally encrypted) chunks,
+// described by an index, which is stored as the header of the archive.
+// The tlog of the vdisk is left untouched.
+func Export(ctx context.Context, confSource config.Source, cfg Config) (*Index, error) {
+	err := cfg.validate()
+	if err != nil {
+		return nil, err
+	}
+	err = checkTlogCluster(confSource, cfg.VdiskID)
+	if err != nil {
+		return nil, err
+	}
+
+	driver, err := backup.NewStorageDriver(cfg.BackupStoragDriverConfig)
+	if err != nil {
+		return nil, err
+	}
+	defer driver.Close()
+
+	if !cfg.Force {
+		err = driver.GetHeader(cfg.ArchiveID, ioutil.Discard)
+		if err == nil {
+			return nil, errors.Newf("archive %s already exists", cfg.ArchiveID)
+		}
+		if err != backup.ErrDataDidNotExist {
+			return nil, errors.Wrapf(err, "couldn't check if archive %s already exists", cfg.ArchiveID)
+		}
+	}
+
+	storCli, err := stor.NewClientFromConfigSource(confSource, cfg.VdiskID, cfg.PrivKey)
+	if err != nil {
+		return nil, err
+	}
+	defer storCli.Close()
+
+	index := &Index{
+		ArchiveID: cfg.ArchiveID,
+		VdiskID:   cfg.VdiskID,
+		Version:   zerodisk.CurrentVersion,
+	}
+
+	var (
+		buffer     []aggregation
+		bufferSize int64
+	)
+	flush := func() error {
+		if len(buffer) == 0 {
+			return nil
+		}
+		chunk, err := storeChunk(driver, buffer, &cfg)
+		if err != nil {
+			return err
+		}
+		index.Chunks = append(index.Chunks, chunk)
+		buffer, bufferSize = buffer[:0], 0
+		return nil
+	}
+
+	for wr := range storCli.Walk(cfg.StartEpoch, cfg.EndEpoch) {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		default:
+		}
+
+		if wr.Err != nil {
+			return nil, errors.Wrapf(wr.Err, "couldn't walk the tlog of vdisk %s", cfg.VdiskID)
+		}
+
+		// the block list can be bigger than the actual amount of blocks
+		blocks, err := wr.Agg.Blocks()
+		if err != nil {
+			return nil, err
+		}
+		if size := int(wr.Agg.Size()); size > 0 && size <= blocks.Len() {
+			if index.Aggregations == 0 {
+				index.FirstSequence = blocks.At(0).Sequence()
+			}
+			index.LastSequence = blocks.At(size - 1).Sequence()
+		}
+		if index.Aggregations == 0 {
+			index.FirstEpoch = wr.Meta.Epoch
+		}
+		index.LastEpoch = wr.Meta.Epoch
+		index.Aggregations++
+
+		buffer = append(buffer, aggregation{Epoch: wr.Meta.Epoch, Data: wr.Data})
+		bufferSize += int64(len(wr.Data))
+		if bufferSize >= cfg.ChunkSize {
+			err = flush()
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	err = flush()
+	if err != nil {
+		return nil, err
+	}
+
+	if index.Aggregations == 0 {
+		return nil, errors.Newf("vdisk %s has no tlog aggregations to archive", cfg.VdiskID)
+	}
+
+	log.Infof("archived %d tlog aggregations of vdisk %s into %d chunks",
+		index.Aggregations, cfg.VdiskID, len(index.Chunks))
+
+	// store the index last, such that an archive only exists,
+	// once all its chunks are stored
+	index.Created = time.Now().Format(time.RFC3339)
+	buf := bytes.NewBuffer(nil)
+	err = serialize(index, &cfg.CryptoKey, cfg.CompressionType, buf)
+	if err != nil {
+		return nil, errors.Wrap(err, "couldn't serialize archive index")
+	}
+	err = driver.SetHeader(cfg.ArchiveID, buf)
+	if err != nil {
+		return nil, errors.Wrapf(err, "couldn't store index of archive %s", cfg.ArchiveID)
+	}
+	return index, nil
+}
+
+// Import rebuilds the tlog of a vdisk from an archive,
+// storing all its aggregations, in order, into 0-stor.
+// The aggregations keep their original epoch, blocks and sequences,
+// while they are stored with the time of the import as their timestamp.
+// The vdisk is required to have an empty tlog.
+// Only the tlog is imported, the (ARDB) storage of the vdisk is left untouched.
+func Import(ctx context.Context, confSource config.Source, cfg Config) (*Index, error) {
+	err := cfg.validate()
+	if err != nil {
+		return nil, err
+	}
+	err = checkTlogCluster(confSource, cfg.VdiskID)
+	if err != nil {
+		return nil, err
+	}
+
+	driver, err := backup.NewStorageDriver(cfg.BackupStoragDriverConfig)
+	if err != nil {
+		return nil, err
+	}
+	defer driver.Close()
+
+	index, err := loadIndex(cfg.ArchiveID, driver, &cfg.CryptoKey, cfg.CompressionType)
+	if err != nil {
+		return nil, err
+	}
+	if index.Aggregations == 0 || len(index.Chunks) == 0 {
+		return nil, errors.Newf("archive %s contains no tlog aggregations to import", cfg.ArchiveID)
+	}
+
+	storCli, err := stor.NewClientFromConfigSource(confSource, cfg.VdiskID, cfg.PrivKey)
+	if err != nil {
+		return nil, err
+	}
+	defer storCli.Close()
+
+	// an archive can't be merged with an existing tlog
+	_, err = storCli.LoadLastSequence()
+	if err == nil {
+		return nil, errors.Newf(
+			"cannot import archive %s, as vdisk %s already has a tlog", cfg.ArchiveID, cfg.VdiskID)
+	}
+	if err != stor.ErrNoFlushedBlock {
+		return nil, err
+	}
+
+	var (
+		prevMd *meta.Meta
+		count  int64
+	)
+	for _, chunk := range index.Chunks {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		default:
+		}
+
+		aggs, err := loadChunk(driver, chunk, &cfg)
+		if err != nil {
+			return nil, err
+		}
+		for _, agg := range aggs {
+			prevMd, err = storCli.ImportAgg(agg.Data, agg.Epoch, prevMd)
+			if err != nil {
+				return nil, errors.Wrapf(err, "couldn't store aggregation of vdisk %s", cfg.VdiskID)
+			}
+			count++
+		}
+	}
+	if count != index.Aggregations {
+		return nil, errors.Newf("archive %s contains %d aggregations, while its index defines %d",
+			cfg.ArchiveID, count, index.Aggregations)
+	}
+
+	err = storCli.SetLastMetaKey(prevMd.Key)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Infof("imported %d tlog aggregations of archive %s into vdisk %s",
+		count, cfg.ArchiveID, cfg.VdiskID)
+	return index, nil
+}
+
+// ReadIndex loads the index of an archive from a given (backup) storage.
+func ReadIndex(archiveID string, storageDriverConfig interface{}, key *backup.CryptoKey, ct compress.CompressionType) (*Index, error) {
+	driver, err := backup.NewStorageDriver(storageDriverConfig)
+	if err != nil {
+		return nil, err
+	}
+	defer driver.Close()
+	return loadIndex(archiveID, driver, key, ct)
+}
+
+// References returns the hashes of all chunks of an archive,
+// or false in case the given header isn't the index of an archive.
+// It implements backup.HeaderReferences, such that the chunks of archives
+// aren't deleted by a garbage collection of the backup storage they're stored in.
+func References(id string, driver backup.StorageDriver, key *backup.CryptoKey, ct compress.CompressionType) ([]zerodisk.Hash, bool, error) {
+	index, err := loadIndex(id, driver, key, ct)
+	if err != nil {
+		log.Debugf("header %s isn't a tlog archive: %v", id, err)
+		return nil, false, nil
+	}
+
+	hashes := make([]zerodisk.Hash, len(index.Chunks))
+	for i, chunk := range index.Chunks {
+		hashes[i] = zerodisk.Hash(chunk.Hash)
+	}
+	return hashes, true, nil
+}
+
+// loadIndex loads (read=>[decrypt=>]decompress=>decode)
+// the index of an archive from a given (backup) storage.
+func loadIndex(archiveID string, driver backup.StorageDriver, key *backup.CryptoKey, ct compress.CompressionType) (*Index, error) {
+	buf := bytes.NewBuffer(nil)
+	err := driver.GetHeader(archiveID, buf)
+	if err != nil {
+		return nil, errors.Wrapf(err, "couldn't read index of archive %s", archiveID)
+	}
+
+	var index Index
+	err = deserialize(buf, key, ct, &index)
+	if err != nil {
+		return nil, errors.Wrapf(err, "couldn't deserialize index of archive %s", archiveID)
+	}
+	// headers of snapshots share the same storage space
+	if index.ArchiveID != archiveID || index.VdiskID == "" {
+		return nil, errors.Newf("%s is not a valid tlog archive", archiveID)
+	}
+	return &index, nil
+}
+
+// storeChunk stores (encode=>compress=>[encrypt=>]write) the given aggregations
+// as a single chunk into the given (backup) storage.
+func storeChunk(driver backup.StorageDriver, aggs []aggregation, cfg *Config) (Chunk, error) {
+	buf := bytes.NewBuffer(nil)
+	err := serialize(aggs, &cfg.CryptoKey, cfg.CompressionType, buf)
+	if err != nil {
+		return Chunk{}, errors.Wrap(err, "couldn't serialize archive chunk")
+	}
+
+	hash := zerodisk.HashBytes(buf.Bytes())
+	err = driver.SetDedupedBlock(hash, buf)
+	if err != nil {
+		return Chunk{}, errors.Wrapf(err, "couldn't store archive chunk %x", hash)
+	}
+	return Chunk{Hash: hash, Aggregations: int64(len(aggs))}, nil
+}
+
+// loadChunk loads (read=>[decrypt=>]decompress=>decode) the aggregations
+// of a single chunk from the given (backup) storage.
+func loadChunk(driver backup.StorageDriver, chunk Chunk, cfg *Config) ([]aggregation, error) {
+	buf := bytes.NewBuffer(nil)
+	err := driver.GetDedupedBlock(zerodisk.Hash(chunk.Hash), buf)
+	if err != nil {
+		return nil, errors.Wrapf(err, "couldn't read archive chunk %x", chunk.Hash)
+	}
+	if !zerodisk.HashBytes(buf.Bytes()).Equals(zerodisk.Hash(chunk.Hash)) {
+		return nil, errors.Newf("archive chunk %x is corrupt", chunk.Hash)
+	}
+
+	var aggs []aggregation
+	err = deserialize(buf, &cfg.CryptoKey, cfg.CompressionType, &aggs)
+	if err != nil {
+		return nil, errors.Wrapf(err, "couldn't deserialize archive chunk %x", chunk.Hash)
+	}
+	if int64(len(aggs)) != chunk.Aggregations {
+		return nil, errors.Newf("archive chunk %x contains %d aggregations, while %d were expected",
+			chunk.Hash, len(aggs), chunk.Aggregations)
+	}
+	return aggs, nil
+}
+
+// serialize (encode=>compress=>[encrypt=>]write) a value to the given writer.
+func serialize(v interface{}, key *backup.CryptoKey, ct compress.CompressionType, dst io.Writer) error {
+	compressor, err := compress.NewCompressor(ct)
+	if err != nil {
+		return err
+	}
+
+	encoded := bytes.NewBuffer(nil)
+	err = bencode.NewEncoder(encoded).Encode(v)
+	if err != nil {
+		return err
+	}
+
+	if !key.Defined() {
+		return compressor.Compress(encoded, dst)
+	}
+	compressed := bytes.NewBuffer(nil)
+	err = compressor.Compress(encoded, compressed)
+	if err != nil {
+		return err
+	}
+	return backup.Encrypt(key, compressed, dst)
+}
+
+// deserialize (read=>[decrypt=>]decompress=>decode) a value from the given reader.
+func deserialize(src io.Reader, key *backup.CryptoKey, ct compress.CompressionType, v interface{}) error {
+	decompressor, err := compress.NewDecompressor(ct)
+	if err != nil {
+		return err
+	}
+
+	if key.Defined() {
+		decrypted := bytes.NewBuffer(nil)
+		err = backup.Decrypt(key, src, decrypted)
+		if err != nil {
+			return err
+		}
+		src = decrypted
+	}
+
+	decompressed := bytes.NewBuffer(nil)
+	err = decompressor.Decompress(src, decompressed)
+	if err != nil {
+		return err
+	}
+	return bencode.NewDecoder(decompressed).Decode(v)
+}
+
+// checkTlogCluster ensures the vdisk has a tlog cluster configured.
+func checkTlogCluster(confSource config.Source, vdiskID string) error {
+	hasTlog, err := tlog.HasTlogCluster(confSource, vdiskID)
+	if err != nil {
+		return err
+	}
+	if !hasTlog {
+		return errors.Newf("vdisk %s has no tlog cluster configured", vdiskID)
+	}
+	return nil
+}
@@ -0,0 +1,228 @@
+package archive
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/zero-os/0-Disk"
+	"github.com/zero-os/0-Disk/compress"
+	"github.com/zero-os/0-Disk/config"
+	"github.com/zero-os/0-Disk/nbd/ardb/backup"
+	"github.com/zero-os/0-Disk/tlog"
+	"github.com/zero-os/0-Disk/tlog/flusher"
+	"github.com/zero-os/0-Disk/tlog/schema"
+	"github.com/zero-os/0-Disk/tlog/stor"
+	"github.com/zero-os/0-Disk/tlog/stor/embeddedserver"
+	"github.com/zero-os/0-stor/client/meta/embedserver"
+)
+
+func TestExportImport(t *testing.T) {
+	const (
+		sourceVdiskID = "a"
+		targetVdiskID = "b"
+		archiveID     = "a_archive"
+		dataShards    = 4
+		parityShards  = 2
+		blockSize     = 4096
+		numLogs       = 50
+		privKey       = "12345678901234567890123456789012"
+	)
+
+	storCluster, err := embeddedserver.NewZeroStorCluster(dataShards + parityShards)
+	require.NoError(t, err)
+	defer storCluster.Close()
+
+	mdServer, err := embedserver.New()
+	require.NoError(t, err)
+	defer mdServer.Stop()
+
+	var serverConf []config.ServerConfig
+	for _, addr := range storCluster.Addrs() {
+		serverConf = append(serverConf, config.ServerConfig{
+			Address: addr,
+		})
+	}
+
+	// both vdisks share the same 0-stor cluster
+	confSource := config.NewStubSource()
+	defer confSource.Close()
+	for _, vdiskID := range []string{sourceVdiskID, targetVdiskID} {
+		confSource.SetVdiskConfig(vdiskID, &config.VdiskStaticConfig{
+			BlockSize: blockSize,
+			Size:      1,
+			Type:      config.VdiskTypeBoot,
+		})
+		// the primary storage cluster is unreachable,
+		// as archiving the tlog should never touch it
+		confSource.SetPrimaryStorageCluster(vdiskID, "primarycluster", &config.StorageClusterConfig{
+			Servers: []config.StorageServerConfig{
+				config.StorageServerConfig{Address: "localhost:1"},
+			},
+		})
+		confSource.SetTlogServerCluster(vdiskID, "tlogcluster", &config.TlogClusterConfig{
+			Servers: []string{"localhost:1"},
+		})
+		confSource.SetTlogZeroStorCluster(vdiskID, "zerostorcluster", &config.ZeroStorClusterConfig{
+			IYO: config.IYOCredentials{
+				Org:       "testorg",
+				Namespace: "thedisk",
+			},
+			DataServers: serverConf,
+			MetadataServers: []config.ServerConfig{
+				config.ServerConfig{
+					Address: mdServer.ListenAddr(),
+				},
+			},
+			DataShards:   dataShards,
+			ParityShards: parityShards,
+		})
+	}
+
+	// generate the tlog history of the source vdisk
+	flusher, err := flusher.New(confSource, 8, sourceVdiskID, privKey)
+	require.NoError(t, err)
+	for i := 1; i <= numLogs; i++ {
+		data := make([]byte, blockSize)
+		rand.Read(data)
+		err = flusher.AddTransaction(tlog.Transaction{
+			Operation: schema.OpSet,
+			Sequence:  uint64(i),
+			Content:   data,
+			Index:     int64(i % 20),
+			Timestamp: tlog.TimeNowTimestamp(),
+			Hash:      zerodisk.HashBytes(data),
+		})
+		require.NoError(t, err)
+		if flusher.Full() {
+			_, _, err = flusher.Flush()
+			require.NoError(t, err)
+		}
+	}
+	_, _, err = flusher.Flush()
+	require.NoError(t, err)
+
+	// archive it into a local backup storage
+	dir, err := ioutil.TempDir("", "tlogarchive")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	var cryptoKey backup.CryptoKey
+	copy(cryptoKey[:], privKey)
+	cfg := Config{
+		VdiskID:                  sourceVdiskID,
+		ArchiveID:                archiveID,
+		PrivKey:                  privKey,
+		ChunkSize:                blockSize * 16, // multiple chunks
+		BackupStoragDriverConfig: backup.LocalStorageDriverConfig{Path: dir},
+		CompressionType:          compress.XZCompression,
+		CryptoKey:                cryptoKey,
+	}
+	ctx := context.Background()
+
+	index, err := Export(ctx, confSource, cfg)
+	require.NoError(t, err)
+	require.Equal(t, int64(7), index.Aggregations)
+	require.Equal(t, uint64(1), index.FirstSequence)
+	require.Equal(t, uint64(numLogs), index.LastSequence)
+	require.True(t, len(index.Chunks) > 1, "expected multiple chunks")
+
+	readIndex, err := ReadIndex(archiveID, cfg.BackupStoragDriverConfig, &cryptoKey, cfg.CompressionType)
+	require.NoError(t, err)
+	require.Equal(t, index, readIndex)
+
+	// an existing archive isn't overwritten, unless forced
+	_, err = Export(ctx, confSource, cfg)
+	require.Error(t, err)
+	forcedCfg := cfg
+	forcedCfg.Force = true
+	_, err = Export(ctx, confSource, forcedCfg)
+	require.NoError(t, err)
+
+	// an archive can't be imported using a different crypto key
+	invalidCfg := cfg
+	invalidCfg.VdiskID = targetVdiskID
+	invalidCfg.CryptoKey[0]++
+	_, err = Import(ctx, confSource, invalidCfg)
+	require.Error(t, err)
+
+	// import the archive into the target vdisk
+	importCfg := cfg
+	importCfg.VdiskID = targetVdiskID
+	_, err = Import(ctx, confSource, importCfg)
+	require.NoError(t, err)
+
+	// the target vdisk has the same tlog history as the source vdisk
+	type walkedAgg struct {
+		epoch int64
+		seqs  []uint64
+		data  [][]byte
+	}
+	walk := func(vdiskID string) (aggs []walkedAgg) {
+		storCli, err := stor.NewClientFromConfigSource(confSource, vdiskID, privKey)
+		require.NoError(t, err)
+		defer storCli.Close()
+
+		for wr := range storCli.Walk(0, tlog.TimeNowTimestamp()) {
+			require.NoError(t, wr.Err)
+			agg := walkedAgg{epoch: wr.Meta.Epoch}
+			blocks, err := wr.Agg.Blocks()
+			require.NoError(t, err)
+			for i := 0; i < int(wr.Agg.Size()); i++ {
+				block := blocks.At(i)
+				data, err := block.Data()
+				require.NoError(t, err)
+				agg.seqs = append(agg.seqs, block.Sequence())
+				agg.data = append(agg.data, data)
+			}
+			aggs = append(aggs, agg)
+		}
+		return
+	}
+	expected := walk(sourceVdiskID)
+	require.Len(t, expected, int(index.Aggregations))
+	require.Equal(t, expected, walk(targetVdiskID))
+
+	// an archive can't be imported into an existing tlog
+	_, err = Import(ctx, confSource, importCfg)
+	require.Error(t, err)
+
+	// the archive can be imported into the source vdisk,
+	// once its tlog is deleted, without affecting the target vdisk
+	storCli, err := stor.NewClientFromConfigSource(confSource, sourceVdiskID, privKey)
+	require.NoError(t, err)
+	require.NoError(t, storCli.Delete())
+	storCli.Close()
+
+	_, err = Import(ctx, confSource, cfg)
+	require.NoError(t, err)
+	require.Equal(t, expected, walk(sourceVdiskID))
+	require.Equal(t, expected, walk(targetVdiskID))
+
+	// an archive without any aggregations can't be imported
+	storCli, err = stor.NewClientFromConfigSource(confSource, targetVdiskID, privKey)
+	require.NoError(t, err)
+	require.NoError(t, storCli.Delete())
+	storCli.Close()
+
+	emptyCfg := importCfg
+	emptyCfg.ArchiveID = "empty_archive"
+	buf := bytes.NewBuffer(nil)
+	err = serialize(&Index{
+		ArchiveID: emptyCfg.ArchiveID,
+		VdiskID:   sourceVdiskID,
+		Version:   zerodisk.CurrentVersion,
+	}, &cryptoKey, cfg.CompressionType, buf)
+	require.NoError(t, err)
+	driver, err := backup.NewStorageDriver(cfg.BackupStoragDriverConfig)
+	require.NoError(t, err)
+	require.NoError(t, driver.SetHeader(emptyCfg.ArchiveID, buf))
+	driver.Close()
+
+	_, err = Import(ctx, confSource, emptyCfg)
+	require.Error(t, err)
+}
@@ -0,0 +1,30 @@
+package tlog
+
+import (
+	"crypto/subtle"
+
+	"github.com/zero-os/0-Disk/config"
+	"github.com/zero-os/0-Disk/errors"
+)
+
+// ReadAuthToken reads the authentication token of a vdisk,
+// as defined in its tlog config. An empty token is returned
+// in case no authentication is required for that vdisk.
+func ReadAuthToken(confSource config.Source, vdiskID string) (string, error) {
+	tlogConf, err := config.ReadVdiskTlogConfig(confSource, vdiskID)
+	if err != nil {
+		return "", errors.Wrapf(err,
+			"couldn't read vdisk %s's tlog config", vdiskID)
+	}
+	return tlogConf.AuthToken, nil
+}
+
+// ValidAuthToken returns true in case the given token
+// matches the expected authentication token of a vdisk,
+// or in case no authentication token is expected.
+func ValidAuthToken(expected, token string) bool {
+	if expected == "" {
+		return true
+	}
+	return subtle.ConstantTimeCompare([]byte(expected), []byte(token)) == 1
+}
@@ -128,7 +128,7 @@ func (g *Generator) GenerateFromStorage(parentCtx context.Context) (uint64, erro
 					Content:   ic.content,
 					Index:     ic.idx,
 					Timestamp: timestamp,
-					Hash:      zerodisk.Hash(ic.content),
+					Hash:      zerodisk.HashBytes(ic.content),
 				})
 
 				if err != nil {
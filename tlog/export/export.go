@@ -0,0 +1,162 @@
+package export
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+
+	"github.com/zero-os/0-Disk/config"
+	"github.com/zero-os/0-Disk/errors"
+	"github.com/zero-os/0-Disk/log"
+	"github.com/zero-os/0-Disk/nbd/ardb/backup"
+	"github.com/zero-os/0-Disk/nbd/ardb/storage"
+	"github.com/zero-os/0-Disk/tlog"
+	"github.com/zero-os/0-Disk/tlog/tlogclient/decoder"
+	"github.com/zero-os/0-Disk/tlog/tlogclient/player"
+	"gopkg.in/validator.v2"
+)
+
+// Config represents config for the export operations
+type Config struct {
+	VdiskID string `validate:"nonzero"`
+	PrivKey string `validate:"nonzero"`
+	// Optional: limits the part of the tlog which is replayed,
+	// the entire tlog is replayed when nil
+	Limiter decoder.Limiter
+}
+
+// ToImage replays the tlog of a vdisk into a local (sparse) raw image,
+// stored at the given path, without touching the (ARDB) storage of that vdisk.
+// The raw image has the size of the vdisk, as defined in its static config.
+// It returns the last sequence that was replayed.
+//
+// NOTE: the resulting image only represents the vdisk in full,
+// in case its tlog contains the entire history of that vdisk,
+// meaning that none of its aggregations were deleted by a retention policy.
+func ToImage(ctx context.Context, confSource config.Source, conf Config, path string) (uint64, error) {
+	blockStorage, err := newRawImage(confSource, conf, path)
+	if err != nil {
+		return 0, err
+	}
+	defer blockStorage.Close()
+
+	return replay(ctx, confSource, conf, blockStorage)
+}
+
+// ToSnapshot replays the tlog of a vdisk into a backup (snapshot),
+// without touching the (ARDB) storage of that vdisk.
+// The tlog is first replayed into a temporary local (sparse) raw image,
+// which is exported as a snapshot of the vdisk, using the given backup config,
+// and deleted afterwards. The VdiskID and ConfigSource of the given
+// backup config are overwritten, as they are defined by the export config.
+// It returns the last sequence that was replayed.
+//
+// NOTE: the resulting snapshot only represents the vdisk in full,
+// in case its tlog contains the entire history of that vdisk,
+// meaning that none of its aggregations were deleted by a retention policy.
+func ToSnapshot(ctx context.Context, confSource config.Source, conf Config, backupConf backup.Config) (uint64, error) {
+	file, err := ioutil.TempFile("", "tlog-export-"+conf.VdiskID)
+	if err != nil {
+		return 0, errors.Wrap(err, "couldn't create temporary raw image")
+	}
+	imagePath := file.Name()
+	file.Close()
+	defer os.Remove(imagePath)
+
+	blockStorage, err := newRawImage(confSource, conf, imagePath)
+	if err != nil {
+		return 0, err
+	}
+	defer blockStorage.Close()
+
+	lastSeq, err := replay(ctx, confSource, conf, blockStorage)
+	if err != nil {
+		return lastSeq, err
+	}
+
+	// collect the indices of all blocks that were replayed
+	indices, err := blockStorage.BlockIndices()
+	if err != nil {
+		return lastSeq, err
+	}
+
+	log.Infof("exporting %d replayed blocks of vdisk %s", len(indices), conf.VdiskID)
+	backupConf.VdiskID = conf.VdiskID
+	backupConf.ConfigSource = confSource
+	return lastSeq, backup.ExportBlockStorage(ctx, blockStorage, indices, backupConf)
+}
+
+// rawImageStorage is the block storage of a raw image,
+// which can list the indices of all blocks it stores.
+type rawImageStorage interface {
+	storage.BlockStorage
+	storage.BlockIndexLister
+}
+
+// newRawImage creates a raw image for the vdisk defined in the given config.
+func newRawImage(confSource config.Source, conf Config, path string) (rawImageStorage, error) {
+	if err := validator.Validate(conf); err != nil {
+		return nil, err
+	}
+
+	hasTlog, err := tlog.HasTlogCluster(confSource, conf.VdiskID)
+	if err != nil {
+		return nil, err
+	}
+	if !hasTlog {
+		return nil, errors.Newf("vdisk %s has no tlog cluster configured", conf.VdiskID)
+	}
+
+	staticConfig, err := config.ReadVdiskStaticConfig(confSource, conf.VdiskID)
+	if err != nil {
+		return nil, err
+	}
+	blockSize := int64(staticConfig.BlockSize)
+	size := int64(staticConfig.Size) * 1024 * 1024 * 1024 // GiB -> bytes
+
+	blockStorage, err := storage.RawImage(path, blockSize, size)
+	if err != nil {
+		return nil, err
+	}
+	rawImage, ok := blockStorage.(rawImageStorage)
+	if !ok {
+		blockStorage.Close()
+		return nil, errors.Newf("raw image storage %T can't list its blocks", blockStorage)
+	}
+	return rawImage, nil
+}
+
+// replay the tlog of the vdisk defined in the given config into the given storage,
+// returning the last sequence that was replayed.
+// The given storage isn't closed, such that it can still be used afterwards.
+func replay(ctx context.Context, confSource config.Source, conf Config, blockStorage storage.BlockStorage) (uint64, error) {
+	tlogPlayer, err := player.NewPlayerWithStorage(
+		ctx, confSource, nil, unclosableStorage{blockStorage}, conf.VdiskID, conf.PrivKey)
+	if err != nil {
+		return 0, err
+	}
+	defer tlogPlayer.Close()
+
+	limiter := conf.Limiter
+	if limiter == nil {
+		limiter = decoder.NewLimitByTimestamp(0, 0)
+	}
+
+	lastSeq, err := tlogPlayer.Replay(limiter)
+	if err != nil {
+		return lastSeq, errors.Wrapf(err, "couldn't replay tlog of vdisk %s", conf.VdiskID)
+	}
+	log.Infof("replayed tlog of vdisk %s until sequence %d", conf.VdiskID, lastSeq)
+	return lastSeq, nil
+}
+
+// unclosableStorage is a BlockStorage which ignores the Close call,
+// used to keep a storage open after it has been used by the tlog player
+type unclosableStorage struct {
+	storage.BlockStorage
+}
+
+// Close implements BlockStorage.Close
+func (unclosableStorage) Close() error {
+	return nil
+}
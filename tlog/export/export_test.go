@@ -0,0 +1,178 @@
+package export
+
+import (
+	"context"
+	"crypto/rand"
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/zero-os/0-Disk"
+	"github.com/zero-os/0-Disk/compress"
+	"github.com/zero-os/0-Disk/config"
+	"github.com/zero-os/0-Disk/nbd/ardb/backup"
+	"github.com/zero-os/0-Disk/tlog"
+	"github.com/zero-os/0-Disk/tlog/flusher"
+	"github.com/zero-os/0-Disk/tlog/schema"
+	"github.com/zero-os/0-Disk/tlog/stor/embeddedserver"
+	"github.com/zero-os/0-Disk/tlog/tlogclient/decoder"
+	"github.com/zero-os/0-stor/client/meta/embedserver"
+)
+
+func TestExport(t *testing.T) {
+	const (
+		vdiskID      = "a"
+		dataShards   = 4
+		parityShards = 2
+		blockSize    = 4096
+		numLogs      = 50
+		endSequence  = 30
+		privKey      = "12345678901234567890123456789012"
+	)
+
+	storCluster, err := embeddedserver.NewZeroStorCluster(dataShards + parityShards)
+	require.NoError(t, err)
+	defer storCluster.Close()
+
+	mdServer, err := embedserver.New()
+	require.NoError(t, err)
+	defer mdServer.Stop()
+
+	// config source
+	confSource := config.NewStubSource()
+	defer confSource.Close()
+
+	var serverConf []config.ServerConfig
+	for _, addr := range storCluster.Addrs() {
+		serverConf = append(serverConf, config.ServerConfig{
+			Address: addr,
+		})
+	}
+
+	confSource.SetVdiskConfig(vdiskID, &config.VdiskStaticConfig{
+		BlockSize: blockSize,
+		Size:      1,
+		Type:      config.VdiskTypeBoot,
+	})
+	// the primary storage cluster is unreachable,
+	// as exporting the tlog should never touch it
+	confSource.SetPrimaryStorageCluster(vdiskID, "primarycluster", &config.StorageClusterConfig{
+		Servers: []config.StorageServerConfig{
+			config.StorageServerConfig{Address: "localhost:1"},
+		},
+	})
+	confSource.SetTlogServerCluster(vdiskID, "tlogcluster", &config.TlogClusterConfig{
+		Servers: []string{"localhost:1"},
+	})
+	confSource.SetTlogZeroStorCluster(vdiskID, "zerostorcluster", &config.ZeroStorClusterConfig{
+		IYO: config.IYOCredentials{
+			Org:       "testorg",
+			Namespace: "thedisk",
+		},
+		DataServers: serverConf,
+		MetadataServers: []config.ServerConfig{
+			config.ServerConfig{
+				Address: mdServer.ListenAddr(),
+			},
+		},
+		DataShards:   dataShards,
+		ParityShards: parityShards,
+	})
+
+	// generate some tlog data, overwriting and deleting some blocks,
+	// while keeping track of the expected content up to the end sequence
+	flusher, err := flusher.New(confSource, 8, vdiskID, privKey)
+	require.NoError(t, err)
+
+	expected := make(map[int64][]byte)
+	for i := 1; i <= numLogs; i++ {
+		seq := uint64(i)
+		idx := int64(i % 20)
+		op := uint8(schema.OpSet)
+		var data []byte
+		if i%7 == 0 {
+			op = schema.OpDelete
+		} else {
+			data = make([]byte, blockSize)
+			rand.Read(data)
+		}
+
+		err = flusher.AddTransaction(tlog.Transaction{
+			Operation: op,
+			Sequence:  seq,
+			Content:   data,
+			Index:     idx,
+			Timestamp: tlog.TimeNowTimestamp(),
+			Hash:      zerodisk.Hash(data),
+		})
+		require.NoError(t, err)
+
+		if flusher.Full() {
+			_, _, err = flusher.Flush()
+			require.NoError(t, err)
+		}
+
+		if seq > endSequence {
+			continue
+		}
+		if op == schema.OpDelete {
+			delete(expected, idx)
+		} else {
+			expected[idx] = data
+		}
+	}
+	_, _, err = flusher.Flush()
+	require.NoError(t, err)
+
+	dir, err := ioutil.TempDir("", "tlogexport")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	conf := Config{
+		VdiskID: vdiskID,
+		PrivKey: privKey,
+		Limiter: decoder.NewLimitBySequence(0, endSequence),
+	}
+
+	// export to a raw image
+	imagePath := path.Join(dir, "a.img")
+	lastSeq, err := ToImage(context.Background(), confSource, conf, imagePath)
+	require.NoError(t, err)
+	require.Equal(t, uint64(endSequence), lastSeq)
+
+	image, err := os.Open(imagePath)
+	require.NoError(t, err)
+	defer image.Close()
+	stat, err := image.Stat()
+	require.NoError(t, err)
+	require.Equal(t, int64(1024*1024*1024), stat.Size())
+	for index := int64(0); index < 20; index++ {
+		content := make([]byte, blockSize)
+		_, err = image.ReadAt(content, index*blockSize)
+		require.NoError(t, err)
+		if data, ok := expected[index]; ok {
+			require.Equal(t, data, content, "block %d", index)
+		} else {
+			require.Equal(t, make([]byte, blockSize), content, "block %d", index)
+		}
+	}
+
+	// export to a snapshot
+	backupDir := path.Join(dir, "backup")
+	require.NoError(t, os.Mkdir(backupDir, 0755))
+	storageConfig := backup.LocalStorageDriverConfig{Path: backupDir}
+
+	lastSeq, err = ToSnapshot(context.Background(), confSource, conf, backup.Config{
+		SnapshotID:               "snapshot",
+		BackupStoragDriverConfig: storageConfig,
+	})
+	require.NoError(t, err)
+	require.Equal(t, uint64(endSequence), lastSeq)
+
+	header, err := backup.ReadSnapshotHeader("snapshot", storageConfig, nil, compress.LZ4Compression)
+	require.NoError(t, err)
+	require.Equal(t, vdiskID, header.Metadata.Source.VdiskID)
+	require.Equal(t, int64(blockSize), header.Metadata.Source.BlockSize)
+}
@@ -0,0 +1,102 @@
+package recovery
+
+import (
+	"io/ioutil"
+	"os"
+
+	"github.com/zero-os/0-Disk/errors"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// checkpoint keeps track of the progress of a recovery,
+// such that an interrupted recovery can be resumed,
+// rather than having to start all over again.
+type checkpoint struct {
+	// ID of the cluster which is being recovered
+	ClusterID string `yaml:"cluster"`
+	// all vdisks which have been recovered
+	Finished []string `yaml:"finished,omitempty"`
+	// vdisk which is being recovered,
+	// and the last sequence which has been replayed for it
+	VdiskID  string `yaml:"vdisk,omitempty"`
+	Sequence uint64 `yaml:"sequence,omitempty"`
+
+	// path of the file the checkpoint is stored in,
+	// the checkpoint isn't stored if no path is defined
+	path string
+}
+
+// loadCheckpoint loads the checkpoint stored at the given path,
+// or creates a new checkpoint if no checkpoint was stored there yet.
+func loadCheckpoint(path, clusterID string) (*checkpoint, error) {
+	cp := &checkpoint{ClusterID: clusterID, path: path}
+	if path == "" {
+		return cp, nil
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return cp, nil
+		}
+		return nil, errors.Wrapf(err, "couldn't read recovery checkpoint %s", path)
+	}
+	err = yaml.Unmarshal(data, cp)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid recovery checkpoint %s", path)
+	}
+	if cp.ClusterID != clusterID {
+		return nil, errors.Newf(
+			"recovery checkpoint %s was created for cluster %s, not for cluster %s",
+			path, cp.ClusterID, clusterID)
+	}
+	return cp, nil
+}
+
+// IsFinished returns true if the given vdisk has already been recovered.
+func (cp *checkpoint) IsFinished(vdiskID string) bool {
+	for _, id := range cp.Finished {
+		if id == vdiskID {
+			return true
+		}
+	}
+	return false
+}
+
+// StartSequence returns the sequence to start replaying the given vdisk from.
+func (cp *checkpoint) StartSequence(vdiskID string) uint64 {
+	if cp.VdiskID != vdiskID || cp.Sequence == 0 {
+		return 0 // start from the beginning
+	}
+	return cp.Sequence + 1
+}
+
+// Replayed marks the given sequence of the given vdisk as replayed.
+func (cp *checkpoint) Replayed(vdiskID string, seq uint64) {
+	cp.VdiskID, cp.Sequence = vdiskID, seq
+}
+
+// Finish marks the given vdisk as recovered.
+func (cp *checkpoint) Finish(vdiskID string) {
+	cp.Finished = append(cp.Finished, vdiskID)
+	cp.VdiskID, cp.Sequence = "", 0
+}
+
+// Save the checkpoint to its file (if defined),
+// the checkpoint is written to a temporary file first,
+// such that a crash while saving doesn't corrupt the previous checkpoint.
+func (cp *checkpoint) Save() error {
+	if cp.path == "" {
+		return nil
+	}
+	data, err := yaml.Marshal(cp)
+	if err != nil {
+		return err
+	}
+	tmpPath := cp.path + ".tmp"
+	err = ioutil.WriteFile(tmpPath, data, 0644)
+	if err != nil {
+		return errors.Wrapf(err, "couldn't write recovery checkpoint %s", tmpPath)
+	}
+	return os.Rename(tmpPath, cp.path)
+}
@@ -0,0 +1,140 @@
+package recovery
+
+import (
+	"context"
+
+	"github.com/zero-os/0-Disk/config"
+	"github.com/zero-os/0-Disk/errors"
+	"github.com/zero-os/0-Disk/nbd/ardb"
+)
+
+// lostServers returns for each server of the given pre-disaster cluster,
+// whether or not that server was lost during the disaster.
+// A server is considered lost when it is marked as RIP in the post-disaster cluster,
+// while it wasn't yet marked as RIP in the pre-disaster cluster.
+func lostServers(preDisaster, postDisaster config.StorageClusterConfig) ([]bool, error) {
+	if len(preDisaster.Servers) != len(postDisaster.Servers) {
+		return nil, errors.Newf(
+			"post-disaster cluster has %d servers, while the pre-disaster cluster has %d servers "+
+				"(lost servers should be marked as rip instead of being removed)",
+			len(postDisaster.Servers), len(preDisaster.Servers))
+	}
+
+	var lostCount, onlineCount int
+	lost := make([]bool, len(preDisaster.Servers))
+	for index, server := range postDisaster.Servers {
+		switch server.State {
+		case config.StorageServerStateOnline:
+			onlineCount++
+		case config.StorageServerStateRIP:
+			if preDisaster.Servers[index].State != config.StorageServerStateRIP {
+				lost[index] = true
+				lostCount++
+			}
+		default:
+			return nil, errors.Newf(
+				"post-disaster server #%d %s has state %s, while only online and rip are supported",
+				index, &server, server.State)
+		}
+	}
+
+	if lostCount == 0 {
+		return nil, errors.New("no lost servers found, nothing to recover")
+	}
+	if onlineCount == 0 {
+		return nil, errors.New("no online servers found in the post-disaster cluster")
+	}
+	return lost, nil
+}
+
+// newRecoveryCluster creates a new recovery cluster,
+// see `recoveryCluster` for more information.
+func newRecoveryCluster(preDisaster, postDisaster config.StorageClusterConfig, lost []bool, dialer ardb.ConnectionDialer) (*recoveryCluster, error) {
+	cluster, err := ardb.NewCluster(postDisaster, dialer)
+	if err != nil {
+		return nil, err
+	}
+	return &recoveryCluster{
+		cluster:            cluster,
+		preDisasterServers: preDisaster.Servers,
+		serverCount:        int64(len(preDisaster.Servers)),
+		lost:               lost,
+	}, nil
+}
+
+// recoveryCluster is a storage cluster which applies all actions to the post-disaster cluster,
+// but only applies actions which modify data in case the object in question
+// was stored on a lost server prior to the disaster.
+// This way only the data which got lost is rewritten,
+// while the data of all other servers remains untouched.
+type recoveryCluster struct {
+	cluster            *ardb.Cluster
+	preDisasterServers []config.StorageServerConfig
+	serverCount        int64
+	lost               []bool
+}
+
+// Do implements StorageCluster.Do
+func (rc *recoveryCluster) Do(action ardb.StorageAction) (interface{}, error) {
+	serverIndex, err := ardb.FindFirstServerIndex(rc.serverCount, rc.serverOperational)
+	if err != nil {
+		return nil, err
+	}
+	if !rc.shouldApply(serverIndex, action) {
+		return nil, nil
+	}
+	return rc.cluster.Do(action)
+}
+
+// DoFor implements StorageCluster.DoFor
+func (rc *recoveryCluster) DoFor(objectIndex int64, action ardb.StorageAction) (interface{}, error) {
+	serverIndex, err := ardb.ComputeServerIndex(rc.serverCount, objectIndex, rc.serverOperational)
+	if err != nil {
+		return nil, err
+	}
+	if !rc.shouldApply(serverIndex, action) {
+		return nil, nil
+	}
+	return rc.cluster.DoFor(objectIndex, action)
+}
+
+// DoForAll implements StorageCluster.DoForAll
+func (rc *recoveryCluster) DoForAll(pairs []ardb.IndexActionPair) ([]interface{}, error) {
+	replies := make([]interface{}, len(pairs))
+	for i, pair := range pairs {
+		reply, err := rc.DoFor(pair.Index, pair.Action)
+		if err != nil {
+			return nil, err
+		}
+		replies[i] = reply
+	}
+	return replies, nil
+}
+
+// ServerIterator implements StorageCluster.ServerIterator
+func (rc *recoveryCluster) ServerIterator(ctx context.Context) (<-chan ardb.StorageServer, error) {
+	return rc.cluster.ServerIterator(ctx)
+}
+
+// ServerCount implements StorageCluster.ServerCount
+func (rc *recoveryCluster) ServerCount() int64 {
+	return rc.cluster.ServerCount()
+}
+
+// shouldApply returns true in case the action only reads data,
+// or in case the pre-disaster server it maps to was lost.
+func (rc *recoveryCluster) shouldApply(serverIndex int64, action ardb.StorageAction) bool {
+	if _, ok := action.KeysModified(); !ok {
+		return true
+	}
+	return rc.lost[serverIndex]
+}
+
+// serverOperational returns if a pre-disaster server was operational
+func (rc *recoveryCluster) serverOperational(index int64) (bool, error) {
+	return rc.preDisasterServers[index].State == config.StorageServerStateOnline, nil
+}
+
+var (
+	_ ardb.StorageCluster = (*recoveryCluster)(nil)
+)
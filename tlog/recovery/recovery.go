@@ -0,0 +1,205 @@
+package recovery
+
+import (
+	"context"
+	"time"
+
+	"github.com/zero-os/0-Disk/config"
+	"github.com/zero-os/0-Disk/errors"
+	"github.com/zero-os/0-Disk/log"
+	"github.com/zero-os/0-Disk/nbd/ardb"
+	"github.com/zero-os/0-Disk/nbd/ardb/storage"
+	"github.com/zero-os/0-Disk/tlog"
+	"github.com/zero-os/0-Disk/tlog/tlogclient/decoder"
+	"github.com/zero-os/0-Disk/tlog/tlogclient/player"
+	"gopkg.in/validator.v2"
+)
+
+// Config represents the config for the disaster recovery of a storage cluster.
+type Config struct {
+	// ID of the (primary) storage cluster to recover,
+	// its config (read from the config source) is expected to be the post-disaster config,
+	// in which all lost servers are marked as RIP.
+	ClusterID string `validate:"nonzero"`
+	// Config of the storage cluster as it was before the disaster happened.
+	PreDisasterCluster config.StorageClusterConfig
+	// IDs of the vdisks to recover,
+	// vdisks which do not use the storage cluster as their primary cluster are skipped.
+	VdiskIDs []string
+	PrivKey  string `validate:"nonzero"`
+	// Optional path of the file used to store the recovery progress,
+	// such that an interrupted recovery can be resumed.
+	CheckpointPath string
+}
+
+// checkpointInterval defines how often the progress
+// of a vdisk recovery is logged and stored in the checkpoint.
+const checkpointInterval = 5 * time.Second
+
+// Recover rewrites all data of the given vdisks, which got lost during a disaster,
+// by replaying the tlog of each vdisk.
+// Only data which was stored on a lost server, according to the pre-disaster cluster config,
+// is rewritten, using the post-disaster cluster config.
+// See `/specs/disaster_recovery.md` for more information.
+func Recover(ctx context.Context, source config.Source, cfg Config) error {
+	if err := validator.Validate(cfg); err != nil {
+		return err
+	}
+	if err := cfg.PreDisasterCluster.Validate(); err != nil {
+		return errors.Wrap(err, "invalid pre-disaster cluster config")
+	}
+
+	postDisasterCluster, err := config.ReadStorageClusterConfig(source, cfg.ClusterID)
+	if err != nil {
+		return errors.Wrapf(err,
+			"couldn't read post-disaster config for cluster %s", cfg.ClusterID)
+	}
+	lost, err := lostServers(cfg.PreDisasterCluster, *postDisasterCluster)
+	if err != nil {
+		return err
+	}
+	for index, isLost := range lost {
+		if isLost {
+			log.Infof("recovering data of lost server #%d %s",
+				index, &cfg.PreDisasterCluster.Servers[index])
+		}
+	}
+
+	cp, err := loadCheckpoint(cfg.CheckpointPath, cfg.ClusterID)
+	if err != nil {
+		return err
+	}
+
+	pool := ardb.NewPool(nil)
+	defer pool.Close()
+
+	vdiskCount := len(cfg.VdiskIDs)
+	for index, vdiskID := range cfg.VdiskIDs {
+		if cp.IsFinished(vdiskID) {
+			log.Infof("skipping vdisk %s (%d/%d), as it was already recovered",
+				vdiskID, index+1, vdiskCount)
+			continue
+		}
+
+		log.Infof("recovering vdisk %s (%d/%d)...", vdiskID, index+1, vdiskCount)
+		vr := vdiskRecoverer{
+			vdiskID:      vdiskID,
+			cfg:          &cfg,
+			postDisaster: *postDisasterCluster,
+			lost:         lost,
+			dialer:       pool,
+			checkpoint:   cp,
+		}
+		err = vr.Recover(ctx, source)
+		if err != nil {
+			return errors.Wrapf(err, "couldn't recover vdisk %s", vdiskID)
+		}
+
+		cp.Finish(vdiskID)
+		err = cp.Save()
+		if err != nil {
+			return err
+		}
+		log.Infof("recovered vdisk %s (%d/%d)", vdiskID, index+1, vdiskCount)
+	}
+
+	return nil
+}
+
+// vdiskRecoverer is used to recover the lost data of a single vdisk.
+type vdiskRecoverer struct {
+	vdiskID      string
+	cfg          *Config
+	postDisaster config.StorageClusterConfig
+	lost         []bool
+	dialer       ardb.ConnectionDialer
+	checkpoint   *checkpoint
+}
+
+// Recover the lost data of the vdisk, by replaying its tlog.
+func (vr *vdiskRecoverer) Recover(ctx context.Context, source config.Source) error {
+	hasTlog, err := tlog.HasTlogCluster(source, vr.vdiskID)
+	if err != nil {
+		return err
+	}
+	if !hasTlog {
+		// [TODO] Notify AYS about this error
+		log.Errorf(
+			"vdisk %s has no tlog cluster configured, its lost data (if any) cannot be recovered",
+			vr.vdiskID)
+		return nil
+	}
+
+	nbdConfig, err := config.ReadVdiskNBDConfig(source, vr.vdiskID)
+	if err != nil {
+		return err
+	}
+	if nbdConfig.StorageClusterID != vr.cfg.ClusterID {
+		log.Infof(
+			"skipping vdisk %s, as it uses storage cluster %s instead of %s",
+			vr.vdiskID, nbdConfig.StorageClusterID, vr.cfg.ClusterID)
+		return nil
+	}
+
+	staticConfig, err := config.ReadVdiskStaticConfig(source, vr.vdiskID)
+	if err != nil {
+		return err
+	}
+
+	cluster, err := newRecoveryCluster(vr.cfg.PreDisasterCluster, vr.postDisaster, vr.lost, vr.dialer)
+	if err != nil {
+		return err
+	}
+	// the template cluster isn't needed,
+	// as all data which is to be recovered is replayed from the tlog.
+	blockStorage, err := storage.NewBlockStorage(storage.BlockStorageConfig{
+		VdiskID:         vr.vdiskID,
+		TemplateVdiskID: staticConfig.TemplateVdiskID,
+		VdiskType:       staticConfig.Type,
+		BlockSize:       int64(staticConfig.BlockSize),
+		LBACacheLimit:   ardb.DefaultLBACacheLimit,
+	}, cluster, nil)
+	if err != nil {
+		return err
+	}
+
+	p, err := player.NewPlayerWithStorage(ctx, source, nil, blockStorage, vr.vdiskID, vr.cfg.PrivKey)
+	if err != nil {
+		blockStorage.Close()
+		return err
+	}
+	defer p.Close()
+
+	startSeq := vr.checkpoint.StartSequence(vr.vdiskID)
+	if startSeq > 0 {
+		log.Infof("resuming recovery of vdisk %s from sequence %d", vr.vdiskID, startSeq)
+	}
+
+	lastCheckpoint := time.Now()
+	lastSeq, err := p.ReplayWithCallback(
+		decoder.NewLimitBySequence(startSeq, 0),
+		func(seq uint64) error {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			// the block storage is flushed prior to this callback,
+			// hence it is safe to mark this sequence as replayed.
+			vr.checkpoint.Replayed(vr.vdiskID, seq)
+			if time.Since(lastCheckpoint) < checkpointInterval {
+				return nil
+			}
+			lastCheckpoint = time.Now()
+			log.Infof("recovering vdisk %s: replayed up to sequence %d", vr.vdiskID, seq)
+			return vr.checkpoint.Save()
+		})
+	if err != nil {
+		// store the progress made so far, such that we can resume from there
+		if saveErr := vr.checkpoint.Save(); saveErr != nil {
+			log.Errorf("couldn't save recovery checkpoint: %v", saveErr)
+		}
+		return err
+	}
+
+	log.Infof("replayed vdisk %s up to sequence %d", vr.vdiskID, lastSeq)
+	return nil
+}
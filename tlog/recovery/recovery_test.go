@@ -0,0 +1,304 @@
+package recovery
+
+import (
+	"context"
+	"crypto/rand"
+	"io/ioutil"
+	"os"
+	"path"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zero-os/0-Disk"
+	"github.com/zero-os/0-Disk/config"
+	"github.com/zero-os/0-Disk/nbd/ardb"
+	"github.com/zero-os/0-Disk/nbd/ardb/command"
+	"github.com/zero-os/0-Disk/nbd/ardb/storage"
+	"github.com/zero-os/0-Disk/redisstub"
+	"github.com/zero-os/0-Disk/tlog"
+	"github.com/zero-os/0-Disk/tlog/flusher"
+	"github.com/zero-os/0-Disk/tlog/schema"
+	"github.com/zero-os/0-Disk/tlog/stor/embeddedserver"
+	"github.com/zero-os/0-stor/client/meta/embedserver"
+)
+
+func TestLostServers(t *testing.T) {
+	online := config.StorageServerConfig{Address: "localhost:16379", State: config.StorageServerStateOnline}
+	rip := config.StorageServerConfig{Address: "localhost:16379", State: config.StorageServerStateRIP}
+	offline := config.StorageServerConfig{Address: "localhost:16379", State: config.StorageServerStateOffline}
+
+	testCases := []struct {
+		preDisaster, postDisaster []config.StorageServerConfig
+		expected                  []bool
+	}{
+		{
+			[]config.StorageServerConfig{online, online},
+			[]config.StorageServerConfig{online, rip},
+			[]bool{false, true},
+		},
+		{
+			[]config.StorageServerConfig{online, rip, online, online},
+			[]config.StorageServerConfig{rip, rip, online, rip},
+			[]bool{true, false, false, true},
+		},
+		// invalid: different server count
+		{
+			[]config.StorageServerConfig{online, online},
+			[]config.StorageServerConfig{online},
+			nil,
+		},
+		// invalid: no lost servers
+		{
+			[]config.StorageServerConfig{online, rip},
+			[]config.StorageServerConfig{online, rip},
+			nil,
+		},
+		// invalid: no online servers
+		{
+			[]config.StorageServerConfig{online, online},
+			[]config.StorageServerConfig{rip, rip},
+			nil,
+		},
+		// invalid: unsupported state
+		{
+			[]config.StorageServerConfig{online, online},
+			[]config.StorageServerConfig{offline, rip},
+			nil,
+		},
+	}
+
+	for index, testCase := range testCases {
+		lost, err := lostServers(
+			config.StorageClusterConfig{Servers: testCase.preDisaster},
+			config.StorageClusterConfig{Servers: testCase.postDisaster})
+		if testCase.expected == nil {
+			assert.Error(t, err, "test case #%d", index)
+			continue
+		}
+		if assert.NoError(t, err, "test case #%d", index) {
+			assert.Equal(t, testCase.expected, lost, "test case #%d", index)
+		}
+	}
+}
+
+func TestRecoveryCluster(t *testing.T) {
+	slice := redisstub.NewMemoryRedisSlice(2)
+	defer slice.Close()
+
+	preDisaster := slice.StorageClusterConfig()
+	postDisaster := preDisaster.Clone()
+	postDisaster.Servers[1].State = config.StorageServerStateRIP
+
+	lost, err := lostServers(preDisaster, postDisaster)
+	require.NoError(t, err)
+	cluster, err := newRecoveryCluster(preDisaster, postDisaster, lost, nil)
+	require.NoError(t, err)
+
+	server, err := ardb.NewUniCluster(preDisaster.Servers[0], nil)
+	require.NoError(t, err)
+
+	const objectCount = 64
+	for index := int64(0); index < objectCount; index++ {
+		_, err = cluster.DoFor(index, ardb.Command(command.Set, strconv.FormatInt(index, 10), index))
+		require.NoError(t, err)
+	}
+
+	for index := int64(0); index < objectCount; index++ {
+		// only the objects which were stored on the lost server should be written,
+		// while they should all be readable via the recovery cluster
+		exists, err := ardb.Bool(server.Do(ardb.Command(command.Exists, strconv.FormatInt(index, 10))))
+		require.NoError(t, err)
+		assert.Equal(t, index%2 == 1, exists, "object %d", index)
+
+		_, err = ardb.Int64(cluster.DoFor(index, ardb.Command(command.Get, strconv.FormatInt(index, 10))))
+		if index%2 == 1 {
+			assert.NoError(t, err, "object %d", index)
+		} else {
+			assert.Equal(t, ardb.ErrNil, err, "object %d", index)
+		}
+	}
+}
+
+func TestRecoverDeduped(t *testing.T) {
+	testRecover(t, config.VdiskTypeBoot)
+}
+
+func TestRecoverNonDeduped(t *testing.T) {
+	testRecover(t, config.VdiskTypeDB)
+}
+
+func testRecover(t *testing.T, vdiskType config.VdiskType) {
+	const (
+		vdiskID           = "a"
+		clusterID         = "primary"
+		zeroStorClusterID = "zerostor"
+		dataShards        = 4
+		parityShards      = 2
+		blockSize         = 4096
+		blockCount        = 64
+		privKey           = "12345678901234567890123456789012"
+	)
+
+	require := require.New(t)
+
+	// creates zero-stor cluster
+	storCluster, err := embeddedserver.NewZeroStorCluster(dataShards + parityShards)
+	require.NoError(err)
+	defer storCluster.Close()
+	mdServer, err := embedserver.New()
+	require.NoError(err)
+	defer mdServer.Stop()
+
+	var serverConf []config.ServerConfig
+	for _, addr := range storCluster.Addrs() {
+		serverConf = append(serverConf, config.ServerConfig{Address: addr})
+	}
+
+	slice := redisstub.NewMemoryRedisSlice(2)
+	defer slice.Close()
+	preDisaster := slice.StorageClusterConfig()
+
+	staticConfig := config.VdiskStaticConfig{
+		BlockSize: blockSize,
+		Size:      1,
+		Type:      vdiskType,
+	}
+
+	source := config.NewStubSource()
+	defer source.Close()
+	source.SetVdiskConfig(vdiskID, &staticConfig)
+	source.SetPrimaryStorageCluster(vdiskID, clusterID, &preDisaster)
+	source.SetTlogServerCluster(vdiskID, "tlog", &config.TlogClusterConfig{
+		Servers: []string{"localhost:20031"},
+	})
+	source.SetTlogZeroStorCluster(vdiskID, zeroStorClusterID, &config.ZeroStorClusterConfig{
+		IYO: config.IYOCredentials{
+			Org:       "testorg",
+			Namespace: "thedisk",
+		},
+		MetadataServers: []config.ServerConfig{
+			config.ServerConfig{Address: mdServer.ListenAddr()},
+		},
+		DataServers:  serverConf,
+		DataShards:   dataShards,
+		ParityShards: parityShards,
+	})
+
+	// store the data both in the tlog and the storage cluster
+	preCluster, err := ardb.NewCluster(preDisaster, nil)
+	require.NoError(err)
+	blockStorage, err := storage.NewBlockStorage(storage.BlockStorageConfig{
+		VdiskID:       vdiskID,
+		VdiskType:     vdiskType,
+		BlockSize:     blockSize,
+		LBACacheLimit: ardb.DefaultLBACacheLimit,
+	}, preCluster, nil)
+	require.NoError(err)
+
+	flusher, err := flusher.New(source, 0, vdiskID, privKey)
+	require.NoError(err)
+
+	var contents [][]byte
+	for index := int64(0); index < blockCount; index++ {
+		content := make([]byte, blockSize)
+		rand.Read(content)
+		contents = append(contents, content)
+
+		require.NoError(blockStorage.SetBlock(index, content))
+		require.NoError(flusher.AddTransaction(tlog.Transaction{
+			Operation: schema.OpSet,
+			Sequence:  uint64(index),
+			Content:   content,
+			Index:     index,
+			Timestamp: tlog.TimeNowTimestamp(),
+			Hash:      zerodisk.Hash(content),
+		}))
+		if flusher.Full() {
+			_, _, err = flusher.Flush()
+			require.NoError(err)
+		}
+	}
+	require.NoError(blockStorage.Flush())
+	require.NoError(blockStorage.Close())
+	_, _, err = flusher.Flush()
+	require.NoError(err)
+
+	// the disaster happens, and the 2nd server is lost
+	slice.CloseServer(1)
+	postDisaster := preDisaster.Clone()
+	postDisaster.Servers[1].State = config.StorageServerStateRIP
+	source.SetStorageCluster(clusterID, &postDisaster)
+
+	tmpDir, err := ioutil.TempDir("", "recovery")
+	require.NoError(err)
+	defer os.RemoveAll(tmpDir)
+	checkpointPath := path.Join(tmpDir, "checkpoint.yaml")
+
+	err = Recover(context.Background(), source, Config{
+		ClusterID:          clusterID,
+		PreDisasterCluster: preDisaster,
+		VdiskIDs:           []string{vdiskID},
+		PrivKey:            privKey,
+		CheckpointPath:     checkpointPath,
+	})
+	require.NoError(err)
+
+	// all data should be available again in the post-disaster cluster
+	postCluster, err := ardb.NewCluster(postDisaster, nil)
+	require.NoError(err)
+	blockStorage, err = storage.NewBlockStorage(storage.BlockStorageConfig{
+		VdiskID:       vdiskID,
+		VdiskType:     vdiskType,
+		BlockSize:     blockSize,
+		LBACacheLimit: ardb.DefaultLBACacheLimit,
+	}, postCluster, nil)
+	require.NoError(err)
+	defer blockStorage.Close()
+	for index := int64(0); index < blockCount; index++ {
+		content, err := blockStorage.GetBlock(index)
+		require.NoError(err)
+		require.Equal(contents[index], content, "block %d", index)
+	}
+
+	// the vdisk should be marked as finished
+	cp, err := loadCheckpoint(checkpointPath, clusterID)
+	require.NoError(err)
+	require.True(cp.IsFinished(vdiskID))
+	require.Empty(cp.VdiskID)
+}
+
+func TestCheckpoint(t *testing.T) {
+	require := require.New(t)
+
+	tmpDir, err := ioutil.TempDir("", "recovery")
+	require.NoError(err)
+	defer os.RemoveAll(tmpDir)
+	checkpointPath := path.Join(tmpDir, "checkpoint.yaml")
+
+	cp, err := loadCheckpoint(checkpointPath, "foo")
+	require.NoError(err)
+	require.False(cp.IsFinished("a"))
+	require.Equal(uint64(0), cp.StartSequence("a"))
+
+	cp.Replayed("a", 42)
+	require.NoError(cp.Save())
+
+	cp, err = loadCheckpoint(checkpointPath, "foo")
+	require.NoError(err)
+	require.Equal(uint64(43), cp.StartSequence("a"))
+	require.Equal(uint64(0), cp.StartSequence("b"))
+
+	cp.Finish("a")
+	require.NoError(cp.Save())
+
+	cp, err = loadCheckpoint(checkpointPath, "foo")
+	require.NoError(err)
+	require.True(cp.IsFinished("a"))
+	require.Equal(uint64(0), cp.StartSequence("a"))
+
+	// a checkpoint can't be used for another cluster
+	_, err = loadCheckpoint(checkpointPath, "bar")
+	require.Error(err)
+}
@@ -15,12 +15,12 @@ type HandshakeRequest struct{ capnp.Struct }
 const HandshakeRequest_TypeID = 0xe0d4e6d68fa24ac0
 
 func NewHandshakeRequest(s *capnp.Segment) (HandshakeRequest, error) {
-	st, err := capnp.NewStruct(s, capnp.ObjectSize{DataSize: 8, PointerCount: 1})
+	st, err := capnp.NewStruct(s, capnp.ObjectSize{DataSize: 8, PointerCount: 2})
 	return HandshakeRequest{st}, err
 }
 
 func NewRootHandshakeRequest(s *capnp.Segment) (HandshakeRequest, error) {
-	st, err := capnp.NewRootStruct(s, capnp.ObjectSize{DataSize: 8, PointerCount: 1})
+	st, err := capnp.NewRootStruct(s, capnp.ObjectSize{DataSize: 8, PointerCount: 2})
 	return HandshakeRequest{st}, err
 }
 
@@ -61,12 +61,31 @@ func (s HandshakeRequest) SetVdiskID(v string) error {
 	return s.Struct.SetText(0, v)
 }
 
+func (s HandshakeRequest) Token() (string, error) {
+	p, err := s.Struct.Ptr(1)
+	return p.Text(), err
+}
+
+func (s HandshakeRequest) HasToken() bool {
+	p, err := s.Struct.Ptr(1)
+	return p.IsValid() || err != nil
+}
+
+func (s HandshakeRequest) TokenBytes() ([]byte, error) {
+	p, err := s.Struct.Ptr(1)
+	return p.TextBytes(), err
+}
+
+func (s HandshakeRequest) SetToken(v string) error {
+	return s.Struct.SetText(1, v)
+}
+
 // HandshakeRequest_List is a list of HandshakeRequest.
 type HandshakeRequest_List struct{ capnp.List }
 
 // NewHandshakeRequest creates a new list of HandshakeRequest.
 func NewHandshakeRequest_List(s *capnp.Segment, sz int32) (HandshakeRequest_List, error) {
-	l, err := capnp.NewCompositeList(s, capnp.ObjectSize{DataSize: 8, PointerCount: 1}, sz)
+	l, err := capnp.NewCompositeList(s, capnp.ObjectSize{DataSize: 8, PointerCount: 2}, sz)
 	return HandshakeRequest_List{l}, err
 }
 
@@ -639,12 +658,12 @@ type WaitTlogHandshakeRequest struct{ capnp.Struct }
 const WaitTlogHandshakeRequest_TypeID = 0xb52fe5db64314d44
 
 func NewWaitTlogHandshakeRequest(s *capnp.Segment) (WaitTlogHandshakeRequest, error) {
-	st, err := capnp.NewStruct(s, capnp.ObjectSize{DataSize: 0, PointerCount: 1})
+	st, err := capnp.NewStruct(s, capnp.ObjectSize{DataSize: 0, PointerCount: 2})
 	return WaitTlogHandshakeRequest{st}, err
 }
 
 func NewRootWaitTlogHandshakeRequest(s *capnp.Segment) (WaitTlogHandshakeRequest, error) {
-	st, err := capnp.NewRootStruct(s, capnp.ObjectSize{DataSize: 0, PointerCount: 1})
+	st, err := capnp.NewRootStruct(s, capnp.ObjectSize{DataSize: 0, PointerCount: 2})
 	return WaitTlogHandshakeRequest{st}, err
 }
 
@@ -677,12 +696,31 @@ func (s WaitTlogHandshakeRequest) SetVdiskID(v string) error {
 	return s.Struct.SetText(0, v)
 }
 
+func (s WaitTlogHandshakeRequest) Token() (string, error) {
+	p, err := s.Struct.Ptr(1)
+	return p.Text(), err
+}
+
+func (s WaitTlogHandshakeRequest) HasToken() bool {
+	p, err := s.Struct.Ptr(1)
+	return p.IsValid() || err != nil
+}
+
+func (s WaitTlogHandshakeRequest) TokenBytes() ([]byte, error) {
+	p, err := s.Struct.Ptr(1)
+	return p.TextBytes(), err
+}
+
+func (s WaitTlogHandshakeRequest) SetToken(v string) error {
+	return s.Struct.SetText(1, v)
+}
+
 // WaitTlogHandshakeRequest_List is a list of WaitTlogHandshakeRequest.
 type WaitTlogHandshakeRequest_List struct{ capnp.List }
 
 // NewWaitTlogHandshakeRequest creates a new list of WaitTlogHandshakeRequest.
 func NewWaitTlogHandshakeRequest_List(s *capnp.Segment, sz int32) (WaitTlogHandshakeRequest_List, error) {
-	l, err := capnp.NewCompositeList(s, capnp.ObjectSize{DataSize: 0, PointerCount: 1}, sz)
+	l, err := capnp.NewCompositeList(s, capnp.ObjectSize{DataSize: 0, PointerCount: 2}, sz)
 	return WaitTlogHandshakeRequest_List{l}, err
 }
 
@@ -770,66 +808,68 @@ func (p WaitTlogHandshakeResponse_Promise) Struct() (WaitTlogHandshakeResponse,
 	return WaitTlogHandshakeResponse{s}, err
 }
 
-const schema_f4533cbae6e08506 = "x\xda\x8cS]h\x1cU\x14>\xdf\xbd3\x9b\x14\xb2" +
-	"I\x86Y\xa1)\x94E,X\x83\xb5I\xd7\xa7PH" +
-	"\xb3\xc6R\x03\x95\xde]\xa1\x10\x14\x1dgow\xc7\xcc" +
-	"\xcel\xf7\xce\xa6\x1bm\xd1\x96\x06JQ\x14Q\xa9E" +
-	"\xc4\x84\x0a\x0a\x15\x14\x12\x11\x9f\xfa\xa6>I\xa1\x0aA" +
-	"\x90\x08\xfd\xc1\x07\x85\x82\x08\x82u\xe4\xeef\x7f\x9a\xac" +
-	"\xb6o3\x87\x8f\xef~?\xe7\x8c\xe5\xd9\x016n>" +
-	"l\x10\x8913\x11\x8f_;\xb3\xff\xe7\xfa\xed\xd7I" +
-	"\x8c\xc0\x88\x13\x8b\xeb7\xbf\xde\x9f\xff\x83L\xd6G\x94" +
-	"\xf9\x0b;`ok|\x9a\xec(\x08\xf1\xda\xae\xf3\xdf" +
-	"\xfc\xb2\xe3\xea\x05\x0dG\x17\x1c\x1a\xf3\x1c\xdf\x07\xbb\xcc" +
-	"\xfb\x88l\x8f\x9f \xc4\xd3\x87\xc7\x0b?\xdd\xd8\xbbJ" +
-	"\xd6\xc8\x16\xf0\x1a\x7f\x1b\xf6\xed\x06\xf87>I\x88\x97" +
-	"\xaf\xfey\xe7\xa1W\x0e|\xab\xa9Y\x07\xfd$\xfa\x0c" +
-	"\xa2L\xd2\x98\x85\xfd\xa0\xa1\xe1;\x8d[\x84\xf8\xca\xcc" +
-	"\xf2\x9b?\xde\xbc\xb6\xdeS\x09\xcc\x1c\xec\x07L\x8d\xb6" +
-	"L\xad\xc4\xfep\xe6\xfb\x95\xb5\xf1\xeb\x9b\xd0\x9a/S" +
-	"6/\xc2^l\x80O\x9bZ\xc9;\xeb#_\xae\xac" +
-	"\xbet}\x93\x12S\x8b\xcd,\x993\xb0W5:\xf3" +
-	"\x85\x99\xd6\x99L\x1d\xfb\xee\xbdS\x17\xdf\xfd}\x13\xbc" +
-	"\xc1\xfdCb\x16\xf6\xaf\x09\xcd}#q\x8b\xf6\xc4\xca" +
-	"-\xc9\xb2\xb37\xe2~X|\xbe\xf9\xf3\x98\xebT\x82" +
-	"\xca\xc43~X\xcc\xfa!w\xe7\x8e\x00b;7\x88" +
-	"\x0c\x10Y\xef\xcf\x10\x89\x0b\x1c\xe2\x12\x83\x05\xa4\xa0\x87" +
-	"K\xfb\x88\xc4\x07\x1c\xe2\x13\x06\xb0\x14\x18\x91\xf5\xf1(" +
-	"\x91\xf8\x88C\\f\xb08R\xe0D\xd6\xa7zx\x89" +
-	"C|\xce`\x19,\x05\x83\xc8\xfa,G$.s\x88" +
-	"\xaf\x18,s{\x0a&\x91\xb5\xaa\x87+\x1c\xe2\x0aC" +
-	"\xac\xe4\xf1\x9a\x0c\\ID\xd8F\x0c\xdb\x08i/(" +
-	"\xc8:Lb0\x09C%G\x95\x90$\x86$a\xa8" +
-	"\xe0DN\xeb'\x8e\xbc\xb2T\x91S&TZ\xe88" +
-	"\xac\xc8\xaa\x13y!!@\x82\x18\x12\x84{D\x91\x93" +
-	"*]\x09\x03%u\x1a\xfd\xed4\x1e\x99 \x12\xbb8" +
-	"\xc4\x18C+\x8c=Z\xf9\xa3\x1c\xe2\x10\xc3\xa4\x8a\x9c" +
-	"\xa8\xa6\xc0\x88\x81Q\x97\x11(\x0c\x12\x8ep4\xfc\x0c" +
-	"v\xbdony\xff\xa8\xe3EZ\xc3!'(\xa8\x92" +
-	"3's\x9aE!\xd2Z\x8c\xb6\x96d\x96H\xf4s" +
-	"\x88\x14\xc3\xab\xf3\x05O\xcd=5\x8d\x01b\x18\xe8b" +
-	"7z\xba{\xc2\xf7d\x10\x1d\x96J9\xbc\xd8\xb08" +
-	"\xcc\x8d\x818n\xf0:\xba\xdcg9D\x89a'\xfe" +
-	"\x897l\xca3D\xa2\xc0!*\x0cIv'n\xb6" +
-	"^>O$*\x1c\xe2$C\x92\xff\x1d7k_\x98" +
-	"%\x12u\x0eq\x96!\xfd\xa2\x1f\xbas\x18\xee\xdc:" +
-	"\x01\xc3\x84\xf8XXu\xe5A\xbf\x06U\x9a\x8a\xf2\xf2" +
-	"x\xbb\xea\xf8\x84\xe3EOg\xa7\xf3\xf0\x9dy\x99_" +
-	"\x08\\\xea\x8b\x0b\x9er\xc3 \x90\xc4\xdd\x88\x12\xff\xe3" +
-	"\xafgj]\x0df{4\xa8g\xbb9\xc4\xe3:I" +
-	"YU^\x18\xa0\x9f\x18\xfa\xe9\xbf\x93\xbd\xaf\xdeT%" +
-	"\x0cxs\x89\xba\x8a\x9b\xe8\x147)\xeb\x9e\x8a\x14@" +
-	"\x0c\xb8goS\xc5bU\x16\xf5&\x07D\x9a4\xd5" +
-	"&=5\xda\x89\xbc\xe5\xeb\xb4\x9e\x9d\xe4\x10\xe7\x18," +
-	"\x86fc\x8bz]\xcfr\x88\xb7\xba\xee\xf4\x0d-\xe9" +
-	"\xdc\xc6\x95\xb7\xeeti\xb4s\xe5C\x81S\x96\xad\x0c" +
-	"\x86\x94\xf7\xb2l\xb7\xd5\xe3\xe0&\x1b\x9d\xb7W\xfe\xee" +
-	"\xee\x07\x09C\x95\xaa\x9co_\xec}u\xd9\x95\xe4p" +
-	"\xdb\xb4\x93\xed\xac\xaa\x05ccQ\xb5\x97\x178\x84\xdf" +
-	"\xe5\xda[&\x12>\x87\xa8k\xd7\xbb\x9b\xaekU\"" +
-	"\x11q\x88\xd7\xb6\xf6\xbe\xf9\x92}GE\x07\xfd\x9aB" +
-	"I\x16\xf2z\xb3\xfa\x02W\xde\xb5\xb1\xba J\xe7\xa4" +
-	"SXh\xd5\xf9o\x00\x00\x00\xff\xff\x0cs\xb9y"
+const schema_f4533cbae6e08506 = "x\xda\x8cT]h\x1cE\x1c\xff\xfffv\xef\x12\xc8" +
+	"\xd7r\xf7\xd0\x14\xe4\x1e,\xd8\x06c\x93\xc6\xa7R\xc9" +
+	"\x87iI\x82\x91LR(\x06\x83\xaew\xd3\xbb5{" +
+	"\xbb\x97\x9bM\x9ah\x8bZZ(EQD\xa5\x16\x11" +
+	"\x1b*(T\xf4!\x11\xf1\xa9o\xea\x93\x14\x8a\x10\x04" +
+	"\x89\xd0\x8f'\x85\x82\x08\x05\xe3\xc8\xdc\xe5\xf6\xae\xd7`" +
+	"\xf3\xb6;\xfc\xf8\xcf\xef\xeb?}\x0bl\x88\xf5\xdbO" +
+	"XD\xa2\xcfN\xe8\xfe\x9bg\x8f\xfc\xb6|\xefm\x12" +
+	"\xdd\xb0t\xe2\xfc\xe6\x9d\xef\x8f\xcc\xfcE6K\x12\x0d" +
+	"\xdc\xc7^\xa4Z+\x9f6;\x01\x82\xde\xd8w\xf1\x87" +
+	"\xdf\xf7\xde\xb8d\xe0h\x80\xc3`\xe6\xf8!\xa4\x8a<" +
+	"I\x94\xf2\xf8)\x82\x1e\x9d\xec\xcf\xfdz\xfb\xe0:9" +
+	"\xddh\x9e\xbd\xc1\xdfG\xea^\x05\xfcG\x05\xbcz\xe3" +
+	"\xef\xad\xc7_\x1f\xfa\xd1\x8cfu\xf4Q$-\xa2\x81" +
+	"qk\x16\xa99\xcb\xc0_\xb0\xee\x12\xf4\xf5\x89\xd5w" +
+	"\x7f\xb9ss\xb3\x99Ie\xf83\xf64R\xc26\xe8" +
+	"I\xfbk\x82N}:\xf1\xf3\xdaF\xff\xad&\xb4\x99" +
+	"7p\xdb\xbe\x8c\xd4V\x05|\xdf\x1e$\xe8\x0f6\xbb" +
+	"\xbf][\x7f\xf5V\x13\x13\xdb\x90\x1d\xe8NL \xd5" +
+	"\x9b0\x9f\x07\x12\x19\x10\xf4\xf0\xc9\x9f>:s\xf9\xc3" +
+	"?\x9b\xe0\x95\xd9\x93\xc9Y\xa4\xdc\xa4\x99=\x97\xbcK" +
+	"\xbdZe\x0b\xb2\xe8\x1e\x8c\xb8\x1f\xe6_\xaa\xfe<\x95" +
+	"uKA\xe9\xf0q?\xcc\x8f\xf8!\xcf\xceO\x01b" +
+	"\x0f\xb7\x88,\x109\x1fO\x10\x89K\x1c\xe2*\x83\x03" +
+	"\xa4a\x0e\xaf\x1c\"\x12\x9fp\x88/\x18\xc0\xd2`D" +
+	"\xce\xe7=D\xe23\x0eq\x8d\xc1\xe1H\x83\x139_" +
+	"\x9a\xc3\xab\x1c\xe2\x1b\x06\xc7biXD\xceW\xd3D" +
+	"\xe2\x1a\x87\xf8\x8e\xc1\xb1\xf7\xa4a\x139\xeb\xe6p\x8d" +
+	"C\\g\xd0J.,\xca +\x89\x08\xad\xc4\xd0J" +
+	"\xc8xAN.\xc3&\x06\x9b\xd0YpU\x01\xed\xc4" +
+	"\xd0N\xe8\xcc\xb9\x91[\xfb\xd1\x91W\x94*r\x8b\x84" +
+	"R\x0d\xad\xc3\x92,\xbb\x91\x17\x12\x02$\x88!Ax" +
+	"\x84\x15\xd3ReJa\xa0\xa4q\xa3%v\xe3\xc0a" +
+	"\"\xb1\x8fC\xf41\xd4\xcc\xe85\xcc\x9f\xe4\x10c\x0c" +
+	"\x83*r\xa3E\x05F\x0c\x8c\x1a\x84@\xa1\x830\xc5" +
+	"Q\xd1\xd3\xd1p\xbf\xfd\xd0\xfd'\\/2\x1c\xc6\xdc" +
+	" \xa7\x0a\xee\xbc\x9c6S\x14\xa2&.#u.q" +
+	"2\xbd&\x99\xfd\x1c\xe2i\x867\x96r\x9e\x9a\x1f\x1f" +
+	"E\x1b1\xb4\x112Q8/\x83\xda_L\xc0\xda\xd1" +
+	"\x80g}O\x06\xd1\xa4T\xca\xe5\xf9\x8a\x0b]\xdcj" +
+	"\xd3\xbar\xb5kny\x91C\x14\x18\x1e\xc3\xbfz\xfb" +
+	"ry\x96H\xe48D\x89\xa1\x9dm\xe9j1\x8a\x17" +
+	"\x89D\x89C\x9cfh\xe7\xff\xe8j3Vf\x89\xc4" +
+	"2\x878\xc7\x90y\xc5\x0f\xb3\xf3\xe8\xaa?\x07\x04t" +
+	"\x11\xf4\xc9\xb0\x9c\x95\xc7\xfcE\xa8\xc2p4#\x17\xe2" +
+	"6\xe8S\xae\x17=?2:\x03\xdf]\x923+A" +
+	"\x96\x92:\xe7\xa9l\x18\x04\x92x6\xa2\xc4\xff\xe8\xdb" +
+	"\xd1\xd8\xb6\xd8\xd8\xa3\xc6\xd8!\x0e\xf1\\=\xe4qs" +
+	"6\xca!\xa6\x18\x1c\x86\xaa\xb2Ic\xc3\x18\x878n" +
+	"\xcc\x96e\xe5\x85\x01Z\x88\xa1\x85vk\xfe\xae\xd2W" +
+	"\xa50\xe0\xd5*Z1\xcbvS\xc5\x16\x0e\x91f\x18" +
+	"\x94\xcb\x9e\x8a\x14@\x0cxd\xb4\xc3\xf9|Y\xe6\xcd" +
+	">\x04Dfh:\x1ez\xa6\xa7\x9eJM\xfa[\xe6" +
+	"\xec4\x87\xb8\xd0 \xfd\xbc)\xfd9\x0e\xf1^\xc3\xb6" +
+	"\xbfc(]\xd8~+j\xdb~\xa5\xa7\xfeVt\x06" +
+	"nQ\xd6<\xe8T\xdek2\x0et\x87\xb5\x1d\xac\xd4" +
+	"\"^\x9c\x07\xeb\xd1A\xe8,\x95\xe5R\xbc\xf7\xbb\x8a" +
+	"\xbb\xc1\xc9\xaeX\xb4;Ro\xb3\x03k\xbb\xcbF\xcb" +
+	"\xcb\x1c\xc2oP\xed\xad\x12\x09\x9fC,\x1b\xd5\xfb\xab" +
+	"\xaa\x17\xcbD\"\xe2\x10o>\xdc\x82\xe6\xf7\xc0wU" +
+	"t\xcc_T(\xc8\xdc\x8c)_2\xc8\xca\x07Jm" +
+	"\x02\xa2\xcc\xb4ts+\xb58\xff\x1b\x00\xd2\xea\xc3\x0b"
 
 func init() {
 	schemas.Register(schema_f4533cbae6e08506,
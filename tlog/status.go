@@ -85,6 +85,8 @@ func (status HandshakeStatus) String() string {
 		return "InternalServerError"
 	case HandshakeStatusInvalidRequest:
 		return "InvalidRequest"
+	case HandshakeStatusInvalidToken:
+		return "InvalidToken"
 	default:
 		return "Unknown"
 	}
@@ -108,6 +110,8 @@ func (status HandshakeStatus) Error() error {
 		return errors.New("client version is not compatible with server")
 	case HandshakeStatusInvalidRequest:
 		return errors.New("client's HandshakeRequest could not be decoded")
+	case HandshakeStatusInvalidToken:
+		return errors.New("given authentication token is not accepted by the server")
 	default:
 		return errors.Newf("invalid connection with unknown status: %d", status)
 	}
@@ -115,6 +119,9 @@ func (status HandshakeStatus) Error() error {
 
 // Handshake status values
 const (
+	// returned when the given authentication token
+	// doesn't match the token configured for the vdisk
+	HandshakeStatusInvalidToken HandshakeStatus = -6
 	// returned when the connection failed to attach to vdisk.
 	// it currently only happened when a connection trying to
 	// to connect to an already used vdisk
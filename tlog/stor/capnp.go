@@ -8,7 +8,13 @@ import (
 	"github.com/zero-os/0-Disk/tlog/schema"
 )
 
+// decodeCapnp decodes a stored aggregation,
+// decompressing it first if required.
 func (c *Client) decodeCapnp(data []byte) (*schema.TlogAggregation, error) {
+	data, err := decompressAggregation(data)
+	if err != nil {
+		return nil, err
+	}
 	buf := bytes.NewBuffer(data)
 
 	msg, err := capnp.NewDecoder(buf).Decode()
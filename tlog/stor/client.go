@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"sync"
 
+	"github.com/zero-os/0-Disk/compress"
 	"github.com/zero-os/0-Disk/config"
 	"github.com/zero-os/0-Disk/errors"
 	"github.com/zero-os/0-Disk/log"
@@ -30,6 +31,9 @@ type Config struct {
 	DataShardsNum   int
 	ParityShardsNum int
 	EncryptPrivKey  string
+	// compression type (lz4 or xz) of the stored aggregations,
+	// aggregations are stored uncompressed when empty
+	Compression string
 }
 
 // Client defines the 0-stor client
@@ -42,6 +46,11 @@ type Client struct {
 
 	hasher *hash.Hasher
 
+	// compressor of the stored aggregations,
+	// nil in case aggregations are stored uncompressed
+	compressor  compress.Compressor
+	compression compress.CompressionType
+
 	metaCli *MetaClient
 	// first & last metadata key
 	firstMetaKey []byte
@@ -77,6 +86,11 @@ func NewClient(conf Config) (*Client, error) {
 		return nil, err
 	}
 
+	compressor, compression, err := newCompressor(conf.Compression)
+	if err != nil {
+		return nil, err
+	}
+
 	metaCli, err := NewMetaClient(conf.MetaShards)
 	if err != nil {
 		return nil, err
@@ -86,6 +100,8 @@ func NewClient(conf Config) (*Client, error) {
 		vdiskID:          conf.VdiskID,
 		storClient:       sc,
 		hasher:           hasher,
+		compressor:       compressor,
+		compression:      compression,
 		metaCli:          metaCli,
 		firstMetaEtcdKey: []byte(fmt.Sprintf("tlog:%v:first_meta", conf.VdiskID)),
 		lastMetaEtcdKey:  []byte(fmt.Sprintf("tlog:%v:last_meta", conf.VdiskID)),
@@ -118,7 +134,9 @@ func NewClientFromConfigSource(confSource config.Source, vdiskID, privKey string
 	return NewClient(conf)
 }
 
-// ProcessStoreAgg processes and then stores the aggregation to 0-stor server
+// ProcessStoreAgg processes and then stores the aggregation to 0-stor server,
+// compressing it first if compression is configured.
+// The encoded (uncompressed) aggregation is returned.
 func (c *Client) ProcessStoreAgg(agg *tlog.Aggregation) ([]byte, error) {
 	c.mux.Lock()
 	defer c.mux.Unlock()
@@ -130,7 +148,11 @@ func (c *Client) ProcessStoreAgg(agg *tlog.Aggregation) ([]byte, error) {
 	if err != nil {
 		return nil, err
 	}
-	return data, c.processStoreData(data, agg.LastSequence(), timestamp)
+	storedData, err := c.compressAggregation(data)
+	if err != nil {
+		return nil, err
+	}
+	return data, c.processStoreData(storedData, agg.LastSequence(), timestamp)
 }
 
 func (c *Client) processStoreData(data []byte, lastSequence uint64, timestamp int64) error {
@@ -203,6 +225,33 @@ func (c *Client) Store(key, val []byte, prevMd, md *meta.Meta) (*meta.Meta, erro
 	return md, c.saveFirstMetaKey()
 }
 
+// ImportAgg stores a raw aggregation, as returned by Walk (possibly for another vdisk),
+// as the aggregation following the given (previous) metadata, using the given epoch.
+// The aggregation is stored with the current time as its timestamp,
+// such that its data never equals the data of the aggregation it originates from,
+// as 0-stor doesn't support storing the same data twice.
+// The aggregation is compressed if compression is configured.
+func (c *Client) ImportAgg(data []byte, epoch int64, prevMd *meta.Meta) (*meta.Meta, error) {
+	agg, err := c.decodeCapnp(data)
+	if err != nil {
+		return nil, err
+	}
+	agg.SetTimestamp(tlog.TimeNowTimestamp())
+	data, err = agg.Segment().Message().Marshal()
+	if err != nil {
+		return nil, err
+	}
+	data, err = c.compressAggregation(data)
+	if err != nil {
+		return nil, err
+	}
+
+	key := c.hasher.Hash(append([]byte(c.vdiskID), data...))
+	md := meta.New(key)
+	md.Epoch = epoch
+	return c.Store(key, data, prevMd, md)
+}
+
 // SetFirstMetaKey set & store first meta key of this vdisk
 func (c *Client) SetFirstMetaKey(key []byte) error {
 	c.mux.Lock()
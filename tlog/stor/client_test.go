@@ -1,6 +1,7 @@
 package stor
 
 import (
+	"bytes"
 	"crypto/rand"
 	"testing"
 
@@ -71,6 +72,87 @@ func TestRoundTrip(t *testing.T) {
 	require.Equal(t, numData, i)
 }
 
+func TestRoundTripCompression(t *testing.T) {
+	for _, compression := range []string{"lz4", "xz"} {
+		t.Run(compression, func(t *testing.T) {
+			testRoundTripCompression(t, compression)
+		})
+	}
+}
+
+// test that compressed aggregations can be stored and read,
+// while the uncompressed aggregations stored before remain readable
+func testRoundTripCompression(t *testing.T, compression string) {
+	const (
+		vdiskID      = "12345678"
+		numData      = 10
+		dataShards   = 4
+		parityShards = 2
+	)
+
+	mdServer, err := embedserver.New()
+	require.Nil(t, err)
+	defer mdServer.Stop()
+
+	storCluster, err := embeddedserver.NewZeroStorCluster(dataShards + parityShards)
+	require.Nil(t, err)
+	defer storCluster.Close()
+
+	conf := newTestConfig(vdiskID, dataShards, parityShards, mdServer.ListenAddr(),
+		storCluster.Addrs())
+
+	// store the first half uncompressed, and the second half compressed
+	var vals [][]byte
+	for _, compress := range []bool{false, true} {
+		if compress {
+			conf.Compression = compression
+		}
+		cli, err := NewClient(conf)
+		require.NoError(t, err)
+
+		for i := 0; i < numData/2; i++ {
+			val := make([]byte, 4096) // mostly zero-padded, and thus compressible
+			rand.Read(val[:128])
+
+			agg, err := tlog.NewAggregation(nil, 1)
+			require.NoError(t, err)
+			require.NoError(t, agg.AddBlock(encodeBlock(t, val)))
+
+			data, err := cli.ProcessStoreAgg(agg)
+			require.NoError(t, err)
+			require.False(t, isCompressed(data), "uncompressed data should be returned")
+
+			vals = append(vals, val)
+		}
+		require.NoError(t, cli.Close())
+	}
+
+	// a new client, which loads the (compressed) last aggregation,
+	// can walk over the entire history
+	cli, err := NewClient(conf)
+	require.NoError(t, err)
+	defer cli.Close()
+
+	var i int
+	for wr := range cli.Walk(0, tlog.TimeNowTimestamp()) {
+		require.NoError(t, wr.Err)
+		require.Equal(t, i >= numData/2, isCompressed(wr.Data))
+
+		blocks, err := wr.Agg.Blocks()
+		require.NoError(t, err)
+		require.Equal(t, 1, blocks.Len())
+		data, err := blocks.At(0).Data()
+		require.NoError(t, err)
+		require.Equal(t, vals[i], data)
+		i++
+	}
+	require.Equal(t, numData, i)
+}
+
+func isCompressed(data []byte) bool {
+	return bytes.HasPrefix(data, compressedAggregationMagic)
+}
+
 func encodeBlock(t *testing.T, data []byte) *schema.TlogBlock {
 	buf := make([]byte, 0, 4096)
 	_, seg, err := capnp.NewMessage(capnp.SingleSegment(buf))
@@ -88,7 +170,17 @@ func encodeBlock(t *testing.T, data []byte) *schema.TlogBlock {
 func createTestClient(t *testing.T, vdiskID string, dataShards, parityShards int,
 	mdServerAddr string, storClusterAddrs []string) *Client {
 
-	conf := Config{
+	cli, err := NewClient(newTestConfig(vdiskID, dataShards, parityShards,
+		mdServerAddr, storClusterAddrs))
+	require.Nil(t, err)
+
+	return cli
+}
+
+func newTestConfig(vdiskID string, dataShards, parityShards int,
+	mdServerAddr string, storClusterAddrs []string) Config {
+
+	return Config{
 		VdiskID:         vdiskID,
 		Organization:    "testorg",
 		Namespace:       "thedisk",
@@ -100,8 +192,4 @@ func createTestClient(t *testing.T, vdiskID string, dataShards, parityShards int
 		ParityShardsNum: parityShards,
 		EncryptPrivKey:  "12345678901234567890123456789012",
 	}
-	cli, err := NewClient(conf)
-	require.Nil(t, err)
-
-	return cli
 }
@@ -0,0 +1,244 @@
+package stor
+
+import (
+	"github.com/zero-os/0-Disk/tlog"
+	"github.com/zero-os/0-Disk/tlog/schema"
+	"github.com/zero-os/0-stor/client/meta"
+)
+
+// checkpointAggregationSize is the maximum amount of blocks
+// stored in a single checkpoint aggregation
+var checkpointAggregationSize = 1024
+
+// CompactResult is the result of a compaction.
+type CompactResult struct {
+	// amount of aggregations replaced by the checkpoint
+	Aggregations int
+	// amount of aggregations the checkpoint consists of
+	CheckpointAggregations int
+	// amount of blocks stored in the checkpoint
+	CheckpointBlocks int
+}
+
+// Compact collapses all tlog aggregations of this vdisk,
+// of which all blocks have a sequence lower than the given sequence,
+// into a checkpoint, which only contains the latest block of each block index.
+// A sequence of 0 means that all aggregations but the last one are compacted.
+// The last aggregation is never compacted, such that the history
+// can still be appended to as usual.
+//
+// The checkpoint is stored as one or multiple aggregations,
+// named `tlog.CheckpointAggregationName`, which replace the compacted aggregations
+// at the start of the history. Replaying the checkpoint results
+// in the same vdisk content as replaying the compacted aggregations,
+// it is however no longer possible to replay only a part of them.
+//
+// The first metadata key is only updated once the checkpoint is fully stored,
+// and the compacted aggregations are only deleted afterwards,
+// such that the history remains walkable, even if the compaction is interrupted.
+//
+// NOTE: the history can't be compacted while a tlog server serves this vdisk,
+// as that server caches the first and last metadata of the history,
+// and would overwrite the relinked history with it. See `tlog.ActiveTlogServer`.
+func (c *Client) Compact(sequence uint64) (*CompactResult, error) {
+	c.mux.Lock()
+	key := c.firstMetaKey
+	c.mux.Unlock()
+
+	result := new(CompactResult)
+	if len(key) == 0 {
+		return result, nil // we have no data yet
+	}
+
+	// collect all aggregations to compact,
+	// and the sequence of the latest block of each block index
+	var (
+		compacted       []truncatedAggregation
+		latest          = make(map[int64]uint64)
+		onlyCheckpoints = true
+	)
+	for {
+		md, err := c.storClient.GetMeta(key)
+		if err != nil {
+			return nil, err
+		}
+		if len(md.Next) == 0 {
+			break // never compact the last aggregation
+		}
+
+		data, refList, err := c.storClient.Read(key)
+		if err != nil {
+			return nil, err
+		}
+		agg, err := c.decodeCapnp(data)
+		if err != nil {
+			return nil, err
+		}
+		blocks, err := agg.Blocks()
+		if err != nil {
+			return nil, err
+		}
+		size := int(agg.Size())
+		if sequence > 0 && size > 0 && blocks.At(size-1).Sequence() >= sequence {
+			break
+		}
+
+		for i := 0; i < size; i++ {
+			block := blocks.At(i)
+			latest[block.Index()] = block.Sequence()
+		}
+		if !tlog.IsCheckpoint(agg) {
+			onlyCheckpoints = false
+		}
+
+		compacted = append(compacted, truncatedAggregation{
+			key:     key,
+			md:      md,
+			refList: refList,
+		})
+		key = md.Next
+	}
+
+	// nothing to compact, or already compacted
+	if onlyCheckpoints {
+		return result, nil
+	}
+	result.Aggregations = len(compacted)
+
+	// store the checkpoint, as a new chain of aggregations,
+	// using the epoch of the last compacted aggregation
+	cs := &checkpointStorer{
+		client:  c,
+		epoch:   compacted[len(compacted)-1].md.Epoch,
+		newKeys: make(map[string]struct{}),
+	}
+	for _, ca := range compacted {
+		data, _, err := c.storClient.Read(ca.key)
+		if err != nil {
+			return nil, err
+		}
+		agg, err := c.decodeCapnp(data)
+		if err != nil {
+			return nil, err
+		}
+		blocks, err := agg.Blocks()
+		if err != nil {
+			return nil, err
+		}
+
+		for i := 0; i < int(agg.Size()); i++ {
+			block := blocks.At(i)
+			if latest[block.Index()] != block.Sequence() {
+				continue // overwritten by a later block
+			}
+			if err := cs.add(block); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if err := cs.flush(); err != nil {
+		return nil, err
+	}
+	result.CheckpointAggregations = cs.aggregations
+	result.CheckpointBlocks = cs.blocks
+
+	// link the checkpoint to the rest of the history,
+	// and make it the start of the history, prior to deleting anything
+	cs.prevMd.Next = key
+	if err := c.storClient.PutMeta(cs.prevKey, cs.prevMd); err != nil {
+		return nil, err
+	}
+	if err := c.relinkHistory(cs.firstKey, key, cs.prevKey); err != nil {
+		return nil, err
+	}
+
+	// delete the compacted aggregations,
+	// making sure we never delete a checkpoint aggregation with the same content
+	outdated := compacted[:0]
+	for _, ca := range compacted {
+		if _, ok := cs.newKeys[string(ca.key)]; !ok {
+			outdated = append(outdated, ca)
+		}
+	}
+	if _, err := c.deleteAggregations(outdated); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// checkpointStorer stores the blocks of a checkpoint,
+// as a linked chain of checkpoint aggregations.
+type checkpointStorer struct {
+	client *Client
+	epoch  int64
+	blocks int
+
+	buffer       []schema.TlogBlock
+	aggregations int
+
+	firstKey []byte
+	prevKey  []byte
+	prevMd   *meta.Meta
+	newKeys  map[string]struct{}
+}
+
+// add a block to the checkpoint,
+// storing an aggregation if enough blocks are buffered.
+func (cs *checkpointStorer) add(block schema.TlogBlock) error {
+	cs.buffer = append(cs.buffer, block)
+	cs.blocks++
+	if len(cs.buffer) < checkpointAggregationSize {
+		return nil
+	}
+	return cs.flush()
+}
+
+// flush all buffered blocks as a single checkpoint aggregation,
+// an empty aggregation is only stored if the checkpoint is empty.
+func (cs *checkpointStorer) flush() error {
+	if len(cs.buffer) == 0 && cs.firstKey != nil {
+		return nil
+	}
+
+	agg, err := tlog.NewAggregation(nil, len(cs.buffer))
+	if err != nil {
+		return err
+	}
+	if err := agg.SetName(tlog.CheckpointAggregationName); err != nil {
+		return err
+	}
+	for i := range cs.buffer {
+		if err := agg.AddBlock(&cs.buffer[i]); err != nil {
+			return err
+		}
+	}
+	agg.SetTimestamp(cs.epoch)
+	data, err := agg.Encode()
+	if err != nil {
+		return err
+	}
+
+	c := cs.client
+	data, err = c.compressAggregation(data)
+	if err != nil {
+		return err
+	}
+	key := c.hasher.Hash(append([]byte(c.vdiskID), data...))
+	initialMeta := meta.New(key)
+	initialMeta.Epoch = cs.epoch
+
+	md, err := c.storClient.WriteWithMeta(key, data, cs.prevKey, cs.prevMd, initialMeta, c.refList)
+	if err != nil {
+		return err
+	}
+
+	if cs.firstKey == nil {
+		cs.firstKey = key
+	}
+	cs.prevKey, cs.prevMd = key, md
+	cs.newKeys[string(key)] = struct{}{}
+	cs.aggregations++
+	cs.buffer = cs.buffer[:0]
+	return nil
+}
@@ -0,0 +1,162 @@
+package stor
+
+import (
+	"crypto/rand"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/zero-os/0-stor/client/meta/embedserver"
+
+	"github.com/zero-os/0-Disk"
+	"github.com/zero-os/0-Disk/tlog"
+	"github.com/zero-os/0-Disk/tlog/schema"
+	"github.com/zero-os/0-Disk/tlog/stor/embeddedserver"
+)
+
+func TestCompact(t *testing.T) {
+	const (
+		vdiskID      = "12345678"
+		numAggs      = 10
+		aggSize      = 3
+		numIndices   = 4
+		dataShards   = 4
+		parityShards = 2
+	)
+
+	// store checkpoints in multiple aggregations
+	defer func(size int) {
+		checkpointAggregationSize = size
+	}(checkpointAggregationSize)
+	checkpointAggregationSize = 2
+
+	mdServer, err := embedserver.New()
+	require.Nil(t, err)
+	defer mdServer.Stop()
+
+	storCluster, err := embeddedserver.NewZeroStorCluster(dataShards + parityShards)
+	require.Nil(t, err)
+	defer storCluster.Close()
+
+	cli := createTestClient(t, vdiskID, dataShards, parityShards, mdServer.ListenAddr(),
+		storCluster.Addrs())
+
+	// the walked history, applied to an in-memory vdisk
+	replay := func(cli *Client) (vdisk map[int64][]byte, seqs []uint64, checkpoints int) {
+		vdisk = make(map[int64][]byte)
+		for wr := range cli.Walk(0, tlog.TimeNowTimestamp()) {
+			require.NoError(t, wr.Err)
+			if tlog.IsCheckpoint(wr.Agg) {
+				checkpoints++
+			}
+			blocks, err := wr.Agg.Blocks()
+			require.NoError(t, err)
+			for i := 0; i < int(wr.Agg.Size()); i++ {
+				block := blocks.At(i)
+				seqs = append(seqs, block.Sequence())
+				switch block.Operation() {
+				case schema.OpSet:
+					data, err := block.Data()
+					require.NoError(t, err)
+					vdisk[block.Index()] = data
+				case schema.OpDelete:
+					delete(vdisk, block.Index())
+				}
+			}
+		}
+		return
+	}
+
+	// store the data, overwriting (and sometimes deleting) the same blocks
+	var seq uint64
+	for i := 0; i < numAggs; i++ {
+		agg, err := tlog.NewAggregation(nil, aggSize)
+		require.NoError(t, err)
+
+		for j := 0; j < aggSize; j++ {
+			seq++
+			op := uint8(schema.OpSet)
+			var data []byte
+			if seq%5 == 0 {
+				op = schema.OpDelete
+			} else {
+				data = make([]byte, 512)
+				rand.Read(data)
+			}
+			err = agg.AddTransaction(tlog.Transaction{
+				Operation: op,
+				Sequence:  seq,
+				Index:     int64(seq % numIndices),
+				Content:   data,
+				Hash:      zerodisk.HashBytes(data),
+				Timestamp: tlog.TimeNowTimestamp(),
+			})
+			require.NoError(t, err)
+		}
+
+		_, err = cli.ProcessStoreAgg(agg)
+		require.NoError(t, err)
+	}
+
+	expectedVdisk, _, _ := replay(cli)
+
+	// compact all aggregations which only contain sequences before 19
+	result, err := cli.Compact(19)
+	require.NoError(t, err)
+	require.Equal(t, 6, result.Aggregations)
+	require.Equal(t, numIndices, result.CheckpointBlocks)
+	require.Equal(t, 2, result.CheckpointAggregations)
+
+	vdisk, seqs, checkpoints := replay(cli)
+	require.Equal(t, expectedVdisk, vdisk)
+	require.Equal(t, 2, checkpoints)
+	require.Equal(t, []uint64{15, 16, 17, 18, 19, 20, 21, 22, 23, 24, 25, 26, 27, 28, 29, 30}, seqs)
+
+	// compacting again doesn't change anything
+	result, err = cli.Compact(19)
+	require.NoError(t, err)
+	require.Equal(t, 0, result.Aggregations)
+
+	// a new client should still be able to walk the history
+	// and load the last sequence
+	newCli := createTestClient(t, vdiskID, dataShards, parityShards, mdServer.ListenAddr(),
+		storCluster.Addrs())
+	vdisk, _, _ = replay(newCli)
+	require.Equal(t, expectedVdisk, vdisk)
+	lastSeq, err := newCli.LoadLastSequence()
+	require.NoError(t, err)
+	require.Equal(t, seq, lastSeq)
+
+	// compact everything but the last aggregation,
+	// which includes the previous checkpoint
+	result, err = cli.Compact(0)
+	require.NoError(t, err)
+	require.Equal(t, 5, result.Aggregations)
+	require.Equal(t, numIndices, result.CheckpointBlocks)
+
+	vdisk, seqs, checkpoints = replay(cli)
+	require.Equal(t, expectedVdisk, vdisk)
+	require.Equal(t, 2, checkpoints)
+	require.Equal(t, []uint64{24, 25, 26, 27, 28, 29, 30}, seqs)
+
+	// the history can still be appended to
+	agg, err := tlog.NewAggregation(nil, 1)
+	require.NoError(t, err)
+	data := make([]byte, 512)
+	rand.Read(data)
+	err = agg.AddTransaction(tlog.Transaction{
+		Operation: schema.OpSet,
+		Sequence:  seq + 1,
+		Index:     0,
+		Content:   data,
+		Hash:      zerodisk.HashBytes(data),
+		Timestamp: tlog.TimeNowTimestamp(),
+	})
+	require.NoError(t, err)
+	_, err = cli.ProcessStoreAgg(agg)
+	require.NoError(t, err)
+	expectedVdisk[0] = data
+
+	vdisk, seqs, _ = replay(cli)
+	require.Equal(t, expectedVdisk, vdisk)
+	require.Equal(t, []uint64{24, 25, 26, 27, 28, 29, 30, 31}, seqs)
+}
@@ -0,0 +1,75 @@
+package stor
+
+import (
+	"bytes"
+
+	"github.com/zero-os/0-Disk/compress"
+	"github.com/zero-os/0-Disk/errors"
+)
+
+// compressedAggregationMagic prefixes each compressed aggregation,
+// and is followed by a single byte defining its compression type.
+// The first 4 bytes of an uncompressed (capnp) aggregation
+// define the amount of segments of that message minus one,
+// which is never this big, hence the two formats can't be mistaken.
+var compressedAggregationMagic = []byte("tlgz")
+
+// newCompressor creates the compressor for the given compression type,
+// nil is returned in case no compression is required.
+func newCompressor(compression string) (compress.Compressor, compress.CompressionType, error) {
+	if compression == "" {
+		return nil, 0, nil
+	}
+
+	var ct compress.CompressionType
+	err := ct.Set(compression)
+	if err != nil {
+		return nil, 0, errors.Wrapf(err, "invalid tlog compression %q", compression)
+	}
+	compressor, err := compress.NewCompressor(ct)
+	if err != nil {
+		return nil, 0, err
+	}
+	return compressor, ct, nil
+}
+
+// compressAggregation compresses an encoded aggregation,
+// prefixed by a header which defines the compression type used.
+// The data is returned as is, in case no compression is configured.
+func (c *Client) compressAggregation(data []byte) ([]byte, error) {
+	if c.compressor == nil {
+		return data, nil
+	}
+
+	buf := bytes.NewBuffer(make([]byte, 0, len(data)/2))
+	buf.Write(compressedAggregationMagic)
+	buf.WriteByte(byte(c.compression))
+	err := c.compressor.Compress(bytes.NewReader(data), buf)
+	if err != nil {
+		return nil, errors.Wrapf(err, "couldn't compress aggregation of vdisk %s", c.vdiskID)
+	}
+	return buf.Bytes(), nil
+}
+
+// decompressAggregation decompresses a stored aggregation,
+// using the compression type defined in its header.
+// Uncompressed aggregations are returned as is.
+func decompressAggregation(data []byte) ([]byte, error) {
+	headerLength := len(compressedAggregationMagic) + 1
+	if len(data) < headerLength || !bytes.HasPrefix(data, compressedAggregationMagic) {
+		return data, nil
+	}
+
+	ct := compress.CompressionType(data[headerLength-1])
+	decompressor, err := compress.NewDecompressor(ct)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid compression type %d of aggregation", ct)
+	}
+
+	var buf bytes.Buffer
+	err = decompressor.Decompress(bytes.NewReader(data[headerLength:]), &buf)
+	if err != nil {
+		return nil, errors.Wrap(err, "couldn't decompress aggregation")
+	}
+	return buf.Bytes(), nil
+}
@@ -47,5 +47,6 @@ func ConfigFromConfigSource(source config.Source, vdiskID, privKey string) (conf
 		DataShardsNum:   zsc.DataShards,
 		ParityShardsNum: zsc.ParityShards,
 		EncryptPrivKey:  privKey,
+		Compression:     vdiskConf.Compression,
 	}, nil
 }
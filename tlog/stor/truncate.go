@@ -0,0 +1,190 @@
+package stor
+
+import (
+	"bytes"
+
+	stormeta "github.com/zero-os/0-stor/client/meta"
+)
+
+// RetentionPolicy defines which tlog aggregations can be truncated.
+type RetentionPolicy struct {
+	// aggregations with an epoch before this epoch can be deleted,
+	// 0 means that aggregations are never deleted because of their age
+	CutoffEpoch int64
+	// maximum amount of aggregations to keep,
+	// 0 means that aggregations are never deleted because of their count
+	MaxAggregations int64
+	// only aggregations of which all blocks have a sequence
+	// lower than or equal to this sequence can be deleted,
+	// as the aggregations after it might still be needed to recover the vdisk
+	FlushedSequence uint64
+}
+
+// truncatedAggregation is an aggregation which is about to be deleted
+type truncatedAggregation struct {
+	key     []byte
+	md      *stormeta.Meta
+	refList []string
+}
+
+// Truncate deletes the oldest tlog aggregations of this vdisk,
+// as long as they are outdated according to the given retention policy.
+// The last aggregation is always kept, such that the history never becomes empty.
+// The first metadata key is advanced before any data gets deleted,
+// such that the history remains walkable, even if the truncation is interrupted.
+// The amount of deleted aggregations is returned.
+func (c *Client) Truncate(policy RetentionPolicy) (int, error) {
+	if policy.CutoffEpoch <= 0 && policy.MaxAggregations <= 0 {
+		return 0, nil // nothing can be outdated
+	}
+
+	c.mux.Lock()
+	key := c.firstMetaKey
+	c.mux.Unlock()
+
+	if len(key) == 0 {
+		return 0, nil // we have no data yet
+	}
+
+	var count int64
+	if policy.MaxAggregations > 0 {
+		var err error
+		count, err = c.countAggregations(key)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	// collect all outdated aggregations,
+	// starting from the first (and thus oldest) aggregation
+	var outdated []truncatedAggregation
+	for {
+		md, err := c.storClient.GetMeta(key)
+		if err != nil {
+			return 0, err
+		}
+		if len(md.Next) == 0 {
+			break // never delete the last aggregation
+		}
+
+		tooOld := policy.CutoffEpoch > 0 && md.Epoch < policy.CutoffEpoch
+		tooMany := policy.MaxAggregations > 0 && count-int64(len(outdated)) > policy.MaxAggregations
+		if !tooOld && !tooMany {
+			break
+		}
+
+		data, refList, err := c.storClient.Read(key)
+		if err != nil {
+			return 0, err
+		}
+		lastSequence, err := c.aggregationLastSequence(data)
+		if err != nil {
+			return 0, err
+		}
+		if lastSequence > policy.FlushedSequence {
+			break // not yet flushed to the vdisk's storage
+		}
+
+		outdated = append(outdated, truncatedAggregation{
+			key:     key,
+			md:      md,
+			refList: refList,
+		})
+		key = md.Next
+	}
+
+	if len(outdated) == 0 {
+		return 0, nil
+	}
+
+	// advance the start of the history, prior to deleting anything
+	if err := c.relinkHistory(key, key, nil); err != nil {
+		return 0, err
+	}
+
+	return c.deleteAggregations(outdated)
+}
+
+// relinkHistory makes firstKey the first meta key of this vdisk,
+// and links the aggregation of the given key to the given previous key,
+// unlinking it from the aggregations which came before it.
+func (c *Client) relinkHistory(firstKey, key, prevKey []byte) error {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	// the metadata is fetched again while locked,
+	// as new aggregations might have been linked to it in the meantime
+	md, err := c.storClient.GetMeta(key)
+	if err != nil {
+		return err
+	}
+	md.Previous = prevKey
+	if err := c.storClient.PutMeta(key, md); err != nil {
+		return err
+	}
+	if c.lastMd != nil && bytes.Equal(c.lastMetaKey, key) {
+		c.lastMd.Previous = prevKey
+	}
+
+	c.firstMetaKey = firstKey
+	if err := c.saveFirstMetaKey(); err != nil {
+		return err
+	}
+
+	// the stored last meta key might be one of the deleted aggregations,
+	// so we store the actual last meta key, which is never deleted
+	if len(c.lastMetaKey) == 0 {
+		return nil
+	}
+	return c.saveLastMetaKey()
+}
+
+// deleteAggregations deletes the (meta)data of all given aggregations,
+// returning the amount of deleted aggregations.
+func (c *Client) deleteAggregations(aggs []truncatedAggregation) (int, error) {
+	storMetaCli, err := stormeta.NewClient(c.metaShards)
+	if err != nil {
+		return 0, err
+	}
+	defer storMetaCli.Close()
+
+	for i, agg := range aggs {
+		if err := c.deleteData(storMetaCli, agg.key, agg.md, agg.refList); err != nil {
+			return i, err
+		}
+	}
+	return len(aggs), nil
+}
+
+// countAggregations counts all aggregations, starting from the given key.
+func (c *Client) countAggregations(key []byte) (int64, error) {
+	var count int64
+	for len(key) > 0 {
+		md, err := c.storClient.GetMeta(key)
+		if err != nil {
+			return 0, err
+		}
+		count++
+		key = md.Next
+	}
+	return count, nil
+}
+
+// aggregationLastSequence returns the sequence
+// of the last block of an encoded aggregation.
+func (c *Client) aggregationLastSequence(data []byte) (uint64, error) {
+	agg, err := c.decodeCapnp(data)
+	if err != nil {
+		return 0, err
+	}
+	blocks, err := agg.Blocks()
+	if err != nil {
+		return 0, err
+	}
+	// the block list can be bigger than the actual amount of blocks
+	size := int(agg.Size())
+	if size == 0 || size > blocks.Len() {
+		return 0, nil
+	}
+	return blocks.At(size - 1).Sequence(), nil
+}
@@ -0,0 +1,113 @@
+package stor
+
+import (
+	"crypto/rand"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/zero-os/0-stor/client/meta/embedserver"
+
+	"github.com/zero-os/0-Disk/tlog"
+	"github.com/zero-os/0-Disk/tlog/stor/embeddedserver"
+)
+
+func TestTruncate(t *testing.T) {
+	const (
+		vdiskID      = "12345678"
+		numData      = 10
+		dataShards   = 4
+		parityShards = 2
+	)
+
+	mdServer, err := embedserver.New()
+	require.Nil(t, err)
+	defer mdServer.Stop()
+
+	storCluster, err := embeddedserver.NewZeroStorCluster(dataShards + parityShards)
+	require.Nil(t, err)
+	defer storCluster.Close()
+
+	cli := createTestClient(t, vdiskID, dataShards, parityShards, mdServer.ListenAddr(),
+		storCluster.Addrs())
+
+	// store the data, one block (and sequence) per aggregation
+	for i := 0; i < numData; i++ {
+		val := make([]byte, 1024)
+		rand.Read(val)
+
+		block := encodeBlock(t, val)
+		block.SetSequence(uint64(i + 1))
+
+		agg, err := tlog.NewAggregation(nil, 1)
+		require.NoError(t, err)
+
+		err = agg.AddBlock(block)
+		require.NoError(t, err)
+
+		_, err = cli.ProcessStoreAgg(agg)
+		require.NoError(t, err)
+	}
+
+	walkSequences := func(cli *Client) (seqs []uint64, keys [][]byte) {
+		for wr := range cli.Walk(0, tlog.TimeNowTimestamp()) {
+			require.NoError(t, wr.Err)
+			blocks, err := wr.Agg.Blocks()
+			require.NoError(t, err)
+			require.Equal(t, 1, blocks.Len())
+			seqs = append(seqs, blocks.At(0).Sequence())
+			keys = append(keys, wr.StorKey)
+		}
+		return
+	}
+	_, allKeys := walkSequences(cli)
+	require.Len(t, allKeys, numData)
+
+	// no retention policy, nothing can be deleted
+	n, err := cli.Truncate(RetentionPolicy{FlushedSequence: numData})
+	require.NoError(t, err)
+	require.Equal(t, 0, n)
+
+	// only aggregations which are flushed can be deleted
+	n, err = cli.Truncate(RetentionPolicy{MaxAggregations: 4, FlushedSequence: 3})
+	require.NoError(t, err)
+	require.Equal(t, 3, n)
+	seqs, _ := walkSequences(cli)
+	require.Equal(t, []uint64{4, 5, 6, 7, 8, 9, 10}, seqs)
+
+	n, err = cli.Truncate(RetentionPolicy{MaxAggregations: 4, FlushedSequence: numData})
+	require.NoError(t, err)
+	require.Equal(t, 3, n)
+	seqs, keys := walkSequences(cli)
+	require.Equal(t, []uint64{7, 8, 9, 10}, seqs)
+
+	// the new first aggregation is no longer linked to the deleted ones
+	md, err := cli.storClient.GetMeta(keys[0])
+	require.NoError(t, err)
+	require.Empty(t, md.Previous)
+
+	// deleted data should be gone
+	for _, key := range allKeys[:6] {
+		_, _, err = cli.storClient.Read(key)
+		require.Error(t, err)
+	}
+
+	// a new client should still be able to walk the history
+	// and load the last sequence
+	newCli := createTestClient(t, vdiskID, dataShards, parityShards, mdServer.ListenAddr(),
+		storCluster.Addrs())
+	seqs, _ = walkSequences(newCli)
+	require.Equal(t, []uint64{7, 8, 9, 10}, seqs)
+	lastSeq, err := newCli.LoadLastSequence()
+	require.NoError(t, err)
+	require.Equal(t, uint64(numData), lastSeq)
+
+	// all aggregations are too old, but the last one is always kept
+	n, err = cli.Truncate(RetentionPolicy{
+		CutoffEpoch:     tlog.TimeNowTimestamp(),
+		FlushedSequence: numData,
+	})
+	require.NoError(t, err)
+	require.Equal(t, 3, n)
+	seqs, _ = walkSequences(cli)
+	require.Equal(t, []uint64{10}, seqs)
+}
@@ -16,7 +16,8 @@ type WalkResult struct {
 	// 0-stor metadata
 	Meta *meta.Meta
 
-	// Raw data
+	// Raw data, as stored in 0-stor,
+	// which is compressed in case compression was configured
 	Data []byte
 
 	// Reference list
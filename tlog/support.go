@@ -1,6 +1,9 @@
 package tlog
 
 import (
+	"net"
+	"time"
+
 	"github.com/zero-os/0-Disk/config"
 	"github.com/zero-os/0-Disk/errors"
 )
@@ -25,3 +28,39 @@ func HasTlogCluster(confSource config.Source, vdiskID string) (bool, error) {
 
 	return nbdConf.TlogServerClusterID != "", nil
 }
+
+// ActiveTlogServer returns the address of the first tlog server
+// configured for the given vdiskID, which accepts connections,
+// or an empty string in case none of those tlog servers are active.
+// An active tlog server caches the start and end of the tlog history of the vdisk,
+// and thus that history can't be modified by another process in the meantime.
+func ActiveTlogServer(confSource config.Source, vdiskID string) (string, error) {
+	nbdConf, err := config.ReadVdiskNBDConfig(confSource, vdiskID)
+	if err != nil {
+		return "", errors.Wrapf(err,
+			"couldn't read vdisk %s's NBD vdisk config", vdiskID)
+	}
+	if nbdConf.TlogServerClusterID == "" {
+		return "", nil
+	}
+
+	clusterConf, err := config.ReadTlogClusterConfig(confSource, nbdConf.TlogServerClusterID)
+	if err != nil {
+		return "", errors.Wrapf(err,
+			"couldn't read vdisk %s's tlog cluster config", vdiskID)
+	}
+
+	for _, addr := range clusterConf.Servers {
+		conn, err := net.DialTimeout("tcp", addr, activeTlogServerDialTimeout)
+		if err != nil {
+			continue // tlog server isn't active
+		}
+		conn.Close()
+		return addr, nil
+	}
+	return "", nil
+}
+
+// activeTlogServerDialTimeout is the maximum time waited
+// for a tlog server to accept a connection.
+const activeTlogServerDialTimeout = 2 * time.Second
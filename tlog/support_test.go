@@ -0,0 +1,51 @@
+package tlog
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zero-os/0-Disk/config"
+)
+
+func TestActiveTlogServer(t *testing.T) {
+	const vdiskID = "a"
+
+	source := config.NewStubSource()
+	defer source.Close()
+
+	// no tlog server is configured
+	source.SetVdiskConfig(vdiskID, &config.VdiskStaticConfig{
+		BlockSize: 4096,
+		Size:      1,
+		Type:      config.VdiskTypeBoot,
+	})
+	source.SetPrimaryStorageCluster(vdiskID, "primary", &config.StorageClusterConfig{
+		Servers: []config.StorageServerConfig{
+			config.StorageServerConfig{Address: "localhost:1"},
+		},
+	})
+	addr, err := ActiveTlogServer(source, vdiskID)
+	require.NoError(t, err)
+	assert.Empty(t, addr)
+
+	// none of the configured tlog servers is active
+	source.SetTlogServerCluster(vdiskID, "tlog", &config.TlogClusterConfig{
+		Servers: []string{"localhost:1"},
+	})
+	addr, err = ActiveTlogServer(source, vdiskID)
+	require.NoError(t, err)
+	assert.Empty(t, addr)
+
+	// one of the configured tlog servers is active
+	listener, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err)
+	defer listener.Close()
+	source.SetTlogServerCluster(vdiskID, "tlog", &config.TlogClusterConfig{
+		Servers: []string{"localhost:1", listener.Addr().String()},
+	})
+	addr, err = ActiveTlogServer(source, vdiskID)
+	require.NoError(t, err)
+	assert.Equal(t, listener.Addr().String(), addr)
+}
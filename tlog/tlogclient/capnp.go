@@ -27,6 +27,11 @@ func (c *Client) encodeHandshakeCapnp() error {
 		return errors.Wrap(err, "couldn't set handshake vdiskID")
 	}
 
+	err = handshake.SetToken(c.authToken)
+	if err != nil {
+		return errors.Wrap(err, "couldn't set handshake token")
+	}
+
 	return capnp.NewEncoder(c.bw).Encode(msg)
 }
 
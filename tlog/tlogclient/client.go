@@ -3,11 +3,13 @@ package tlogclient
 import (
 	"bufio"
 	"context"
+	"crypto/tls"
 	"io"
 	"net"
 	"sync"
 	"time"
 
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/zero-os/0-Disk/errors"
 	"github.com/zero-os/0-Disk/log"
 	"github.com/zero-os/0-Disk/tlog"
@@ -18,6 +20,7 @@ const (
 	readTimeout          = 2 * time.Second
 	resendTimeoutDur     = 2 * time.Second // duration to wait before re-send the tlog.
 	failedFlushSleepTime = 10 * time.Second
+	tlsHandshakeTimeout  = 5 * time.Second
 )
 
 var (
@@ -47,17 +50,33 @@ type Result struct {
 	Err  error
 }
 
+// Config defines the optional configuration of a Tlog Client.
+type Config struct {
+	// authentication token of the vdisk,
+	// required in case the tlog config of the vdisk defines one
+	AuthToken string
+	// TLS config used to connect to the tlog servers using (mutual) TLS,
+	// plain TCP connections are used when nil
+	TLSConfig *tls.Config
+}
+
 // Client defines a Tlog Client.
 // This client is not thread/goroutine safe.
 type Client struct {
 	servers         []string
 	vdiskID         string
-	conn            *net.TCPConn
+	authToken       string
+	tlsConfig       *tls.Config
+	conn            net.Conn
+	tcpConn         *net.TCPConn // underlying TCP connection of conn
 	bw              writerFlusher
 	rd              io.Reader // reader of this client
 	blockBuffer     *blockbuffer.Buffer
 	capnpSegmentBuf []byte
 
+	// amount of blocks resent by this client
+	resends prometheus.Counter
+
 	commandCh      chan command
 	retryCommandCh chan command
 	respCh         chan *Result
@@ -78,21 +97,29 @@ type Client struct {
 // New creates a new tlog client for a vdisk with 'addrs' is the tlogserver addresses.
 // Client is going to use first address and then move to next addresses if the first address
 // is failed.
+// The optional config defines the authentication token and TLS config to use,
+// when nil no token is sent and plain TCP connections are used.
 // The client is not goroutine safe.
-func New(servers []string, vdiskID string) (client *Client, err error) {
-	client, err = newClient(servers, vdiskID)
+func New(servers []string, vdiskID string, cfg *Config) (client *Client, err error) {
+	client, err = newClient(servers, vdiskID, cfg)
 	if err != nil {
 		return
 	}
 	go client.run(client.ctx)
 	return
 }
-func newClient(servers []string, vdiskID string) (*Client, error) {
+func newClient(servers []string, vdiskID string, cfg *Config) (*Client, error) {
+	if cfg == nil {
+		cfg = new(Config)
+	}
 	ctx, cancelFunc := context.WithCancel(context.Background())
 	client := &Client{
 		servers:           servers,
 		vdiskID:           vdiskID,
+		authToken:         cfg.AuthToken,
+		tlsConfig:         cfg.TLSConfig,
 		blockBuffer:       blockbuffer.NewBuffer(resendTimeoutDur),
+		resends:           resends.WithLabelValues(vdiskID),
 		ctx:               ctx,
 		cancelFunc:        cancelFunc,
 		waitSlaveSyncCond: sync.NewCond(&sync.Mutex{}),
@@ -165,7 +192,7 @@ func (c *Client) runSender(ctx context.Context, cancelFunc context.CancelFunc) <
 	doneCh := make(chan struct{})
 	go func() {
 		defer func() {
-			c.conn.CloseRead()
+			c.tcpConn.CloseRead()
 			cancelFunc()
 			doneCh <- struct{}{}
 		}()
@@ -202,7 +229,7 @@ func (c *Client) runReceiver(ctx context.Context, cancelFunc context.CancelFunc)
 	doneCh := make(chan struct{})
 	go func() {
 		defer func() {
-			c.conn.CloseWrite()
+			c.tcpConn.CloseWrite()
 			cancelFunc()
 			doneCh <- struct{}{}
 		}()
@@ -362,7 +389,7 @@ func (c *Client) reconnect(closedTime time.Time) error {
 // connect to server
 func (c *Client) connect() error {
 	if c.conn != nil {
-		c.conn.CloseRead() // interrupt the receiver
+		c.tcpConn.CloseRead() // interrupt the receiver
 	}
 
 	if c.getCurServerFailedFlushStatus() {
@@ -415,6 +442,7 @@ func (c *Client) resender() {
 				continue
 			}
 
+			c.resends.Inc()
 			err = c.Send(block.Operation(), seq, block.Index(), block.Timestamp(), data)
 			if err != nil {
 				log.Errorf("client resender failed to send data:%v", err)
@@ -487,15 +515,29 @@ func (c *Client) recvOne() (*Response, error) {
 }
 
 func (c *Client) createConn() error {
-	genericConn, err := net.DialTimeout("tcp", c.curServerAddress(), time.Second)
+	address := c.curServerAddress()
+	genericConn, err := net.DialTimeout("tcp", address, time.Second)
 	if err != nil {
 		return err
 	}
 
-	conn := genericConn.(*net.TCPConn)
+	tcpConn := genericConn.(*net.TCPConn)
+	tcpConn.SetKeepAlive(true)
+
+	var conn net.Conn = tcpConn
+	if c.tlsConfig != nil {
+		tlsConn := tls.Client(tcpConn, tlog.ClientTLSConfig(c.tlsConfig, address))
+		tlsConn.SetDeadline(time.Now().Add(tlsHandshakeTimeout))
+		if err := tlsConn.Handshake(); err != nil {
+			tcpConn.Close()
+			return errors.Wrap(err, "TLS handshake failed")
+		}
+		tlsConn.SetDeadline(time.Time{})
+		conn = tlsConn
+	}
 
-	conn.SetKeepAlive(true)
 	c.conn = conn
+	c.tcpConn = tcpConn
 	c.bw = bufio.NewWriter(conn)
 	c.rd = conn
 	return nil
@@ -584,7 +626,7 @@ func (c *Client) Close() error {
 	c.cancelFunc()
 
 	if c.conn != nil {
-		c.conn.CloseRead() // interrupt the receiver
+		c.tcpConn.CloseRead() // interrupt the receiver
 		return c.conn.Close()
 	}
 	return nil
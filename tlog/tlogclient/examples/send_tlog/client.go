@@ -29,7 +29,7 @@ func main() {
 		dataLen       = 4096
 	)
 
-	client, err := client.New([]string{"127.0.0.1:11211"}, vdiskID)
+	client, err := client.New([]string{"127.0.0.1:11211"}, vdiskID, nil)
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -0,0 +1,19 @@
+package tlogclient
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/zero-os/0-Disk/metrics"
+)
+
+var (
+	resends = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metrics.Namespace,
+		Subsystem: "tlogclient",
+		Name:      "resends_total",
+		Help:      "Amount of blocks resent to the tlog server of a vdisk, because they weren't flushed in time.",
+	}, []string{"vdisk"})
+)
+
+func init() {
+	prometheus.MustRegister(resends)
+}
@@ -165,7 +165,7 @@ func testTwoServers(t *testing.T, ttConf testTwoServerConf) {
 		tlogAddrs = tlogAddrs[0:1]
 	}
 
-	client, err := New(tlogAddrs, vdiskID)
+	client, err := New(tlogAddrs, vdiskID, nil)
 	require.Nil(t, err)
 
 	respChan := client.Recv()
@@ -93,15 +93,19 @@ func (p *Player) Replay(lmt decoder.Limiter) (uint64, error) {
 // It returns last sequence number it replayed.
 func (p *Player) ReplayWithCallback(lmt decoder.Limiter, onReplayCb OnReplayCb) (uint64, error) {
 	var lastSeq uint64
-	var err error
 
 	for wr := range p.storCli.Walk(lmt.FromEpoch(), lmt.ToEpoch()) {
 		if wr.Err != nil {
 			return lastSeq, wr.Err
 		}
 
-		if lastSeq, err = p.ReplayAggregationWithCallback(wr.Agg, lmt, onReplayCb); err != nil {
-			return lastSeq, err
+		seq, err := p.ReplayAggregationWithCallback(wr.Agg, lmt, onReplayCb)
+		if err != nil {
+			return seq, err
+		}
+		// aggregations outside of the limits don't replay any block
+		if seq > 0 {
+			lastSeq = seq
 		}
 	}
 	return lastSeq, p.blockStorage.Flush()
@@ -166,7 +166,7 @@ func TestResendTimeout(t *testing.T) {
 	ds := newDummyServer(unusedServer)
 	go ds.run(t, logsToIgnore)
 
-	client, err := newClient([]string{unusedServer.ListenAddr()}, vdisk)
+	client, err := newClient([]string{unusedServer.ListenAddr()}, vdisk, nil)
 	assert.Nil(t, err)
 	defer client.Close()
 
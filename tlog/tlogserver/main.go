@@ -11,6 +11,8 @@ import (
 	"github.com/zero-os/0-Disk"
 	"github.com/zero-os/0-Disk/config"
 	"github.com/zero-os/0-Disk/log"
+	"github.com/zero-os/0-Disk/metrics"
+	"github.com/zero-os/0-Disk/tlog"
 	"github.com/zero-os/0-Disk/tlog/tlogserver/server"
 )
 
@@ -20,11 +22,13 @@ func main() {
 	var version bool
 	var verbose bool
 	var profileAddr string
+	var metricsAddr string
 	var storageAddresses string
 	//var withSlaveSync bool
 	var logPath string
 	var sourceConfig config.SourceConfig
 	var serverID string
+	var tlsConfig tlog.TLSConfig
 
 	flag.StringVar(&conf.ListenAddr, "address", conf.ListenAddr, "Address to listen on")
 	flag.IntVar(&conf.FlushSize, "flush-size", conf.FlushSize, "flush size")
@@ -33,13 +37,19 @@ func main() {
 	flag.StringVar(&conf.WaitListenAddr, "wait-listen-addr", conf.WaitListenAddr, "wait listen addr")
 	flag.StringVar(&conf.WaitConnectAddr, "wait-connect-addr", conf.WaitConnectAddr, "wait connect addr")
 	flag.StringVar(&conf.PrivKey, "priv-key", conf.PrivKey, "private key")
+	flag.DurationVar(&conf.RetentionInterval, "retention-interval", conf.RetentionInterval, "interval at which tlog retention policies are applied (0 disables it)")
 	flag.StringVar(&profileAddr, "profile-address", "", "Enables profiling of this server as an http service")
+	flag.StringVar(&metricsAddr, "metrics-address", "", "Enables Prometheus metrics of this server as an http service, served on the /metrics path")
 	flag.Var(&sourceConfig, "config", "config resource: dialstrings (etcd cluster) or path (yaml file)")
 	//flag.BoolVar(&withSlaveSync, "with-slave-sync", false, "sync to ardb slave")
 	flag.BoolVar(&verbose, "v", false, "log verbose (debug) statements")
 	flag.StringVar(&logPath, "logfile", "", "optionally log to the specified file, instead of the stderr")
 	flag.StringVar(&serverID, "id", "default", "The server ID (default: default)")
 	flag.StringVar(&conf.AcceptAddr, "accept-address", "", "Address from which the tlog server can accept connection from")
+	flag.StringVar(&tlsConfig.CertFile, "tls-cert", "", "PEM-encoded file containing the TLS cert (mutual TLS is enabled when given)")
+	flag.StringVar(&tlsConfig.KeyFile, "tls-key", "", "PEM-encoded file containing the private TLS key")
+	flag.StringVar(&tlsConfig.CAFile, "tls-ca", "", "PEM-encoded file containing the TLS CA Pool, used to verify the certs of clients and the wait-connect server")
+	flag.StringVar(&tlsConfig.ServerName, "tls-server", "", "name used to verify the cert of the wait-connect server (defaults to its host)")
 	flag.BoolVar(&version, "version", false, "prints build version and exits")
 
 	flag.Parse()
@@ -66,20 +76,31 @@ func main() {
 
 	zerodisk.LogVersion()
 
-	log.Debugf("flags parsed: address=%q flush-size=%d flush-time=%d block-size=%d priv-key=%q profile-address=%q config=%q storage-addresses=%q logfile=%q id=%q accept-address=%q",
+	log.Debugf("flags parsed: address=%q flush-size=%d flush-time=%d block-size=%d priv-key=%q retention-interval=%v profile-address=%q metrics-address=%q config=%q storage-addresses=%q logfile=%q id=%q accept-address=%q tls-cert=%q tls-key=%q tls-ca=%q tls-server=%q",
 		conf.ListenAddr,
 		conf.FlushSize,
 		conf.FlushTime,
 		conf.BlockSize,
 		conf.PrivKey,
+		conf.RetentionInterval,
 		profileAddr,
+		metricsAddr,
 		sourceConfig.String(),
 		storageAddresses,
 		logPath,
 		serverID,
 		conf.AcceptAddr,
+		tlsConfig.CertFile,
+		tlsConfig.KeyFile,
+		tlsConfig.CAFile,
+		tlsConfig.ServerName,
 	)
 
+	// mutual TLS is optional
+	if tlsConfig.CertFile != "" || tlsConfig.KeyFile != "" || tlsConfig.CAFile != "" {
+		conf.TLS = &tlsConfig
+	}
+
 	// let's create the source and defer close it
 	configSource, err := config.NewSource(sourceConfig)
 	if err != nil {
@@ -102,6 +123,16 @@ func main() {
 		}()
 	}
 
+	// metrics
+	if metricsAddr != "" {
+		go func() {
+			log.Infof("metrics enabled on %v", metricsAddr)
+			if err := metrics.ListenAndServe(metricsAddr); err != nil {
+				log.Infof("Failed to enable metrics on %v, err:%v", metricsAddr, err)
+			}
+		}()
+	}
+
 	ctx, cancelFunc := context.WithCancel(context.Background())
 	defer cancelFunc()
 
@@ -1,6 +1,8 @@
 package server
 
 import (
+	"time"
+
 	"github.com/zero-os/0-Disk/tlog"
 	"github.com/zero-os/0-Disk/tlog/flusher"
 )
@@ -19,6 +21,8 @@ func DefaultConfig() *Config {
 		FlushTime:  25,
 		BlockSize:  4096,
 		PrivKey:    "12345678901234567890123456789012",
+
+		RetentionInterval: 10 * time.Minute,
 	}
 }
 
@@ -33,12 +37,22 @@ type Config struct {
 	SlaveSyncerMgr  tlog.SlaveSyncerManager
 	WaitListenAddr  string
 	WaitConnectAddr string
+
+	// optional mutual TLS config, used to secure both the tlog and wait listeners,
+	// as well as the connection to the tlog server defined by the WaitConnectAddr,
+	// TLS is disabled when nil
+	TLS *tlog.TLSConfig
+
+	// interval at which the tlog retention policy of a vdisk is applied,
+	// 0 disables the truncation of tlog aggregations
+	RetentionInterval time.Duration
 }
 
 // flusherConfig is used by the server to create a flusher
 // for a specific vdisk.
 type flusherConfig struct {
-	FlushSize int
-	FlushTime int
-	PrivKey   string
+	FlushSize         int
+	FlushTime         int
+	PrivKey           string
+	RetentionInterval time.Duration
 }
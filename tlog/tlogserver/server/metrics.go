@@ -0,0 +1,20 @@
+package server
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/zero-os/0-Disk/metrics"
+)
+
+var (
+	flushDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metrics.Namespace,
+		Subsystem: "tlogserver",
+		Name:      "flush_duration_seconds",
+		Help:      "Latency of flushing a tlog aggregation of a vdisk into 0-stor.",
+		Buckets:   metrics.LatencyBuckets,
+	}, []string{"vdisk"})
+)
+
+func init() {
+	prometheus.MustRegister(flushDuration)
+}
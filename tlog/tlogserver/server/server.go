@@ -3,6 +3,7 @@ package server
 import (
 	"bufio"
 	"context"
+	"crypto/tls"
 	"io"
 	"net"
 	"time"
@@ -25,8 +26,10 @@ type Server struct {
 	listener             net.Listener
 	coordListener        net.Listener
 	waitConnectAddr      string
+	waitTLSConfig        *tls.Config // used to connect to the waitConnectAddr
 	flusherConf          *flusherConfig
 	vdiskMgr             *vdiskManager
+	configSource         config.Source
 	ctx                  context.Context
 }
 
@@ -39,8 +42,20 @@ func NewServer(conf *Config, configSource config.Source) (*Server, error) {
 	var (
 		err                     error
 		coordListener, listener net.Listener
+		serverTLS, clientTLS    *tls.Config
 	)
 
+	if conf.TLS != nil {
+		serverTLS, err = conf.TLS.ServerConfig()
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid tlogserver TLS config")
+		}
+		clientTLS, err = conf.TLS.ClientConfig()
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid tlogserver TLS config")
+		}
+	}
+
 	// tlog main listen addr
 	if conf.ListenAddr != "" {
 		// listen for tcp requests on given address
@@ -66,15 +81,26 @@ func NewServer(conf *Config, configSource config.Source) (*Server, error) {
 		}
 		coordListener, err = net.Listen("tcp", conf.WaitListenAddr)
 		if err != nil {
+			listener.Close()
 			return nil, err
 		}
 	}
 
+	// only accept mutual TLS connections, when enabled
+	if serverTLS != nil {
+		listener = tls.NewListener(listener, serverTLS)
+		if coordListener != nil {
+			coordListener = tls.NewListener(coordListener, serverTLS)
+		}
+	}
+
 	// used to created a flusher on rumtime
 	flusherConf := &flusherConfig{
 		FlushSize: conf.FlushSize,
 		FlushTime: conf.FlushTime,
 		PrivKey:   conf.PrivKey,
+
+		RetentionInterval: conf.RetentionInterval,
 	}
 
 	vdiskManager := newVdiskManager(conf.SlaveSyncerMgr, conf.FlushSize, configSource)
@@ -83,9 +109,11 @@ func NewServer(conf *Config, configSource config.Source) (*Server, error) {
 		acceptAddr:           conf.AcceptAddr,
 		coordListener:        coordListener,
 		waitConnectAddr:      conf.WaitConnectAddr,
+		waitTLSConfig:        clientTLS,
 		flusherConf:          flusherConf,
 		maxRespSegmentBufLen: schema.RawTlogRespLen(conf.FlushSize),
 		vdiskMgr:             vdiskManager,
+		configSource:         configSource,
 	}, nil
 }
 
@@ -124,14 +152,8 @@ func (s *Server) Listen(ctx context.Context) {
 			}
 			log.Infof("connection accepted from %s", remoteAddr)
 
-			tcpConn, ok := conn.(*net.TCPConn)
-			if !ok {
-				log.Info("received conn is not tcp conn")
-				conn.Close()
-				continue
-			}
 			go func() {
-				err := s.handle(tcpConn)
+				err := s.handle(conn)
 				if err == nil {
 					log.Infof("connection from %s dropped", remoteAddr)
 				} else {
@@ -153,7 +175,7 @@ func (s *Server) WaitListenAddr() string {
 }
 
 // handshake stage, required prior to receiving blocks
-func (s *Server) handshake(r io.Reader, w io.Writer, conn *net.TCPConn) (vd *vdisk, err error) {
+func (s *Server) handshake(r io.Reader, w io.Writer, conn net.Conn) (vd *vdisk, err error) {
 	status := tlog.HandshakeStatusInternalServerError
 	var lastSeq uint64
 	var vdiskReady bool
@@ -196,8 +218,22 @@ func (s *Server) handshake(r io.Reader, w io.Writer, conn *net.TCPConn) (vd *vdi
 		return // error return
 	}
 
+	// validate the authentication token of the vdisk,
+	// prior to creating that vdisk
+	expectedToken, err := tlog.ReadAuthToken(s.configSource, vdiskID)
+	if err != nil {
+		status = tlog.HandshakeStatusInternalServerError
+		return // error return
+	}
+	token, err := req.Token()
+	if err != nil || !tlog.ValidAuthToken(expectedToken, token) {
+		status = tlog.HandshakeStatusInvalidToken
+		err = errors.Newf("invalid authentication token for vdisk %s", vdiskID)
+		return // error return
+	}
+
 	log.Infof("get vdisk %v", vdiskID)
-	vd, err = s.vdiskMgr.Get(s.ctx, vdiskID, conn, s.flusherConf, s.waitConnectAddr)
+	vd, err = s.vdiskMgr.Get(s.ctx, vdiskID, conn, s.flusherConf, s.waitConnectAddr, s.waitTLSConfig)
 	if err != nil {
 		status = tlog.HandshakeStatusInternalServerError
 		err = errors.Wrapf(err, "couldn't create vdisk %s", vdiskID)
@@ -239,7 +275,7 @@ func (s *Server) writeHandshakeResponse(w io.Writer, segmentBuf []byte, status t
 	return capnp.NewEncoder(w).Encode(msg)
 }
 
-func (s *Server) handle(conn *net.TCPConn) error {
+func (s *Server) handle(conn net.Conn) error {
 
 	br := bufio.NewReader(conn)
 
@@ -306,6 +342,20 @@ func (s *Server) handleTlogWait(conn net.Conn) {
 		return
 	}
 
+	// validate the authentication token of the vdisk,
+	// closing the connection without a response when it is invalid
+	expectedToken, err := tlog.ReadAuthToken(s.configSource, vdiskID)
+	if err != nil {
+		log.Errorf("couldn't validate coordination connection for vdisk %s: %v", vdiskID, err)
+		return
+	}
+	token, err := req.Token()
+	if err != nil || !tlog.ValidAuthToken(expectedToken, token) {
+		log.Errorf("coordination connection from %s refused: invalid authentication token for vdisk %s",
+			conn.RemoteAddr().String(), vdiskID)
+		return
+	}
+
 	// send back handshake response
 	vdiskExists := s.vdiskMgr.exists(vdiskID)
 	msg, seg, err := capnp.NewMessage(capnp.SingleSegment(nil))
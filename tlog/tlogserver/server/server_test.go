@@ -114,7 +114,7 @@ func TestEndToEnd(t *testing.T) {
 	t.Logf("listen addr=%v", s.ListenAddr())
 
 	// create tlog client
-	client, err := tlogclient.New([]string{s.ListenAddr()}, expectedVdiskID)
+	client, err := tlogclient.New([]string{s.ListenAddr()}, expectedVdiskID, nil)
 	if !assert.Nil(t, err) {
 		return
 	}
@@ -198,6 +198,47 @@ func TestEndToEnd(t *testing.T) {
 	require.Equal(t, numFlush, aggReceived)
 }
 
+// Test that only clients with the correct authentication token
+// can connect for a vdisk which defines one
+func TestServerAuthToken(t *testing.T) {
+	const (
+		vdiskID = "12345"
+		token   = "s3cr3t"
+	)
+
+	ctx, cancelFunc := context.WithCancel(context.Background())
+	defer cancelFunc()
+
+	conf := testConf
+
+	cleanFunc, stubSource, _ := newZeroStorConfig(t, vdiskID, conf.PrivKey)
+	defer cleanFunc()
+	stubSource.SetTlogAuthToken(vdiskID, token)
+
+	s, err := NewServer(conf, stubSource)
+	require.NoError(t, err)
+	go s.Listen(ctx)
+
+	// clients without a token are refused
+	_, err = tlogclient.New([]string{s.ListenAddr()}, vdiskID, nil)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), tlog.HandshakeStatusInvalidToken.Error().Error())
+
+	// clients with an invalid token are refused
+	_, err = tlogclient.New([]string{s.ListenAddr()}, vdiskID, &tlogclient.Config{
+		AuthToken: "invalid",
+	})
+	require.Error(t, err)
+	require.Contains(t, err.Error(), tlog.HandshakeStatusInvalidToken.Error().Error())
+
+	// clients with the correct token are accepted
+	client, err := tlogclient.New([]string{s.ListenAddr()}, vdiskID, &tlogclient.Config{
+		AuthToken: token,
+	})
+	require.NoError(t, err)
+	require.NoError(t, client.Close())
+}
+
 func init() {
 	log.SetLevel(log.DebugLevel)
 }
@@ -0,0 +1,130 @@
+package server
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/zero-os/0-Disk/tlog"
+	"github.com/zero-os/0-Disk/tlog/tlogclient"
+)
+
+// newTestTLSConfig creates a CA and a certificate signed by that CA,
+// which can be used by both the tlog server and its clients.
+// It returns the TLS config of the server, and the tls.Config of a client.
+func newTestTLSConfig(t *testing.T) (*tlog.TLSConfig, *tls.Config, func()) {
+	dir, err := ioutil.TempDir("", "tlogtls")
+	require.NoError(t, err)
+	cleanup := func() { os.RemoveAll(dir) }
+
+	writePEM := func(name, blockType string, bytes []byte) string {
+		filePath := path.Join(dir, name)
+		file, err := os.Create(filePath)
+		require.NoError(t, err)
+		defer file.Close()
+		require.NoError(t, pem.Encode(file, &pem.Block{Type: blockType, Bytes: bytes}))
+		return filePath
+	}
+
+	// self-signed CA
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "tlog test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	require.NoError(t, err)
+	caCert, err := x509.ParseCertificate(caDER)
+	require.NoError(t, err)
+
+	// cert used for both server and client authentication
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "tlog"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	certDER, err := x509.CreateCertificate(rand.Reader, template, caCert, &key.PublicKey, caKey)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	tlsConf := &tlog.TLSConfig{
+		CertFile: writePEM("cert.pem", "CERTIFICATE", certDER),
+		KeyFile:  writePEM("key.pem", "EC PRIVATE KEY", keyDER),
+		CAFile:   writePEM("ca.pem", "CERTIFICATE", caDER),
+	}
+	clientTLS, err := tlsConf.ClientConfig()
+	require.NoError(t, err)
+
+	return tlsConf, clientTLS, cleanup
+}
+
+// Test that a server with TLS enabled only accepts TLS clients
+func TestServerTLS(t *testing.T) {
+	const vdiskID = "12345"
+
+	ctx, cancelFunc := context.WithCancel(context.Background())
+	defer cancelFunc()
+
+	tlsConf, clientTLS, cleanup := newTestTLSConfig(t)
+	defer cleanup()
+
+	conf := *testConf
+	conf.TLS = tlsConf
+
+	cleanFunc, stubSource, _ := newZeroStorConfig(t, vdiskID, conf.PrivKey)
+	defer cleanFunc()
+
+	s, err := NewServer(&conf, stubSource)
+	require.NoError(t, err)
+	go s.Listen(ctx)
+
+	// plain TCP clients are refused
+	_, err = tlogclient.New([]string{s.ListenAddr()}, vdiskID, nil)
+	require.Error(t, err)
+
+	// TLS clients without a valid client cert are refused
+	_, err = tlogclient.New([]string{s.ListenAddr()}, vdiskID, &tlogclient.Config{
+		TLSConfig: &tls.Config{RootCAs: clientTLS.RootCAs},
+	})
+	require.Error(t, err)
+
+	// TLS clients with a valid client cert are accepted
+	client, err := tlogclient.New([]string{s.ListenAddr()}, vdiskID, &tlogclient.Config{
+		TLSConfig: clientTLS,
+	})
+	require.NoError(t, err)
+	defer client.Close()
+
+	// send 2 aggregations worth of logs over TLS
+	lastSeq := uint64(conf.FlushSize * 2)
+	data := make([]byte, 4096)
+	rand.Read(data)
+	testClientSendWaitFlushResp(t, client, client.Recv(), int(lastSeq)+1, tlog.FirstSequence, lastSeq, data)
+}
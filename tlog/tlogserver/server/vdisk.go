@@ -2,6 +2,7 @@ package server
 
 import (
 	"context"
+	"crypto/tls"
 	"net"
 	"sync"
 	"time"
@@ -65,7 +66,7 @@ type vdisk struct {
 	flusherConf *flusherConfig
 
 	// connected clients table
-	clientConn     *net.TCPConn
+	clientConn     net.Conn
 	clientConnLock sync.Mutex
 
 	slaveSyncMgr tlog.SlaveSyncerManager
@@ -79,10 +80,15 @@ type vdisk struct {
 	// - have no remote tlog server to coordinate
 	// - remote tlog server to coordinate/wait already dies
 	ready            bool
-	coordConnectAddr string // remote tlog server to coordinate before marking ourself as ready
+	coordConnectAddr string      // remote tlog server to coordinate before marking ourself as ready
+	coordTLSConfig   *tls.Config // used to connect to the remote tlog server over TLS, if not nil
 
 	storClient *stor.Client
 	flusher    *flusher.Flusher
+
+	// latest tlog config, defining the retention policy
+	// and authentication token of this vdisk
+	tlogConf config.VdiskTlogConfig
 }
 
 // ID returns the ID of this vdisk
@@ -97,7 +103,7 @@ func (vd *vdisk) ResponseChan() <-chan *BlockResponse {
 
 // creates vdisk with given vdiskID
 func newVdisk(parentCtx context.Context, vdiskID string, slaveSyncMgr tlog.SlaveSyncerManager, configSource config.Source,
-	flusherConf *flusherConfig, cleanup vdiskCleanupFunc, coordConnectAddr string) (*vdisk, error) {
+	flusherConf *flusherConfig, cleanup vdiskCleanupFunc, coordConnectAddr string, coordTLSConfig *tls.Config) (*vdisk, error) {
 
 	ctx, cancelFunc := context.WithCancel(parentCtx)
 
@@ -120,6 +126,7 @@ func newVdisk(parentCtx context.Context, vdiskID string, slaveSyncMgr tlog.Slave
 		ctx:              ctx,
 		cancelFunc:       cancelFunc,
 		coordConnectAddr: coordConnectAddr,
+		coordTLSConfig:   coordTLSConfig,
 		ready:            coordConnectAddr == "",
 	}
 
@@ -143,6 +150,7 @@ func newVdisk(parentCtx context.Context, vdiskID string, slaveSyncMgr tlog.Slave
 
 	// run vdisk goroutines
 	go vd.runFlusher()
+	go vd.runRetention()
 	go vd.cleanup(cleanup)
 
 	log.Infof("vdisk %v created", vd.id)
@@ -164,6 +172,7 @@ func (vd *vdisk) watchConfig() error {
 		return err
 	}
 	vtc := <-vtcCh
+	vd.setTlogConfig(vtc)
 	zeroStorClusterID := vtc.ZeroStorClusterID
 
 	zeroStorClusterConfCh, err := config.WatchZeroStorClusterConfig(vd.ctx, vd.configSource, zeroStorClusterID)
@@ -183,6 +192,7 @@ func (vd *vdisk) watchConfig() error {
 				if err != nil {
 					continue
 				}
+				vd.setTlogConfig(vtc)
 				zeroStorClusterID = vtc.ZeroStorClusterID
 			case <-zeroStorClusterConfCh:
 				if err != nil {
@@ -237,7 +247,7 @@ func (vd *vdisk) forceFlushAtSeq(seq uint64) {
 }
 
 // connects the given connection to this vdisk
-func (vd *vdisk) connect(conn *net.TCPConn) (uint64, error) {
+func (vd *vdisk) connect(conn net.Conn) (uint64, error) {
 	if err := vd.attachConn(conn); err != nil {
 		return 0, err
 	}
@@ -312,6 +322,9 @@ func (vd *vdisk) runFlusher() {
 
 		// true if we wait for a sequence to be force flushed
 		needForceFlushSeq bool
+
+		// latency of the flushes of this vdisk
+		flushLatency = flushDuration.WithLabelValues(vd.id)
 	)
 
 	for {
@@ -390,7 +403,9 @@ func (vd *vdisk) runFlusher() {
 		status := tlog.BlockStatusFlushOK
 
 		// flush to 0-stor
+		flushStart := time.Now()
 		rawAgg, seqs, err := vd.flusher.Flush()
+		flushLatency.Observe(time.Since(flushStart).Seconds())
 		if err != nil {
 			log.Errorf("flush %v failed: %v", vd.id, err)
 			notifyFlushError(err)
@@ -14,7 +14,7 @@ import (
 	"zombiezen.com/go/capnproto2"
 )
 
-func (vd *vdisk) handle(conn *net.TCPConn, br *bufio.Reader, respSegmentBufLen int) error {
+func (vd *vdisk) handle(conn net.Conn, br *bufio.Reader, respSegmentBufLen int) error {
 	ctx, cancelFunc := context.WithCancel(vd.ctx)
 	defer func() {
 		vd.removeConn(conn)
@@ -89,7 +89,7 @@ func (vd *vdisk) handle(conn *net.TCPConn, br *bufio.Reader, respSegmentBufLen i
 }
 
 // response sender for a vdisk
-func (vd *vdisk) sendResp(ctx context.Context, conn *net.TCPConn, respSegmentBufLen int) {
+func (vd *vdisk) sendResp(ctx context.Context, conn net.Conn, respSegmentBufLen int) {
 	segmentBuf := make([]byte, 0, respSegmentBufLen)
 
 	capnpEnc := capnp.NewEncoder(conn)
@@ -197,7 +197,7 @@ func (vd *vdisk) hash(tlb *schema.TlogBlock) (err error) {
 	return
 }
 
-func (vd *vdisk) attachConn(conn *net.TCPConn) error {
+func (vd *vdisk) attachConn(conn net.Conn) error {
 	vd.clientConnLock.Lock()
 	defer vd.clientConnLock.Unlock()
 
@@ -209,7 +209,7 @@ func (vd *vdisk) attachConn(conn *net.TCPConn) error {
 	return nil
 }
 
-func (vd *vdisk) removeConn(conn *net.TCPConn) error {
+func (vd *vdisk) removeConn(conn net.Conn) error {
 	vd.clientConnLock.Lock()
 	defer vd.clientConnLock.Unlock()
 
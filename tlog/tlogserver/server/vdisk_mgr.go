@@ -2,6 +2,7 @@ package server
 
 import (
 	"context"
+	"crypto/tls"
 	"net"
 	"sync"
 
@@ -27,7 +28,7 @@ func newVdiskManager(slaveSyncMgr tlog.SlaveSyncerManager, flushSize int, config
 
 // get or create the vdisk
 func (vt *vdiskManager) Get(ctx context.Context, vdiskID string,
-	conn *net.TCPConn, flusherConf *flusherConfig, coordConnectAddr string) (vd *vdisk, err error) {
+	conn net.Conn, flusherConf *flusherConfig, coordConnectAddr string, coordTLSConfig *tls.Config) (vd *vdisk, err error) {
 
 	vt.lock.Lock()
 	defer vt.lock.Unlock()
@@ -40,7 +41,7 @@ func (vt *vdiskManager) Get(ctx context.Context, vdiskID string,
 
 	// create vdisk
 	vd, err = newVdisk(ctx, vdiskID, vt.slaveSyncMgr, vt.configSource,
-		flusherConf, vt.remove, coordConnectAddr)
+		flusherConf, vt.remove, coordConnectAddr, coordTLSConfig)
 	if err != nil {
 		return
 	}
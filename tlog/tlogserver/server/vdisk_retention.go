@@ -0,0 +1,94 @@
+package server
+
+import (
+	"time"
+
+	"github.com/zero-os/0-Disk/config"
+	"github.com/zero-os/0-Disk/log"
+	"github.com/zero-os/0-Disk/nbd/ardb"
+	"github.com/zero-os/0-Disk/nbd/ardb/storage"
+	"github.com/zero-os/0-Disk/tlog"
+	"github.com/zero-os/0-Disk/tlog/stor"
+)
+
+// set the latest tlog config of this vdisk
+func (vd *vdisk) setTlogConfig(vtc config.VdiskTlogConfig) {
+	vd.mux.Lock()
+	vd.tlogConf = vtc
+	vd.mux.Unlock()
+}
+
+// runRetention periodically applies the retention policy of this vdisk,
+// deleting the tlog aggregations which are outdated.
+// Only aggregations which are already flushed to the primary storage cluster
+// of this vdisk are deleted, as the other ones might still be needed to recover it.
+func (vd *vdisk) runRetention() {
+	interval := vd.flusherConf.RetentionInterval
+	if interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	// primary cluster is only created once it is needed,
+	// as most vdisks don't have a retention policy
+	var primaryCluster *storage.Cluster
+	defer func() {
+		if primaryCluster != nil {
+			primaryCluster.Close()
+		}
+	}()
+
+	for {
+		select {
+		case <-vd.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		vd.mux.Lock()
+		tlogConf := vd.tlogConf
+		vd.mux.Unlock()
+
+		if !tlogConf.HasRetention() {
+			continue
+		}
+
+		if primaryCluster == nil {
+			cluster, err := storage.NewPrimaryCluster(vd.ctx, vd.id, vd.configSource)
+			if err != nil {
+				log.Errorf("vdisk `%v` failed to create primary cluster for tlog retention: %v", vd.id, err)
+				continue
+			}
+			primaryCluster = cluster
+		}
+
+		if err := vd.truncate(primaryCluster, tlogConf); err != nil {
+			log.Errorf("vdisk `%v` failed to truncate tlog aggregations: %v", vd.id, err)
+		}
+	}
+}
+
+// truncate the tlog aggregations of this vdisk,
+// according to the retention policy defined in the given tlog config.
+func (vd *vdisk) truncate(cluster ardb.StorageCluster, tlogConf config.VdiskTlogConfig) error {
+	metadata, err := storage.LoadTlogMetadata(vd.id, cluster)
+	if err != nil {
+		return err
+	}
+
+	policy := stor.RetentionPolicy{
+		MaxAggregations: tlogConf.MaxAggregations,
+		FlushedSequence: metadata.LastFlushedSequence,
+	}
+	if tlogConf.MaxAge > 0 {
+		policy.CutoffEpoch = tlog.TimeNow().Add(-tlogConf.MaxAge).UnixNano()
+	}
+
+	count, err := vd.storClient.Truncate(policy)
+	if count > 0 {
+		log.Infof("vdisk `%v` deleted %d outdated tlog aggregations", vd.id, count)
+	}
+	return err
+}
@@ -1,11 +1,14 @@
 package server
 
 import (
+	"crypto/tls"
 	"net"
+	"time"
 
 	"zombiezen.com/go/capnproto2"
 
 	"github.com/zero-os/0-Disk/log"
+	"github.com/zero-os/0-Disk/tlog"
 	"github.com/zero-os/0-Disk/tlog/schema"
 )
 
@@ -27,11 +30,12 @@ func (vd *vdisk) waitOtherToFinish() error {
 	defer log.Infof("waitOther %v finished", vd.coordConnectAddr)
 
 	// connect to the other server
-	conn, err := net.Dial("tcp", vd.coordConnectAddr)
+	conn, err := vd.dialCoord()
 	if err != nil {
 		log.Errorf("failed to dial %v: %v", vd.coordConnectAddr, err)
 		return nil
 	}
+	defer conn.Close()
 
 	log.Infof("vdisk %v waiting for remote server %v to dies", vd.id, vd.coordConnectAddr)
 
@@ -63,6 +67,12 @@ func (vd *vdisk) waitOtherHandshake(conn net.Conn) (bool, error) {
 	if err := handshake.SetVdiskID(vd.id); err != nil {
 		return false, err
 	}
+	vd.mux.Lock()
+	token := vd.tlogConf.AuthToken
+	vd.mux.Unlock()
+	if err := handshake.SetToken(token); err != nil {
+		return false, err
+	}
 	if err := capnp.NewEncoder(conn).Encode(msg); err != nil {
 		return false, err
 	}
@@ -79,3 +89,13 @@ func (vd *vdisk) waitOtherHandshake(conn net.Conn) (bool, error) {
 
 	return resp.Exists(), nil
 }
+
+// dial the other server, using TLS if configured
+func (vd *vdisk) dialCoord() (net.Conn, error) {
+	if vd.coordTLSConfig == nil {
+		return net.Dial("tcp", vd.coordConnectAddr)
+	}
+	dialer := &net.Dialer{Timeout: 5 * time.Second}
+	return tls.DialWithDialer(dialer, "tcp", vd.coordConnectAddr,
+		tlog.ClientTLSConfig(vd.coordTLSConfig, vd.coordConnectAddr))
+}
@@ -26,6 +26,16 @@ import (
 // - c2 send some transaction (B)
 // -  make sure we have (A+B) with proper sequence number
 func TestCoord(t *testing.T) {
+	testCoord(t, false)
+}
+
+// same scenario as TestCoord,
+// using mutual TLS and an authentication token
+func TestCoordSecure(t *testing.T) {
+	testCoord(t, true)
+}
+
+func testCoord(t *testing.T, secure bool) {
 	tlogConf := DefaultConfig()
 	tlogConf.ListenAddr = ""
 
@@ -38,6 +48,20 @@ func TestCoord(t *testing.T) {
 	cleanFunc, confSource, _ := newZeroStorConfig(t, vdiskID, tlogConf.PrivKey)
 	defer cleanFunc()
 
+	var clientConf *tlogclient.Config
+	if secure {
+		tlsConf, clientTLS, cleanup := newTestTLSConfig(t)
+		defer cleanup()
+		tlogConf.TLS = tlsConf
+
+		const token = "s3cr3t"
+		confSource.SetTlogAuthToken(vdiskID, token)
+		clientConf = &tlogclient.Config{
+			AuthToken: token,
+			TLSConfig: clientTLS,
+		}
+	}
+
 	parentCtx, parentCancelFunc := context.WithCancel(context.Background())
 	defer parentCancelFunc()
 
@@ -57,7 +81,7 @@ func TestCoord(t *testing.T) {
 	go t1.Listen(ctx1)
 
 	// start client 1
-	c1, err := tlogclient.New([]string{t1.ListenAddr()}, vdiskID)
+	c1, err := tlogclient.New([]string{t1.ListenAddr()}, vdiskID, clientConf)
 	require.NoError(t, err)
 
 	resp1Ch := c1.Recv()
@@ -89,7 +113,7 @@ func TestCoord(t *testing.T) {
 	go t2.Listen(ctx2)
 
 	// start c2
-	c2, err := tlogclient.New([]string{t2.ListenAddr()}, vdiskID)
+	c2, err := tlogclient.New([]string{t2.ListenAddr()}, vdiskID, clientConf)
 	require.NoError(t, err)
 
 	// wait for t1 finish it flush and kil it
@@ -97,7 +97,7 @@ func (sc *SlaveCluster) DoForAll(pairs []ardb.IndexActionPair) ([]interface{}, e
 	case 0:
 		return nil, nil // nothing to do
 	case 1:
-		reply, err := sc.DoFor(pairs[0].Index, pairs[1].Action)
+		reply, err := sc.DoFor(pairs[0].Index, pairs[0].Action)
 		if err != nil {
 			return nil, err
 		}
@@ -53,7 +53,7 @@ func TestSlaveSyncEndToEnd(t *testing.T) {
 	go s.Listen(ctx)
 
 	// create tlog client
-	client, err := tlogclient.New([]string{s.ListenAddr()}, vdiskID)
+	client, err := tlogclient.New([]string{s.ListenAddr()}, vdiskID, nil)
 	require.Nil(t, err)
 
 	// initialize test data
@@ -0,0 +1,112 @@
+package tlog
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"io/ioutil"
+	"net"
+
+	"github.com/zero-os/0-Disk/errors"
+)
+
+// TLSConfig defines the PEM-encoded files used
+// to secure the tlog protocol connections using mutual TLS.
+// Both the tlog server and its clients require a certificate,
+// signed by a CA from the configured CA pool of the other side.
+type TLSConfig struct {
+	// PEM-encoded file containing the certificate
+	CertFile string
+	// PEM-encoded file containing the private key
+	KeyFile string
+	// PEM-encoded file containing the CA pool,
+	// used to verify the certificate of the other side
+	CAFile string
+	// optional name used to verify the certificate of the server,
+	// defaults to the host of the address a client connects to
+	ServerName string
+}
+
+// Validate the TLS config,
+// returning an error in case a required file is not defined.
+func (cfg *TLSConfig) Validate() error {
+	if cfg == nil {
+		return errors.New("nil TLS config")
+	}
+	if cfg.CertFile == "" {
+		return errors.New("TLS config requires a cert file")
+	}
+	if cfg.KeyFile == "" {
+		return errors.New("TLS config requires a key file")
+	}
+	if cfg.CAFile == "" {
+		return errors.New("TLS config requires a CA file")
+	}
+	return nil
+}
+
+// ServerConfig creates a tls.Config for a tlog (wait) listener,
+// which requires and verifies the certificate of each client.
+func (cfg *TLSConfig) ServerConfig() (*tls.Config, error) {
+	cert, caPool, err := cfg.load()
+	if err != nil {
+		return nil, err
+	}
+	return &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		ClientCAs:    caPool,
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+	}, nil
+}
+
+// ClientConfig creates a tls.Config for a tlog (wait) connection,
+// which presents a client certificate and verifies the certificate of the server.
+func (cfg *TLSConfig) ClientConfig() (*tls.Config, error) {
+	cert, caPool, err := cfg.load()
+	if err != nil {
+		return nil, err
+	}
+	return &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		RootCAs:      caPool,
+		ServerName:   cfg.ServerName,
+	}, nil
+}
+
+// load the certificate and CA pool defined by this config
+func (cfg *TLSConfig) load() (tls.Certificate, *x509.CertPool, error) {
+	err := cfg.Validate()
+	if err != nil {
+		return tls.Certificate{}, nil, err
+	}
+
+	cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+	if err != nil {
+		return tls.Certificate{}, nil, errors.Wrap(err, "couldn't load TLS cert")
+	}
+
+	caCert, err := ioutil.ReadFile(cfg.CAFile)
+	if err != nil {
+		return tls.Certificate{}, nil, errors.Wrap(err, "couldn't read TLS CA file")
+	}
+	caPool := x509.NewCertPool()
+	if !caPool.AppendCertsFromPEM(caCert) {
+		return tls.Certificate{}, nil, errors.Newf("no PEM-encoded certs found in %s", cfg.CAFile)
+	}
+
+	return cert, caPool, nil
+}
+
+// ClientTLSConfig returns a copy of the given tls.Config,
+// which verifies the server certificate using the host of the given address,
+// in case no server name is configured yet.
+func ClientTLSConfig(cfg *tls.Config, address string) *tls.Config {
+	cfg = cfg.Clone()
+	if cfg.ServerName == "" {
+		host, _, err := net.SplitHostPort(address)
+		if err != nil {
+			host = address
+		}
+		cfg.ServerName = host
+	}
+	return cfg
+}
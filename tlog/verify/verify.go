@@ -0,0 +1,293 @@
+package verify
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/zero-os/0-Disk"
+	"github.com/zero-os/0-Disk/config"
+	"github.com/zero-os/0-Disk/errors"
+	"github.com/zero-os/0-Disk/log"
+	"github.com/zero-os/0-Disk/nbd/ardb"
+	"github.com/zero-os/0-Disk/nbd/ardb/storage"
+	"github.com/zero-os/0-Disk/tlog"
+	"github.com/zero-os/0-Disk/tlog/schema"
+	"github.com/zero-os/0-Disk/tlog/stor"
+	"gopkg.in/validator.v2"
+)
+
+// Config represents the config for the verification of a vdisk's tlog.
+type Config struct {
+	VdiskID string `validate:"nonzero"`
+	PrivKey string `validate:"nonzero"`
+}
+
+// Result is the result of a tlog verification.
+type Result struct {
+	// amount of aggregations walked
+	Aggregations int
+	// amount of blocks walked
+	Blocks int
+	// last sequence flushed to the (ARDB) storage of the vdisk,
+	// only blocks up to this sequence are compared against that storage
+	LastFlushedSequence uint64
+	// amount of block indices compared against the (ARDB) storage of the vdisk
+	ComparedIndices int
+	// amount of block indices which weren't compared,
+	// as they were modified after the last flushed sequence
+	SkippedIndices int
+
+	// errors found in the chain of aggregations
+	ChainErrors []string
+	// errors found in the hashes of the blocks
+	HashErrors []string
+	// block indices of which the (ARDB) storage content
+	// doesn't match the content defined by the tlog
+	DivergingIndices []int64
+}
+
+// Valid returns true if no errors were found during the verification.
+func (r *Result) Valid() bool {
+	return len(r.ChainErrors) == 0 && len(r.HashErrors) == 0 && len(r.DivergingIndices) == 0
+}
+
+// Verify verifies the integrity of a vdisk's tlog, end to end.
+//
+// The chain of aggregations is walked, validating that each aggregation
+// links to the aggregation before it, and that the hash of each block
+// matches its data. While walking, the tlog is replayed into an in-memory shadow,
+// which only keeps the hash of the latest content of each block index.
+// Once the entire chain is walked, this shadow is compared block by block
+// against the current content of the vdisk's (ARDB) storage.
+//
+// Only blocks up to the last flushed sequence of the vdisk are compared,
+// block indices which were modified in the tlog after that sequence are skipped.
+// Block indices which are not part of the tlog (anymore) are not compared either.
+// As the vdisk can still be in use while it's being verified,
+// blocks which are written during the verification can be reported as diverging,
+// it is therefore recommended to verify an idle vdisk.
+func Verify(ctx context.Context, source config.Source, cfg Config) (*Result, error) {
+	if err := validator.Validate(cfg); err != nil {
+		return nil, err
+	}
+
+	hasTlog, err := tlog.HasTlogCluster(source, cfg.VdiskID)
+	if err != nil {
+		return nil, err
+	}
+	if !hasTlog {
+		return nil, errors.Newf("vdisk %s has no tlog cluster configured", cfg.VdiskID)
+	}
+
+	pool := ardb.NewPool(nil)
+	defer pool.Close()
+
+	// load the last flushed sequence, prior to walking the tlog,
+	// such that the storage content is at least as recent as that sequence
+	nbdStorageConfig, err := config.ReadNBDStorageConfig(source, cfg.VdiskID)
+	if err != nil {
+		return nil, err
+	}
+	cluster, err := ardb.NewCluster(nbdStorageConfig.StorageCluster, pool)
+	if err != nil {
+		return nil, err
+	}
+	metadata, err := storage.LoadTlogMetadata(cfg.VdiskID, cluster)
+	if err != nil {
+		return nil, errors.Wrapf(err, "couldn't load tlog metadata of vdisk %s", cfg.VdiskID)
+	}
+
+	storCli, err := stor.NewClientFromConfigSource(source, cfg.VdiskID, cfg.PrivKey)
+	if err != nil {
+		return nil, err
+	}
+	defer storCli.Close()
+
+	v := verifier{
+		result: Result{
+			LastFlushedSequence: metadata.LastFlushedSequence,
+		},
+		shadow:    make(map[int64]zerodisk.Hash),
+		unflushed: make(map[int64]struct{}),
+	}
+	complete, err := v.walk(ctx, storCli)
+	if err != nil {
+		return nil, err
+	}
+	if !complete {
+		// a broken chain results in an incomplete shadow,
+		// which would report false divergences
+		log.Errorf("not comparing the tlog of vdisk %s with its storage, as its chain is broken", cfg.VdiskID)
+		return &v.result, nil
+	}
+
+	blockStorage, err := storage.BlockStorageFromConfig(cfg.VdiskID, source, pool)
+	if err != nil {
+		return nil, err
+	}
+	defer blockStorage.Close()
+
+	err = v.compare(ctx, blockStorage)
+	if err != nil {
+		return nil, err
+	}
+	return &v.result, nil
+}
+
+// verifier is used to verify the tlog of a single vdisk.
+type verifier struct {
+	result Result
+	// hash of the latest content of each flushed block index,
+	// nil in case the block is deleted
+	shadow map[int64]zerodisk.Hash
+	// block indices modified after the last flushed sequence
+	unflushed map[int64]struct{}
+}
+
+// walk the tlog chain, validating it, while replaying it into the shadow.
+// It returns false in case the chain couldn't be walked entirely.
+func (v *verifier) walk(ctx context.Context, storCli *stor.Client) (bool, error) {
+	var (
+		prevKey []byte
+		lastSeq uint64
+	)
+	for wr := range storCli.Walk(0, tlog.TimeNowTimestamp()) {
+		select {
+		case <-ctx.Done():
+			return false, ctx.Err()
+		default:
+		}
+
+		if wr.Err != nil {
+			v.result.ChainErrors = append(v.result.ChainErrors, fmt.Sprintf(
+				"failed to walk past aggregation %x: %v", prevKey, wr.Err))
+			return false, nil
+		}
+		v.result.Aggregations++
+
+		// validate the link to the previous aggregation
+		if !bytes.Equal(wr.Meta.Previous, prevKey) {
+			v.result.ChainErrors = append(v.result.ChainErrors, fmt.Sprintf(
+				"aggregation %x links to %x, while it follows %x",
+				wr.StorKey, wr.Meta.Previous, prevKey))
+		}
+		prevKey = wr.StorKey
+
+		blocks, err := wr.Agg.Blocks()
+		if err != nil {
+			return false, err
+		}
+		// the block list can be bigger than the actual amount of blocks
+		for i := 0; i < int(wr.Agg.Size()); i++ {
+			block := blocks.At(i)
+			v.result.Blocks++
+
+			// validate that sequences only increase
+			seq := block.Sequence()
+			if seq <= lastSeq {
+				v.result.ChainErrors = append(v.result.ChainErrors, fmt.Sprintf(
+					"aggregation %x contains sequence %d, while sequence %d was already stored",
+					wr.StorKey, seq, lastSeq))
+			}
+			lastSeq = seq
+
+			err = v.replayBlock(block)
+			if err != nil {
+				return false, err
+			}
+		}
+	}
+
+	return true, nil
+}
+
+// replayBlock validates the hash of a block,
+// and replays it into the shadow.
+func (v *verifier) replayBlock(block schema.TlogBlock) error {
+	data, err := block.Data()
+	if err != nil {
+		return err
+	}
+	rawHash, err := block.Hash()
+	if err != nil {
+		return err
+	}
+
+	index, seq := block.Index(), block.Sequence()
+	hash := zerodisk.HashBytes(data)
+	if !hash.Equals(zerodisk.Hash(rawHash)) {
+		v.result.HashErrors = append(v.result.HashErrors, fmt.Sprintf(
+			"block %d (sequence %d) has hash %x, while its data has hash %x",
+			index, seq, rawHash, hash))
+	}
+
+	if seq > v.result.LastFlushedSequence {
+		v.unflushed[index] = struct{}{}
+		return nil
+	}
+
+	// storages don't store zero blocks,
+	// and thus these are handled as deleted blocks
+	if block.Operation() == schema.OpDelete || isZeroContent(data) {
+		v.shadow[index] = nil
+	} else {
+		v.shadow[index] = hash
+	}
+	return nil
+}
+
+// compare the shadow, block by block, against the given storage,
+// storing the block indices of which the content diverges.
+func (v *verifier) compare(ctx context.Context, blockStorage storage.BlockStorage) error {
+	v.result.SkippedIndices = len(v.unflushed)
+	indices := make([]int64, 0, len(v.shadow))
+	for index := range v.shadow {
+		if _, ok := v.unflushed[index]; ok {
+			continue
+		}
+		indices = append(indices, index)
+	}
+	sort.Slice(indices, func(i, j int) bool {
+		return indices[i] < indices[j]
+	})
+
+	for _, index := range indices {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+		}
+
+		content, err := blockStorage.GetBlock(index)
+		if err != nil {
+			return errors.Wrapf(err, "couldn't get block %d from storage", index)
+		}
+		v.result.ComparedIndices++
+
+		expected := v.shadow[index]
+		if expected == nil {
+			if !isZeroContent(content) {
+				v.result.DivergingIndices = append(v.result.DivergingIndices, index)
+			}
+			continue
+		}
+		if !expected.Equals(zerodisk.HashBytes(content)) {
+			v.result.DivergingIndices = append(v.result.DivergingIndices, index)
+		}
+	}
+
+	return nil
+}
+
+// isZeroContent detects if a given content buffer is completely filled with 0s
+func isZeroContent(content []byte) bool {
+	for _, c := range content {
+		if c != 0 {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,165 @@
+package verify
+
+import (
+	"context"
+	"crypto/rand"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/zero-os/0-Disk"
+	"github.com/zero-os/0-Disk/config"
+	"github.com/zero-os/0-Disk/nbd/ardb"
+	"github.com/zero-os/0-Disk/nbd/ardb/storage"
+	"github.com/zero-os/0-Disk/redisstub"
+	"github.com/zero-os/0-Disk/tlog"
+	"github.com/zero-os/0-Disk/tlog/flusher"
+	"github.com/zero-os/0-Disk/tlog/schema"
+	"github.com/zero-os/0-Disk/tlog/stor/embeddedserver"
+	"github.com/zero-os/0-stor/client/meta/embedserver"
+)
+
+func TestVerify(t *testing.T) {
+	const (
+		vdiskID           = "a"
+		clusterID         = "primary"
+		zeroStorClusterID = "zerostor"
+		dataShards        = 4
+		parityShards      = 2
+		blockSize         = 4096
+		blockCount        = 32
+		privKey           = "12345678901234567890123456789012"
+	)
+
+	require := require.New(t)
+
+	// creates zero-stor cluster
+	storCluster, err := embeddedserver.NewZeroStorCluster(dataShards + parityShards)
+	require.NoError(err)
+	defer storCluster.Close()
+	mdServer, err := embedserver.New()
+	require.NoError(err)
+	defer mdServer.Stop()
+
+	var serverConf []config.ServerConfig
+	for _, addr := range storCluster.Addrs() {
+		serverConf = append(serverConf, config.ServerConfig{Address: addr})
+	}
+
+	slice := redisstub.NewMemoryRedisSlice(2)
+	defer slice.Close()
+	clusterConfig := slice.StorageClusterConfig()
+
+	source := config.NewStubSource()
+	defer source.Close()
+	source.SetVdiskConfig(vdiskID, &config.VdiskStaticConfig{
+		BlockSize: blockSize,
+		Size:      1,
+		Type:      config.VdiskTypeDB,
+	})
+	source.SetPrimaryStorageCluster(vdiskID, clusterID, &clusterConfig)
+	source.SetTlogServerCluster(vdiskID, "tlog", &config.TlogClusterConfig{
+		Servers: []string{"localhost:20031"},
+	})
+	source.SetTlogZeroStorCluster(vdiskID, zeroStorClusterID, &config.ZeroStorClusterConfig{
+		IYO: config.IYOCredentials{
+			Org:       "testorg",
+			Namespace: "thedisk",
+		},
+		MetadataServers: []config.ServerConfig{
+			config.ServerConfig{Address: mdServer.ListenAddr()},
+		},
+		DataServers:  serverConf,
+		DataShards:   dataShards,
+		ParityShards: parityShards,
+	})
+
+	cluster, err := ardb.NewCluster(clusterConfig, nil)
+	require.NoError(err)
+	blockStorage, err := storage.NewBlockStorage(storage.BlockStorageConfig{
+		VdiskID:   vdiskID,
+		VdiskType: config.VdiskTypeDB,
+		BlockSize: blockSize,
+	}, cluster, nil)
+	require.NoError(err)
+	defer blockStorage.Close()
+
+	flusher, err := flusher.New(source, 8, vdiskID, privKey)
+	require.NoError(err)
+
+	// store the data both in the tlog and the storage cluster,
+	// overwriting and deleting some blocks
+	var seq uint64
+	addTransaction := func(index int64, op uint8, content []byte, store bool) {
+		seq++
+		if store {
+			if op == schema.OpDelete {
+				require.NoError(blockStorage.DeleteBlock(index))
+			} else {
+				require.NoError(blockStorage.SetBlock(index, content))
+			}
+		}
+		require.NoError(flusher.AddTransaction(tlog.Transaction{
+			Operation: op,
+			Sequence:  seq,
+			Content:   content,
+			Index:     index,
+			Timestamp: tlog.TimeNowTimestamp(),
+			Hash:      zerodisk.HashBytes(content),
+		}))
+		if flusher.Full() {
+			_, _, err = flusher.Flush()
+			require.NoError(err)
+		}
+	}
+	for i := 0; i < 2*blockCount; i++ {
+		index := int64(i % blockCount)
+		if i%5 == 0 {
+			addTransaction(index, schema.OpDelete, nil, true)
+			continue
+		}
+		content := make([]byte, blockSize)
+		rand.Read(content)
+		addTransaction(index, schema.OpSet, content, true)
+	}
+	_, _, err = flusher.Flush()
+	require.NoError(err)
+	require.NoError(blockStorage.Flush())
+	require.NoError(storage.StoreTlogMetadata(vdiskID, cluster, storage.TlogMetadata{
+		LastFlushedSequence: seq,
+	}))
+
+	// the tlog and storage are in sync
+	result, err := Verify(context.Background(), source, Config{VdiskID: vdiskID, PrivKey: privKey})
+	require.NoError(err)
+	require.True(result.Valid(), "%+v", result)
+	require.Equal(2*blockCount, result.Blocks)
+	require.Equal(blockCount, result.ComparedIndices)
+	require.Equal(0, result.SkippedIndices)
+	require.Equal(seq, result.LastFlushedSequence)
+
+	// a block which isn't flushed yet, is skipped
+	content := make([]byte, blockSize)
+	rand.Read(content)
+	addTransaction(1, schema.OpSet, content, false)
+	_, _, err = flusher.Flush()
+	require.NoError(err)
+
+	result, err = Verify(context.Background(), source, Config{VdiskID: vdiskID, PrivKey: privKey})
+	require.NoError(err)
+	require.True(result.Valid(), "%+v", result)
+	require.Equal(blockCount-1, result.ComparedIndices)
+	require.Equal(1, result.SkippedIndices)
+
+	// blocks which diverge from the tlog are reported
+	rand.Read(content)
+	require.NoError(blockStorage.SetBlock(2, content))
+	require.NoError(blockStorage.SetBlock(3, content)) // deleted in the tlog
+	require.NoError(blockStorage.Flush())
+
+	result, err = Verify(context.Background(), source, Config{VdiskID: vdiskID, PrivKey: privKey})
+	require.NoError(err)
+	require.False(result.Valid())
+	require.Equal([]int64{2, 3}, result.DivergingIndices)
+	require.Empty(result.ChainErrors)
+	require.Empty(result.HashErrors)
+}
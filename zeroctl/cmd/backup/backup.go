@@ -4,6 +4,7 @@ import (
 	"os"
 	"strings"
 
+	"github.com/zero-os/0-Disk/compress"
 	"github.com/zero-os/0-Disk/config"
 	"github.com/zero-os/0-Disk/errors"
 	"github.com/zero-os/0-Disk/nbd/ardb/backup"
@@ -12,13 +13,13 @@ import (
 // see `init` and `parsePosArguments` for more information
 // about the meaning of each config property.
 var vdiskCmdCfg struct {
-	VdiskID         string                 // required
-	SourceConfig    config.SourceConfig    // optional
-	SnapshotID      string                 // optional
-	PrivateKey      backup.CryptoKey       // optional
-	CompressionType backup.CompressionType // optional
-	JobCount        int                    // optional
-	Force           bool                   // optional
+	VdiskID         string                   // required
+	SourceConfig    config.SourceConfig      // optional
+	SnapshotID      string                   // optional
+	PrivateKey      backup.CryptoKey         // optional
+	CompressionType compress.CompressionType // optional
+	JobCount        int                      // optional
+	Force           bool                     // optional
 
 	BackupStorageConfig storageConfig          // optional
 	TLSConfig           backup.TLSClientConfig // optional
@@ -31,16 +32,24 @@ var (
 )
 
 func createBackupStorageConfigFromFlags() (cfg interface{}) {
+	// only use the TLS config if at least one TLS flag was given
+	var tlsConfig *backup.TLSClientConfig
+	if vdiskCmdCfg.TLSConfig.InsecureSkipVerify || vdiskCmdCfg.TLSConfig.CertFile != "" ||
+		vdiskCmdCfg.TLSConfig.CAFile != "" || vdiskCmdCfg.TLSConfig.ServerName != "" {
+		tlsConfig = &vdiskCmdCfg.TLSConfig
+	}
+
 	if vdiskCmdCfg.BackupStorageConfig.StorageType == ftpStorageType {
-		storageConfig := backup.FTPStorageDriverConfig{
+		return backup.FTPStorageDriverConfig{
 			ServerConfig: vdiskCmdCfg.BackupStorageConfig.Resource.(backup.FTPServerConfig),
+			TLSConfig:    tlsConfig,
 		}
-		if vdiskCmdCfg.TLSConfig.InsecureSkipVerify || vdiskCmdCfg.TLSConfig.CertFile != "" ||
-			vdiskCmdCfg.TLSConfig.CAFile != "" || vdiskCmdCfg.TLSConfig.ServerName != "" {
-			storageConfig.TLSConfig = &vdiskCmdCfg.TLSConfig
+	}
+	if vdiskCmdCfg.BackupStorageConfig.StorageType == s3StorageType {
+		return backup.S3StorageDriverConfig{
+			ServerConfig: vdiskCmdCfg.BackupStorageConfig.Resource.(backup.S3ServerConfig),
+			TLSConfig:    tlsConfig,
 		}
-
-		return storageConfig
 	}
 	if vdiskCmdCfg.BackupStorageConfig.Resource != nil {
 		return backup.LocalStorageDriverConfig{
@@ -69,6 +78,18 @@ func newStorageConfig(data string) (cfg storageConfig, err error) {
 		return
 	}
 
+	// if an S3 protocol was specified,
+	// interpret it as an S3 server config
+	if strings.HasPrefix(data, "s3://") || strings.HasPrefix(data, "s3+http://") {
+		var s3StorageConfig backup.S3ServerConfig
+		s3StorageConfig, err = backup.NewS3ServerConfig(data)
+		if err == nil {
+			cfg.Resource = s3StorageConfig
+			cfg.StorageType = s3StorageType
+		}
+		return
+	}
+
 	// try to interpret it as an FTP server config
 	ftpStorageConfig, err := backup.NewFTPServerConfig(data)
 	if err == nil {
@@ -114,6 +135,7 @@ type storageConfig struct {
 	// The resource used to create the specific storage.
 	// Type = local -> Resource defines root directory path.
 	// Type = ftp -> Resource defines ftp configuration.
+	// Type = s3 -> Resource defines s3 configuration.
 	Resource interface{}
 	// Defines the type of (backup) storage to
 	// write/read the backup to/from.
@@ -137,6 +159,11 @@ func (cfg *storageConfig) String() string {
 		return path
 	}
 
+	if cfg.StorageType == s3StorageType {
+		s3Config, _ := cfg.Resource.(backup.S3ServerConfig)
+		return s3Config.String()
+	}
+
 	// must be an FTP Storage Config, as the config is valid
 	ftpConfig, _ := cfg.Resource.(backup.FTPServerConfig)
 	// and return the ftp config as a string
@@ -169,4 +196,8 @@ const (
 	// on/from an FTP Server.
 	// This is the (backup) storage used in production.
 	ftpStorageType storageType = 1
+	// s3StorageType defines the S3 storage,
+	// meaning a backup would be stored/loaded,
+	// on/from an S3 compatible object storage.
+	s3StorageType storageType = 2
 )
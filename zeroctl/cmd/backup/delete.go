@@ -0,0 +1,151 @@
+package backup
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/zero-os/0-Disk/errors"
+	"github.com/zero-os/0-Disk/log"
+	"github.com/zero-os/0-Disk/nbd/ardb/backup"
+	"github.com/zero-os/0-Disk/tlog/archive"
+
+	cmdconfig "github.com/zero-os/0-Disk/zeroctl/cmd/config"
+)
+
+// DeleteSnapshotCmd represents the delete-snapshot subcommand
+var DeleteSnapshotCmd = &cobra.Command{
+	Use:   "snapshot snapshotID [snapshotID...]",
+	Short: "delete one or multiple snapshots",
+	RunE:  deleteSnapshots,
+}
+
+// delete only configuration
+// see `init` for more information
+// about the meaning of each config property.
+var deleteSnapshotCmdCfg struct {
+	KeepBlocks bool
+}
+
+func deleteSnapshots(cmd *cobra.Command, args []string) error {
+	logLevel := log.InfoLevel
+	if cmdconfig.Verbose {
+		logLevel = log.DebugLevel
+	}
+	log.SetLevel(logLevel)
+
+	if len(args) == 0 {
+		return errors.New("no snapshot identifier given")
+	}
+
+	driver, err := backup.NewStorageDriver(createBackupStorageConfigFromFlags())
+	if err != nil {
+		return err
+	}
+	defer driver.Close()
+
+	// delete the headers of all given snapshots,
+	// after which the snapshots can no longer be imported
+	for _, snapshotID := range args {
+		err = driver.DeleteHeader(snapshotID)
+		if err != nil {
+			if err == backup.ErrDataDidNotExist {
+				return errors.Newf(
+					"snapshot %s doesn't exist at %s",
+					snapshotID, vdiskCmdCfg.BackupStorageConfig.String())
+			}
+			return errors.Wrapf(err, "couldn't delete snapshot %s", snapshotID)
+		}
+		log.Infof("deleted snapshot %s", snapshotID)
+	}
+
+	if deleteSnapshotCmdCfg.KeepBlocks {
+		return nil
+	}
+
+	// delete all deduped blocks which are no longer referenced
+	return collectBackupGarbage(driver, false)
+}
+
+// collectBackupGarbage deletes (or only counts in case of a dry run)
+// all deduped blocks of the given backup storage which are no longer referenced,
+// by either a snapshot or a tlog archive, and prints the result to the STDOUT.
+func collectBackupGarbage(driver backup.StorageDriver, dryRun bool) error {
+	result, err := backup.CollectDedupedGarbage(
+		driver, &vdiskCmdCfg.PrivateKey, vdiskCmdCfg.CompressionType,
+		dryRun, archive.References)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("headers: %d\n", result.Headers)
+	fmt.Printf("referenced deduped blocks: %d\n", result.ReferencedBlocks)
+	if result.Deleted {
+		fmt.Printf("deleted deduped blocks: %d\n", result.UnreferencedBlocks)
+	} else {
+		fmt.Printf("unreferenced deduped blocks: %d\n", result.UnreferencedBlocks)
+	}
+	return nil
+}
+
+func init() {
+	DeleteSnapshotCmd.Long = DeleteSnapshotCmd.Short + `
+
+Deletes the header of all given snapshots,
+after which the snapshots can no longer be imported.
+Once all snapshots are deleted, all deduped blocks
+which are no longer referenced by any snapshot or tlog archive
+are deleted from the backup storage as well,
+unless the --keep-blocks flag is given.
+See the "gc backup" command for more information about that process.
+
+  Deleting the unreferenced deduped blocks requires all snapshots
+(and tlog archives) in the backup storage to be readable,
+and thus all of them have to use the same crypto (private) key
+and compression type, as given using the --key and --compression flags.
+When that isn't the case, no deduped blocks will be deleted,
+but the given snapshots will be deleted none the less.
+
+  No snapshot should be exported to the same backup storage
+while deleting snapshots, as its deduped blocks might be deleted,
+prior to being referenced by its (not yet stored) header.
+
+See the "import vdisk" command for more information about the flags
+which define the backup storage, compression and encryption.
+`
+
+	DeleteSnapshotCmd.Flags().VarP(
+		&vdiskCmdCfg.CompressionType, "compression", "c",
+		"the compression type used by the snapshots, options { lz4, xz }")
+	DeleteSnapshotCmd.Flags().VarP(
+		&vdiskCmdCfg.PrivateKey, "key", "k",
+		"an optional 32 byte fixed-size private key used for decryption when given")
+
+	DeleteSnapshotCmd.Flags().BoolVar(
+		&deleteSnapshotCmdCfg.KeepBlocks, "keep-blocks", false,
+		"when given the deduped blocks which are no longer referenced will not be deleted")
+
+	DeleteSnapshotCmd.Flags().VarP(
+		&vdiskCmdCfg.BackupStorageConfig, "storage", "s",
+		"ftp/s3 server url or local dir path to delete the snapshots from")
+
+	DeleteSnapshotCmd.Flags().BoolVar(
+		&vdiskCmdCfg.TLSConfig.InsecureSkipVerify,
+		"tls-insecure", false,
+		"when given FTP over SSL will be used without cert verification")
+	DeleteSnapshotCmd.Flags().StringVar(
+		&vdiskCmdCfg.TLSConfig.ServerName,
+		"tls-server", "",
+		"certs will be verified when given (required when --tls-insecure is not used)")
+	DeleteSnapshotCmd.Flags().StringVar(
+		&vdiskCmdCfg.TLSConfig.CertFile,
+		"tls-cert", "",
+		"PEM-encoded file containing the TLS Client cert (FTPS will be used when given)")
+	DeleteSnapshotCmd.Flags().StringVar(
+		&vdiskCmdCfg.TLSConfig.KeyFile,
+		"tls-key", "",
+		"PEM-encoded file containing the private TLS client key")
+	DeleteSnapshotCmd.Flags().StringVar(
+		&vdiskCmdCfg.TLSConfig.CAFile,
+		"tls-ca", "",
+		"optional PEM-encoded file containing the TLS CA Pool (defaults to system pool when not given)")
+}
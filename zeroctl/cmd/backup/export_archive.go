@@ -0,0 +1,184 @@
+package backup
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/zero-os/0-Disk/config"
+	"github.com/zero-os/0-Disk/errors"
+	"github.com/zero-os/0-Disk/log"
+	"github.com/zero-os/0-Disk/tlog/archive"
+
+	cmdconfig "github.com/zero-os/0-Disk/zeroctl/cmd/config"
+)
+
+// ExportArchiveCmd represents the tlog archive export subcommand
+var ExportArchiveCmd = &cobra.Command{
+	Use:   "archive vdiskid [archiveID]",
+	Short: "export the tlog history of a vdisk into an archive",
+	RunE:  exportArchive,
+}
+
+// export archive only configuration
+// see `init` for more information
+// about the meaning of each config property.
+var exportArchiveCmdCfg struct {
+	TlogPrivKey string
+	StartTs     int64
+	EndTs       int64
+	ChunkSize   int64
+}
+
+func exportArchive(cmd *cobra.Command, args []string) error {
+	logLevel := log.ErrorLevel
+	if cmdconfig.Verbose {
+		logLevel = log.DebugLevel
+	}
+	log.SetLevel(logLevel)
+
+	// parse the position arguments
+	err := parseExportArchivePosArguments(args)
+	if err != nil {
+		return err
+	}
+
+	// create config source
+	cs, err := config.NewSource(vdiskCmdCfg.SourceConfig)
+	if err != nil {
+		return err
+	}
+	defer cs.Close()
+	configSource := config.NewOnceSource(cs)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	index, err := archive.Export(ctx, configSource, archive.Config{
+		VdiskID:                  vdiskCmdCfg.VdiskID,
+		ArchiveID:                vdiskCmdCfg.SnapshotID,
+		PrivKey:                  exportArchiveCmdCfg.TlogPrivKey,
+		StartEpoch:               exportArchiveCmdCfg.StartTs,
+		EndEpoch:                 exportArchiveCmdCfg.EndTs,
+		ChunkSize:                exportArchiveCmdCfg.ChunkSize,
+		BackupStoragDriverConfig: createBackupStorageConfigFromFlags(),
+		CompressionType:          vdiskCmdCfg.CompressionType,
+		CryptoKey:                vdiskCmdCfg.PrivateKey,
+		Force:                    vdiskCmdCfg.Force,
+	})
+	if err != nil {
+		return err
+	}
+
+	log.Infof("archived %d aggregations (sequence %d-%d) of vdisk %s",
+		index.Aggregations, index.FirstSequence, index.LastSequence, index.VdiskID)
+	fmt.Println(index.ArchiveID)
+	return nil
+}
+
+func parseExportArchivePosArguments(args []string) error {
+	// validate pos arg length
+	argn := len(args)
+	if argn < 1 {
+		return errors.New("not enough arguments")
+	} else if argn > 2 {
+		return errors.New("too many arguments")
+	}
+
+	vdiskCmdCfg.VdiskID = args[0]
+	if argn == 2 {
+		vdiskCmdCfg.SnapshotID = args[1]
+	} else {
+		epoch := time.Now().UTC().Unix()
+		vdiskCmdCfg.SnapshotID = fmt.Sprintf("%s_tlog_%d", vdiskCmdCfg.VdiskID, epoch)
+	}
+
+	return nil
+}
+
+func init() {
+	ExportArchiveCmd.Long = ExportArchiveCmd.Short + `
+
+Streams the tlog aggregations of a vdisk, optionally limited by timestamps,
+into a backup storage, as a series of compressed (and optionally encrypted) chunks,
+described by an index which is stored as the header of the archive.
+The tlog of the vdisk is left untouched, such that the vdisk can keep running
+while its history is being archived. An archive can be imported into
+the (empty) tlog of a vdisk using the "import archive" command.
+
+Remember to keep note of the used archive name,
+crypto (private) key and the compression type,
+as you will need the same information when importing the archive.
+
+If the archiveID is not given,
+one will be generated automatically using the "<vdiskID>_tlog_epoch" format.
+The used archiveID will be printed in the STDOUT in case
+no (fatal) error occured, at the end of the command's lifetime.
+
+  Archives share the same storage space as the snapshots of the
+"export vdisk" command, hence an archive can't use the ID of an existing snapshot.
+An existing archive is only overwritten when the --force flag is given.
+
+See the "export vdisk" command for more information about the flags
+which define the backup storage, compression and encryption.
+`
+
+	ExportArchiveCmd.Flags().Var(
+		&vdiskCmdCfg.SourceConfig, "config",
+		"config resource: dialstrings (etcd cluster) or path (yaml file)")
+	ExportArchiveCmd.Flags().StringVar(
+		&exportArchiveCmdCfg.TlogPrivKey,
+		"tlog-priv-key", "12345678901234567890123456789012",
+		"32 bytes tlog private key")
+
+	ExportArchiveCmd.Flags().Int64Var(
+		&exportArchiveCmdCfg.StartTs,
+		"start-timestamp", 0,
+		"start UTC timestamp in nanosecond(default 0: since beginning)")
+	ExportArchiveCmd.Flags().Int64Var(
+		&exportArchiveCmdCfg.EndTs,
+		"end-timestamp", 0,
+		"end UTC timestamp in nanosecond(default 0: until the end)")
+	ExportArchiveCmd.Flags().Int64Var(
+		&exportArchiveCmdCfg.ChunkSize,
+		"chunk-size", archive.DefaultChunkSize,
+		"the amount of (aggregation) bytes stored per chunk")
+
+	ExportArchiveCmd.Flags().VarP(
+		&vdiskCmdCfg.CompressionType, "compression", "c",
+		"the compression type to use, options { lz4, xz }")
+	ExportArchiveCmd.Flags().VarP(
+		&vdiskCmdCfg.PrivateKey, "key", "k",
+		"an optional 32 byte fixed-size private key used for encryption when given")
+
+	ExportArchiveCmd.Flags().VarP(
+		&vdiskCmdCfg.BackupStorageConfig, "storage", "s",
+		"ftp/s3 server url or local dir path to export the archive to")
+
+	ExportArchiveCmd.Flags().BoolVarP(
+		&vdiskCmdCfg.Force,
+		"force", "f", false,
+		"when given, overwrite the archive if it already existed")
+
+	ExportArchiveCmd.Flags().BoolVar(
+		&vdiskCmdCfg.TLSConfig.InsecureSkipVerify,
+		"tls-insecure", false,
+		"when given FTP over SSL will be used without cert verification")
+	ExportArchiveCmd.Flags().StringVar(
+		&vdiskCmdCfg.TLSConfig.ServerName,
+		"tls-server", "",
+		"certs will be verified when given (required when --tls-insecure is not used)")
+	ExportArchiveCmd.Flags().StringVar(
+		&vdiskCmdCfg.TLSConfig.CertFile,
+		"tls-cert", "",
+		"PEM-encoded file containing the TLS Client cert (FTPS will be used when given)")
+	ExportArchiveCmd.Flags().StringVar(
+		&vdiskCmdCfg.TLSConfig.KeyFile,
+		"tls-key", "",
+		"PEM-encoded file containing the private TLS client key")
+	ExportArchiveCmd.Flags().StringVar(
+		&vdiskCmdCfg.TLSConfig.CAFile,
+		"tls-ca", "",
+		"optional PEM-encoded file containing the TLS CA Pool (defaults to system pool when not given)")
+}
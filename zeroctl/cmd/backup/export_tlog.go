@@ -0,0 +1,210 @@
+package backup
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+
+	"github.com/spf13/cobra"
+	"github.com/zero-os/0-Disk/config"
+	"github.com/zero-os/0-Disk/errors"
+	"github.com/zero-os/0-Disk/log"
+	"github.com/zero-os/0-Disk/nbd/ardb/backup"
+	"github.com/zero-os/0-Disk/tlog/export"
+	"github.com/zero-os/0-Disk/tlog/tlogclient/decoder"
+
+	cmdconfig "github.com/zero-os/0-Disk/zeroctl/cmd/config"
+)
+
+// ExportTlogCmd represents the tlog export subcommand
+var ExportTlogCmd = &cobra.Command{
+	Use:   "tlog vdiskid [snapshotID]",
+	Short: "export a vdisk from its tlog, as it was at a given point in time",
+	RunE:  exportTlog,
+}
+
+// export tlog only configuration
+// see `init` for more information
+// about the meaning of each config property.
+var exportTlogCmdCfg struct {
+	ExportBlockSize int64
+	TlogPrivKey     string
+	StartTs         int64
+	EndTs           int64
+	StartSeq        uint64
+	EndSeq          uint64
+	ImagePath       string
+}
+
+func exportTlog(cmd *cobra.Command, args []string) error {
+	logLevel := log.ErrorLevel
+	if cmdconfig.Verbose {
+		logLevel = log.DebugLevel
+	}
+	log.SetLevel(logLevel)
+
+	// parse the position arguments
+	err := parseExportPosArguments(args)
+	if err != nil {
+		return err
+	}
+
+	limiter, err := createTlogLimiter()
+	if err != nil {
+		return err
+	}
+
+	// create config source
+	cs, err := config.NewSource(vdiskCmdCfg.SourceConfig)
+	if err != nil {
+		return err
+	}
+	defer cs.Close()
+	configSource := config.NewOnceSource(cs)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	cfg := export.Config{
+		VdiskID: vdiskCmdCfg.VdiskID,
+		PrivKey: exportTlogCmdCfg.TlogPrivKey,
+		Limiter: limiter,
+	}
+
+	// export to a local raw image
+	if exportTlogCmdCfg.ImagePath != "" {
+		_, err = export.ToImage(ctx, configSource, cfg, exportTlogCmdCfg.ImagePath)
+		if err != nil {
+			return err
+		}
+		fmt.Println(exportTlogCmdCfg.ImagePath)
+		return nil
+	}
+
+	// export to a backup snapshot
+	_, err = export.ToSnapshot(ctx, configSource, cfg, backup.Config{
+		SnapshotID:               vdiskCmdCfg.SnapshotID,
+		BlockSize:                exportTlogCmdCfg.ExportBlockSize,
+		BackupStoragDriverConfig: createBackupStorageConfigFromFlags(),
+		JobCount:                 vdiskCmdCfg.JobCount,
+		CompressionType:          vdiskCmdCfg.CompressionType,
+		CryptoKey:                vdiskCmdCfg.PrivateKey,
+		Force:                    vdiskCmdCfg.Force,
+	})
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(vdiskCmdCfg.SnapshotID)
+	return nil
+}
+
+// createTlogLimiter creates the limiter which defines the part of the tlog to replay,
+// limited either by timestamps or by sequences.
+func createTlogLimiter() (decoder.Limiter, error) {
+	bySequence := exportTlogCmdCfg.StartSeq != 0 || exportTlogCmdCfg.EndSeq != 0
+	byTimestamp := exportTlogCmdCfg.StartTs != 0 || exportTlogCmdCfg.EndTs != 0
+	if bySequence && byTimestamp {
+		return nil, errors.New("cannot limit an export by both timestamps and sequences")
+	}
+	if bySequence {
+		return decoder.NewLimitBySequence(exportTlogCmdCfg.StartSeq, exportTlogCmdCfg.EndSeq), nil
+	}
+	return decoder.NewLimitByTimestamp(exportTlogCmdCfg.StartTs, exportTlogCmdCfg.EndTs), nil
+}
+
+func init() {
+	ExportTlogCmd.Long = ExportTlogCmd.Short + `
+
+Replays the tlog of a vdisk, optionally limited by timestamps or sequences,
+without touching the (ARDB) storage of that vdisk,
+such that the vdisk can keep running while it is being exported.
+
+By default the replayed vdisk is exported as a backup (snapshot),
+in the same way as the "export vdisk" command does,
+such that it can be imported using the "import vdisk" command.
+See the "export vdisk" command for more information about the flags
+which define the backup storage, compression and encryption.
+
+  When the --image flag is given, the replayed vdisk is instead
+stored as a local (sparse) raw image at the given path,
+with the size of the vdisk, overwriting the file if it already existed.
+
+  Note that the exported vdisk only represents the vdisk in full,
+in case its tlog contains the entire history of the vdisk,
+meaning that none of its tlog aggregations were deleted by a retention policy.
+`
+
+	ExportTlogCmd.Flags().Var(
+		&vdiskCmdCfg.SourceConfig, "config",
+		"config resource: dialstrings (etcd cluster) or path (yaml file)")
+	ExportTlogCmd.Flags().StringVar(
+		&exportTlogCmdCfg.TlogPrivKey,
+		"tlog-priv-key", "12345678901234567890123456789012",
+		"32 bytes tlog private key")
+
+	ExportTlogCmd.Flags().Int64Var(
+		&exportTlogCmdCfg.StartTs,
+		"start-timestamp", 0,
+		"start UTC timestamp in nanosecond(default 0: since beginning)")
+	ExportTlogCmd.Flags().Int64Var(
+		&exportTlogCmdCfg.EndTs,
+		"end-timestamp", 0,
+		"end UTC timestamp in nanosecond(default 0: until the end)")
+	ExportTlogCmd.Flags().Uint64Var(
+		&exportTlogCmdCfg.StartSeq,
+		"start-sequence", 0,
+		"start sequence (default 0: since beginning)")
+	ExportTlogCmd.Flags().Uint64Var(
+		&exportTlogCmdCfg.EndSeq,
+		"end-sequence", 0,
+		"end sequence (default 0: until the end)")
+
+	ExportTlogCmd.Flags().StringVar(
+		&exportTlogCmdCfg.ImagePath,
+		"image", "",
+		"path of a local raw image to export to, instead of a backup")
+
+	ExportTlogCmd.Flags().Int64VarP(
+		&exportTlogCmdCfg.ExportBlockSize, "blocksize", "b", backup.DefaultBlockSize,
+		"the size of the exported (deduped) blocks")
+	ExportTlogCmd.Flags().VarP(
+		&vdiskCmdCfg.CompressionType, "compression", "c",
+		"the compression type to use, options { lz4, xz }")
+	ExportTlogCmd.Flags().VarP(
+		&vdiskCmdCfg.PrivateKey, "key", "k",
+		"an optional 32 byte fixed-size private key used for encryption when given")
+	ExportTlogCmd.Flags().IntVarP(
+		&vdiskCmdCfg.JobCount, "jobs", "j", runtime.NumCPU(),
+		"the amount of parallel jobs to run")
+
+	ExportTlogCmd.Flags().VarP(
+		&vdiskCmdCfg.BackupStorageConfig, "storage", "s",
+		"ftp/s3 server url or local dir path to export the backup to")
+
+	ExportTlogCmd.Flags().BoolVarP(
+		&vdiskCmdCfg.Force,
+		"force", "f", false,
+		"when given, overwrite a deduped map if it can't be loaded")
+
+	ExportTlogCmd.Flags().BoolVar(
+		&vdiskCmdCfg.TLSConfig.InsecureSkipVerify,
+		"tls-insecure", false,
+		"when given FTP over SSL will be used without cert verification")
+	ExportTlogCmd.Flags().StringVar(
+		&vdiskCmdCfg.TLSConfig.ServerName,
+		"tls-server", "",
+		"certs will be verified when given (required when --tls-insecure is not used)")
+	ExportTlogCmd.Flags().StringVar(
+		&vdiskCmdCfg.TLSConfig.CertFile,
+		"tls-cert", "",
+		"PEM-encoded file containing the TLS Client cert (FTPS will be used when given)")
+	ExportTlogCmd.Flags().StringVar(
+		&vdiskCmdCfg.TLSConfig.KeyFile,
+		"tls-key", "",
+		"PEM-encoded file containing the private TLS client key")
+	ExportTlogCmd.Flags().StringVar(
+		&vdiskCmdCfg.TLSConfig.CAFile,
+		"tls-ca", "",
+		"optional PEM-encoded file containing the TLS CA Pool (defaults to system pool when not given)")
+}
@@ -0,0 +1,100 @@
+package backup
+
+import (
+	"github.com/spf13/cobra"
+	"github.com/zero-os/0-Disk/log"
+	"github.com/zero-os/0-Disk/nbd/ardb/backup"
+
+	cmdconfig "github.com/zero-os/0-Disk/zeroctl/cmd/config"
+)
+
+// GCCmd represents the gc backup subcommand
+var GCCmd = &cobra.Command{
+	Use:   "backup",
+	Short: "Delete all deduped blocks of a backup storage which are no longer referenced",
+	RunE:  collectGarbage,
+}
+
+// gc only configuration
+// see `init` for more information
+// about the meaning of each config property.
+var gcCmdCfg struct {
+	DryRun bool
+}
+
+func collectGarbage(cmd *cobra.Command, args []string) error {
+	logLevel := log.InfoLevel
+	if cmdconfig.Verbose {
+		logLevel = log.DebugLevel
+	}
+	log.SetLevel(logLevel)
+
+	driver, err := backup.NewStorageDriver(createBackupStorageConfigFromFlags())
+	if err != nil {
+		return err
+	}
+	defer driver.Close()
+
+	return collectBackupGarbage(driver, gcCmdCfg.DryRun)
+}
+
+func init() {
+	GCCmd.Long = GCCmd.Short + `
+
+All headers stored in the backup storage are loaded,
+marking all deduped blocks referenced by the snapshot or tlog archive
+they belong to. Once all headers are loaded, all deduped blocks
+which weren't marked are deleted from the backup storage.
+When the --dry-run flag is given, the unreferenced deduped blocks
+are only counted, and not deleted.
+
+  All snapshots (and tlog archives) in the backup storage have to be readable,
+and thus all of them have to use the same crypto (private) key
+and compression type, as given using the --key and --compression flags.
+When that isn't the case, the collection is aborted
+without deleting any deduped block.
+
+  No snapshot should be exported to the same backup storage
+during the collection, as its deduped blocks might be deleted,
+prior to being referenced by its (not yet stored) header.
+
+See the "import vdisk" command for more information about the flags
+which define the backup storage, compression and encryption.
+`
+
+	GCCmd.Flags().BoolVar(
+		&gcCmdCfg.DryRun, "dry-run", false,
+		"only count the unreferenced deduped blocks, without deleting them")
+
+	GCCmd.Flags().VarP(
+		&vdiskCmdCfg.CompressionType, "compression", "c",
+		"the compression type used by the snapshots, options { lz4, xz }")
+	GCCmd.Flags().VarP(
+		&vdiskCmdCfg.PrivateKey, "key", "k",
+		"an optional 32 byte fixed-size private key used for decryption when given")
+
+	GCCmd.Flags().VarP(
+		&vdiskCmdCfg.BackupStorageConfig, "storage", "s",
+		"ftp/s3 server url or local dir path to collect the garbage from")
+
+	GCCmd.Flags().BoolVar(
+		&vdiskCmdCfg.TLSConfig.InsecureSkipVerify,
+		"tls-insecure", false,
+		"when given FTP over SSL will be used without cert verification")
+	GCCmd.Flags().StringVar(
+		&vdiskCmdCfg.TLSConfig.ServerName,
+		"tls-server", "",
+		"certs will be verified when given (required when --tls-insecure is not used)")
+	GCCmd.Flags().StringVar(
+		&vdiskCmdCfg.TLSConfig.CertFile,
+		"tls-cert", "",
+		"PEM-encoded file containing the TLS Client cert (FTPS will be used when given)")
+	GCCmd.Flags().StringVar(
+		&vdiskCmdCfg.TLSConfig.KeyFile,
+		"tls-key", "",
+		"PEM-encoded file containing the private TLS client key")
+	GCCmd.Flags().StringVar(
+		&vdiskCmdCfg.TLSConfig.CAFile,
+		"tls-ca", "",
+		"optional PEM-encoded file containing the TLS CA Pool (defaults to system pool when not given)")
+}
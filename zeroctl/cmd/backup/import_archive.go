@@ -0,0 +1,148 @@
+package backup
+
+import (
+	"context"
+
+	"github.com/spf13/cobra"
+	"github.com/zero-os/0-Disk/config"
+	"github.com/zero-os/0-Disk/errors"
+	"github.com/zero-os/0-Disk/log"
+	"github.com/zero-os/0-Disk/tlog/archive"
+
+	cmdconfig "github.com/zero-os/0-Disk/zeroctl/cmd/config"
+)
+
+// ImportArchiveCmd represents the tlog archive import subcommand
+var ImportArchiveCmd = &cobra.Command{
+	Use:   "archive vdiskid archiveID",
+	Short: "import an archive into the tlog of a vdisk",
+	RunE:  importArchive,
+}
+
+// import archive only configuration
+// see `init` for more information
+// about the meaning of each config property.
+var importArchiveCmdCfg struct {
+	TlogPrivKey string
+}
+
+func importArchive(cmd *cobra.Command, args []string) error {
+	logLevel := log.InfoLevel
+	if cmdconfig.Verbose {
+		logLevel = log.DebugLevel
+	}
+	log.SetLevel(logLevel)
+
+	// parse the position arguments
+	err := parseImportArchivePosArguments(args)
+	if err != nil {
+		return err
+	}
+
+	// create config source
+	cs, err := config.NewSource(vdiskCmdCfg.SourceConfig)
+	if err != nil {
+		return err
+	}
+	defer cs.Close()
+	configSource := config.NewOnceSource(cs)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	index, err := archive.Import(ctx, configSource, archive.Config{
+		VdiskID:                  vdiskCmdCfg.VdiskID,
+		ArchiveID:                vdiskCmdCfg.SnapshotID,
+		PrivKey:                  importArchiveCmdCfg.TlogPrivKey,
+		BackupStoragDriverConfig: createBackupStorageConfigFromFlags(),
+		CompressionType:          vdiskCmdCfg.CompressionType,
+		CryptoKey:                vdiskCmdCfg.PrivateKey,
+	})
+	if err != nil {
+		return err
+	}
+
+	log.Infof("imported %d aggregations (sequence %d-%d) of vdisk %s into vdisk %s",
+		index.Aggregations, index.FirstSequence, index.LastSequence,
+		index.VdiskID, vdiskCmdCfg.VdiskID)
+	return nil
+}
+
+func parseImportArchivePosArguments(args []string) error {
+	// validate pos arg length
+	argn := len(args)
+	if argn < 2 {
+		return errors.New("not enough arguments")
+	} else if argn > 2 {
+		return errors.New("too many arguments")
+	}
+
+	vdiskCmdCfg.VdiskID = args[0]
+	vdiskCmdCfg.SnapshotID = args[1]
+
+	return nil
+}
+
+func init() {
+	ImportArchiveCmd.Long = ImportArchiveCmd.Short + `
+
+Rebuilds the tlog of a vdisk from an archive,
+created using the "export archive" command,
+storing all its aggregations, in order, into the 0-stor cluster of that vdisk.
+The vdisk can be another vdisk than the one the archive was exported from,
+but is required to have an empty tlog.
+Only the tlog is imported, the (ARDB) storage of the vdisk is left untouched,
+the imported history can for example be replayed using the "export tlog" command.
+
+Remember to use the same archive name,
+crypto (private) key and the compression type,
+as you used while exporting the archive in question.
+
+  If an error occured during the import process,
+aggregations might already have been stored into the tlog of the vdisk.
+Deleting the vdisk in such a scenario will help with this problem.
+
+See the "import vdisk" command for more information about the flags
+which define the backup storage, compression and encryption.
+`
+
+	ImportArchiveCmd.Flags().Var(
+		&vdiskCmdCfg.SourceConfig, "config",
+		"config resource: dialstrings (etcd cluster) or path (yaml file)")
+	ImportArchiveCmd.Flags().StringVar(
+		&importArchiveCmdCfg.TlogPrivKey,
+		"tlog-priv-key", "12345678901234567890123456789012",
+		"32 bytes tlog private key")
+
+	ImportArchiveCmd.Flags().VarP(
+		&vdiskCmdCfg.CompressionType, "compression", "c",
+		"the compression type to use, options { lz4, xz }")
+	ImportArchiveCmd.Flags().VarP(
+		&vdiskCmdCfg.PrivateKey, "key", "k",
+		"an optional 32 byte fixed-size private key used for decryption when given")
+
+	ImportArchiveCmd.Flags().VarP(
+		&vdiskCmdCfg.BackupStorageConfig, "storage", "s",
+		"ftp/s3 server url or local dir path to import the archive from")
+
+	ImportArchiveCmd.Flags().BoolVar(
+		&vdiskCmdCfg.TLSConfig.InsecureSkipVerify,
+		"tls-insecure", false,
+		"when given FTP over SSL will be used without cert verification")
+	ImportArchiveCmd.Flags().StringVar(
+		&vdiskCmdCfg.TLSConfig.ServerName,
+		"tls-server", "",
+		"certs will be verified when given (required when --tls-insecure is not used)")
+	ImportArchiveCmd.Flags().StringVar(
+		&vdiskCmdCfg.TLSConfig.CertFile,
+		"tls-cert", "",
+		"PEM-encoded file containing the TLS Client cert (FTPS will be used when given)")
+	ImportArchiveCmd.Flags().StringVar(
+		&vdiskCmdCfg.TLSConfig.KeyFile,
+		"tls-key", "",
+		"PEM-encoded file containing the private TLS client key")
+	ImportArchiveCmd.Flags().StringVar(
+		&vdiskCmdCfg.TLSConfig.CAFile,
+		"tls-ca", "",
+		"optional PEM-encoded file containing the TLS CA Pool (defaults to system pool when not given)")
+}
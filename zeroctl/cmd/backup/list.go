@@ -92,7 +92,7 @@ When the configured server does not support FTPS an error will be returned.
 
 	ListSnapshotsCmd.Flags().VarP(
 		&vdiskCmdCfg.BackupStorageConfig, "storage", "s",
-		"ftp server url or local dir path to list the snapshots from")
+		"ftp/s3 server url or local dir path to list the snapshots from")
 
 	ListSnapshotsCmd.Flags().StringVar(
 		&listSnapshotsCmdCfg.NameRegexp, "name", "",
@@ -0,0 +1,167 @@
+package backup
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/zero-os/0-Disk/errors"
+	"github.com/zero-os/0-Disk/log"
+	"github.com/zero-os/0-Disk/nbd/ardb/backup"
+
+	cmdconfig "github.com/zero-os/0-Disk/zeroctl/cmd/config"
+)
+
+// PruneSnapshotsCmd represents the prune-snapshots subcommand
+var PruneSnapshotsCmd = &cobra.Command{
+	Use:   "snapshots vdiskid",
+	Short: "delete the snapshots of a vdisk which aren't kept by a retention policy",
+	RunE:  pruneSnapshots,
+}
+
+// prune only configuration
+// see `init` for more information
+// about the meaning of each config property.
+var pruneSnapshotsCmdCfg struct {
+	Policy     backup.RetentionPolicy
+	DryRun     bool
+	KeepBlocks bool
+}
+
+func pruneSnapshots(cmd *cobra.Command, args []string) error {
+	logLevel := log.InfoLevel
+	if cmdconfig.Verbose {
+		logLevel = log.DebugLevel
+	}
+	log.SetLevel(logLevel)
+
+	// parse the position arguments
+	err := parsePrunePosArguments(args)
+	if err != nil {
+		return err
+	}
+
+	driver, err := backup.NewStorageDriver(createBackupStorageConfigFromFlags())
+	if err != nil {
+		return err
+	}
+	defer driver.Close()
+
+	result, err := backup.ApplyRetentionPolicy(
+		vdiskCmdCfg.VdiskID, pruneSnapshotsCmdCfg.Policy, driver,
+		&vdiskCmdCfg.PrivateKey, vdiskCmdCfg.CompressionType,
+		pruneSnapshotsCmdCfg.DryRun)
+	if err != nil {
+		return err
+	}
+
+	for _, snapshotID := range result.Kept {
+		fmt.Printf("keep %s\n", snapshotID)
+	}
+	for _, snapshotID := range result.Deleted {
+		fmt.Printf("delete %s\n", snapshotID)
+	}
+
+	// no deduped blocks can become unreferenced
+	// in case no snapshot was deleted
+	if pruneSnapshotsCmdCfg.DryRun || pruneSnapshotsCmdCfg.KeepBlocks || len(result.Deleted) == 0 {
+		return nil
+	}
+
+	// delete all deduped blocks which are no longer referenced
+	return collectBackupGarbage(driver, false)
+}
+
+func parsePrunePosArguments(args []string) error {
+	// validate pos arg length
+	argn := len(args)
+	if argn < 1 {
+		return errors.New("not enough arguments")
+	} else if argn > 1 {
+		return errors.New("too many arguments")
+	}
+
+	vdiskCmdCfg.VdiskID = args[0]
+	return nil
+}
+
+func init() {
+	PruneSnapshotsCmd.Long = PruneSnapshotsCmd.Short + `
+
+Applies a retention policy to all snapshots of a vdisk,
+deleting all snapshots of that vdisk which aren't kept by any of the
+--keep-last, --keep-daily and --keep-weekly rules.
+The snapshots of a vdisk are ordered using their creation time,
+and for each day (or ISO week) which has at least one snapshot,
+only the most recent snapshot is kept by the daily (or weekly) rule.
+Snapshots without a creation time are always kept.
+
+  Keeping the last 7 snapshots, as well as one snapshot per day
+for the last 30 days and one snapshot per week for the last year,
+can for example be done as follows:
+
+	zeroctl prune snapshots foo --keep-last 7 --keep-daily 30 --keep-weekly 52
+
+  The snapshots which are kept (and deleted) are printed to the STDOUT,
+when the --dry-run flag is given no snapshots are deleted.
+Once the snapshots are deleted, all deduped blocks
+which are no longer referenced by any snapshot or tlog archive
+are deleted from the backup storage as well,
+unless the --keep-blocks flag is given.
+See the "delete snapshot" command for more information about that process.
+
+  Only snapshots which can be read using the given crypto (private) key
+and compression type are considered.
+
+See the "import vdisk" command for more information about the flags
+which define the backup storage, compression and encryption.
+`
+
+	PruneSnapshotsCmd.Flags().IntVar(
+		&pruneSnapshotsCmdCfg.Policy.KeepLast, "keep-last", 0,
+		"amount of most recent snapshots to keep")
+	PruneSnapshotsCmd.Flags().IntVar(
+		&pruneSnapshotsCmdCfg.Policy.KeepDaily, "keep-daily", 0,
+		"amount of days for which to keep the most recent snapshot")
+	PruneSnapshotsCmd.Flags().IntVar(
+		&pruneSnapshotsCmdCfg.Policy.KeepWeekly, "keep-weekly", 0,
+		"amount of weeks for which to keep the most recent snapshot")
+
+	PruneSnapshotsCmd.Flags().BoolVar(
+		&pruneSnapshotsCmdCfg.DryRun, "dry-run", false,
+		"only list the snapshots which would be deleted, without deleting anything")
+	PruneSnapshotsCmd.Flags().BoolVar(
+		&pruneSnapshotsCmdCfg.KeepBlocks, "keep-blocks", false,
+		"when given the deduped blocks which are no longer referenced will not be deleted")
+
+	PruneSnapshotsCmd.Flags().VarP(
+		&vdiskCmdCfg.CompressionType, "compression", "c",
+		"the compression type used by the snapshots, options { lz4, xz }")
+	PruneSnapshotsCmd.Flags().VarP(
+		&vdiskCmdCfg.PrivateKey, "key", "k",
+		"an optional 32 byte fixed-size private key used for decryption when given")
+
+	PruneSnapshotsCmd.Flags().VarP(
+		&vdiskCmdCfg.BackupStorageConfig, "storage", "s",
+		"ftp/s3 server url or local dir path to prune the snapshots from")
+
+	PruneSnapshotsCmd.Flags().BoolVar(
+		&vdiskCmdCfg.TLSConfig.InsecureSkipVerify,
+		"tls-insecure", false,
+		"when given FTP over SSL will be used without cert verification")
+	PruneSnapshotsCmd.Flags().StringVar(
+		&vdiskCmdCfg.TLSConfig.ServerName,
+		"tls-server", "",
+		"certs will be verified when given (required when --tls-insecure is not used)")
+	PruneSnapshotsCmd.Flags().StringVar(
+		&vdiskCmdCfg.TLSConfig.CertFile,
+		"tls-cert", "",
+		"PEM-encoded file containing the TLS Client cert (FTPS will be used when given)")
+	PruneSnapshotsCmd.Flags().StringVar(
+		&vdiskCmdCfg.TLSConfig.KeyFile,
+		"tls-key", "",
+		"PEM-encoded file containing the private TLS client key")
+	PruneSnapshotsCmd.Flags().StringVar(
+		&vdiskCmdCfg.TLSConfig.CAFile,
+		"tls-ca", "",
+		"optional PEM-encoded file containing the TLS CA Pool (defaults to system pool when not given)")
+}
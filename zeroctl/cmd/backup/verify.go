@@ -0,0 +1,138 @@
+package backup
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/zero-os/0-Disk/errors"
+	"github.com/zero-os/0-Disk/log"
+	"github.com/zero-os/0-Disk/nbd/ardb/backup"
+
+	cmdconfig "github.com/zero-os/0-Disk/zeroctl/cmd/config"
+)
+
+// VerifySnapshotCmd represents the verify snapshot subcommand
+var VerifySnapshotCmd = &cobra.Command{
+	Use:   "snapshot snapshotID",
+	Short: "Verify the integrity of a snapshot, without importing it",
+	RunE:  verifySnapshot,
+}
+
+func verifySnapshot(cmd *cobra.Command, args []string) error {
+	logLevel := log.ErrorLevel
+	if cmdconfig.Verbose {
+		logLevel = log.DebugLevel
+	}
+	log.SetLevel(logLevel)
+
+	// parse the position arguments
+	argn := len(args)
+	if argn < 1 {
+		return errors.New("not enough arguments")
+	} else if argn > 1 {
+		return errors.New("too many arguments")
+	}
+	vdiskCmdCfg.SnapshotID = args[0]
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	result, err := backup.Verify(ctx, backup.VerifyConfig{
+		SnapshotID:               vdiskCmdCfg.SnapshotID,
+		BackupStoragDriverConfig: createBackupStorageConfigFromFlags(),
+		JobCount:                 vdiskCmdCfg.JobCount,
+		CompressionType:          vdiskCmdCfg.CompressionType,
+		CryptoKey:                vdiskCmdCfg.PrivateKey,
+	})
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("block size: %d\n", result.BlockSize)
+	fmt.Printf("blocks: %d\n", result.Blocks)
+	fmt.Printf("deduped blocks: %d\n", result.DedupedBlocks)
+	for _, block := range result.MissingBlocks {
+		fmt.Printf("missing deduped block %x: vdisk offsets %s\n",
+			block.Hash, formatOffsets(block.Offsets(result.BlockSize)))
+	}
+	for _, block := range result.CorruptBlocks {
+		fmt.Printf("corrupt deduped block %x (%v): vdisk offsets %s\n",
+			block.Hash, block.Err, formatOffsets(block.Offsets(result.BlockSize)))
+	}
+
+	if !result.Valid() {
+		return errors.Newf(
+			"snapshot %s is invalid: %d missing and %d corrupt deduped blocks",
+			vdiskCmdCfg.SnapshotID, len(result.MissingBlocks), len(result.CorruptBlocks))
+	}
+	return nil
+}
+
+func formatOffsets(offsets []int64) string {
+	strs := make([]string, len(offsets))
+	for i, offset := range offsets {
+		strs[i] = fmt.Sprint(offset)
+	}
+	return strings.Join(strs, ", ")
+}
+
+func init() {
+	VerifySnapshotCmd.Long = VerifySnapshotCmd.Short + `
+
+The header of the snapshot is loaded, after which every deduped block
+referenced by it is fetched from the backup storage,
+decrypted (if needed) and decompressed, and its hash is recomputed
+and compared to the hash stored in the header of the snapshot.
+
+All missing and corrupt deduped blocks are printed,
+together with the vdisk offsets (in bytes) of the data they affect,
+each offset covering exactly one (snapshot) block size of data.
+In that case the command exits with a non-zero exit code.
+A valid snapshot means that the vdisk can be imported from that snapshot.
+
+Remember to use the same (snapshot) name,
+crypto (private) key and the compression type,
+as you used while exporting the backup in question.
+
+See the "import vdisk" command for more information about the flags
+which define the backup storage, compression and encryption.
+`
+
+	VerifySnapshotCmd.Flags().VarP(
+		&vdiskCmdCfg.CompressionType, "compression", "c",
+		"the compression type used by the snapshot, options { lz4, xz }")
+	VerifySnapshotCmd.Flags().VarP(
+		&vdiskCmdCfg.PrivateKey, "key", "k",
+		"an optional 32 byte fixed-size private key used for decryption when given")
+	VerifySnapshotCmd.Flags().IntVarP(
+		&vdiskCmdCfg.JobCount, "jobs", "j", runtime.NumCPU(),
+		"the amount of parallel jobs to run")
+
+	VerifySnapshotCmd.Flags().VarP(
+		&vdiskCmdCfg.BackupStorageConfig, "storage", "s",
+		"ftp/s3 server url or local dir path to read the snapshot from")
+
+	VerifySnapshotCmd.Flags().BoolVar(
+		&vdiskCmdCfg.TLSConfig.InsecureSkipVerify,
+		"tls-insecure", false,
+		"when given FTP over SSL will be used without cert verification")
+	VerifySnapshotCmd.Flags().StringVar(
+		&vdiskCmdCfg.TLSConfig.ServerName,
+		"tls-server", "",
+		"certs will be verified when given (required when --tls-insecure is not used)")
+	VerifySnapshotCmd.Flags().StringVar(
+		&vdiskCmdCfg.TLSConfig.CertFile,
+		"tls-cert", "",
+		"PEM-encoded file containing the TLS Client cert (FTPS will be used when given)")
+	VerifySnapshotCmd.Flags().StringVar(
+		&vdiskCmdCfg.TLSConfig.KeyFile,
+		"tls-key", "",
+		"PEM-encoded file containing the private TLS client key")
+	VerifySnapshotCmd.Flags().StringVar(
+		&vdiskCmdCfg.TLSConfig.CAFile,
+		"tls-ca", "",
+		"optional PEM-encoded file containing the TLS CA Pool (defaults to system pool when not given)")
+}
@@ -0,0 +1,18 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+	"github.com/zero-os/0-Disk/zeroctl/cmd/compact"
+)
+
+// CompactCmd represents the compact subcommand
+var CompactCmd = &cobra.Command{
+	Use:   "compact",
+	Short: "Compact the history of a zero-os resource",
+}
+
+func init() {
+	CompactCmd.AddCommand(
+		compact.TlogCmd,
+	)
+}
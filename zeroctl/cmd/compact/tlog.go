@@ -0,0 +1,120 @@
+package compact
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	zerodiskcfg "github.com/zero-os/0-Disk/config"
+	"github.com/zero-os/0-Disk/errors"
+	"github.com/zero-os/0-Disk/log"
+	"github.com/zero-os/0-Disk/tlog"
+	"github.com/zero-os/0-Disk/tlog/stor"
+	"github.com/zero-os/0-Disk/zeroctl/cmd/config"
+)
+
+var tlogCmdCfg struct {
+	SourceConfig zerodiskcfg.SourceConfig
+	TlogPrivKey  string
+	Sequence     uint64
+}
+
+// TlogCmd represents the compact tlog subcommand
+var TlogCmd = &cobra.Command{
+	Use:   "tlog vdiskID",
+	Short: "Compact the tlog history of a vdisk into a checkpoint",
+	RunE:  compactTlog,
+}
+
+func compactTlog(cmd *cobra.Command, args []string) error {
+	logLevel := log.InfoLevel
+	if config.Verbose {
+		logLevel = log.DebugLevel
+	}
+	log.SetLevel(logLevel)
+
+	// get command line argument
+	argn := len(args)
+	if argn < 1 {
+		return errors.New("no vdisk identifier given")
+	}
+	if argn > 1 {
+		return errors.New("too many vdisk identifiers given")
+	}
+	vdiskID := args[0]
+
+	// create config source
+	source, err := zerodiskcfg.NewSource(tlogCmdCfg.SourceConfig)
+	if err != nil {
+		return err
+	}
+	defer source.Close()
+
+	hasTlog, err := tlog.HasTlogCluster(source, vdiskID)
+	if err != nil {
+		return errors.Wrapf(err, "failed to read config for vdisk %s", vdiskID)
+	}
+	if !hasTlog {
+		return errors.Newf("vdisk %s has no tlog cluster configured", vdiskID)
+	}
+
+	// an active tlog server would overwrite the relinked history
+	// with the start and end of the history it cached
+	addr, err := tlog.ActiveTlogServer(source, vdiskID)
+	if err != nil {
+		return err
+	}
+	if addr != "" {
+		return errors.Newf(
+			"can't compact the tlog history of vdisk %s, as its tlog server %s is active",
+			vdiskID, addr)
+	}
+
+	storCli, err := stor.NewClientFromConfigSource(source, vdiskID, tlogCmdCfg.TlogPrivKey)
+	if err != nil {
+		return errors.Wrapf(err, "failed to create 0-stor client for vdisk %s", vdiskID)
+	}
+	defer storCli.Close()
+
+	result, err := storCli.Compact(tlogCmdCfg.Sequence)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("compacted aggregations: %d\n", result.Aggregations)
+	fmt.Printf("checkpoint aggregations: %d\n", result.CheckpointAggregations)
+	fmt.Printf("checkpoint blocks: %d\n", result.CheckpointBlocks)
+	return nil
+}
+
+func init() {
+	TlogCmd.Long = TlogCmd.Short + `
+
+Collapses all tlog aggregations of a vdisk, which only contain
+sequences lower than the given sequence, into a checkpoint.
+This checkpoint only contains the latest transaction of each block,
+such that restoring the vdisk no longer replays every overwrite of a block.
+When no sequence is given, all aggregations but the last one are compacted.
+Some examples:
+
+  	zeroctl compact tlog myVdisk
+  	zeroctl compact tlog myVdisk --sequence 1000000
+
+NOTE: once compacted, the vdisk can no longer be restored
+  to a point in time covered by the checkpoint.
+
+NOTE: the tlog history is only compacted while none of the tlog servers
+  of the vdisk are active, as they would overwrite the compacted history.
+`
+
+	TlogCmd.Flags().Var(
+		&tlogCmdCfg.SourceConfig, "config",
+		"config resource: dialstrings (etcd cluster) or path (yaml file)")
+	TlogCmd.Flags().StringVar(
+		&tlogCmdCfg.TlogPrivKey,
+		"tlog-priv-key", "12345678901234567890123456789012",
+		"32 bytes tlog private key")
+	TlogCmd.Flags().Uint64Var(
+		&tlogCmdCfg.Sequence,
+		"sequence", 0,
+		"compact all aggregations with sequences lower than this sequence (default 0: all but the last aggregation)")
+}
@@ -68,6 +68,9 @@ func copyVdisk(cmd *cobra.Command, args []string) error {
 	if err != nil {
 		return err
 	}
+	if !srcStaticCfg.Type.Persistent() {
+		return errors.Wrapf(storage.ErrVdiskNotPersistent, "cannot copy vdisk %s", sourceVdiskID)
+	}
 	srcNBDConfig, err := config.ReadVdiskNBDConfig(configSource, sourceVdiskID)
 	if err != nil {
 		return err
@@ -86,6 +89,9 @@ func copyVdisk(cmd *cobra.Command, args []string) error {
 	if err != nil {
 		return err
 	}
+	if !dstStaticConfig.Type.Persistent() {
+		return errors.Wrapf(storage.ErrVdiskNotPersistent, "cannot copy to vdisk %s", targetVdiskID)
+	}
 	dstNBDConfig, err := config.ReadVdiskNBDConfig(configSource, targetVdiskID)
 	if err != nil {
 		return err
@@ -2,6 +2,7 @@ package cmd
 
 import (
 	"github.com/spf13/cobra"
+	"github.com/zero-os/0-Disk/zeroctl/cmd/backup"
 	"github.com/zero-os/0-Disk/zeroctl/cmd/delvdisk"
 )
 
@@ -14,5 +15,6 @@ var DeleteCmd = &cobra.Command{
 func init() {
 	DeleteCmd.AddCommand(
 		delvdisk.VdiskCmd,
+		backup.DeleteSnapshotCmd,
 	)
 }
@@ -3,6 +3,7 @@ package cmd
 import (
 	"github.com/spf13/cobra"
 	"github.com/zero-os/0-Disk/zeroctl/cmd/backup"
+	"github.com/zero-os/0-Disk/zeroctl/cmd/describe"
 )
 
 // DescribeCmd represents the describe subcommand
@@ -14,5 +15,6 @@ var DescribeCmd = &cobra.Command{
 func init() {
 	DescribeCmd.AddCommand(
 		backup.DescribeSnapshotCmd,
+		describe.TlogCmd,
 	)
 }
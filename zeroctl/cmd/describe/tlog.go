@@ -0,0 +1,312 @@
+package describe
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+	zerodiskcfg "github.com/zero-os/0-Disk/config"
+	"github.com/zero-os/0-Disk/errors"
+	"github.com/zero-os/0-Disk/log"
+	"github.com/zero-os/0-Disk/tlog"
+	"github.com/zero-os/0-Disk/tlog/schema"
+	"github.com/zero-os/0-Disk/tlog/stor"
+	"github.com/zero-os/0-Disk/zeroctl/cmd/config"
+)
+
+var tlogCmdCfg struct {
+	SourceConfig zerodiskcfg.SourceConfig
+	TlogPrivKey  string
+	Index        int64
+	PrettyPrint  bool
+}
+
+// TlogCmd represents the describe tlog subcommand
+var TlogCmd = &cobra.Command{
+	Use:   "tlog vdiskID",
+	Short: "Describe the tlog history of a vdisk",
+	RunE:  describeTlog,
+}
+
+func describeTlog(cmd *cobra.Command, args []string) error {
+	logLevel := log.ErrorLevel
+	if config.Verbose {
+		logLevel = log.DebugLevel
+	}
+	log.SetLevel(logLevel)
+
+	// get command line argument
+	argn := len(args)
+	if argn < 1 {
+		return errors.New("no vdisk identifier given")
+	}
+	if argn > 1 {
+		return errors.New("too many vdisk identifiers given")
+	}
+	vdiskID := args[0]
+
+	// create config source
+	source, err := zerodiskcfg.NewSource(tlogCmdCfg.SourceConfig)
+	if err != nil {
+		return err
+	}
+	defer source.Close()
+
+	hasTlog, err := tlog.HasTlogCluster(source, vdiskID)
+	if err != nil {
+		return errors.Wrapf(err, "failed to read config for vdisk %s", vdiskID)
+	}
+	if !hasTlog {
+		return errors.Newf("vdisk %s has no tlog cluster configured", vdiskID)
+	}
+
+	storCli, err := stor.NewClientFromConfigSource(source, vdiskID, tlogCmdCfg.TlogPrivKey)
+	if err != nil {
+		return errors.Wrapf(err, "failed to create 0-stor client for vdisk %s", vdiskID)
+	}
+	defer storCli.Close()
+
+	var info interface{}
+	var brokenChain bool
+	if cmd.Flags().Changed("index") {
+		history, err := describeBlockHistory(storCli, vdiskID, tlogCmdCfg.Index)
+		if err != nil {
+			return err
+		}
+		info = history
+	} else {
+		tlogInfo, err := describeAggregations(storCli, vdiskID)
+		if err != nil {
+			return err
+		}
+		info, brokenChain = tlogInfo, !tlogInfo.ValidChain
+	}
+
+	var output []byte
+	if tlogCmdCfg.PrettyPrint {
+		output, err = json.MarshalIndent(info, "", "  \t")
+	} else {
+		output, err = json.Marshal(info)
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(output))
+
+	if brokenChain {
+		return errors.Newf("tlog chain of vdisk %s is broken", vdiskID)
+	}
+	return nil
+}
+
+// TlogInfo describes the tlog history of a vdisk.
+type TlogInfo struct {
+	VdiskID      string            `json:"vdiskID"`
+	Aggregations []AggregationInfo `json:"aggregations"`
+	ValidChain   bool              `json:"validChain"`
+	ChainErrors  []string          `json:"chainErrors,omitempty"`
+}
+
+// AggregationInfo describes a single tlog aggregation.
+type AggregationInfo struct {
+	Key           string `json:"key"`
+	Previous      string `json:"previous,omitempty"`
+	Timestamp     int64  `json:"timestamp"`
+	Time          string `json:"time"`
+	FirstSequence uint64 `json:"firstSequence"`
+	LastSequence  uint64 `json:"lastSequence"`
+	Blocks        int    `json:"blocks"`
+	Checkpoint    bool   `json:"checkpoint,omitempty"`
+}
+
+// BlockHistory describes all transactions stored in the tlog for a single block index.
+type BlockHistory struct {
+	VdiskID      string            `json:"vdiskID"`
+	Index        int64             `json:"index"`
+	Transactions []TransactionInfo `json:"transactions"`
+}
+
+// TransactionInfo describes a single transaction of a block.
+type TransactionInfo struct {
+	Sequence  uint64 `json:"sequence"`
+	Timestamp int64  `json:"timestamp"`
+	Time      string `json:"time"`
+	Operation string `json:"operation"`
+	Hash      string `json:"hash,omitempty"`
+}
+
+// describeAggregations walks over all aggregations of a vdisk,
+// describing each of them, while validating the chain they form.
+func describeAggregations(storCli *stor.Client, vdiskID string) (*TlogInfo, error) {
+	info := &TlogInfo{
+		VdiskID:      vdiskID,
+		Aggregations: []AggregationInfo{},
+	}
+
+	var (
+		prevKey []byte
+		lastSeq uint64
+	)
+	for wr := range storCli.Walk(0, tlog.TimeNowTimestamp()) {
+		if wr.Err != nil {
+			// the remaining part of the chain can't be reached
+			info.ChainErrors = append(info.ChainErrors, fmt.Sprintf(
+				"failed to walk past aggregation %x: %v", prevKey, wr.Err))
+			break
+		}
+
+		blocks, err := wr.Agg.Blocks()
+		if err != nil {
+			return nil, err
+		}
+		// the block list can be bigger than the actual amount of blocks
+		size := int(wr.Agg.Size())
+
+		timestamp := wr.Agg.Timestamp()
+		agg := AggregationInfo{
+			Key:        hex.EncodeToString(wr.StorKey),
+			Previous:   hex.EncodeToString(wr.Meta.Previous),
+			Timestamp:  timestamp,
+			Time:       formatTimestamp(timestamp),
+			Blocks:     size,
+			Checkpoint: tlog.IsCheckpoint(wr.Agg),
+		}
+		if size > 0 {
+			agg.FirstSequence = blocks.At(0).Sequence()
+			agg.LastSequence = blocks.At(size - 1).Sequence()
+		}
+
+		// validate the link to the previous aggregation
+		if !bytes.Equal(wr.Meta.Previous, prevKey) {
+			info.ChainErrors = append(info.ChainErrors, fmt.Sprintf(
+				"aggregation %s links to %s, while it follows %x",
+				agg.Key, agg.Previous, prevKey))
+		}
+		// validate that sequences only increase
+		if size > 0 {
+			if agg.FirstSequence <= lastSeq {
+				info.ChainErrors = append(info.ChainErrors, fmt.Sprintf(
+					"aggregation %s starts at sequence %d, while sequence %d was already stored",
+					agg.Key, agg.FirstSequence, lastSeq))
+			}
+			lastSeq = agg.LastSequence
+		}
+
+		info.Aggregations = append(info.Aggregations, agg)
+		prevKey = wr.StorKey
+	}
+
+	info.ValidChain = len(info.ChainErrors) == 0
+	return info, nil
+}
+
+// describeBlockHistory walks over all aggregations of a vdisk,
+// describing all transactions of the given block index.
+func describeBlockHistory(storCli *stor.Client, vdiskID string, index int64) (*BlockHistory, error) {
+	history := &BlockHistory{
+		VdiskID:      vdiskID,
+		Index:        index,
+		Transactions: []TransactionInfo{},
+	}
+
+	for wr := range storCli.Walk(0, tlog.TimeNowTimestamp()) {
+		if wr.Err != nil {
+			return nil, wr.Err
+		}
+
+		blocks, err := wr.Agg.Blocks()
+		if err != nil {
+			return nil, err
+		}
+		for i := 0; i < int(wr.Agg.Size()); i++ {
+			block := blocks.At(i)
+			if block.Index() != index {
+				continue
+			}
+
+			hash, err := block.Hash()
+			if err != nil {
+				return nil, err
+			}
+			timestamp := block.Timestamp()
+			history.Transactions = append(history.Transactions, TransactionInfo{
+				Sequence:  block.Sequence(),
+				Timestamp: timestamp,
+				Time:      formatTimestamp(timestamp),
+				Operation: operationString(block.Operation()),
+				Hash:      hex.EncodeToString(hash),
+			})
+		}
+	}
+
+	return history, nil
+}
+
+// formatTimestamp formats a UTC timestamp in nanoseconds,
+// as a date+time in format RFC3339
+func formatTimestamp(timestamp int64) string {
+	return time.Unix(0, timestamp).UTC().Format(time.RFC3339Nano)
+}
+
+// operationString returns the name of a block operation
+func operationString(op uint8) string {
+	switch op {
+	case schema.OpSet:
+		return "set"
+	case schema.OpDelete:
+		return "delete"
+	default:
+		return fmt.Sprintf("unknown (%d)", op)
+	}
+}
+
+func init() {
+	TlogCmd.Long = TlogCmd.Short + `
+
+The tlog history will be described in JSON format and written to the STDOUT.
+By default all aggregations are described, in the order they were stored,
+with following properties:
+
++ "key": the 0-stor key of the aggregation;
++ "previous": the 0-stor key of the previous aggregation it links to;
++ "timestamp": UTC timestamp (in nanoseconds) of the aggregation;
++ "time": the timestamp as a date+time in format RFC3339;
++ "firstSequence"/"lastSequence": the sequence range of the aggregation;
++ "blocks": the amount of blocks stored in the aggregation;
++ "checkpoint": true if the aggregation is part of a compacted checkpoint;
+
+While walking the aggregations, their chain is validated,
+meaning that each aggregation has to link to the aggregation before it,
+and its sequences have to be higher than the sequences before it.
+If the chain is broken, the errors are listed as "chainErrors"
+and the command exits with a non-zero exit code.
+
+When an index is given, the full history of that block is described instead,
+listing the sequence, timestamp, operation and data hash of each of its transactions.
+Some examples:
+
+  	zeroctl describe tlog myVdisk --pretty
+  	zeroctl describe tlog myVdisk --index 42
+
+The described timestamps and sequences can be used
+to pick the point in time to restore a vdisk to.
+`
+
+	TlogCmd.Flags().Var(
+		&tlogCmdCfg.SourceConfig, "config",
+		"config resource: dialstrings (etcd cluster) or path (yaml file)")
+	TlogCmd.Flags().StringVar(
+		&tlogCmdCfg.TlogPrivKey,
+		"tlog-priv-key", "12345678901234567890123456789012",
+		"32 bytes tlog private key")
+	TlogCmd.Flags().Int64Var(
+		&tlogCmdCfg.Index,
+		"index", 0,
+		"describe the history of the block with this index, instead of the aggregations")
+	TlogCmd.Flags().BoolVar(
+		&tlogCmdCfg.PrettyPrint, "pretty", false,
+		"pretty print output when this flag is specified")
+}
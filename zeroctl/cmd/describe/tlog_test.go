@@ -0,0 +1,208 @@
+package describe
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zero-os/0-stor/client/meta/embedserver"
+
+	"github.com/zero-os/0-Disk"
+	"github.com/zero-os/0-Disk/tlog"
+	"github.com/zero-os/0-Disk/tlog/schema"
+	"github.com/zero-os/0-Disk/tlog/stor"
+	"github.com/zero-os/0-Disk/tlog/stor/embeddedserver"
+)
+
+const (
+	testDataShards   = 4
+	testParityShards = 2
+)
+
+func TestDescribeAggregations(t *testing.T) {
+	mdServer, err := embedserver.New()
+	require.NoError(t, err)
+	defer mdServer.Stop()
+
+	storCluster, err := embeddedserver.NewZeroStorCluster(testDataShards + testParityShards)
+	require.NoError(t, err)
+	defer storCluster.Close()
+
+	// no aggregations is a valid chain
+	cli := createTestClient(t, "empty", mdServer.ListenAddr(), storCluster.Addrs())
+	info, err := describeAggregations(cli, "empty")
+	require.NoError(t, err)
+	assert.Equal(t, "empty", info.VdiskID)
+	assert.Empty(t, info.Aggregations)
+	assert.True(t, info.ValidChain)
+	assert.Empty(t, info.ChainErrors)
+	require.NoError(t, cli.Close())
+
+	// a valid chain
+	cli = createTestClient(t, "valid", mdServer.ListenAddr(), storCluster.Addrs())
+	storeTestAggregation(t, cli, testTransaction(1, 0), testTransaction(2, 1), testTransaction(3, 2))
+	storeTestAggregation(t, cli, testTransaction(4, 0), testTransaction(5, 1))
+	storeTestAggregation(t, cli, testTransaction(6, 2))
+	info, err = describeAggregations(cli, "valid")
+	require.NoError(t, err)
+	assert.True(t, info.ValidChain)
+	assert.Empty(t, info.ChainErrors)
+	if assert.Len(t, info.Aggregations, 3) {
+		expected := []struct {
+			firstSeq, lastSeq uint64
+			blocks            int
+		}{
+			{1, 3, 3},
+			{4, 5, 2},
+			{6, 6, 1},
+		}
+		var prevKey string
+		for i, agg := range info.Aggregations {
+			assert.NotEmpty(t, agg.Key, "aggregation %d", i)
+			assert.Equal(t, prevKey, agg.Previous, "aggregation %d", i)
+			assert.Equal(t, expected[i].firstSeq, agg.FirstSequence, "aggregation %d", i)
+			assert.Equal(t, expected[i].lastSeq, agg.LastSequence, "aggregation %d", i)
+			assert.Equal(t, expected[i].blocks, agg.Blocks, "aggregation %d", i)
+			assert.Equal(t, formatTimestamp(agg.Timestamp), agg.Time, "aggregation %d", i)
+			assert.False(t, agg.Checkpoint, "aggregation %d", i)
+			prevKey = agg.Key
+		}
+	}
+	require.NoError(t, cli.Close())
+
+	// a broken previous link
+	cli = createTestClient(t, "broken", mdServer.ListenAddr(), storCluster.Addrs())
+	storeTestAggregation(t, cli, testTransaction(1, 0))
+	storeTestAggregation(t, cli, testTransaction(2, 1))
+	storeTestAggregation(t, cli, testTransaction(3, 2))
+	var brokenKey []byte
+	for wr := range cli.Walk(0, tlog.TimeNowTimestamp()) {
+		require.NoError(t, wr.Err)
+		if wr.Meta.Previous == nil {
+			continue
+		}
+		// link the second aggregation to itself
+		brokenKey = wr.StorKey
+		wr.Meta.Previous = wr.StorKey
+		require.NoError(t, cli.PutMeta(wr.StorKey, wr.Meta))
+		break
+	}
+	require.NotNil(t, brokenKey)
+	info, err = describeAggregations(cli, "broken")
+	require.NoError(t, err)
+	assert.Len(t, info.Aggregations, 3)
+	assert.False(t, info.ValidChain)
+	if assert.Len(t, info.ChainErrors, 1) {
+		assert.Contains(t, info.ChainErrors[0], hex.EncodeToString(brokenKey))
+	}
+	require.NoError(t, cli.Close())
+
+	// sequences which don't increase
+	cli = createTestClient(t, "sequences", mdServer.ListenAddr(), storCluster.Addrs())
+	storeTestAggregation(t, cli, testTransaction(1, 0), testTransaction(2, 1))
+	storeTestAggregation(t, cli, testTransaction(5, 0))
+	storeTestAggregation(t, cli, testTransaction(5, 1))
+	storeTestAggregation(t, cli, testTransaction(3, 2))
+	storeTestAggregation(t, cli, testTransaction(6, 2))
+	info, err = describeAggregations(cli, "sequences")
+	require.NoError(t, err)
+	assert.Len(t, info.Aggregations, 5)
+	assert.False(t, info.ValidChain)
+	if assert.Len(t, info.ChainErrors, 2) {
+		assert.Contains(t, info.ChainErrors[0], info.Aggregations[2].Key)
+		assert.Contains(t, info.ChainErrors[1], info.Aggregations[3].Key)
+	}
+	require.NoError(t, cli.Close())
+}
+
+func TestDescribeBlockHistory(t *testing.T) {
+	const vdiskID = "history"
+
+	mdServer, err := embedserver.New()
+	require.NoError(t, err)
+	defer mdServer.Stop()
+
+	storCluster, err := embeddedserver.NewZeroStorCluster(testDataShards + testParityShards)
+	require.NoError(t, err)
+	defer storCluster.Close()
+
+	cli := createTestClient(t, vdiskID, mdServer.ListenAddr(), storCluster.Addrs())
+	defer cli.Close()
+
+	// no aggregations means no history
+	history, err := describeBlockHistory(cli, vdiskID, 1)
+	require.NoError(t, err)
+	assert.Equal(t, vdiskID, history.VdiskID)
+	assert.Equal(t, int64(1), history.Index)
+	assert.Empty(t, history.Transactions)
+
+	// overwrite and delete the same block, spread over multiple aggregations
+	set1, set2 := testTransaction(2, 1), testTransaction(5, 1)
+	del := testTransaction(4, 1)
+	del.Operation, del.Content, del.Hash = schema.OpDelete, nil, nil
+	storeTestAggregation(t, cli, testTransaction(1, 0), set1, testTransaction(3, 2))
+	storeTestAggregation(t, cli, del)
+	storeTestAggregation(t, cli, set2, testTransaction(6, 0))
+
+	history, err = describeBlockHistory(cli, vdiskID, 1)
+	require.NoError(t, err)
+	if assert.Len(t, history.Transactions, 3) {
+		expected := []tlog.Transaction{set1, del, set2}
+		for i, tx := range history.Transactions {
+			assert.Equal(t, expected[i].Sequence, tx.Sequence, "transaction %d", i)
+			assert.Equal(t, expected[i].Timestamp, tx.Timestamp, "transaction %d", i)
+			assert.Equal(t, formatTimestamp(tx.Timestamp), tx.Time, "transaction %d", i)
+			assert.Equal(t, hex.EncodeToString(expected[i].Hash), tx.Hash, "transaction %d", i)
+		}
+		assert.Equal(t, "set", history.Transactions[0].Operation)
+		assert.Equal(t, "delete", history.Transactions[1].Operation)
+		assert.Equal(t, "set", history.Transactions[2].Operation)
+	}
+
+	// a block which was never written has no history
+	history, err = describeBlockHistory(cli, vdiskID, 3)
+	require.NoError(t, err)
+	assert.Empty(t, history.Transactions)
+}
+
+func createTestClient(t *testing.T, vdiskID, mdServerAddr string, storClusterAddrs []string) *stor.Client {
+	cli, err := stor.NewClient(stor.Config{
+		VdiskID:         vdiskID,
+		Organization:    "testorg",
+		Namespace:       "thedisk",
+		ZeroStorShards:  storClusterAddrs,
+		MetaShards:      []string{mdServerAddr},
+		DataShardsNum:   testDataShards,
+		ParityShardsNum: testParityShards,
+		EncryptPrivKey:  "12345678901234567890123456789012",
+	})
+	require.NoError(t, err)
+	return cli
+}
+
+// storeTestAggregation stores the given transactions as a single aggregation
+func storeTestAggregation(t *testing.T, cli *stor.Client, txs ...tlog.Transaction) {
+	agg, err := tlog.NewAggregation(nil, len(txs))
+	require.NoError(t, err)
+	for _, tx := range txs {
+		require.NoError(t, agg.AddTransaction(tx))
+	}
+	_, err = cli.ProcessStoreAgg(agg)
+	require.NoError(t, err)
+}
+
+// testTransaction creates a set transaction with random content
+func testTransaction(seq uint64, index int64) tlog.Transaction {
+	data := make([]byte, 512)
+	rand.Read(data)
+	return tlog.Transaction{
+		Operation: schema.OpSet,
+		Sequence:  seq,
+		Index:     index,
+		Content:   data,
+		Hash:      zerodisk.HashBytes(data),
+		Timestamp: tlog.TimeNowTimestamp(),
+	}
+}
@@ -14,5 +14,7 @@ var ExportCmd = &cobra.Command{
 func init() {
 	ExportCmd.AddCommand(
 		backup.ExportVdiskCmd,
+		backup.ExportTlogCmd,
+		backup.ExportArchiveCmd,
 	)
 }
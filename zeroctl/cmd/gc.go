@@ -0,0 +1,20 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+	"github.com/zero-os/0-Disk/zeroctl/cmd/backup"
+	"github.com/zero-os/0-Disk/zeroctl/cmd/gc"
+)
+
+// GCCmd represents the gc subcommand
+var GCCmd = &cobra.Command{
+	Use:   "gc",
+	Short: "Collect the garbage of a zero-os resource",
+}
+
+func init() {
+	GCCmd.AddCommand(
+		gc.ClusterCmd,
+		backup.GCCmd,
+	)
+}
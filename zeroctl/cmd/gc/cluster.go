@@ -0,0 +1,158 @@
+package gc
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/spf13/cobra"
+	zerodiskcfg "github.com/zero-os/0-Disk/config"
+	"github.com/zero-os/0-Disk/errors"
+	"github.com/zero-os/0-Disk/log"
+	"github.com/zero-os/0-Disk/nbd/ardb"
+	"github.com/zero-os/0-Disk/nbd/ardb/storage"
+	"github.com/zero-os/0-Disk/zeroctl/cmd/config"
+)
+
+var clusterCmdCfg struct {
+	SourceConfig      zerodiskcfg.SourceConfig
+	ReferenceClusters []string
+	DryRun            bool
+	Force             bool
+}
+
+// ClusterCmd represents the gc cluster subcommand
+var ClusterCmd = &cobra.Command{
+	Use:   "cluster (clusterID|address[@db])",
+	Short: "Delete all deduped blocks of a cluster which are no longer referenced",
+	RunE:  collectClusterGarbage,
+}
+
+func collectClusterGarbage(cmd *cobra.Command, args []string) error {
+	logLevel := log.InfoLevel
+	if config.Verbose {
+		logLevel = log.DebugLevel
+	}
+	log.SetLevel(logLevel)
+
+	// get command line argument
+	argn := len(args)
+	if argn < 1 {
+		return errors.New("no cluster identifier given")
+	}
+	if argn > 1 {
+		return errors.New("too many cluster identifiers given")
+	}
+
+	// deduped blocks written by a vdisk which is being served,
+	// might not be referenced yet, and would thus be deleted,
+	// as this can't be verified, it has to be confirmed explicitly
+	if !clusterCmdCfg.DryRun && !clusterCmdCfg.Force {
+		return errors.New(
+			"all vdisks which use the cluster (directly or as a template) have to be stopped, " +
+				"use --force to confirm that none of them is being served")
+	}
+
+	// create the (uni)cluster to collect the garbage from
+	cluster, err := createCluster(args[0])
+	if err != nil {
+		return err
+	}
+
+	// create all (uni)clusters which can reference its deduped blocks
+	var referenceClusters []ardb.StorageCluster
+	for _, str := range clusterCmdCfg.ReferenceClusters {
+		referenceCluster, err := createCluster(str)
+		if err != nil {
+			return errors.Wrapf(err, "invalid reference cluster %s", str)
+		}
+		referenceClusters = append(referenceClusters, referenceCluster)
+	}
+
+	result, err := storage.CollectDedupedGarbage(
+		context.Background(), cluster, referenceClusters, clusterCmdCfg.DryRun)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("referenced deduped blocks: %d\n", result.ReferencedBlocks)
+	fmt.Printf("unreferenced deduped blocks: %d\n", result.UnreferencedBlocks)
+	if result.Deleted {
+		fmt.Printf("reclaimed bytes: %d\n", result.ReclaimableBytes)
+	} else {
+		fmt.Printf("reclaimable bytes: %d\n", result.ReclaimableBytes)
+	}
+	return nil
+}
+
+// create a cluster based on the given string,
+// which is either a serverConfigStirng or the ID of a pre-configured cluster.
+func createCluster(str string) (ardb.StorageCluster, error) {
+	serverCfg, err := zerodiskcfg.ParseStorageServerConfigString(str)
+	if err == nil {
+		return ardb.NewUniCluster(serverCfg, nil)
+	}
+	log.Debugf("failed to create serverConfig using posarg '%s': %v", str, err)
+
+	// create config source
+	source, err := zerodiskcfg.NewSource(clusterCmdCfg.SourceConfig)
+	if err != nil {
+		return nil, err
+	}
+	defer source.Close()
+
+	// read cluster config
+	clusterConfig, err := zerodiskcfg.ReadStorageClusterConfig(source, str)
+	if err != nil {
+		return nil, err
+	}
+
+	// create cluster
+	return ardb.NewCluster(*clusterConfig, nil)
+}
+
+func init() {
+	ClusterCmd.Long = ClusterCmd.Short + `
+
+Deduped blocks can be shared between vdisks, and are therefore
+not deleted when a vdisk is deleted or when its blocks are overwritten.
+This command deletes all deduped blocks stored on a cluster
+(or a single storage server), which are no longer referenced
+by any vdisk stored on that cluster or any of the reference clusters.
+Some examples:
+
+  	zeroctl gc cluster myCluster --dry-run
+  	zeroctl gc cluster localhost:2000 --force
+  	zeroctl gc cluster myTemplateCluster --reference-cluster myCluster --force
+
+Reference clusters have to be given when the vdisks of other clusters
+can reference the deduped blocks of the given cluster,
+which is for example the case for template clusters.
+
+Only keys which store content that hashes to the key itself
+are identified as deduped blocks, other keys are never deleted.
+
+NOTE: all vdisks which use the cluster (directly or as a template)
+  have to be stopped prior to using this command,
+  as their deduped blocks might not be referenced yet.
+  As this can't be verified, the --force flag is required
+  to confirm this, unless the --dry-run flag is used.
+
+WARNING: This command is very slow, and might take a while to finish!
+  It might also decrease the performance of the ardb servers
+  in question, by locking the servers down for each operation.
+`
+
+	ClusterCmd.Flags().Var(
+		&clusterCmdCfg.SourceConfig, "config",
+		"config resource: dialstrings (etcd cluster) or path (yaml file)")
+	ClusterCmd.Flags().StringSliceVar(
+		&clusterCmdCfg.ReferenceClusters, "reference-cluster", nil,
+		"cluster (clusterID|address[@db]) whose vdisks can reference the deduped blocks of the given cluster (can be given multiple times)")
+	ClusterCmd.Flags().BoolVar(
+		&clusterCmdCfg.DryRun, "dry-run", false,
+		"only report the unreferenced deduped blocks and reclaimable bytes, without deleting anything")
+	ClusterCmd.Flags().BoolVarP(
+		&clusterCmdCfg.Force,
+		"force", "f", false,
+		"confirm that none of the vdisks which use the cluster (directly or as a template) is being served")
+}
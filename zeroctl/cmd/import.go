@@ -14,5 +14,6 @@ var ImportCmd = &cobra.Command{
 func init() {
 	ImportCmd.AddCommand(
 		backup.ImportVdiskCmd,
+		backup.ImportArchiveCmd,
 	)
 }
@@ -0,0 +1,18 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+	"github.com/zero-os/0-Disk/zeroctl/cmd/backup"
+)
+
+// PruneCmd represents the prune subcommand
+var PruneCmd = &cobra.Command{
+	Use:   "prune",
+	Short: "Prune zero-os resources using a retention policy",
+}
+
+func init() {
+	PruneCmd.AddCommand(
+		backup.PruneSnapshotsCmd,
+	)
+}
@@ -0,0 +1,18 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+	"github.com/zero-os/0-Disk/zeroctl/cmd/recovery"
+)
+
+// RecoverCmd represents the recover subcommand
+var RecoverCmd = &cobra.Command{
+	Use:   "recover",
+	Short: "Recover a zero-os resource after a disaster",
+}
+
+func init() {
+	RecoverCmd.AddCommand(
+		recovery.ClusterCmd,
+	)
+}
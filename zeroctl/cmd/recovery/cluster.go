@@ -0,0 +1,143 @@
+package recovery
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/spf13/cobra"
+	"github.com/zero-os/0-Disk/config"
+	"github.com/zero-os/0-Disk/errors"
+	"github.com/zero-os/0-Disk/log"
+	"github.com/zero-os/0-Disk/nbd/ardb"
+	"github.com/zero-os/0-Disk/nbd/ardb/storage"
+	tlogrecovery "github.com/zero-os/0-Disk/tlog/recovery"
+	cmdConf "github.com/zero-os/0-Disk/zeroctl/cmd/config"
+)
+
+// clusterCmdCfg is the configuration used for the recover cluster command
+var clusterCmdCfg struct {
+	SourceConfig       config.SourceConfig
+	TlogPrivKey        string
+	PreDisasterCluster string
+	Checkpoint         string
+}
+
+// ClusterCmd represents the recover cluster subcommand
+var ClusterCmd = &cobra.Command{
+	Use:   "cluster clusterID [vdiskID...]",
+	Short: "Recover the data of the lost servers of a storage cluster, using the tlog",
+	RunE:  recoverCluster,
+}
+
+func recoverCluster(cmd *cobra.Command, args []string) error {
+	logLevel := log.InfoLevel
+	if cmdConf.Verbose {
+		logLevel = log.DebugLevel
+	}
+	log.SetLevel(logLevel)
+
+	// parse positional args
+	if len(args) < 1 {
+		return errors.New("not enough arguments")
+	}
+	clusterID, vdiskIDs := args[0], args[1:]
+
+	// read the pre-disaster cluster config
+	if clusterCmdCfg.PreDisasterCluster == "" {
+		return errors.New("no pre-disaster cluster config given (see `--pre-disaster-config`)")
+	}
+	data, err := ioutil.ReadFile(clusterCmdCfg.PreDisasterCluster)
+	if err != nil {
+		return errors.Wrap(err, "couldn't read pre-disaster cluster config")
+	}
+	preDisasterCluster, err := config.NewStorageClusterConfig(data)
+	if err != nil {
+		return errors.Wrap(err, "invalid pre-disaster cluster config")
+	}
+
+	// create config source
+	cs, err := config.NewSource(clusterCmdCfg.SourceConfig)
+	if err != nil {
+		return err
+	}
+	defer cs.Close()
+	configSource := config.NewOnceSource(cs)
+
+	// when no vdisks are specified, recover all vdisks available on the cluster
+	if len(vdiskIDs) == 0 {
+		vdiskIDs, err = listVdisks(configSource, clusterID)
+		if err != nil {
+			return err
+		}
+		if len(vdiskIDs) == 0 {
+			log.Infof("no vdisks could be found in cluster %s", clusterID)
+			return nil
+		}
+	}
+
+	checkpoint := clusterCmdCfg.Checkpoint
+	if checkpoint == "" {
+		checkpoint = fmt.Sprintf("recovery_%s.yaml", clusterID)
+	}
+	log.Infof("storing recovery progress in %s", checkpoint)
+
+	return tlogrecovery.Recover(context.Background(), configSource, tlogrecovery.Config{
+		ClusterID:          clusterID,
+		PreDisasterCluster: *preDisasterCluster,
+		VdiskIDs:           vdiskIDs,
+		PrivKey:            clusterCmdCfg.TlogPrivKey,
+		CheckpointPath:     checkpoint,
+	})
+}
+
+// listVdisks lists all vdisks available on the (remaining servers of the) given cluster.
+func listVdisks(source config.Source, clusterID string) ([]string, error) {
+	clusterConfig, err := config.ReadStorageClusterConfig(source, clusterID)
+	if err != nil {
+		return nil, err
+	}
+	cluster, err := ardb.NewCluster(*clusterConfig, nil)
+	if err != nil {
+		return nil, err
+	}
+	return storage.ListVdisks(cluster, nil)
+}
+
+func init() {
+	ClusterCmd.Long = ClusterCmd.Short + `
+
+The config of the storage cluster (as found in the config source)
+is expected to be the post-disaster config, in which all lost servers
+are marked as rip. The config of the storage cluster as it was
+prior to the disaster has to be given as a YAML file.
+
+Only the data which was stored on a lost server (according to the
+pre-disaster config) is rewritten, using the post-disaster config.
+
+When no vdisks are specified, all vdisks that can still be found
+on the storage cluster will be recovered.
+
+The progress is stored in a checkpoint file,
+such that an interrupted recovery can be resumed.
+
+NOTE: all vdisks that use the storage cluster
+  should be stopped prior to using this command.
+`
+
+	ClusterCmd.Flags().Var(
+		&clusterCmdCfg.SourceConfig, "config",
+		"config resource: dialstrings (etcd cluster) or path (yaml file)")
+	ClusterCmd.Flags().StringVar(
+		&clusterCmdCfg.TlogPrivKey,
+		"tlog-priv-key", "12345678901234567890123456789012",
+		"32 bytes tlog private key")
+	ClusterCmd.Flags().StringVar(
+		&clusterCmdCfg.PreDisasterCluster,
+		"pre-disaster-config", "",
+		"path to the (YAML) storage cluster config as it was prior to the disaster (required)")
+	ClusterCmd.Flags().StringVar(
+		&clusterCmdCfg.Checkpoint,
+		"checkpoint", "",
+		"path to the file used to store (and resume) the recovery progress (default: recovery_<clusterID>.yaml)")
+}
@@ -7,6 +7,7 @@ import (
 	"github.com/zero-os/0-Disk/config"
 	"github.com/zero-os/0-Disk/errors"
 	"github.com/zero-os/0-Disk/log"
+	"github.com/zero-os/0-Disk/nbd/ardb"
 	"github.com/zero-os/0-Disk/nbd/ardb/storage"
 	tlogdelete "github.com/zero-os/0-Disk/tlog/delete"
 	"github.com/zero-os/0-Disk/tlog/tlogclient/decoder"
@@ -18,14 +19,16 @@ import (
 var vdiskCmdCfg struct {
 	SourceConfig config.SourceConfig
 	TlogPrivKey  string
-	StartTs      int64 // start timestamp
-	EndTs        int64 // end timestamp
+	StartTs      int64  // start timestamp
+	EndTs        int64  // end timestamp
+	StartSeq     uint64 // start sequence
+	EndSeq       uint64 // end sequence
 	Force        bool
 }
 
 // VdiskCmd represents the restore vdisk subcommand
 var VdiskCmd = &cobra.Command{
-	Use:   "vdisk id",
+	Use:   "vdisk id [target_id]",
 	Short: "Restore a vdisk using a given tlogserver",
 	RunE:  restoreVdisk,
 }
@@ -44,11 +47,16 @@ func restoreVdisk(cmd *cobra.Command, args []string) error {
 	if argn < 1 {
 		return errors.New("not enough arguments")
 	}
-	if argn > 1 {
+	if argn > 2 {
 		return errors.New("too many arguments")
 	}
 
-	vdiskID := args[0]
+	// the tlog of the source vdisk is replayed into the target vdisk,
+	// which is the source vdisk itself, unless a target vdisk is specified
+	vdiskID, targetVdiskID := args[0], args[0]
+	if argn == 2 {
+		targetVdiskID = args[1]
+	}
 
 	logLevel := log.InfoLevel
 	if cmdConf.Verbose {
@@ -56,25 +64,114 @@ func restoreVdisk(cmd *cobra.Command, args []string) error {
 	}
 	log.SetLevel(logLevel)
 
-	err = checkVdiskExists(vdiskID, configSource)
+	limiter, err := createLimiter()
+	if err != nil {
+		return err
+	}
+
+	if targetVdiskID != vdiskID {
+		err = checkTargetVdiskCompatible(vdiskID, targetVdiskID, configSource)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = checkVdiskExists(targetVdiskID, configSource)
 	if err != nil {
 		return err
 	}
 
 	ctx := context.Background()
 
-	player, err := player.NewPlayer(ctx, configSource, vdiskID, vdiskCmdCfg.TlogPrivKey)
+	var tlogPlayer *player.Player
+	if targetVdiskID == vdiskID {
+		tlogPlayer, err = player.NewPlayer(ctx, configSource, vdiskID, vdiskCmdCfg.TlogPrivKey)
+	} else {
+		tlogPlayer, err = newTargetPlayer(ctx, vdiskID, targetVdiskID, configSource)
+	}
 	if err != nil {
 		return err
 	}
+	defer tlogPlayer.Close()
 
-	log.Infof("restoring vdisk with start timestamp=%v end timestamp=%v",
-		vdiskCmdCfg.StartTs, vdiskCmdCfg.EndTs)
-	lastSeq, err := player.Replay(decoder.NewLimitByTimestamp(vdiskCmdCfg.StartTs, vdiskCmdCfg.EndTs))
+	log.Infof("restoring vdisk %s into vdisk %s with start timestamp=%v end timestamp=%v start sequence=%v end sequence=%v",
+		vdiskID, targetVdiskID, vdiskCmdCfg.StartTs, vdiskCmdCfg.EndTs, vdiskCmdCfg.StartSeq, vdiskCmdCfg.EndSeq)
+	lastSeq, err := tlogPlayer.Replay(limiter)
 	log.Infof("restore finished with last sequence = %v", lastSeq)
 	return err
 }
 
+// createLimiter creates the limiter which defines the part of the tlog to replay,
+// limited either by timestamps or by sequences.
+func createLimiter() (decoder.Limiter, error) {
+	bySequence := vdiskCmdCfg.StartSeq != 0 || vdiskCmdCfg.EndSeq != 0
+	byTimestamp := vdiskCmdCfg.StartTs != 0 || vdiskCmdCfg.EndTs != 0
+	if bySequence && byTimestamp {
+		return nil, errors.New("cannot limit a restore by both timestamps and sequences")
+	}
+	if bySequence {
+		if vdiskCmdCfg.EndSeq != 0 && vdiskCmdCfg.EndSeq < vdiskCmdCfg.StartSeq {
+			return nil, errors.Newf(
+				"end sequence %d is lower than start sequence %d",
+				vdiskCmdCfg.EndSeq, vdiskCmdCfg.StartSeq)
+		}
+		return decoder.NewLimitBySequence(vdiskCmdCfg.StartSeq, vdiskCmdCfg.EndSeq), nil
+	}
+	return decoder.NewLimitByTimestamp(vdiskCmdCfg.StartTs, vdiskCmdCfg.EndTs), nil
+}
+
+// checkTargetVdiskCompatible checks if the tlog of the source vdisk
+// can be replayed into the (different) target vdisk.
+func checkTargetVdiskCompatible(vdiskID, targetVdiskID string, configSource config.Source) error {
+	staticConfig, err := config.ReadVdiskStaticConfig(configSource, vdiskID)
+	if err != nil {
+		return err
+	}
+	if !staticConfig.Type.TlogSupport() {
+		return errors.Newf("cannot restore vdisk %s as it has no tlog support", vdiskID)
+	}
+
+	targetStaticConfig, err := config.ReadVdiskStaticConfig(configSource, targetVdiskID)
+	if err != nil {
+		return err
+	}
+	if !targetStaticConfig.Type.Persistent() {
+		return errors.Wrapf(storage.ErrVdiskNotPersistent, "cannot restore into vdisk %s", targetVdiskID)
+	}
+	if targetStaticConfig.BlockSize != staticConfig.BlockSize {
+		return errors.Newf(
+			"cannot restore vdisk %s (block size %d) into vdisk %s (block size %d)",
+			vdiskID, staticConfig.BlockSize, targetVdiskID, targetStaticConfig.BlockSize)
+	}
+	if targetStaticConfig.Size < staticConfig.Size {
+		return errors.Newf(
+			"cannot restore vdisk %s (%d GiB) into smaller vdisk %s (%d GiB)",
+			vdiskID, staticConfig.Size, targetVdiskID, targetStaticConfig.Size)
+	}
+
+	return nil
+}
+
+// newTargetPlayer creates a player which replays the tlog of the source vdisk,
+// into the storage of the target vdisk, leaving the source vdisk untouched.
+func newTargetPlayer(ctx context.Context, vdiskID, targetVdiskID string, configSource config.Source) (*player.Player, error) {
+	ardbPool := ardb.NewPool(nil)
+	blockStorage, err := storage.BlockStorageFromConfig(targetVdiskID, configSource, ardbPool)
+	if err != nil {
+		ardbPool.Close()
+		return nil, err
+	}
+
+	tlogPlayer, err := player.NewPlayerWithStorage(
+		ctx, configSource, ardbPool, blockStorage, vdiskID, vdiskCmdCfg.TlogPrivKey)
+	if err != nil {
+		blockStorage.Close()
+		ardbPool.Close()
+		return nil, err
+	}
+	return tlogPlayer, nil
+}
+
 // checkVdiskExists checks if the vdisk in question already/still exists,
 // and if so, and the force flag is specified, delete the vdisk.
 func checkVdiskExists(vdiskID string, configSource config.Source) error {
@@ -111,6 +208,19 @@ func checkVdiskExists(vdiskID string, configSource config.Source) error {
 }
 
 func init() {
+	VdiskCmd.Long = VdiskCmd.Short + `
+
+Replays the tlog of a vdisk, optionally limited by timestamps or sequences.
+When a target vdisk is given, the tlog is replayed into that vdisk instead,
+leaving the source vdisk (and its tlog) untouched. The target vdisk
+needs to be persistent, have the same block size and be at least as big
+as the source vdisk. Some examples:
+
+  	zeroctl restore vdisk a
+  	zeroctl restore vdisk a --end-timestamp 1500000000000000000
+  	zeroctl restore vdisk a b --end-sequence 1000 --force
+`
+
 	VdiskCmd.Flags().Var(
 		&vdiskCmdCfg.SourceConfig, "config",
 		"config resource: dialstrings (etcd cluster) or path (yaml file)")
@@ -126,8 +236,16 @@ func init() {
 		&vdiskCmdCfg.EndTs,
 		"end-timestamp", 0,
 		"end UTC timestamp in nanosecond(default 0: until the end)")
+	VdiskCmd.Flags().Uint64Var(
+		&vdiskCmdCfg.StartSeq,
+		"start-sequence", 0,
+		"start sequence (default 0: since beginning)")
+	VdiskCmd.Flags().Uint64Var(
+		&vdiskCmdCfg.EndSeq,
+		"end-sequence", 0,
+		"end sequence (default 0: until the end)")
 	VdiskCmd.Flags().BoolVarP(
 		&vdiskCmdCfg.Force,
 		"force", "f", false,
-		"when given, delete the vdisk if it already existed")
+		"when given, delete the (target) vdisk if it already existed")
 }
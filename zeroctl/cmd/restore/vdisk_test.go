@@ -0,0 +1,302 @@
+package restore
+
+import (
+	"context"
+	"crypto/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zero-os/0-Disk"
+	"github.com/zero-os/0-Disk/config"
+	"github.com/zero-os/0-Disk/errors"
+	"github.com/zero-os/0-Disk/nbd/ardb"
+	"github.com/zero-os/0-Disk/nbd/ardb/storage"
+	"github.com/zero-os/0-Disk/redisstub"
+	"github.com/zero-os/0-Disk/tlog"
+	"github.com/zero-os/0-Disk/tlog/flusher"
+	"github.com/zero-os/0-Disk/tlog/schema"
+	"github.com/zero-os/0-Disk/tlog/stor/embeddedserver"
+	"github.com/zero-os/0-Disk/tlog/tlogclient/decoder"
+	"github.com/zero-os/0-stor/client/meta/embedserver"
+)
+
+func TestCreateLimiter(t *testing.T) {
+	defer setVdiskCmdLimits(vdiskCmdCfg.StartTs, vdiskCmdCfg.EndTs, vdiskCmdCfg.StartSeq, vdiskCmdCfg.EndSeq)
+
+	testCases := []struct {
+		name               string
+		startTs, endTs     int64
+		startSeq, endSeq   uint64
+		bySequence, failed bool
+	}{
+		{name: "no limits"},
+		{name: "start timestamp", startTs: 42},
+		{name: "timestamps", startTs: 42, endTs: 1500000000000000000},
+		{name: "start sequence", startSeq: 5, bySequence: true},
+		{name: "end sequence", endSeq: 5, bySequence: true},
+		{name: "sequences", startSeq: 5, endSeq: 10, bySequence: true},
+		{name: "single sequence", startSeq: 5, endSeq: 5, bySequence: true},
+		{name: "end sequence lower than start sequence", startSeq: 10, endSeq: 5, failed: true},
+		{name: "start timestamp and start sequence", startTs: 42, startSeq: 5, failed: true},
+		{name: "end timestamp and end sequence", endTs: 42, endSeq: 5, failed: true},
+		{name: "timestamps and sequences", startTs: 42, endTs: 43, startSeq: 5, endSeq: 10, failed: true},
+	}
+	for _, testCase := range testCases {
+		setVdiskCmdLimits(testCase.startTs, testCase.endTs, testCase.startSeq, testCase.endSeq)
+
+		limiter, err := createLimiter()
+		if testCase.failed {
+			assert.Error(t, err, testCase.name)
+			continue
+		}
+		if !assert.NoError(t, err, testCase.name) {
+			continue
+		}
+		if testCase.bySequence {
+			assert.IsType(t, decoder.LimitBySequence{}, limiter, testCase.name)
+			continue
+		}
+		if assert.IsType(t, decoder.LimitByTimestamp{}, limiter, testCase.name) {
+			assert.Equal(t, testCase.startTs, limiter.FromEpoch(), testCase.name)
+			if testCase.endTs != 0 {
+				assert.Equal(t, testCase.endTs, limiter.ToEpoch(), testCase.name)
+			}
+		}
+	}
+}
+
+// setVdiskCmdLimits sets the limits of the restore vdisk command
+func setVdiskCmdLimits(startTs, endTs int64, startSeq, endSeq uint64) {
+	vdiskCmdCfg.StartTs, vdiskCmdCfg.EndTs = startTs, endTs
+	vdiskCmdCfg.StartSeq, vdiskCmdCfg.EndSeq = startSeq, endSeq
+}
+
+func TestCheckTargetVdiskCompatible(t *testing.T) {
+	const (
+		vdiskID   = "a"
+		blockSize = 4096
+		size      = 2
+	)
+
+	testCases := []struct {
+		name          string
+		source        config.VdiskStaticConfig
+		target        config.VdiskStaticConfig
+		failed        bool
+		notPersistent bool
+	}{
+		{
+			name:   "same config",
+			source: config.VdiskStaticConfig{BlockSize: blockSize, Size: size, Type: config.VdiskTypeDB},
+			target: config.VdiskStaticConfig{BlockSize: blockSize, Size: size, Type: config.VdiskTypeDB},
+		},
+		{
+			name:   "bigger persistent vdisk of another type",
+			source: config.VdiskStaticConfig{BlockSize: blockSize, Size: size, Type: config.VdiskTypeBoot},
+			target: config.VdiskStaticConfig{BlockSize: blockSize, Size: size + 1, Type: config.VdiskTypeCache},
+		},
+		{
+			name:   "source without tlog support",
+			source: config.VdiskStaticConfig{BlockSize: blockSize, Size: size, Type: config.VdiskTypeCache},
+			target: config.VdiskStaticConfig{BlockSize: blockSize, Size: size, Type: config.VdiskTypeDB},
+			failed: true,
+		},
+		{
+			name:   "other block size",
+			source: config.VdiskStaticConfig{BlockSize: blockSize, Size: size, Type: config.VdiskTypeDB},
+			target: config.VdiskStaticConfig{BlockSize: blockSize * 2, Size: size, Type: config.VdiskTypeDB},
+			failed: true,
+		},
+		{
+			name:   "smaller vdisk",
+			source: config.VdiskStaticConfig{BlockSize: blockSize, Size: size, Type: config.VdiskTypeDB},
+			target: config.VdiskStaticConfig{BlockSize: blockSize, Size: size - 1, Type: config.VdiskTypeDB},
+			failed: true,
+		},
+		{
+			name:          "non-persistent vdisk",
+			source:        config.VdiskStaticConfig{BlockSize: blockSize, Size: size, Type: config.VdiskTypeDB},
+			target:        config.VdiskStaticConfig{BlockSize: blockSize, Size: size, Type: config.VdiskTypeTmp},
+			failed:        true,
+			notPersistent: true,
+		},
+	}
+	for _, testCase := range testCases {
+		source := config.NewStubSource()
+		source.SetVdiskConfig(vdiskID, &testCase.source)
+		source.SetVdiskConfig("b", &testCase.target)
+
+		err := checkTargetVdiskCompatible(vdiskID, "b", source)
+		source.Close()
+		if !testCase.failed {
+			assert.NoError(t, err, testCase.name)
+			continue
+		}
+		if assert.Error(t, err, testCase.name) && testCase.notPersistent {
+			assert.Equal(t, storage.ErrVdiskNotPersistent, errors.Cause(err), testCase.name)
+		}
+	}
+
+	// the target vdisk has to exist
+	source := config.NewStubSource()
+	defer source.Close()
+	source.SetVdiskConfig(vdiskID, &config.VdiskStaticConfig{
+		BlockSize: blockSize, Size: size, Type: config.VdiskTypeDB})
+	assert.Error(t, checkTargetVdiskCompatible(vdiskID, "b", source))
+}
+
+func TestNewTargetPlayer(t *testing.T) {
+	const (
+		vdiskID       = "a"
+		targetVdiskID = "b"
+		dataShards    = 4
+		parityShards  = 2
+		blockSize     = 4096
+		numLogs       = 50
+		privKey       = "12345678901234567890123456789012"
+	)
+
+	defer func(key string) { vdiskCmdCfg.TlogPrivKey = key }(vdiskCmdCfg.TlogPrivKey)
+	vdiskCmdCfg.TlogPrivKey = privKey
+
+	storCluster, err := embeddedserver.NewZeroStorCluster(dataShards + parityShards)
+	require.NoError(t, err)
+	defer storCluster.Close()
+
+	mdServer, err := embedserver.New()
+	require.NoError(t, err)
+	defer mdServer.Stop()
+
+	sourceServer := redisstub.NewMemoryRedis()
+	defer sourceServer.Close()
+	targetServer := redisstub.NewMemoryRedis()
+	defer targetServer.Close()
+
+	confSource := config.NewStubSource()
+	defer confSource.Close()
+
+	var serverConf []config.ServerConfig
+	for _, addr := range storCluster.Addrs() {
+		serverConf = append(serverConf, config.ServerConfig{
+			Address: addr,
+		})
+	}
+
+	for _, id := range []string{vdiskID, targetVdiskID} {
+		confSource.SetVdiskConfig(id, &config.VdiskStaticConfig{
+			BlockSize: blockSize,
+			Size:      1,
+			Type:      config.VdiskTypeDB,
+		})
+	}
+	confSource.SetPrimaryStorageCluster(vdiskID, "sourcecluster", &config.StorageClusterConfig{
+		Servers: []config.StorageServerConfig{sourceServer.StorageServerConfig()},
+	})
+	confSource.SetPrimaryStorageCluster(targetVdiskID, "targetcluster", &config.StorageClusterConfig{
+		Servers: []config.StorageServerConfig{targetServer.StorageServerConfig()},
+	})
+	confSource.SetTlogServerCluster(vdiskID, "tlogcluster", &config.TlogClusterConfig{
+		Servers: []string{"localhost:1"},
+	})
+	confSource.SetTlogZeroStorCluster(vdiskID, "zerostorcluster", &config.ZeroStorClusterConfig{
+		IYO: config.IYOCredentials{
+			Org:       "testorg",
+			Namespace: "thedisk",
+		},
+		DataServers: serverConf,
+		MetadataServers: []config.ServerConfig{
+			config.ServerConfig{
+				Address: mdServer.ListenAddr(),
+			},
+		},
+		DataShards:   dataShards,
+		ParityShards: parityShards,
+	})
+
+	// the source vdisk stores a single block,
+	// which doesn't match the content of its tlog
+	sourceContent := make([]byte, blockSize)
+	rand.Read(sourceContent)
+	pool := ardb.NewPool(nil)
+	defer pool.Close()
+	sourceStorage, err := storage.BlockStorageFromConfig(vdiskID, confSource, pool)
+	require.NoError(t, err)
+	require.NoError(t, sourceStorage.SetBlock(0, sourceContent))
+	require.NoError(t, sourceStorage.Flush())
+	require.NoError(t, sourceStorage.Close())
+
+	// generate the tlog of the source vdisk,
+	// overwriting and deleting some blocks
+	flusher, err := flusher.New(confSource, 8, vdiskID, privKey)
+	require.NoError(t, err)
+
+	expected := make(map[int64][]byte)
+	for i := 1; i <= numLogs; i++ {
+		idx := int64(i % 20)
+		op := uint8(schema.OpSet)
+		var data []byte
+		if i%7 == 0 {
+			op = schema.OpDelete
+		} else {
+			data = make([]byte, blockSize)
+			rand.Read(data)
+		}
+
+		err = flusher.AddTransaction(tlog.Transaction{
+			Operation: op,
+			Sequence:  uint64(i),
+			Content:   data,
+			Index:     idx,
+			Timestamp: tlog.TimeNowTimestamp(),
+			Hash:      zerodisk.Hash(data),
+		})
+		require.NoError(t, err)
+
+		if flusher.Full() {
+			_, _, err = flusher.Flush()
+			require.NoError(t, err)
+		}
+
+		if op == schema.OpDelete {
+			delete(expected, idx)
+		} else {
+			expected[idx] = data
+		}
+	}
+	_, _, err = flusher.Flush()
+	require.NoError(t, err)
+
+	// replay the tlog of the source vdisk into the target vdisk
+	tlogPlayer, err := newTargetPlayer(context.Background(), vdiskID, targetVdiskID, confSource)
+	require.NoError(t, err)
+	lastSeq, err := tlogPlayer.Replay(decoder.NewLimitByTimestamp(0, 0))
+	require.NoError(t, err)
+	require.Equal(t, uint64(numLogs), lastSeq)
+	require.NoError(t, tlogPlayer.Close())
+
+	// the target vdisk contains the replayed blocks
+	targetStorage, err := storage.BlockStorageFromConfig(targetVdiskID, confSource, pool)
+	require.NoError(t, err)
+	defer targetStorage.Close()
+	for index := int64(0); index < 20; index++ {
+		content, err := targetStorage.GetBlock(index)
+		require.NoError(t, err)
+		if data, ok := expected[index]; ok {
+			assert.Equal(t, data, content, "block %d", index)
+		} else {
+			assert.Empty(t, content, "block %d", index)
+		}
+	}
+
+	// while the source vdisk is left untouched
+	indices, err := storage.ListBlockIndices(vdiskID, confSource)
+	require.NoError(t, err)
+	assert.Equal(t, []int64{0}, indices)
+	sourceStorage, err = storage.BlockStorageFromConfig(vdiskID, confSource, pool)
+	require.NoError(t, err)
+	defer sourceStorage.Close()
+	content, err := sourceStorage.GetBlock(0)
+	require.NoError(t, err)
+	assert.Equal(t, sourceContent, content)
+}
@@ -31,6 +31,11 @@ func init() {
 		CopyCmd,
 		DeleteCmd,
 		RestoreCmd,
+		RecoverCmd,
+		GCCmd,
+		PruneCmd,
+		CompactCmd,
+		VerifyCmd,
 		ExportCmd,
 		ImportCmd,
 		ListCmd,
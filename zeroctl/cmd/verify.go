@@ -0,0 +1,20 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+	"github.com/zero-os/0-Disk/zeroctl/cmd/backup"
+	"github.com/zero-os/0-Disk/zeroctl/cmd/verify"
+)
+
+// VerifyCmd represents the verify subcommand
+var VerifyCmd = &cobra.Command{
+	Use:   "verify",
+	Short: "Verify the integrity of a zero-os resource",
+}
+
+func init() {
+	VerifyCmd.AddCommand(
+		backup.VerifySnapshotCmd,
+		verify.TlogCmd,
+	)
+}
@@ -0,0 +1,107 @@
+package verify
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/spf13/cobra"
+	zerodiskcfg "github.com/zero-os/0-Disk/config"
+	"github.com/zero-os/0-Disk/errors"
+	"github.com/zero-os/0-Disk/log"
+	"github.com/zero-os/0-Disk/tlog/verify"
+	"github.com/zero-os/0-Disk/zeroctl/cmd/config"
+)
+
+var tlogCmdCfg struct {
+	SourceConfig zerodiskcfg.SourceConfig
+	TlogPrivKey  string
+}
+
+// TlogCmd represents the verify tlog subcommand
+var TlogCmd = &cobra.Command{
+	Use:   "tlog vdiskID",
+	Short: "Verify the tlog of a vdisk against the vdisk's storage",
+	RunE:  verifyTlog,
+}
+
+func verifyTlog(cmd *cobra.Command, args []string) error {
+	logLevel := log.InfoLevel
+	if config.Verbose {
+		logLevel = log.DebugLevel
+	}
+	log.SetLevel(logLevel)
+
+	// get command line argument
+	argn := len(args)
+	if argn < 1 {
+		return errors.New("no vdisk identifier given")
+	}
+	if argn > 1 {
+		return errors.New("too many vdisk identifiers given")
+	}
+	vdiskID := args[0]
+
+	// create config source
+	source, err := zerodiskcfg.NewSource(tlogCmdCfg.SourceConfig)
+	if err != nil {
+		return err
+	}
+	defer source.Close()
+
+	result, err := verify.Verify(context.Background(), source, verify.Config{
+		VdiskID: vdiskID,
+		PrivKey: tlogCmdCfg.TlogPrivKey,
+	})
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("aggregations: %d\n", result.Aggregations)
+	fmt.Printf("blocks: %d\n", result.Blocks)
+	fmt.Printf("last flushed sequence: %d\n", result.LastFlushedSequence)
+	fmt.Printf("compared block indices: %d\n", result.ComparedIndices)
+	fmt.Printf("skipped block indices: %d\n", result.SkippedIndices)
+	for _, chainErr := range result.ChainErrors {
+		fmt.Printf("chain error: %s\n", chainErr)
+	}
+	for _, hashErr := range result.HashErrors {
+		fmt.Printf("hash error: %s\n", hashErr)
+	}
+	for _, index := range result.DivergingIndices {
+		fmt.Printf("diverging block index: %d\n", index)
+	}
+
+	if !result.Valid() {
+		return errors.Newf("tlog of vdisk %s is invalid", vdiskID)
+	}
+	return nil
+}
+
+func init() {
+	TlogCmd.Long = TlogCmd.Short + `
+
+Walks the tlog of a vdisk, validating that each aggregation links
+to the aggregation before it, and that the hash of each block matches its data.
+While walking, the tlog is replayed into an in-memory shadow,
+which is compared block by block against the current content of
+the vdisk's (primary) storage, up to the last sequence flushed to that storage.
+Block indices which were modified after that sequence are skipped,
+as well as block indices which aren't part of the tlog (anymore).
+
+All errors and diverging block indices are printed,
+in which case the command exits with a non-zero exit code.
+A valid tlog means that the vdisk can be restored using its tlog.
+
+NOTE: blocks written while the vdisk is being verified,
+  can be reported as diverging, it is therefore recommended
+  to verify a vdisk while it is not in use.
+`
+
+	TlogCmd.Flags().Var(
+		&tlogCmdCfg.SourceConfig, "config",
+		"config resource: dialstrings (etcd cluster) or path (yaml file)")
+	TlogCmd.Flags().StringVar(
+		&tlogCmdCfg.TlogPrivKey,
+		"tlog-priv-key", "12345678901234567890123456789012",
+		"32 bytes tlog private key")
+}